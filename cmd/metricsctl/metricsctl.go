@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/k0st1a/metrics/internal/metricsctl"
+)
+
+func main() {
+	ctx, cancelFunc := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
+	defer cancelFunc()
+
+	err := metricsctl.Run(ctx, os.Args[1:], os.Stdin, os.Stdout)
+	if errors.Is(err, metricsctl.ErrUsage) {
+		fmt.Fprintln(os.Stderr, metricsctl.Usage)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		cancelFunc()
+		os.Exit(1) //nolint:gocritic // cancelFunc уже вызван
+	}
+}
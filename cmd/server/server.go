@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/k0st1a/metrics/internal/server"
 	"github.com/rs/zerolog/log"
@@ -19,7 +20,16 @@ func main() {
 		"Build commit: %s\n",
 		buildVersion, buildDate, buildCommit)
 
-	err := server.Run()
+	var err error
+
+	switch {
+	case len(os.Args) > 1 && os.Args[1] == "migrate":
+		err = server.RunMigrate(os.Args[2:], os.Stdout)
+	case len(os.Args) > 1 && os.Args[1] == "migrate-storage":
+		err = server.RunMigrateStorage(os.Args[2:], os.Stdout)
+	default:
+		err = server.Run()
+	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("")
 	}
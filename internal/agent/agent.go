@@ -14,9 +14,13 @@ import (
 	"github.com/k0st1a/metrics/internal/agent/reporter"
 	"github.com/k0st1a/metrics/internal/metrics/gopsutil"
 	"github.com/k0st1a/metrics/internal/metrics/runtime"
+	"github.com/k0st1a/metrics/internal/middleware/checkresponse"
+	"github.com/k0st1a/metrics/internal/middleware/compressrequest"
+	"github.com/k0st1a/metrics/internal/middleware/decryptresponse"
 	"github.com/k0st1a/metrics/internal/middleware/encrypt"
 	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
 	"github.com/k0st1a/metrics/internal/middleware/sign"
+	"github.com/k0st1a/metrics/internal/pkg/codec"
 	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
 	"github.com/k0st1a/metrics/internal/pkg/hash"
 	"github.com/rs/zerolog/log"
@@ -38,22 +42,69 @@ func Run() error {
 	gm := gopsutil.NewMetric()
 	p, pc := poller.NewPoller(cfg.PollInterval, rm, gm)
 
+	passphrase, err := cfg.KeyPassphrase()
+	if err != nil {
+		return err
+	}
+
 	var middlewares []roundtrip.Middleware
+	var signer sign.Signer
 
 	if cfg.HashKey != "" {
-		h := hash.New(cfg.HashKey)
-		middlewares = append(middlewares, sign.New(h))
+		h := hash.NewWithID(cfg.HashKeyID, cfg.HashKey)
+		middlewares = append(middlewares, checkresponse.New(h))
+		signer = h
 	}
 
-	if cfg.CryptoKey != "" {
-		pbl, err := rsa.NewPublicFromFile(cfg.CryptoKey)
+	// Закрытым ключом агента нельзя подделать данные других агентов, в отличие от общего ключа HashKey.
+	if cfg.SignKey != "" {
+		ed, err := hash.NewEd25519FromFile(cfg.HashKeyID, cfg.SignKey, passphrase)
 		if err != nil {
-			return fmt.Errorf("rsa new public from file error:%w", err)
+			return fmt.Errorf("sign key error:%w", err)
 		}
 
+		signer = ed
+	}
+
+	if signer != nil {
+		middlewares = append(middlewares, sign.New(signer))
+	}
+
+	// Ключ сервера получается с проверкой подписи ответа, но без шифрования.
+	pbl, err := newServerKey(ctx, cfg, roundtrip.New(http.DefaultTransport, middlewares...))
+	if err != nil {
+		return err
+	}
+
+	if cfg.ResponseKey != "" {
+		prv, err := rsa.NewPrivateFromFileWithPassphrase(cfg.ResponseKey, passphrase)
+		if err != nil {
+			return fmt.Errorf("rsa new private from file error:%w", err)
+		}
+
+		der, err := prv.Public().DER()
+		if err != nil {
+			return fmt.Errorf("response public key error:%w", err)
+		}
+
+		// Ответ расшифровывается до проверки его подписи.
+		middlewares = append([]roundtrip.Middleware{decryptresponse.New(prv, der, signer)}, middlewares...)
+	}
+
+	if pbl != nil {
 		middlewares = append(middlewares, encrypt.New(pbl))
 	}
 
+	// Данные сжимаются до подписи и шифрования, так как после шифрования они почти не сжимаются.
+	if cfg.Compress != codec.None {
+		c, err := compressrequest.New(cfg.Compress)
+		if err != nil {
+			return fmt.Errorf("compress request error:%w", err)
+		}
+
+		middlewares = append(middlewares, c)
+	}
+
 	rt := roundtrip.New(http.DefaultTransport, middlewares...)
 
 	r, rc := reporter.NewReporter(cfg.ServerAddr, cfg.ReportInterval, cfg.RateLimit, rt)
@@ -9,6 +9,8 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+	"github.com/k0st1a/metrics/internal/pkg/crypto/pemkey"
 	"github.com/k0st1a/metrics/internal/pkg/netaddr"
 )
 
@@ -17,7 +19,14 @@ const (
 	defaultReportInterval = 10
 	defaultServerAddr     = "localhost:8080"
 	defaultHashKey        = ""
+	defaultHashKeyID      = ""
 	defaultCryptoKey      = ""
+	defaultKeyFingerprint = ""
+	defaultKeyTOFU        = false
+	defaultResponseKey    = ""
+	defaultSignKey        = ""
+	defaultKeyPassphrase  = ""
+	defaultCompress       = codec.Gzip
 	defaultRateLimit      = 1
 	defaultConfig         = ""
 )
@@ -30,10 +39,43 @@ type Config struct {
 	// HashKey - ключ для подписи передаваемых данных по алгоритму SHA256 (по умолчанию пустая строка).
 	// Задается через флаг `-k=<ЗНАЧЕНИЕ>` или переменную окружения `KEY=<ЗНАЧЕНИЕ>`
 	HashKey string
+	// HashKeyID - идентификатор ключа HashKey, передается в HTTP-заголовке `HashKeyID`, чтобы сервер проверил
+	// подпись этим ключом во время смены ключей (по умолчанию пустая строка, заголовок не передается).
+	// Задается через флаг `-key-id=<ЗНАЧЕНИЕ>` или переменную окружения `KEY_ID=<ЗНАЧЕНИЕ>`
+	HashKeyID string
+	// SignKey - путь до файла с закрытым ключом Ed25519 агента в формате PEM (по умолчанию пустая строка).
+	// Если путь задан, то передаваемые данные подписываются этим ключом вместо HashKey, а сервер проверяет
+	// подпись открытым ключом агента с идентификатором HashKeyID. HashKey при этом проверяет подпись ответов.
+	// Задается через флаг `-sign-key=<ЗНАЧЕНИЕ>` или переменную окружения `SIGN_KEY=<ЗНАЧЕНИЕ>`
+	SignKey string
 	// CryptoKey - путь до файла с открытым ключом (по умолчанию пустая строка). Если путь задан, то
 	// с помощью открытого ключа будут шифровываться сообщения, отправляемые агентом.
 	// Задается через флаг `-crypto-key=<ЗНАЧЕНИЕ>` или переменную окружения `CRYPTO_KEY=<ЗНАЧЕНИЕ>`
 	CryptoKey string
+	// CryptoKeyFingerprint - отпечаток открытого ключа сервера: SHA-256 от ключа в формате PKIX DER
+	// в шестнадцатеричном виде (по умолчанию пустая строка). Если отпечаток задан, то агент сверяет с ним ключ
+	// из файла CryptoKey, а без файла получает ключ с сервера по `/api/v1/public-key`.
+	// Задается через флаг `-crypto-key-fingerprint=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `CRYPTO_KEY_FINGERPRINT=<ЗНАЧЕНИЕ>`
+	CryptoKeyFingerprint string
+	// CryptoKeyTOFU - булево значение (`true/false`), определяющее, получать ли открытый ключ сервера
+	// по `/api/v1/public-key` при первом запуске, если файла CryptoKey нет (по умолчанию `false`). Полученный
+	// ключ сохраняется в файл CryptoKey, и дальше агент доверяет только ему.
+	// Задается через флаг `-crypto-key-tofu=<ЗНАЧЕНИЕ>` или переменную окружения `CRYPTO_KEY_TOFU=<ЗНАЧЕНИЕ>`
+	CryptoKeyTOFU bool
+	// ResponseKey - путь до файла с закрытым ключом агента (по умолчанию пустая строка). Если путь задан, то
+	// агент передает серверу открытый ключ, сервер шифрует им ответы, а ответы без шифрования отклоняются.
+	// Задается через флаг `-response-key=<ЗНАЧЕНИЕ>` или переменную окружения `RESPONSE_KEY=<ЗНАЧЕНИЕ>`
+	ResponseKey string
+	// KeyPassphraseFile - путь до файла с парольной фразой зашифрованных ключей SignKey и ResponseKey
+	// (по умолчанию пустая строка). Без файла парольная фраза берется из переменной окружения `KEY_PASSPHRASE`.
+	// Задается через флаг `-key-passphrase-file=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `KEY_PASSPHRASE_FILE=<ЗНАЧЕНИЕ>`
+	KeyPassphraseFile string
+	// Compress - кодек сжатия отправляемых данных: `gzip`, `zstd`, `br` или `none` без сжатия
+	// (по умолчанию `gzip`). Данные сжимаются до подписи и шифрования.
+	// Задается через флаг `-compress=<ЗНАЧЕНИЕ>` или переменную окружения `COMPRESS=<ЗНАЧЕНИЕ>`
+	Compress string
 	// Config - путь до файла конфигурации сервера (по умолчанию пустая строка).
 	// Задается через флаг `-c=<ЗНАЧЕНИЕ>` или переменную окружения `CONFIG=<ЗНАЧЕНИЕ>`
 	Config string
@@ -81,12 +123,19 @@ func NewConfig() (*Config, error) {
 
 func newDefaultConfig() *Config {
 	return &Config{
-		ServerAddr:     defaultServerAddr,
-		HashKey:        defaultHashKey,
-		CryptoKey:      defaultCryptoKey,
-		PollInterval:   defaultPollInterval,
-		ReportInterval: defaultReportInterval,
-		RateLimit:      defaultRateLimit,
+		ServerAddr:           defaultServerAddr,
+		HashKey:              defaultHashKey,
+		HashKeyID:            defaultHashKeyID,
+		CryptoKey:            defaultCryptoKey,
+		CryptoKeyFingerprint: defaultKeyFingerprint,
+		CryptoKeyTOFU:        defaultKeyTOFU,
+		ResponseKey:          defaultResponseKey,
+		SignKey:              defaultSignKey,
+		KeyPassphraseFile:    defaultKeyPassphrase,
+		Compress:             defaultCompress,
+		PollInterval:         defaultPollInterval,
+		ReportInterval:       defaultReportInterval,
+		RateLimit:            defaultRateLimit,
 	}
 }
 
@@ -104,9 +153,30 @@ func (c *Config) applyFromArgsAndEnv() error {
 	flag.StringVar(&c.HashKey, "k", c.HashKey,
 		"Hash key with which the request body will be encoded "+
 			"HTTP Header HashSHA256 will be added to the HTTP request")
+	flag.StringVar(&c.HashKeyID, "key-id", c.HashKeyID,
+		"Идентификатор ключа подписи, передается в HTTP-заголовке HashKeyID.\n"+
+			"Соответствует переменной окружения KEY_ID")
+	flag.StringVar(&c.SignKey, "sign-key", c.SignKey,
+		"Путь до файла с закрытым ключом Ed25519 агента для подписи данных вместо ключа -k.\n"+
+			"Соответствует переменной окружения SIGN_KEY")
 	flag.StringVar(&(c.CryptoKey), "crypto-key", c.CryptoKey,
 		"Путь до файла с открытым ключом (по умолчанию пустая строка). Если путь задан, то "+
 			"с помощью открытого ключа будут шифровываться сообщения, отправляемые агентом.")
+	flag.StringVar(&c.CryptoKeyFingerprint, "crypto-key-fingerprint", c.CryptoKeyFingerprint,
+		"Отпечаток SHA-256 открытого ключа сервера, с которым сверяется ключ.\n"+
+			"Соответствует переменной окружения CRYPTO_KEY_FINGERPRINT")
+	flag.BoolVar(&c.CryptoKeyTOFU, "crypto-key-tofu", c.CryptoKeyTOFU,
+		"Получить открытый ключ сервера при первом запуске и сохранить его в файл crypto-key.\n"+
+			"Соответствует переменной окружения CRYPTO_KEY_TOFU")
+	flag.StringVar(&c.ResponseKey, "response-key", c.ResponseKey,
+		"Путь до файла с закрытым ключом агента для шифрования ответов сервера.\n"+
+			"Соответствует переменной окружения RESPONSE_KEY")
+	flag.StringVar(&c.KeyPassphraseFile, "key-passphrase-file", c.KeyPassphraseFile,
+		"Путь до файла с парольной фразой зашифрованных закрытых ключей агента.\n"+
+			"Соответствует переменной окружения KEY_PASSPHRASE_FILE")
+	flag.StringVar(&c.Compress, "compress", c.Compress,
+		"Кодек сжатия отправляемых данных: gzip, zstd, br или none.\n"+
+			"Соответствует переменной окружения COMPRESS")
 	flag.IntVar(&(c.RateLimit), "l", c.RateLimit, "number of simultaneously outgoing requests to the server")
 
 	flag.Parse()
@@ -127,11 +197,51 @@ func (c *Config) applyFromArgsAndEnv() error {
 		c.HashKey = k
 	}
 
+	kid, ok := os.LookupEnv("KEY_ID")
+	if ok {
+		c.HashKeyID = kid
+	}
+
+	sk, ok := os.LookupEnv("SIGN_KEY")
+	if ok {
+		c.SignKey = sk
+	}
+
 	ck, ok := os.LookupEnv("CRYPTO_KEY")
 	if ok {
 		c.CryptoKey = ck
 	}
 
+	kf, ok := os.LookupEnv("CRYPTO_KEY_FINGERPRINT")
+	if ok {
+		c.CryptoKeyFingerprint = kf
+	}
+
+	kt, ok := os.LookupEnv("CRYPTO_KEY_TOFU")
+	if ok {
+		ktBool, err := strconv.ParseBool(kt)
+		if err != nil {
+			return fmt.Errorf("CRYPTO_KEY_TOFU parse error:%w", err)
+		}
+
+		c.CryptoKeyTOFU = ktBool
+	}
+
+	rk, ok := os.LookupEnv("RESPONSE_KEY")
+	if ok {
+		c.ResponseKey = rk
+	}
+
+	kpf, ok := os.LookupEnv("KEY_PASSPHRASE_FILE")
+	if ok {
+		c.KeyPassphraseFile = kpf
+	}
+
+	cm, ok := os.LookupEnv("COMPRESS")
+	if ok {
+		c.Compress = cm
+	}
+
 	pi, ok := os.LookupEnv("POLL_INTERVAL")
 	if ok {
 		piInt, err := strconv.Atoi(pi)
@@ -162,17 +272,39 @@ func (c *Config) applyFromArgsAndEnv() error {
 		c.RateLimit = rlInt
 	}
 
+	err = codec.Valid(c.Compress)
+	if err != nil {
+		return fmt.Errorf("compress error:%w", err)
+	}
+
 	return nil
 }
 
+// KeyPassphrase - парольная фраза зашифрованных ключей агента из файла KeyPassphraseFile или
+// из переменной окружения `KEY_PASSPHRASE`.
+func (c *Config) KeyPassphrase() ([]byte, error) {
+	p, err := pemkey.Passphrase(c.KeyPassphraseFile, "KEY_PASSPHRASE")
+	if err != nil {
+		return nil, fmt.Errorf("key passphrase error:%w", err)
+	}
+
+	return p, nil
+}
+
 // JSONConfig - промежуточная структура с конфигурационными параметрами сервера.
 // Использользуется для Unmarshal-инга файла в формате JSON в данную структуру.
 // Далее данные данной структуры будут использованы для формирования структуры Config.
 type JSONConfig struct {
-	Address        string `json:"address"`
-	ReportInterval string `json:"report_interval"`
-	PollInterval   string `json:"poll_interval"`
-	CryptoKey      string `json:"crypto_key"`
+	Address              string `json:"address"`
+	ReportInterval       string `json:"report_interval"`
+	PollInterval         string `json:"poll_interval"`
+	CryptoKey            string `json:"crypto_key"`
+	CryptoKeyFingerprint string `json:"crypto_key_fingerprint"`
+	CryptoKeyTOFU        bool   `json:"crypto_key_tofu"`
+	ResponseKey          string `json:"response_key"`
+	SignKey              string `json:"sign_key"`
+	KeyPassphraseFile    string `json:"key_passphrase_file"`
+	Compress             string `json:"compress"`
 }
 
 func (c *Config) applyFromFile(path string) error {
@@ -213,5 +345,29 @@ func (c *Config) applyFromFile(path string) error {
 		c.CryptoKey = cfg.CryptoKey
 	}
 
+	if cfg.CryptoKeyFingerprint != "" {
+		c.CryptoKeyFingerprint = cfg.CryptoKeyFingerprint
+	}
+
+	if cfg.CryptoKeyTOFU {
+		c.CryptoKeyTOFU = true
+	}
+
+	if cfg.ResponseKey != "" {
+		c.ResponseKey = cfg.ResponseKey
+	}
+
+	if cfg.SignKey != "" {
+		c.SignKey = cfg.SignKey
+	}
+
+	if cfg.KeyPassphraseFile != "" {
+		c.KeyPassphraseFile = cfg.KeyPassphraseFile
+	}
+
+	if cfg.Compress != "" {
+		c.Compress = cfg.Compress
+	}
+
 	return nil
 }
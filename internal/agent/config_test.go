@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/k0st1a/metrics/internal/pkg/codec"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,10 +39,16 @@ func TestConfigFromFile(t *testing.T) {
 				"-c", "./config_test.json",
 			},
 			cfg: Config{
-				ServerAddr:     "localhost:8090",
-				ReportInterval: 600,
-				PollInterval:   700,
-				CryptoKey:      "CRYPTO_KEY_FROM_FILE",
+				ServerAddr:           "localhost:8090",
+				ReportInterval:       600,
+				PollInterval:         700,
+				CryptoKey:            "CRYPTO_KEY_FROM_FILE",
+				CryptoKeyFingerprint: "CRYPTO_KEY_FINGERPRINT_FROM_FILE",
+				CryptoKeyTOFU:        true,
+				ResponseKey:          "RESPONSE_KEY_FROM_FILE",
+				SignKey:              "SIGN_KEY_FROM_FILE",
+				KeyPassphraseFile:    "KEY_PASSPHRASE_FILE_FROM_FILE",
+				Compress:             "zstd",
 			},
 		},
 	}
@@ -65,6 +72,12 @@ func TestConfigFromFile(t *testing.T) {
 			assert.Equal(t, test.cfg.ReportInterval, cfg.ReportInterval)
 			assert.Equal(t, test.cfg.PollInterval, cfg.PollInterval)
 			assert.Equal(t, test.cfg.CryptoKey, cfg.CryptoKey)
+			assert.Equal(t, test.cfg.CryptoKeyFingerprint, cfg.CryptoKeyFingerprint)
+			assert.Equal(t, test.cfg.CryptoKeyTOFU, cfg.CryptoKeyTOFU)
+			assert.Equal(t, test.cfg.ResponseKey, cfg.ResponseKey)
+			assert.Equal(t, test.cfg.SignKey, cfg.SignKey)
+			assert.Equal(t, test.cfg.Compress, cfg.Compress)
+			assert.Equal(t, test.cfg.KeyPassphraseFile, cfg.KeyPassphraseFile)
 			origStateFun()
 		})
 	}
@@ -92,20 +105,34 @@ func TestConfigFromEnv(t *testing.T) {
 		{
 			name: "Check config from env",
 			env: map[string]string{
-				"ADDRESS":         "ADDRESS_FROM_ENV",
-				"KEY":             "KEY_FROM_ENV",
-				"CRYPTO_KEY":      "CRYPTO_KEY_FROM_ENV",
-				"POLL_INTERVAL":   "100",
-				"REPORT_INTERVAL": "200",
-				"RATE_LIMIT":      "300",
+				"ADDRESS":                "ADDRESS_FROM_ENV",
+				"KEY":                    "KEY_FROM_ENV",
+				"KEY_ID":                 "KEY_ID_FROM_ENV",
+				"CRYPTO_KEY":             "CRYPTO_KEY_FROM_ENV",
+				"POLL_INTERVAL":          "100",
+				"REPORT_INTERVAL":        "200",
+				"RATE_LIMIT":             "300",
+				"CRYPTO_KEY_FINGERPRINT": "CRYPTO_KEY_FINGERPRINT_FROM_ENV",
+				"CRYPTO_KEY_TOFU":        "true",
+				"RESPONSE_KEY":           "RESPONSE_KEY_FROM_ENV",
+				"SIGN_KEY":               "SIGN_KEY_FROM_ENV",
+				"KEY_PASSPHRASE_FILE":    "KEY_PASSPHRASE_FILE_FROM_ENV",
+				"COMPRESS":               "br",
 			},
 			cfg: Config{
-				ServerAddr:     "ADDRESS_FROM_ENV",
-				HashKey:        "KEY_FROM_ENV",
-				CryptoKey:      "CRYPTO_KEY_FROM_ENV",
-				PollInterval:   100,
-				ReportInterval: 200,
-				RateLimit:      300,
+				ServerAddr:           "ADDRESS_FROM_ENV",
+				HashKey:              "KEY_FROM_ENV",
+				HashKeyID:            "KEY_ID_FROM_ENV",
+				CryptoKey:            "CRYPTO_KEY_FROM_ENV",
+				CryptoKeyFingerprint: "CRYPTO_KEY_FINGERPRINT_FROM_ENV",
+				CryptoKeyTOFU:        true,
+				ResponseKey:          "RESPONSE_KEY_FROM_ENV",
+				SignKey:              "SIGN_KEY_FROM_ENV",
+				KeyPassphraseFile:    "KEY_PASSPHRASE_FILE_FROM_ENV",
+				Compress:             "br",
+				PollInterval:         100,
+				ReportInterval:       200,
+				RateLimit:            300,
 			},
 		},
 	}
@@ -154,16 +181,30 @@ func TestConfigFromFlags(t *testing.T) {
 				"-p", "100",
 				"-r", "200",
 				"-k", "KEY_FROM_FLAG",
+				"-key-id", "KEY_ID_FROM_FLAG",
 				"-crypto-key", "CRYPTO_KEY_FROM_FLAG",
+				"-crypto-key-fingerprint", "CRYPTO_KEY_FINGERPRINT_FROM_FLAG",
+				"-crypto-key-tofu",
+				"-response-key", "RESPONSE_KEY_FROM_FLAG",
+				"-sign-key", "SIGN_KEY_FROM_FLAG",
+				"-key-passphrase-file", "KEY_PASSPHRASE_FILE_FROM_FLAG",
+				"-compress", "none",
 				"-l", "300",
 			},
 			cfg: Config{
-				ServerAddr:     "localhost:8081",
-				PollInterval:   100,
-				ReportInterval: 200,
-				HashKey:        "KEY_FROM_FLAG",
-				CryptoKey:      "CRYPTO_KEY_FROM_FLAG",
-				RateLimit:      300,
+				ServerAddr:           "localhost:8081",
+				PollInterval:         100,
+				ReportInterval:       200,
+				HashKey:              "KEY_FROM_FLAG",
+				HashKeyID:            "KEY_ID_FROM_FLAG",
+				CryptoKey:            "CRYPTO_KEY_FROM_FLAG",
+				CryptoKeyFingerprint: "CRYPTO_KEY_FINGERPRINT_FROM_FLAG",
+				CryptoKeyTOFU:        true,
+				ResponseKey:          "RESPONSE_KEY_FROM_FLAG",
+				SignKey:              "SIGN_KEY_FROM_FLAG",
+				KeyPassphraseFile:    "KEY_PASSPHRASE_FILE_FROM_FLAG",
+				Compress:             "none",
+				RateLimit:            300,
 			},
 		},
 	}
@@ -230,6 +271,7 @@ func TestConfig(t *testing.T) {
 				PollInterval:   100,
 				ReportInterval: 200,
 				RateLimit:      300,
+				Compress:       "gzip",
 			},
 		},
 	}
@@ -252,3 +294,23 @@ func TestConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigCompressError(t *testing.T) {
+	defer func(args []string, cl *flag.FlagSet) {
+		//nolint:reassign //for tests only
+		os.Args = args
+		//nolint:reassign //for tests only
+		flag.CommandLine = cl
+	}(os.Args, flag.CommandLine)
+
+	//nolint:reassign //for tests only
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	//nolint:reassign //for tests only
+	os.Args = []string{"cmd"}
+
+	t.Setenv("COMPRESS", "deflate")
+
+	_, err := NewConfig()
+	assert.ErrorIs(t, err, codec.ErrUnsupported)
+}
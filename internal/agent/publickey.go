@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrKeyFingerprint - отпечаток открытого ключа сервера не совпал с заданным.
+var ErrKeyFingerprint = errors.New("server public key fingerprint mismatch")
+
+// serverKey - открытый ключ сервера.
+type serverKey interface {
+	Encrypt([]byte) ([]byte, error)
+	PEM() ([]byte, error)
+	Fingerprint() (string, error)
+}
+
+// newServerKey - открытый ключ сервера для шифрования сообщений агента. Ключ читается из файла CryptoKey,
+// а если файла нет и включен CryptoKeyTOFU - получается с сервера и сохраняется в файл. Без CryptoKey ключ
+// получается с сервера при каждом запуске, если задан CryptoKeyFingerprint. Если задан отпечаток, то ключ
+// с ним сверяется. Без ключа возвращается nil. Ответ сервера проверяется транспортом rt.
+func newServerKey(ctx context.Context, cfg *Config, rt http.RoundTripper) (serverKey, error) {
+	if cfg.CryptoKey == "" && cfg.CryptoKeyFingerprint == "" {
+		return nil, nil
+	}
+
+	if cfg.CryptoKey != "" {
+		data, err := os.ReadFile(cfg.CryptoKey)
+		if err == nil {
+			return newPinnedKey(string(data), cfg.CryptoKeyFingerprint)
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) || !cfg.CryptoKeyTOFU {
+			return nil, fmt.Errorf("public key file read error:%w", err)
+		}
+	}
+
+	data, err := fetchServerKey(ctx, cfg.ServerAddr, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newPinnedKey(data, cfg.CryptoKeyFingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CryptoKey != "" {
+		pem, err := key.PEM()
+		if err != nil {
+			return nil, fmt.Errorf("public key PEM error:%w", err)
+		}
+
+		err = os.WriteFile(cfg.CryptoKey, pem, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("public key file write error:%w", err)
+		}
+
+		fp, err := key.Fingerprint()
+		if err != nil {
+			return nil, fmt.Errorf("public key fingerprint error:%w", err)
+		}
+
+		log.Printf("Server public key with fingerprint %v saved to %v", fp, cfg.CryptoKey)
+	}
+
+	return key, nil
+}
+
+// newPinnedKey - открытый ключ из data в формате PEM, если его отпечаток совпал с fingerprint
+// или fingerprint пустой.
+func newPinnedKey(data string, fingerprint string) (serverKey, error) {
+	key, err := rsa.NewPublic(data)
+	if err != nil {
+		return nil, fmt.Errorf("rsa new public error:%w", err)
+	}
+
+	if fingerprint == "" {
+		return key, nil
+	}
+
+	fp, err := key.Fingerprint()
+	if err != nil {
+		return nil, fmt.Errorf("public key fingerprint error:%w", err)
+	}
+
+	if !strings.EqualFold(fp, fingerprint) {
+		return nil, fmt.Errorf("%w:got %v, want %v", ErrKeyFingerprint, fp, fingerprint)
+	}
+
+	return key, nil
+}
+
+// fetchServerKey - получение открытого ключа сервера с адресом addr в формате PEM.
+func fetchServerKey(ctx context.Context, addr string, rt http.RoundTripper) (string, error) {
+	url, err := url.JoinPath("http://", addr, "/api/v1/public-key")
+	if err != nil {
+		return "", fmt.Errorf("url join path error:%w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("new request error:%w", err)
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return "", fmt.Errorf("public key request error:%w", err)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("public key read error:%w", err)
+	}
+
+	err = resp.Body.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("resp.Body.Close error")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("public key request status:%v", resp.StatusCode)
+	}
+
+	return string(data), nil
+}
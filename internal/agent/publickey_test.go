@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/handlers/publickey"
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyFingerprint = "ef70a05de18b6b133eb0a9df0e2cd65ebcb96b953ba35f1e00aa0feced9d0b00"
+
+func TestNewServerKey(t *testing.T) {
+	prv, err := rsa.NewPrivateFromFile("../pkg/crypto/rsa/private.pem")
+	require.NoError(t, err)
+
+	pem, err := os.ReadFile("../pkg/crypto/rsa/public.pem")
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	publickey.BuildRouter(r, publickey.NewHandler(prv.Public()))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	tests := []struct {
+		name        string
+		file        bool
+		noPath      bool
+		tofu        bool
+		fingerprint string
+		wantKey     bool
+		wantSaved   bool
+		err         error
+		wantErr     bool
+	}{
+		{
+			name:    "Ключ из файла",
+			file:    true,
+			wantKey: true,
+		},
+		{
+			name:        "Ключ из файла совпал с отпечатком",
+			file:        true,
+			fingerprint: strings.ToUpper(testKeyFingerprint),
+			wantKey:     true,
+		},
+		{
+			name:        "Ключ из файла не совпал с отпечатком",
+			file:        true,
+			fingerprint: "00",
+			err:         ErrKeyFingerprint,
+		},
+		{
+			name:      "Ключ получен с сервера при первом запуске и сохранен",
+			tofu:      true,
+			wantKey:   true,
+			wantSaved: true,
+		},
+		{
+			name:    "Нет файла с ключом",
+			wantErr: true,
+		},
+		{
+			name:        "Ключ получен с сервера по отпечатку",
+			noPath:      true,
+			fingerprint: testKeyFingerprint,
+			wantKey:     true,
+		},
+		{
+			name:        "Ключ с сервера не совпал с отпечатком",
+			noPath:      true,
+			fingerprint: "00",
+			err:         ErrKeyFingerprint,
+		},
+		{
+			name:   "Без ключа",
+			noPath: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "public.pem")
+			if test.file {
+				require.NoError(t, os.WriteFile(path, pem, 0o600))
+			}
+
+			cfg := &Config{
+				ServerAddr:           strings.TrimPrefix(srv.URL, "http://"),
+				CryptoKey:            path,
+				CryptoKeyFingerprint: test.fingerprint,
+				CryptoKeyTOFU:        test.tofu,
+			}
+			if test.noPath {
+				cfg.CryptoKey = ""
+			}
+
+			key, err := newServerKey(context.Background(), cfg, http.DefaultTransport)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if !test.wantKey {
+				assert.Nil(t, key)
+				return
+			}
+
+			fp, err := key.Fingerprint()
+			require.NoError(t, err)
+			assert.Equal(t, testKeyFingerprint, fp)
+
+			if test.wantSaved {
+				saved, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, string(pem), string(saved))
+			}
+		})
+	}
+}
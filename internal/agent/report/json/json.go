@@ -4,18 +4,34 @@ package json
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 
 	"github.com/k0st1a/metrics/internal/agent/model"
+	"github.com/k0st1a/metrics/internal/middleware/idempotency"
 	"github.com/k0st1a/metrics/internal/models"
+	pkgidempotency "github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/k0st1a/metrics/internal/pkg/retry"
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	errServer = errors.New("server error")
+	// errInProgress - сервер еще обрабатывает запрос с тем же ключом идемпотентности.
+	errInProgress = errors.New("request in progress")
+)
+
+// Retryer - интерфейс повторной отправки метрик на сервер.
+type Retryer interface {
+	Retry(ctx context.Context, fnc func() error) error
+}
+
 type report struct {
 	client  *http.Client
+	retry   Retryer
 	channel <-chan map[string]model.MetricInfoRaw
 	address string
 }
@@ -28,6 +44,7 @@ func NewReport(a string, c *http.Client, ch <-chan map[string]model.MetricInfoRa
 	return &report{
 		address: a,
 		client:  c,
+		retry:   retry.New(retry.DefaultPolicy(), isRetryable, nil),
 		channel: ch,
 	}
 }
@@ -37,7 +54,7 @@ func (r *report) Do(ctx context.Context) {
 	for {
 		select {
 		case mi := <-r.channel:
-			r.prepareMetricsAndDoReport(mi)
+			r.prepareMetricsAndDoReport(ctx, mi)
 		case <-ctx.Done():
 			log.Printf("JSON peporter closed with cause:%s\n", ctx.Err())
 			return
@@ -45,16 +62,18 @@ func (r *report) Do(ctx context.Context) {
 	}
 }
 
-func (r *report) prepareMetricsAndDoReport(mi map[string]model.MetricInfoRaw) {
+func (r *report) prepareMetricsAndDoReport(ctx context.Context, mi map[string]model.MetricInfoRaw) {
 	log.Printf("mi:%v", mi)
 	mi2 := model.RawMap2InfoList(mi)
 	log.Printf("mi2:%v", mi2)
 	ml := MetricsInfo2Metrics(mi2)
 	log.Printf("ml:%v", ml)
-	r.doReport(ml)
+	r.doReport(ctx, ml)
 }
 
-func (r *report) doReport(m []models.Metrics) {
+// doReport - отправка метрик на сервер. При повторной отправке используется тот же ключ идемпотентности,
+// поэтому сервер не учтет счетчики дважды, если ответ на предыдущую попытку был потерян.
+func (r *report) doReport(ctx context.Context, m []models.Metrics) {
 	b, err := models.SerializeList(m)
 	if err != nil {
 		log.Error().Err(err).Msg("models.SerializeList")
@@ -67,25 +86,54 @@ func (r *report) doReport(m []models.Metrics) {
 		return
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(b))
+	key, err := pkgidempotency.NewKey()
+	if err != nil {
+		log.Error().Err(err).Msg("new idempotency key error")
+		return
+	}
+
+	err = r.retry.Retry(ctx, func() error {
+		return r.send(ctx, url, key, b)
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("http.NewRequest error")
+		log.Error().Err(err).Msg("report error")
 		return
 	}
+}
+
+func (r *report) send(ctx context.Context, url string, key string, b []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(b))
+	if err != nil {
+		return fmt.Errorf("http.NewRequest error:%w", err)
+	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotency.Header, key)
 
 	resp, err := r.client.Do(req)
 	if err != nil {
-		log.Error().Err(err).Msg("client do error")
-		return
+		return fmt.Errorf("client do error:%w", err)
 	}
 
 	err = resp.Body.Close()
 	if err != nil {
 		log.Error().Err(err).Msg("resp.Body.Close error")
-		return
 	}
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w:%v", errServer, resp.StatusCode)
+	case resp.StatusCode == http.StatusConflict:
+		// Предыдущая попытка еще обрабатывается или ее ответ не сохранен, повтор вернет ее результат.
+		return fmt.Errorf("%w:%v", errInProgress, resp.StatusCode)
+	}
+
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) || errors.Is(err, errServer) || errors.Is(err, errInProgress)
 }
 
 // MetricsInfo2Metrics - преобразование списка метрики из "промежуточного" формата в "окончательный" формат
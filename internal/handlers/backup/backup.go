@@ -0,0 +1,64 @@
+// Package backup for HTTP handler which downloads online backup of storage.
+package backup
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// Path - путь скачивания копии хранилища. Ответ пишется потоком и не должен буферизоваться middleware.
+const Path = "/api/v1/backup"
+
+// Backuper - интерфейс записи согласованной копии хранилища.
+type Backuper interface {
+	Backup(ctx context.Context, w io.Writer) (int64, error)
+}
+
+type handler struct {
+	b    Backuper
+	name string
+}
+
+// NewHandler - создание обработчика для скачивания копии хранилища, где:
+//   - b - интерфейс записи копии хранилища;
+//   - name - префикс имени скачиваемого файла.
+func NewHandler(b Backuper, name string) *handler {
+	return &handler{
+		b:    b,
+		name: name,
+	}
+}
+
+// BuildRouter - формирование маршрута для обработчика, где:
+//   - admin - middleware доступа к копии хранилища, например checksign.Require.
+func BuildRouter(r *chi.Mux, h *handler, admin ...func(http.Handler) http.Handler) {
+	r.With(admin...).Get(Path, h.GetBackupHandler)
+}
+
+// GetBackupHandler - обработчик скачивания копии хранилища. Копия пишется в ответ потоком, поэтому ошибка
+// после начала записи тела обрывает ответ.
+func (h *handler) GetBackupHandler(rw http.ResponseWriter, r *http.Request) {
+	log.Printf("Get Backup")
+
+	fn := h.name + "-" + time.Now().UTC().Format("20060102T150405Z") + ".db"
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	rw.Header().Set("Content-Disposition", `attachment; filename="`+fn+`"`)
+
+	n, err := h.b.Backup(r.Context(), rw)
+	if err != nil {
+		log.Error().Err(err).Int64("written", n).Msg("Backup error")
+		if n == 0 {
+			rw.Header().Del("Content-Disposition")
+			http.Error(rw, "backup error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Get Backup success, size:%v", n)
+}
@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type backuper struct {
+	err  error
+	data string
+}
+
+func (b *backuper) Backup(_ context.Context, w io.Writer) (int64, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	n, err := io.WriteString(w, b.data)
+	return int64(n), err
+}
+
+func TestGetBackupHandler(t *testing.T) {
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			http.Error(rw, "signature is required", http.StatusBadRequest)
+		})
+	}
+
+	tests := []struct {
+		name   string
+		b      *backuper
+		admin  func(http.Handler) http.Handler
+		body   string
+		status int
+	}{
+		{
+			name:   "backup success",
+			b:      &backuper{data: "bolt db content"},
+			status: http.StatusOK,
+			body:   "bolt db content",
+		},
+		{
+			name:   "backup error",
+			b:      &backuper{err: errors.New("db closed")},
+			status: http.StatusInternalServerError,
+			body:   "backup error\n",
+		},
+		{
+			name:   "backup denied",
+			b:      &backuper{data: "bolt db content"},
+			admin:  deny,
+			status: http.StatusBadRequest,
+			body:   "signature is required\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var admin []func(http.Handler) http.Handler
+			if test.admin != nil {
+				admin = append(admin, test.admin)
+			}
+
+			r := chi.NewRouter()
+			BuildRouter(r, NewHandler(test.b, "metrics"), admin...)
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/api/v1/backup")
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.body, string(b))
+			if test.status == http.StatusOK {
+				assert.Contains(t, resp.Header.Get("Content-Disposition"), `filename="metrics-`)
+			}
+		})
+	}
+}
@@ -30,10 +30,16 @@ func BuildRouter(r *chi.Mux, h *handler) {
 	r.Get("/ping", h.GetPingHandler)
 }
 
-// GetPingHandler - обработчик для проверки доступности БД.
+// GetPingHandler - обработчик для проверки доступности БД. Если хранилище проверки соединения не поддерживает,
+// как хранилище в RAM или в файле, то отвечает 501.
 func (h *handler) GetPingHandler(rw http.ResponseWriter, r *http.Request) {
 	log.Printf("Get Ping")
 
+	if h.p == nil {
+		rw.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
 	ctx := r.Context()
 
 	err := h.p.Ping(ctx)
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type pinger struct {
+	err error
+}
+
+func (p pinger) Ping(context.Context) error {
+	return p.err
+}
+
+func TestGetPingHandler(t *testing.T) {
+	tests := []struct {
+		p    Pinger
+		name string
+		want int
+	}{
+		{
+			name: "db is available",
+			p:    pinger{},
+			want: http.StatusOK,
+		},
+		{
+			name: "db is down",
+			p:    pinger{err: errors.New("db is down")},
+			want: http.StatusInternalServerError,
+		},
+		{
+			name: "storage without db",
+			want: http.StatusNotImplemented,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			BuildRouter(r, NewHandler(test.p))
+
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+			assert.Equal(t, test.want, rw.Code)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Package health for HTTP liveness and readiness handlers of the server.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+
+	checkTimeout = 2 * time.Second
+)
+
+// Check - проверка одной из зависимостей сервера.
+type Check struct {
+	// Check - функция проверки, возвращает ошибку, если зависимость не готова.
+	Check func(ctx context.Context) error
+	// Name - имя проверки в ответе /readyz.
+	Name string
+}
+
+// CheckResult - результат проверки зависимости.
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Response - ответ обработчиков /healthz и /readyz.
+type Response struct {
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+	Status string                 `json:"status"`
+}
+
+type handler struct {
+	checks []Check
+}
+
+// NewHandler - создание обработчика проверок живости и готовности сервера, где:
+//   - checks - проверки всех используемых сервером зависимостей.
+func NewHandler(checks []Check) *handler {
+	return &handler{
+		checks: checks,
+	}
+}
+
+// BuildRouter - формирование маршрута для обработчика.
+func BuildRouter(r *chi.Mux, h *handler) {
+	r.Get("/healthz", h.GetHealthzHandler)
+	r.Get("/readyz", h.GetReadyzHandler)
+}
+
+// GetHealthzHandler - обработчик проверки живости сервера, отвечает всегда, пока сервер обрабатывает запросы.
+func (h *handler) GetHealthzHandler(rw http.ResponseWriter, r *http.Request) {
+	writeResponse(rw, http.StatusOK, &Response{Status: statusOK})
+}
+
+// GetReadyzHandler - обработчик проверки готовности сервера. Выполняет все проверки зависимостей и
+// отвечает 503, если хотя бы одна из них не прошла.
+func (h *handler) GetReadyzHandler(rw http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	resp := &Response{
+		Status: statusOK,
+		Checks: make(map[string]CheckResult, len(h.checks)),
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+
+	for _, c := range h.checks {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res := CheckResult{Status: statusOK}
+
+			err := c.Check(ctx)
+			if err != nil {
+				log.Error().Err(err).Str("check", c.Name).Msg("readiness check error")
+				res = CheckResult{Status: statusFail, Error: err.Error()}
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			resp.Checks[c.Name] = res
+			if err != nil {
+				resp.Status = statusFail
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	code := http.StatusOK
+	if resp.Status != statusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeResponse(rw, code, resp)
+}
+
+func writeResponse(rw http.ResponseWriter, code int, resp *Response) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		log.Error().Err(err).Msg("json marshal error")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+
+	_, err = rw.Write(b)
+	if err != nil {
+		log.Error().Err(err).Msg("rw.Write error")
+	}
+}
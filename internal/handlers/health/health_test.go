@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k0st1a/metrics/internal/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	fail := func(context.Context) error { return errors.New("db is down") }
+
+	tests := []struct {
+		name               string
+		path               string
+		checks             []Check
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "Liveness does not depend on checks",
+			path:               "/healthz",
+			checks:             []Check{{Name: "db", Check: fail}},
+			expectedStatusCode: 200,
+			expectedBody:       `{"status":"ok"}`,
+		},
+		{
+			name:               "Ready when all checks pass",
+			path:               "/readyz",
+			checks:             []Check{{Name: "db", Check: ok}, {Name: "file_writable", Check: ok}},
+			expectedStatusCode: 200,
+			expectedBody:       `{"checks":{"db":{"status":"ok"},"file_writable":{"status":"ok"}},"status":"ok"}`,
+		},
+		{
+			name:               "Not ready when one of checks fails",
+			path:               "/readyz",
+			checks:             []Check{{Name: "db", Check: fail}, {Name: "file_writable", Check: ok}},
+			expectedStatusCode: 503,
+			expectedBody: `{"checks":{"db":{"status":"fail","error":"db is down"},` +
+				`"file_writable":{"status":"ok"}},"status":"fail"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := handlers.NewRouter(nil)
+			BuildRouter(r, NewHandler(test.checks))
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+			res := recorder.Result()
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			err = res.Body.Close()
+			assert.NoError(t, err)
+
+			require.Equal(t, test.expectedStatusCode, res.StatusCode)
+			assert.JSONEq(t, test.expectedBody, string(b))
+		})
+	}
+}
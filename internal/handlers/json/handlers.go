@@ -6,10 +6,10 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"sort"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/k0st1a/metrics/internal/models"
-	"github.com/k0st1a/metrics/internal/pkg/retry"
 	"github.com/k0st1a/metrics/internal/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -17,9 +17,27 @@ import (
 const (
 	badMetricType  = "metric type is bad"
 	notFoundMetric = "metric not found"
+	unavailable    = "storage is unavailable"
 	emptyMetricID  = "metric id is empty"
 	nilMetricValue = "metric value is nil"
 	nilMetricDelta = "metric delta is nil"
+	reservedName   = "metric name is reserved"
+	badBatchMode   = "batch mode is bad"
+	batchRejected  = "batch rejected"
+
+	batchModeParam      = "mode"
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best-effort"
+)
+
+var (
+	errBadMetricType  = errors.New(badMetricType)
+	errEmptyMetricID  = errors.New(emptyMetricID)
+	errNilMetricValue = errors.New(nilMetricValue)
+	errNilMetricDelta = errors.New(nilMetricDelta)
+	errReservedName   = errors.New(reservedName)
+	errStoreCounter   = errors.New("store counter error")
+	errStoreGauge     = errors.New("store gauge error")
 )
 
 // Storage - интерфейс работы с хранилищем метрик.
@@ -42,7 +60,7 @@ type Storage interface {
 
 // Retryer - интерфейс повторного обращения к хранилищу.
 type Retryer interface {
-	Retry(ctx context.Context, check func(error) bool, fnc func() error) error
+	Retry(ctx context.Context, fnc func() error) error
 }
 
 type handler struct {
@@ -59,14 +77,21 @@ func NewHandler(s Storage, r Retryer) *handler {
 	}
 }
 
-// BuildRouter - формирование маршрута для HTTP обработчика.
-func BuildRouter(r *chi.Mux, h *handler) {
+// BuildRouter - формирование маршрута для HTTP обработчика, где:
+//   - admin - middleware доступа к выгрузке всех метрик, например checksign.Require.
+func BuildRouter(r *chi.Mux, h *handler, admin ...func(http.Handler) http.Handler) {
 	r.With(contentType).Post("/updates/", h.PostUpdatesHandler)
 	r.With(contentType).Post("/update/", h.PostUpdateHandler)
 	r.With(contentType).Post("/value/", h.PostValueHandler)
+	r.With(admin...).Get("/values/", h.GetValuesHandler)
 }
 
 // PostUpdatesHandler - обработчик сохранения метрик в формате JSON.
+// Режим сохранения пакета задается параметром запроса mode:
+//   - не задан - некорректные метрики пропускаются, остальные сохраняются все вместе или не сохраняются вовсе;
+//   - atomic - пакет с некорректной метрикой отклоняется целиком, иначе метрики сохраняются все вместе или
+//     не сохраняются вовсе, в ответе возвращается результат по каждой метрике;
+//   - best-effort - каждая метрика сохраняется отдельно, в ответе возвращается результат по каждой метрике.
 func (h *handler) PostUpdatesHandler(rw http.ResponseWriter, r *http.Request) {
 	log.Info().
 		Str("uri", r.RequestURI).
@@ -87,55 +112,176 @@ func (h *handler) PostUpdatesHandler(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	switch mode := r.URL.Query().Get(batchModeParam); mode {
+	case "", batchModeAtomic:
+		h.storeBatch(rw, r, m, mode == batchModeAtomic)
+	case batchModeBestEffort:
+		h.storeEach(rw, r, m)
+	default:
+		http.Error(rw, badBatchMode, http.StatusBadRequest)
+	}
+}
+
+// storeBatch - сохранение пакета метрик одной операцией хранилища, где:
+//   - strict - при наличии некорректной метрики отклонить пакет целиком.
+func (h *handler) storeBatch(rw http.ResponseWriter, r *http.Request, m []models.Metrics, strict bool) {
 	g := make(map[string]float64)
 	c := make(map[string]int64)
 
+	results := make([]models.Result, 0, len(m))
+	invalid := false
+
 	for _, v := range m {
+		err := validate(v)
+		if err != nil {
+			log.Error().Err(err).Str("id", v.ID).Str("type", v.MType).Msg("metric rejected")
+			results = append(results, newResult(v, err))
+			invalid = true
+			continue
+		}
+
+		results = append(results, newResult(v, nil))
+
 		switch v.MType {
 		case "counter":
-			cv, ok := c[v.ID]
-			switch ok {
-			case true:
-				if v.Delta != nil {
-					c[v.ID] = cv + *v.Delta
-				} else {
-					log.Error().Msg("empty ptr v.Delta for counter")
-				}
-			default:
-				if v.Delta != nil {
-					c[v.ID] = *v.Delta
-				} else {
-					log.Error().Msg("empty ptr v.Delta for counter")
-				}
-			}
+			c[v.ID] += *v.Delta
 		case "gauge":
-			if v.Value != nil {
-				g[v.ID] = *v.Value
-			} else {
-				log.Error().Msg("empty ptr v.Value for gauge")
+			g[v.ID] = *v.Value
+		}
+	}
+
+	if strict && invalid {
+		for i := range results {
+			if results[i].Status == models.ResultStored {
+				results[i].Status = models.ResultRejected
+				results[i].Error = batchRejected
 			}
-		default:
-			log.Error().
-				Str("unknown MType", v.MType).
-				Msg("")
 		}
+
+		writeResults(rw, http.StatusBadRequest, results)
+		return
 	}
 
 	log.Printf("Store\nCounters:%+v\nGauges:%+v\n", c, g)
 
-	err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+	err := h.retry.Retry(r.Context(), func() error {
 		//nolint // Не за чем оборачивать ошибку
 		return h.storage.StoreAll(r.Context(), c, g)
 	})
+	if errors.Is(err, utils.ErrMetricsReservedName) {
+		http.Error(rw, reservedName, http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Error().Err(err).Msg("s.StoreAll error")
 		rw.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	if strict {
+		writeResults(rw, http.StatusOK, results)
+		return
+	}
+
 	rw.WriteHeader(http.StatusOK)
 }
 
+// storeEach - сохранение каждой метрики пакета отдельно от других.
+func (h *handler) storeEach(rw http.ResponseWriter, r *http.Request, m []models.Metrics) {
+	results := make([]models.Result, 0, len(m))
+
+	for _, v := range m {
+		err := validate(v)
+		if err == nil {
+			err = h.store(r.Context(), v)
+		}
+		if err != nil {
+			log.Error().Err(err).Str("id", v.ID).Str("type", v.MType).Msg("metric rejected")
+		}
+
+		results = append(results, newResult(v, err))
+	}
+
+	writeResults(rw, http.StatusOK, results)
+}
+
+func (h *handler) store(ctx context.Context, m models.Metrics) error {
+	switch m.MType {
+	case "counter":
+		err := h.retry.Retry(ctx, func() error {
+			//nolint // Не за чем оборачивать ошибку
+			return h.storage.StoreCounter(ctx, m.ID, *m.Delta)
+		})
+		if errors.Is(err, utils.ErrMetricsReservedName) {
+			return errReservedName
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("h.storage.StoreCounter error")
+			return errStoreCounter
+		}
+	case "gauge":
+		err := h.retry.Retry(ctx, func() error {
+			//nolint // Не за чем оборачивать ошибку
+			return h.storage.StoreGauge(ctx, m.ID, *m.Value)
+		})
+		if errors.Is(err, utils.ErrMetricsReservedName) {
+			return errReservedName
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("h.storage.StoreGauge error")
+			return errStoreGauge
+		}
+	}
+
+	return nil
+}
+
+func validate(m models.Metrics) error {
+	switch m.MType {
+	case "counter":
+		if m.Delta == nil {
+			return errNilMetricDelta
+		}
+	case "gauge":
+		if m.Value == nil {
+			return errNilMetricValue
+		}
+	default:
+		return errBadMetricType
+	}
+
+	if m.ID == "" {
+		return errEmptyMetricID
+	}
+
+	return nil
+}
+
+func newResult(m models.Metrics, err error) models.Result {
+	if err != nil {
+		return models.Result{ID: m.ID, MType: m.MType, Status: models.ResultRejected, Error: err.Error()}
+	}
+
+	return models.Result{ID: m.ID, MType: m.MType, Status: models.ResultStored}
+}
+
+func writeResults(rw http.ResponseWriter, statusCode int, results []models.Result) {
+	b, err := models.SerializeResultList(results)
+	if err != nil {
+		log.Error().Err(err).Msg("models.SerializeResultList error")
+		http.Error(rw, "serialize error", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(statusCode)
+
+	_, err = rw.Write(b)
+	if err != nil {
+		log.Error().Err(err).Msg("rw.Write error")
+	}
+}
+
 // PostUpdateHandler - обработчик сохранения метрики в формате JSON.
 func (h *handler) PostUpdateHandler(rw http.ResponseWriter, r *http.Request) {
 	log.Info().
@@ -170,10 +316,14 @@ func (h *handler) PostUpdateHandler(rw http.ResponseWriter, r *http.Request) {
 			return
 		}
 		log.Printf("Post Update counter, name(%v), value(%v)", m.ID, *m.Delta)
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			//nolint // Не за чем оборачивать ошибку
 			return h.storage.StoreCounter(r.Context(), m.ID, *m.Delta)
 		})
+		if errors.Is(err, utils.ErrMetricsReservedName) {
+			http.Error(rw, reservedName, http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("h.storage.StoreCounter error")
 			http.Error(rw, "store counter error", http.StatusInternalServerError)
@@ -191,10 +341,14 @@ func (h *handler) PostUpdateHandler(rw http.ResponseWriter, r *http.Request) {
 			return
 		}
 		log.Printf("Post Update gauge, name(%v), value(%v)", m.ID, *m.Value)
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			//nolint // Не за чем оборачивать ошибку
 			return h.storage.StoreGauge(r.Context(), m.ID, *m.Value)
 		})
+		if errors.Is(err, utils.ErrMetricsReservedName) {
+			http.Error(rw, reservedName, http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("h.storage.StorageGauge error")
 			http.Error(rw, "storage gauge error", http.StatusInternalServerError)
@@ -232,7 +386,7 @@ func (h *handler) PostValueHandler(rw http.ResponseWriter, r *http.Request) {
 	switch m.MType {
 	case "counter":
 		var c *int64
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			c, err = h.storage.GetCounter(r.Context(), m.ID)
 			//nolint // Не за чем оборачивать ошибку
 			return err
@@ -241,6 +395,9 @@ func (h *handler) PostValueHandler(rw http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, utils.ErrMetricsNoCounter):
 			http.Error(rw, notFoundMetric, http.StatusNotFound)
 			return
+		case errors.Is(err, utils.ErrStorageUnavailable):
+			http.Error(rw, unavailable, http.StatusServiceUnavailable)
+			return
 		case err != nil:
 			log.Error().Err(err).Msg("get counter error")
 			http.Error(rw, notFoundMetric, http.StatusInternalServerError)
@@ -250,7 +407,7 @@ func (h *handler) PostValueHandler(rw http.ResponseWriter, r *http.Request) {
 		}
 	case "gauge":
 		var g *float64
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			g, err = h.storage.GetGauge(r.Context(), m.ID)
 			//nolint // Не за чем оборачивать ошибку
 			return err
@@ -259,6 +416,9 @@ func (h *handler) PostValueHandler(rw http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, utils.ErrMetricsNoGauge):
 			http.Error(rw, notFoundMetric, http.StatusNotFound)
 			return
+		case errors.Is(err, utils.ErrStorageUnavailable):
+			http.Error(rw, unavailable, http.StatusServiceUnavailable)
+			return
 		case err != nil:
 			log.Error().Err(err).Msg("get gauge error")
 			http.Error(rw, notFoundMetric, http.StatusInternalServerError)
@@ -287,6 +447,58 @@ func (h *handler) PostValueHandler(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetValuesHandler - обработчик получения всех метрик в формате JSON, метрики упорядочены по типу и имени.
+func (h *handler) GetValuesHandler(rw http.ResponseWriter, r *http.Request) {
+	var (
+		c   map[string]int64
+		g   map[string]float64
+		err error
+	)
+
+	err = h.retry.Retry(r.Context(), func() error {
+		c, g, err = h.storage.GetAll(r.Context())
+		//nolint // Не за чем оборачивать ошибку
+		return err
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("get metrics error")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	ml := make([]models.Metrics, 0, len(c)+len(g))
+	for n, v := range c {
+		v := v
+		ml = append(ml, models.Metrics{ID: n, MType: "counter", Delta: &v})
+	}
+	for n, v := range g {
+		v := v
+		ml = append(ml, models.Metrics{ID: n, MType: "gauge", Value: &v})
+	}
+
+	sort.Slice(ml, func(i, j int) bool {
+		if ml[i].MType != ml[j].MType {
+			return ml[i].MType < ml[j].MType
+		}
+		return ml[i].ID < ml[j].ID
+	})
+
+	data, err := models.SerializeList(ml)
+	if err != nil {
+		log.Error().Err(err).Msg("models.SerializeList")
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	_, err = rw.Write(data)
+	if err != nil {
+		log.Error().Err(err).Msg("rw.Write error")
+		return
+	}
+}
+
 func contentType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Content-Type") != "application/json" {
@@ -166,6 +166,92 @@ func TestMetricHandler(t *testing.T) {
 			expectedStatusCode: 200,
 			expectedBody:       "",
 		},
+		{
+			name:      "Upload batch with bad metric in atomic mode",
+			reqMethod: http.MethodPost,
+			reqPath:   "/updates/?mode=atomic",
+			body: `[` +
+				`{"id":"AtomicCounter","type":"counter","delta":1},` +
+				`{"id":"AtomicGauge","type":"gauge"}` +
+				`]`,
+			contentType:        "application/json",
+			expectedStatusCode: 400,
+			expectedBody: `[` +
+				`{"id":"AtomicCounter","type":"counter","status":"rejected","error":"batch rejected"},` +
+				`{"id":"AtomicGauge","type":"gauge","status":"rejected","error":"metric value is nil"}` +
+				`]`,
+		},
+		{
+			name:               "Counter of rejected atomic batch not stored",
+			reqMethod:          http.MethodPost,
+			reqPath:            "/value/",
+			body:               `{"id":"AtomicCounter","type":"counter"}`,
+			contentType:        "application/json",
+			expectedStatusCode: 404,
+			expectedBody:       "metric not found\n",
+		},
+		{
+			name:      "Upload batch in atomic mode",
+			reqMethod: http.MethodPost,
+			reqPath:   "/updates/?mode=atomic",
+			body: `[` +
+				`{"id":"AtomicCounter","type":"counter","delta":1},` +
+				`{"id":"AtomicCounter","type":"counter","delta":2}` +
+				`]`,
+			contentType:        "application/json",
+			expectedStatusCode: 200,
+			expectedBody: `[` +
+				`{"id":"AtomicCounter","type":"counter","status":"stored"},` +
+				`{"id":"AtomicCounter","type":"counter","status":"stored"}` +
+				`]`,
+		},
+		{
+			name:               "Get counter of atomic batch",
+			reqMethod:          http.MethodPost,
+			reqPath:            "/value/",
+			body:               `{"id":"AtomicCounter","type":"counter"}`,
+			contentType:        "application/json",
+			expectedStatusCode: 200,
+			expectedBody:       `{"delta":3,"id":"AtomicCounter","type":"counter"}`,
+		},
+		{
+			name:      "Upload batch in best-effort mode",
+			reqMethod: http.MethodPost,
+			reqPath:   "/updates/?mode=best-effort",
+			body: `[` +
+				`{"id":"BestEffortGauge","type":"gauge","value":1.5},` +
+				`{"type":"counter","delta":1},` +
+				`{"id":"SomeMetricName","type":"bad_type"}` +
+				`]`,
+			contentType:        "application/json",
+			expectedStatusCode: 200,
+			expectedBody: `[` +
+				`{"id":"BestEffortGauge","type":"gauge","status":"stored"},` +
+				`{"id":"","type":"counter","status":"rejected","error":"metric id is empty"},` +
+				`{"id":"SomeMetricName","type":"bad_type","status":"rejected","error":"metric type is bad"}` +
+				`]`,
+		},
+		{
+			name:               "Upload batch in unknown mode",
+			reqMethod:          http.MethodPost,
+			reqPath:            "/updates/?mode=unknown",
+			body:               `[]`,
+			contentType:        "application/json",
+			expectedStatusCode: 400,
+			expectedBody:       "batch mode is bad\n",
+		},
+		{
+			name:               "Get all values",
+			reqMethod:          http.MethodGet,
+			reqPath:            "/values/",
+			expectedStatusCode: 200,
+			expectedBody: `[` +
+				`{"delta":3,"id":"AtomicCounter","type":"counter"},` +
+				`{"delta":246,"id":"CounterName","type":"counter"},` +
+				`{"value":1.5,"id":"BestEffortGauge","type":"gauge"},` +
+				`{"value":123.3,"id":"GaugeName","type":"gauge"}` +
+				`]`,
+		},
 	}
 
 	tmpfile, err := os.CreateTemp("/tmp/", "json-handlers.*.txt")
@@ -176,8 +262,8 @@ func TestMetricHandler(t *testing.T) {
 		_ = os.Remove(tmpfile.Name())
 	}()
 
-	s := file.NewStorage(context.Background(), tmpfile.Name(), 200, false)
-	rt := retry.New()
+	s := file.NewStorage(context.Background(), tmpfile.Name(), 200, false, false, nil)
+	rt := retry.New(retry.DefaultPolicy(), nil, nil)
 	th := NewHandler(s, rt)
 
 	r := handlers.NewRouter(nil)
@@ -0,0 +1,50 @@
+// Package publickey for HTTP handler which returns public key of server for encrypting of agent messages.
+package publickey
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// PEMer - интерфейс получения открытого ключа в формате PEM.
+type PEMer interface {
+	PEM() ([]byte, error)
+}
+
+type handler struct {
+	k PEMer
+}
+
+// NewHandler - создание обработчика для получения открытого ключа сервера, где k - открытый ключ.
+func NewHandler(k PEMer) *handler {
+	return &handler{
+		k: k,
+	}
+}
+
+// BuildRouter - формирование маршрута для обработчика.
+func BuildRouter(r *chi.Mux, h *handler) {
+	r.Get("/api/v1/public-key", h.GetPublicKeyHandler)
+}
+
+// GetPublicKeyHandler - обработчик получения открытого ключа сервера в формате PEM. Ключ в ответе
+// не защищен от подмены, поэтому агент сверяет его отпечаток или подпись ответа.
+func (h *handler) GetPublicKeyHandler(rw http.ResponseWriter, r *http.Request) {
+	log.Printf("Get Public Key")
+
+	data, err := h.k.PEM()
+	if err != nil {
+		log.Error().Err(err).Msg("public key PEM error")
+		http.Error(rw, "public key error", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-pem-file")
+
+	_, err = rw.Write(data)
+	if err != nil {
+		log.Error().Err(err).Msg("public key write error")
+	}
+}
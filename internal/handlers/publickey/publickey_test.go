@@ -0,0 +1,68 @@
+package publickey
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type key struct {
+	err  error
+	data string
+}
+
+func (k *key) PEM() ([]byte, error) {
+	if k.err != nil {
+		return nil, k.err
+	}
+
+	return []byte(k.data), nil
+}
+
+func TestGetPublicKeyHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		k      *key
+		body   string
+		status int
+	}{
+		{
+			name:   "public key success",
+			k:      &key{data: "-----BEGIN PUBLIC KEY-----\n"},
+			status: http.StatusOK,
+			body:   "-----BEGIN PUBLIC KEY-----\n",
+		},
+		{
+			name:   "public key error",
+			k:      &key{err: errors.New("bad key")},
+			status: http.StatusInternalServerError,
+			body:   "public key error\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			BuildRouter(r, NewHandler(test.k))
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			resp, err := http.Get(srv.URL + "/api/v1/public-key")
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.body, string(b))
+		})
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/k0st1a/metrics/internal/pkg/retry"
 	"github.com/k0st1a/metrics/internal/utils"
 	"github.com/rs/zerolog/log"
 )
@@ -28,6 +27,8 @@ const (
 	emptyMetricValue = "metric value is empty"
 	badMetricValue   = "metric value is bad"
 	notFoundMetric   = "metric not found"
+	unavailable      = "storage is unavailable"
+	reservedName     = "metric name is reserved"
 )
 
 // Storage - интерфейс работы с хранилищем метрик.
@@ -42,6 +43,11 @@ type Storage interface {
 	// StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
 	StoreCounter(ctx context.Context, name string, value int64) error
 
+	// DeleteGauge - удаляет метрику типа gauge с именем name.
+	DeleteGauge(ctx context.Context, name string) error
+	// DeleteCounter - удаляет метрику типа counter с именем name.
+	DeleteCounter(ctx context.Context, name string) error
+
 	// StoreAll - сохраняет группу метрик типа counter и gauge.
 	StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
 	// GetAll - возвращает все метрики типа counter и gauge.
@@ -50,7 +56,7 @@ type Storage interface {
 
 // Retryer - интерфейс повторного обращения к хранилищу.
 type Retryer interface {
-	Retry(ctx context.Context, check func(error) bool, fnc func() error) error
+	Retry(ctx context.Context, fnc func() error) error
 }
 
 type handler struct {
@@ -66,14 +72,16 @@ func NewHandler(s Storage, r Retryer) *handler {
 	}
 }
 
-// BuildRouter - формирование маршрута для HTTP обработчика.
-func BuildRouter(r *chi.Mux, h *handler) {
+// BuildRouter - формирование маршрута для HTTP обработчика, где:
+//   - admin - middleware доступа к удалению метрик, например checksign.Require.
+func BuildRouter(r *chi.Mux, h *handler, admin ...func(http.Handler) http.Handler) {
 	r.Post("/update/{type}/{name}/{value}", h.PostMetricHandler)
 	r.Post("/update/counter/", NotFoundHandler)
 	r.Post("/update/gauge/", NotFoundHandler)
 
 	r.Get("/", h.GetAllHandler)
 	r.Get("/value/{type}/{name}", h.GetMetricHandler)
+	r.With(admin...).Delete("/value/{type}/{name}", h.DeleteMetricHandler)
 
 	r.NotFound(BadRequestHandler)
 }
@@ -100,7 +108,7 @@ func (h *handler) GetAllHandler(rw http.ResponseWriter, r *http.Request) {
 		err error
 	)
 
-	err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+	err = h.retry.Retry(r.Context(), func() error {
 		c, g, err = h.storage.GetAll(r.Context())
 		//nolint // Не за чем оборачивать ошибку
 		return err
@@ -167,10 +175,14 @@ func (h *handler) PostMetricHandler(rw http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			//nolint // Не за чем оборачивать ошибку
 			return h.storage.StoreCounter(r.Context(), name, c)
 		})
+		if errors.Is(err, utils.ErrMetricsReservedName) {
+			http.Error(rw, reservedName, http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("add counter error")
 			http.Error(rw, notFoundMetric, http.StatusInternalServerError)
@@ -182,10 +194,14 @@ func (h *handler) PostMetricHandler(rw http.ResponseWriter, r *http.Request) {
 			http.Error(rw, badMetricValue, http.StatusBadRequest)
 			return
 		}
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			//nolint // Не за чем оборачивать ошибку
 			return h.storage.StoreGauge(r.Context(), name, g)
 		})
+		if errors.Is(err, utils.ErrMetricsReservedName) {
+			http.Error(rw, reservedName, http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			log.Error().Err(err).Msg("storage gauge error")
 			http.Error(rw, notFoundMetric, http.StatusInternalServerError)
@@ -197,6 +213,50 @@ func (h *handler) PostMetricHandler(rw http.ResponseWriter, r *http.Request) {
 	rw.WriteHeader(http.StatusOK)
 }
 
+// DeleteMetricHandler - обработчик для удаления метрики. Имя метрики не приводится к нижнему регистру,
+// чтобы можно было удалить метрики, сохраненные через JSON API.
+func (h *handler) DeleteMetricHandler(rw http.ResponseWriter, r *http.Request) {
+	mtype := strings.ToLower(chi.URLParam(r, "type"))
+	if !checkType(mtype) {
+		http.Error(rw, badMetricType, http.StatusBadRequest)
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		http.Error(rw, emptyMetricName, http.StatusNotFound)
+		return
+	}
+
+	err := h.retry.Retry(r.Context(), func() error {
+		if mtype == "counter" {
+			//nolint // Не за чем оборачивать ошибку
+			return h.storage.DeleteCounter(r.Context(), name)
+		}
+		//nolint // Не за чем оборачивать ошибку
+		return h.storage.DeleteGauge(r.Context(), name)
+	})
+
+	switch {
+	case errors.Is(err, utils.ErrMetricsNoCounter), errors.Is(err, utils.ErrMetricsNoGauge):
+		http.Error(rw, notFoundMetric, http.StatusNotFound)
+		return
+	case errors.Is(err, utils.ErrMetricsReservedName):
+		http.Error(rw, reservedName, http.StatusBadRequest)
+		return
+	case errors.Is(err, utils.ErrStorageUnavailable):
+		http.Error(rw, unavailable, http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("delete metric error")
+		http.Error(rw, notFoundMetric, http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+}
+
 // GetMetricHandler - обработчик для получения метрики.
 func (h *handler) GetMetricHandler(rw http.ResponseWriter, r *http.Request) {
 	mtype := strings.ToLower(chi.URLParam(r, "type"))
@@ -219,7 +279,7 @@ func (h *handler) GetMetricHandler(rw http.ResponseWriter, r *http.Request) {
 			c   *int64
 			err error
 		)
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			c, err = h.storage.GetCounter(r.Context(), name)
 			//nolint // Не за чем оборачивать ошибку
 			return err
@@ -229,6 +289,9 @@ func (h *handler) GetMetricHandler(rw http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, utils.ErrMetricsNoCounter):
 			http.Error(rw, notFoundMetric, http.StatusNotFound)
 			return
+		case errors.Is(err, utils.ErrStorageUnavailable):
+			http.Error(rw, unavailable, http.StatusServiceUnavailable)
+			return
 		case err != nil:
 			log.Error().Err(err).Msg("get counter error")
 			http.Error(rw, notFoundMetric, http.StatusInternalServerError)
@@ -246,7 +309,7 @@ func (h *handler) GetMetricHandler(rw http.ResponseWriter, r *http.Request) {
 			g   *float64
 			err error
 		)
-		err = h.retry.Retry(r.Context(), retry.IsConnectionException, func() error {
+		err = h.retry.Retry(r.Context(), func() error {
 			g, err = h.storage.GetGauge(r.Context(), name)
 			//nolint // Не за чем оборачивать ошибку
 			return err
@@ -255,6 +318,9 @@ func (h *handler) GetMetricHandler(rw http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, utils.ErrMetricsNoGauge):
 			http.Error(rw, notFoundMetric, http.StatusNotFound)
 			return
+		case errors.Is(err, utils.ErrStorageUnavailable):
+			http.Error(rw, unavailable, http.StatusServiceUnavailable)
+			return
 		case err != nil:
 			log.Error().Err(err).Msg("get gauge error")
 			http.Error(rw, notFoundMetric, http.StatusInternalServerError)
@@ -1,6 +1,7 @@
 package text
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"github.com/k0st1a/metrics/internal/handlers"
 	"github.com/k0st1a/metrics/internal/pkg/retry"
 	"github.com/k0st1a/metrics/internal/storage/inmemory"
+	"github.com/k0st1a/metrics/internal/utils"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -141,11 +143,39 @@ func TestMetricHandler(t *testing.T) {
 			expectedStatusCode: 200,
 			expectedBody:       "Current metrics in form type/name/value:\ncounter/countername/123\ngauge/gaugename/123.3\n",
 		},
+		{
+			name:               "check delete gauge metric with name gaugename",
+			reqMethod:          http.MethodDelete,
+			reqPath:            "/value/gauge/gaugename",
+			expectedStatusCode: 200,
+			expectedBody:       "",
+		},
+		{
+			name:               "check delete gauge metric with name gaugename which not exists",
+			reqMethod:          http.MethodDelete,
+			reqPath:            "/value/gauge/gaugename",
+			expectedStatusCode: 404,
+			expectedBody:       "metric not found\n",
+		},
+		{
+			name:               "check delete metric with bad type",
+			reqMethod:          http.MethodDelete,
+			reqPath:            "/value/histogram/CounterName",
+			expectedStatusCode: 400,
+			expectedBody:       "metric type is bad\n",
+		},
+		{
+			name:               "check get all metrics after delete",
+			reqMethod:          http.MethodGet,
+			reqPath:            "/",
+			expectedStatusCode: 200,
+			expectedBody:       "Current metrics in form type/name/value:\ncounter/countername/123\n",
+		},
 	}
 
 	r := handlers.NewRouter(nil)
 	s := inmemory.NewStorage()
-	rt := retry.New()
+	rt := retry.New(retry.DefaultPolicy(), nil, nil)
 	th := NewHandler(s, rt)
 
 	BuildRouter(r, th)
@@ -183,3 +213,44 @@ func TestMetricHandler(t *testing.T) {
 		})
 	}
 }
+
+// unavailableStorage - хранилище за открытым предохранителем без последних известных значений.
+type unavailableStorage struct {
+	*inmemory.Storage
+}
+
+func (unavailableStorage) GetGauge(context.Context, string) (*float64, error) {
+	return nil, utils.ErrStorageUnavailable
+}
+
+func (unavailableStorage) GetCounter(context.Context, string) (*int64, error) {
+	return nil, utils.ErrStorageUnavailable
+}
+
+func (unavailableStorage) DeleteGauge(context.Context, string) error {
+	return utils.ErrStorageUnavailable
+}
+
+func (unavailableStorage) DeleteCounter(context.Context, string) error {
+	return utils.ErrStorageUnavailable
+}
+
+func TestMetricHandlerUnavailable(t *testing.T) {
+	r := handlers.NewRouter(nil)
+	BuildRouter(r, NewHandler(unavailableStorage{inmemory.NewStorage()}, retry.New(retry.DefaultPolicy(), nil, nil)))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/value/gauge/GaugeName", nil),
+		httptest.NewRequest(http.MethodGet, "/value/counter/CounterName", nil),
+		httptest.NewRequest(http.MethodDelete, "/value/gauge/GaugeName", nil),
+		httptest.NewRequest(http.MethodDelete, "/value/counter/CounterName", nil),
+	} {
+		t.Run(req.Method+" "+req.URL.Path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+			assert.Equal(t, "storage is unavailable\n", recorder.Body.String())
+		})
+	}
+}
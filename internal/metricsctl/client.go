@@ -0,0 +1,144 @@
+package metricsctl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/middleware/idempotency"
+	"github.com/k0st1a/metrics/internal/models"
+	pkgidempotency "github.com/k0st1a/metrics/internal/pkg/idempotency"
+)
+
+// ErrStatus - сервер ответил кодом, отличным от 200.
+var ErrStatus = errors.New("unexpected response status")
+
+type client struct {
+	client  *http.Client
+	address string
+}
+
+// newClient - создание клиента сервера с адресом address, timeout - время ожидания ответа на каждый запрос.
+func newClient(address string, timeout time.Duration, rt http.RoundTripper) *client {
+	return &client{
+		client:  &http.Client{Transport: rt, Timeout: timeout},
+		address: address,
+	}
+}
+
+// get - получить метрику m.MType с именем m.ID.
+func (c *client) get(ctx context.Context, m models.Metrics) (*models.Metrics, error) {
+	b, err := models.Serialize(&m)
+	if err != nil {
+		return nil, fmt.Errorf("serialize error:%w", err)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/value/", b, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := models.Deserialize(resp)
+	if err != nil {
+		return nil, fmt.Errorf("deserialize error:%w", err)
+	}
+
+	return v, nil
+}
+
+// set - сохранить метрику m.
+func (c *client) set(ctx context.Context, m models.Metrics) error {
+	b, err := models.Serialize(&m)
+	if err != nil {
+		return fmt.Errorf("serialize error:%w", err)
+	}
+
+	_, err = c.do(ctx, http.MethodPost, "/update/", b, nil)
+	return err
+}
+
+// list - получить все метрики сервера.
+func (c *client) list(ctx context.Context) ([]models.Metrics, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/values/", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ml, err := models.DeserializeList(resp)
+	if err != nil {
+		return nil, fmt.Errorf("deserialize error:%w", err)
+	}
+
+	return ml, nil
+}
+
+// delete - удалить метрику типа mtype с именем name.
+func (c *client) delete(ctx context.Context, mtype, name string) error {
+	_, err := c.do(ctx, http.MethodDelete, "/value/"+url.PathEscape(mtype)+"/"+url.PathEscape(name), nil, nil)
+	return err
+}
+
+// update - сохранить группу метрик одним пакетом: либо сохраняются все метрики, либо ни одна.
+// Пакет отправляется с ключом идемпотентности, поэтому повтор команды после потерянного ответа
+// не учтет счетчики дважды, пока ключ хранится на сервере.
+func (c *client) update(ctx context.Context, ml []models.Metrics) error {
+	b, err := models.SerializeList(ml)
+	if err != nil {
+		return fmt.Errorf("serialize error:%w", err)
+	}
+
+	key, err := pkgidempotency.NewKey()
+	if err != nil {
+		return fmt.Errorf("new idempotency key error:%w", err)
+	}
+
+	_, err = c.do(ctx, http.MethodPost, "/updates/?mode=atomic", b, map[string]string{idempotency.Header: key})
+	return err
+}
+
+// ping - проверить доступность хранилища сервера. Используется /readyz, а не /ping: готовность проверяется
+// для любого хранилища, а /ping - только для БД.
+func (c *client) ping(ctx context.Context) error {
+	_, err := c.do(ctx, http.MethodGet, "/readyz", nil, nil)
+	return err
+}
+
+func (c *client) do(ctx context.Context, method, path string, body []byte, headers map[string]string) ([]byte, error) {
+	u := "http://" + c.address + path
+
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new request error:%w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client do error:%w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("response read error:%w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w:%v %s", ErrStatus, resp.StatusCode, bytes.TrimSpace(b))
+	}
+
+	return b, nil
+}
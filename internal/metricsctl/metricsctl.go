@@ -0,0 +1,441 @@
+// Package metricsctl - утилита командной строки для работы с сервером метрик: чтение, запись, удаление,
+// выгрузка и загрузка метрик.
+//
+// Утилита подписывает и шифрует запросы так же, как агент, поэтому работает с сервером, который
+// требует подпись или шифрование.
+package metricsctl
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/middleware/encrypt"
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/middleware/sign"
+	"github.com/k0st1a/metrics/internal/models"
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+)
+
+// Usage - справка по командам утилиты.
+const Usage = `usage: metricsctl [-a=<ADDRESS>] [-k=<KEY>] [-key-id=<ID>] [-crypto-key=<PATH>]
+                  [-timeout=<DURATION>] <command> [args]
+
+commands:
+  get <type> <name>            вывести значение метрики
+  set <type> <name> <value>    сохранить метрику, значение counter прибавляется к текущему
+  list [filters]               вывести метрики
+  watch [-interval=<DURATION>] [-count=<N>] [filters]
+                               выводить изменения метрик до прерывания или N опросов
+  delete <type> <name>         удалить метрику
+  export [-o=<PATH>]           выгрузить метрики в формате JSON
+  import [-i=<PATH>]           загрузить метрики в формате JSON одним пакетом,
+                               значения counter прибавляются к текущим
+  ping                         проверить доступность хранилища сервера
+
+filters:
+  -type=<counter|gauge>        только метрики заданного типа
+  -prefix=<PREFIX>             только метрики с именем, начинающимся с PREFIX
+  -match=<REGEXP>              только метрики с именем, подходящим под REGEXP`
+
+const (
+	defaultServerAddr    = "localhost:8080"
+	defaultTimeout       = 5 * time.Second
+	defaultWatchInterval = 2 * time.Second
+)
+
+var (
+	// ErrUsage - неверные аргументы командной строки.
+	ErrUsage   = errors.New("usage: metricsctl [flags] get|set|list|watch|delete|export|import|ping [args]")
+	errBadType = errors.New("metric type is bad")
+)
+
+// Run - выполнение команды утилиты, где:
+//   - ctx - контекст, при отмене которого прерывается команда watch;
+//   - args - аргументы командной строки без имени утилиты;
+//   - in - источник метрик команды import, если не задан файл;
+//   - out - вывод результата команды.
+//
+// Адрес сервера, ключ подписи, его идентификатор и путь до открытого ключа задаются флагами `-a`, `-k`,
+// `-key-id` и `-crypto-key` или переменными окружения `ADDRESS`, `KEY`, `KEY_ID` и `CRYPTO_KEY`, как и для агента.
+func Run(ctx context.Context, args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("metricsctl", flag.ContinueOnError)
+	addr := fs.String("a", defaultServerAddr, "Адрес сервера. Соответствует переменной окружения ADDRESS")
+	key := fs.String("k", "", "Ключ подписи запросов по алгоритму SHA256. Соответствует переменной окружения KEY")
+	keyID := fs.String("key-id", "", "Идентификатор ключа подписи. Соответствует переменной окружения KEY_ID")
+	cryptoKey := fs.String("crypto-key", "",
+		"Путь до файла с открытым ключом для шифрования запросов. Соответствует переменной окружения CRYPTO_KEY")
+	timeout := fs.Duration("timeout", defaultTimeout, "Время ожидания ответа сервера на каждый запрос")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w:%w", ErrUsage, err)
+	}
+
+	if v, ok := os.LookupEnv("ADDRESS"); ok {
+		*addr = v
+	}
+	if v, ok := os.LookupEnv("KEY"); ok {
+		*key = v
+	}
+	if v, ok := os.LookupEnv("KEY_ID"); ok {
+		*keyID = v
+	}
+	if v, ok := os.LookupEnv("CRYPTO_KEY"); ok {
+		*cryptoKey = v
+	}
+
+	if fs.NArg() == 0 {
+		return ErrUsage
+	}
+
+	var middlewares []roundtrip.Middleware
+
+	if *key != "" {
+		middlewares = append(middlewares, sign.New(hash.NewWithID(*keyID, *key)))
+	}
+
+	if *cryptoKey != "" {
+		pbl, err := rsa.NewPublicFromFile(*cryptoKey)
+		if err != nil {
+			return fmt.Errorf("rsa new public from file error:%w", err)
+		}
+
+		middlewares = append(middlewares, encrypt.New(pbl))
+	}
+
+	c := newClient(*addr, *timeout, roundtrip.New(http.DefaultTransport, middlewares...))
+
+	cmd, cargs := fs.Arg(0), fs.Args()[1:]
+
+	switch cmd {
+	case "get":
+		err = runGet(ctx, c, cargs, out)
+	case "set":
+		err = runSet(ctx, c, cargs)
+	case "list":
+		err = runList(ctx, c, cargs, out)
+	case "watch":
+		err = runWatch(ctx, c, cargs, out)
+	case "delete":
+		err = runDelete(ctx, c, cargs)
+	case "export":
+		err = runExport(ctx, c, cargs, out)
+	case "import":
+		err = runImport(ctx, c, cargs, in)
+	case "ping":
+		err = runPing(ctx, c, cargs, out)
+	default:
+		return ErrUsage
+	}
+	if err != nil {
+		return fmt.Errorf("%v error:%w", cmd, err)
+	}
+
+	return nil
+}
+
+func runGet(ctx context.Context, c *client, args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return ErrUsage
+	}
+
+	m, err := c.get(ctx, models.Metrics{MType: args[0], ID: args[1]})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, value(*m))
+	return err //nolint // Не за чем оборачивать ошибку
+}
+
+func runSet(ctx context.Context, c *client, args []string) error {
+	if len(args) != 3 {
+		return ErrUsage
+	}
+
+	m := models.Metrics{MType: args[0], ID: args[1]}
+
+	switch m.MType {
+	case "counter":
+		d, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("counter value parse error:%w", err)
+		}
+		m.Delta = &d
+	case "gauge":
+		v, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return fmt.Errorf("gauge value parse error:%w", err)
+		}
+		m.Value = &v
+	default:
+		return fmt.Errorf("%w:%v", errBadType, m.MType)
+	}
+
+	return c.set(ctx, m)
+}
+
+func runList(ctx context.Context, c *client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	f := addFilterFlags(fs)
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	keep, err := f.build()
+	if err != nil {
+		return err
+	}
+
+	ml, err := c.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "TYPE\tNAME\tVALUE")
+	for _, m := range ml {
+		if keep(m) {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", m.MType, m.ID, value(m))
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("list write error:%w", err)
+	}
+
+	return nil
+}
+
+// runWatch - опрашивает сервер раз в interval и выводит метрики, значения которых изменились с прошлого
+// опроса, в формате `<время> <тип> <имя> <значение>`, у удаленных метрик вместо значения выводится `-`.
+func runWatch(ctx context.Context, c *client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", defaultWatchInterval, "Интервал опроса сервера")
+	count := fs.Int("count", 0, "Число опросов сервера, значение 0 снимает ограничение")
+	f := addFilterFlags(fs)
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	keep, err := f.build()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	last := make(map[string]string)
+
+	for n := 1; ; n++ {
+		ml, err := c.list(ctx)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		seen := make(map[string]bool, len(ml))
+
+		for _, m := range ml {
+			if !keep(m) {
+				continue
+			}
+
+			k, v := m.MType+" "+m.ID, value(m)
+			seen[k] = true
+			if last[k] != v {
+				last[k] = v
+				fmt.Fprintf(out, "%v %v %v\n", now, k, v)
+			}
+		}
+
+		deleted := make([]string, 0)
+		for k := range last {
+			if !seen[k] {
+				deleted = append(deleted, k)
+			}
+		}
+		sort.Strings(deleted)
+		for _, k := range deleted {
+			delete(last, k)
+			fmt.Fprintf(out, "%v %v -\n", now, k)
+		}
+
+		if *count > 0 && n >= *count {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func runDelete(ctx context.Context, c *client, args []string) error {
+	if len(args) != 2 {
+		return ErrUsage
+	}
+
+	return c.delete(ctx, args[0], args[1])
+}
+
+func runExport(ctx context.Context, c *client, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	path := fs.String("o", "", "Путь до файла, куда выгружаются метрики, по умолчанию - стандартный вывод")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ml, err := c.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	b, err := models.SerializeList(ml)
+	if err != nil {
+		return fmt.Errorf("serialize error:%w", err)
+	}
+	b = append(b, '\n')
+
+	if *path != "" {
+		err = os.WriteFile(*path, b, 0600)
+		if err != nil {
+			return fmt.Errorf("write file(%s) error:%w", *path, err)
+		}
+
+		return nil
+	}
+
+	_, err = out.Write(b)
+	return err //nolint // Не за чем оборачивать ошибку
+}
+
+func runImport(ctx context.Context, c *client, args []string, in io.Reader) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	path := fs.String("i", "", "Путь до файла, откуда загружаются метрики, по умолчанию - стандартный ввод")
+
+	err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var b []byte
+	if *path != "" {
+		b, err = os.ReadFile(*path)
+	} else {
+		b, err = io.ReadAll(in)
+	}
+	if err != nil {
+		return fmt.Errorf("read metrics error:%w", err)
+	}
+
+	ml, err := models.DeserializeList(b)
+	if err != nil {
+		return fmt.Errorf("deserialize error:%w", err)
+	}
+
+	if len(ml) == 0 {
+		return nil
+	}
+
+	return c.update(ctx, ml)
+}
+
+func runPing(ctx context.Context, c *client, args []string, out io.Writer) error {
+	if len(args) != 0 {
+		return ErrUsage
+	}
+
+	err := c.ping(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, "ok")
+	return err //nolint // Не за чем оборачивать ошибку
+}
+
+// parse - разбор флагов команды, лишние аргументы считаются ошибкой.
+func parse(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w:%w", ErrUsage, err)
+	}
+
+	if fs.NArg() != 0 {
+		return ErrUsage
+	}
+
+	return nil
+}
+
+type filterFlags struct {
+	mtype  *string
+	prefix *string
+	match  *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		mtype:  fs.String("type", "", "Только метрики заданного типа: counter или gauge"),
+		prefix: fs.String("prefix", "", "Только метрики с именем, начинающимся с заданной строки"),
+		match:  fs.String("match", "", "Только метрики с именем, подходящим под регулярное выражение"),
+	}
+}
+
+// build - функция отбора метрик по заданным фильтрам.
+func (f *filterFlags) build() (func(models.Metrics) bool, error) {
+	if *f.mtype != "" && *f.mtype != "counter" && *f.mtype != "gauge" {
+		return nil, fmt.Errorf("%w:%v", errBadType, *f.mtype)
+	}
+
+	var re *regexp.Regexp
+	if *f.match != "" {
+		var err error
+		re, err = regexp.Compile(*f.match)
+		if err != nil {
+			return nil, fmt.Errorf("match compile error:%w", err)
+		}
+	}
+
+	return func(m models.Metrics) bool {
+		return (*f.mtype == "" || m.MType == *f.mtype) &&
+			strings.HasPrefix(m.ID, *f.prefix) &&
+			(re == nil || re.MatchString(m.ID))
+	}, nil
+}
+
+// value - значение метрики в том же виде, в котором его отдает сервер.
+func value(m models.Metrics) string {
+	switch {
+	case m.Delta != nil:
+		return strconv.FormatInt(*m.Delta, 10)
+	case m.Value != nil:
+		return strconv.FormatFloat(*m.Value, 'f', -1, 64)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,177 @@
+package metricsctl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/k0st1a/metrics/internal/handlers"
+	"github.com/k0st1a/metrics/internal/handlers/health"
+	"github.com/k0st1a/metrics/internal/handlers/json"
+	"github.com/k0st1a/metrics/internal/handlers/text"
+	"github.com/k0st1a/metrics/internal/middleware/checksign"
+	"github.com/k0st1a/metrics/internal/middleware/decrypt"
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/k0st1a/metrics/internal/pkg/retry"
+	"github.com/k0st1a/metrics/internal/storage/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "secret"
+
+// newTestServer - сервер, который, как и настоящий, проверяет подпись и расшифровывает запросы.
+// Хранилище в RAM, как у сервера по умолчанию, поэтому проверок готовности у него нет.
+func newTestServer(t *testing.T) string {
+	t.Helper()
+
+	prv, err := rsa.NewPrivateFromFile("../middleware/decrypt/private.pem")
+	require.NoError(t, err)
+
+	r := handlers.NewRouter([]func(http.Handler) http.Handler{
+		checksign.New(hash.New(testKey)),
+		decrypt.New(prv),
+	})
+
+	s := inmemory.NewStorage()
+	rt := retry.New(retry.DefaultPolicy(), nil, nil)
+	text.BuildRouter(r, text.NewHandler(s, rt))
+	json.BuildRouter(r, json.NewHandler(s, rt))
+	health.BuildRouter(r, health.NewHandler(nil))
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestRun(t *testing.T) {
+	addr := newTestServer(t)
+	export := filepath.Join(t.TempDir(), "metrics.json")
+
+	flags := []string{"-a", addr, "-k", testKey, "-crypto-key", "../middleware/encrypt/public.pem"}
+
+	tests := []struct {
+		name string
+		args []string
+		in   string
+		out  string
+		err  bool
+	}{
+		{name: "ping", args: []string{"ping"}, out: "ok\n"},
+		{name: "set counter", args: []string{"set", "counter", "PollCount", "2"}},
+		{name: "add counter", args: []string{"set", "counter", "PollCount", "3"}},
+		{name: "set gauge", args: []string{"set", "gauge", "HeapAlloc", "1.5"}},
+		{name: "set other gauge", args: []string{"set", "gauge", "Alloc", "7"}},
+		{name: "set bad type", args: []string{"set", "histogram", "Alloc", "7"}, err: true},
+		{name: "set bad value", args: []string{"set", "counter", "PollCount", "1.5"}, err: true},
+		{name: "get counter", args: []string{"get", "counter", "PollCount"}, out: "5\n"},
+		{name: "get unknown", args: []string{"get", "gauge", "Unknown"}, err: true},
+		{
+			name: "list",
+			args: []string{"list"},
+			out: "" +
+				"TYPE     NAME       VALUE\n" +
+				"counter  PollCount  5\n" +
+				"gauge    Alloc      7\n" +
+				"gauge    HeapAlloc  1.5\n",
+		},
+		{
+			name: "list with filters",
+			args: []string{"list", "-type", "gauge", "-prefix", "Heap", "-match", "Alloc$"},
+			out: "" +
+				"TYPE   NAME       VALUE\n" +
+				"gauge  HeapAlloc  1.5\n",
+		},
+		{name: "list bad type filter", args: []string{"list", "-type", "histogram"}, err: true},
+		{name: "export", args: []string{"export", "-o", export}},
+		{name: "delete", args: []string{"delete", "gauge", "Alloc"}},
+		{name: "delete unknown", args: []string{"delete", "gauge", "Alloc"}, err: true},
+		{
+			name: "import",
+			args: []string{"import"},
+			in:   `[{"id":"PollCount","type":"counter","delta":1},{"id":"Alloc","type":"gauge","value":8}]`,
+		},
+		{
+			name: "list after import",
+			args: []string{"list", "-match", "^(Alloc|PollCount)$"},
+			out: "" +
+				"TYPE     NAME       VALUE\n" +
+				"counter  PollCount  6\n" +
+				"gauge    Alloc      8\n",
+		},
+		{
+			name: "watch",
+			args: []string{"watch", "-count", "1", "-type", "counter"},
+			out:  "counter PollCount 6\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := Run(context.Background(), append(flags, test.args...), strings.NewReader(test.in), &out)
+			if test.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if test.name == "watch" {
+				// Вывод начинается со времени опроса.
+				_, line, _ := strings.Cut(out.String(), " ")
+				assert.Equal(t, test.out, line)
+				return
+			}
+			assert.Equal(t, test.out, out.String())
+		})
+	}
+
+	b, err := os.ReadFile(export)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"delta":5,"id":"PollCount","type":"counter"},`+
+		`{"value":7,"id":"Alloc","type":"gauge"},{"value":1.5,"id":"HeapAlloc","type":"gauge"}]`+"\n", string(b))
+}
+
+func TestRunPingNotReady(t *testing.T) {
+	r := handlers.NewRouter(nil)
+	health.BuildRouter(r, health.NewHandler([]health.Check{{
+		Name:  "db",
+		Check: func(context.Context) error { return errors.New("db is down") },
+	}}))
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	err := Run(context.Background(), []string{"-a", strings.TrimPrefix(srv.URL, "http://"), "ping"},
+		strings.NewReader(""), &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrStatus)
+}
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: []string{}},
+		{name: "unknown command", args: []string{"sideways"}},
+		{name: "unknown flag", args: []string{"-x", "ping"}},
+		{name: "get without name", args: []string{"get", "counter"}},
+		{name: "list with extra args", args: []string{"list", "PollCount"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Run(context.Background(), test.args, strings.NewReader(""), &bytes.Buffer{})
+			assert.ErrorIs(t, err, ErrUsage)
+		})
+	}
+}
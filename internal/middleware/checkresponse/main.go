@@ -0,0 +1,67 @@
+// Package checkresponse для проверки подписи HashSHA256 ответов сервера на стороне HTTP-клиента.
+package checkresponse
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+)
+
+var (
+	// ErrUnsigned - ответ сервера без подписи.
+	ErrUnsigned = errors.New("response is not signed")
+	// ErrWrongSignature - подпись ответа сервера не совпала с вычисленной, ответ изменен по дороге.
+	ErrWrongSignature = errors.New("wrong response signature")
+)
+
+// Checker - интерфейс проверки подписи данных.
+type Checker interface {
+	Check(data []byte, sign []byte) (equal bool)
+}
+
+// New - проверка подписи тела ответа, ответы без подписи или с неверной подписью отклоняются с ошибкой.
+// Тело проверяется после распаковки, поэтому middleware ставится ближе всего к транспорту.
+func New(c Checker) roundtrip.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundtrip.HandlerFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(r)
+			if err != nil {
+				//nolint:wrapcheck //no need here
+				return resp, err
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, fmt.Errorf("body read error while check response:%w", err)
+			}
+
+			err = resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("body close error while check response:%w", err)
+			}
+
+			sign := resp.Header.Get("HashSHA256")
+			if sign == "" {
+				return nil, fmt.Errorf("%w:status %v", ErrUnsigned, resp.StatusCode)
+			}
+
+			ds, err := hex.DecodeString(sign)
+			if err != nil {
+				return nil, fmt.Errorf("hash decode error while check response:%w", err)
+			}
+
+			if !c.Check(body, ds) {
+				return nil, fmt.Errorf("%w:status %v", ErrWrongSignature, resp.StatusCode)
+			}
+
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package checkresponse
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// responder - транспорт, который отвечает телом body с подписью sign.
+type responder struct {
+	body string
+	sign string
+}
+
+func (s responder) RoundTrip(*http.Request) (*http.Response, error) {
+	h := http.Header{}
+	if s.sign != "" {
+		h.Set("HashSHA256", s.sign)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     h,
+		Body:       io.NopCloser(bytes.NewBufferString(s.body)),
+	}, nil
+}
+
+func TestCheckResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		sign string
+		err  error
+	}{
+		{
+			name: "Подпись ответа верная",
+			body: "подписываемые данные",
+			sign: "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+		},
+		{
+			name: "Ответ изменен",
+			body: "измененные данные",
+			sign: "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+			err:  ErrWrongSignature,
+		},
+		{
+			name: "Ответ без подписи",
+			body: "подписываемые данные",
+			err:  ErrUnsigned,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rt := roundtrip.New(responder{body: test.body, sign: test.sign}, New(hash.New("some key")))
+
+			req, err := http.NewRequest(http.MethodPost, "http://localhost/", nil)
+			require.NoError(t, err)
+
+			resp, err := rt.RoundTrip(req)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, test.body, string(b))
+		})
+	}
+}
@@ -6,20 +6,76 @@ import (
 	"encoding/hex"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/k0st1a/metrics/internal/pkg/hash"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// minNonceLen и maxNonceLen - границы длины одноразового значения в шестнадцатеричном виде.
+	minNonceLen = 16
+	maxNonceLen = 128
+)
+
 // Checker - интерфейс проверки подписи данных.
 type Checker interface {
 	Check(data []byte, sign []byte) (equal bool)
 }
 
+// IDChecker - интерфейс проверки подписи данных ключом с идентификатором id из HTTP-заголовка HashKeyID.
+// Если Checker его не реализует, то заголовок не учитывается.
+type IDChecker interface {
+	CheckID(id string, data []byte, sign []byte) (equal bool)
+}
+
+// KeyHolder - интерфейс Checker, сообщающий, заданы ли ключи подписи. Если ключи заданы, то запросы без
+// подписи отклоняются, иначе подпись перехваченного запроса можно просто убрать из заголовков.
+// Если Checker его не реализует, то запросы без подписи пропускаются.
+type KeyHolder interface {
+	HasKeys() bool
+}
+
+// NonceCache - интерфейс кеша одноразовых значений уже принятых запросов.
+type NonceCache interface {
+	// Seen - было ли значение nonce уже получено, если нет, то оно запоминается до момента until.
+	// Ошибка означает, что значение запомнить нельзя, и запрос отклоняется.
+	Seen(nonce string, until time.Time) (bool, error)
+}
+
+// Options - параметры защиты от повторной отправки подписанных запросов.
+type Options struct {
+	// MaxSkew - допустимое расхождение времени из HTTP-заголовка HashTimestamp со временем сервера.
+	// При `0` защита выключена и принимаются подписи только тела запроса от агентов прежних версий.
+	MaxSkew time.Duration
+	// Nonces - кеш одноразовых значений из HTTP-заголовка HashNonce, значение хранится MaxSkew после
+	// времени запроса, а более старые запросы отклоняются по времени. Кеш у каждого сервера свой.
+	Nonces NonceCache
+	// Public - пути, запросы к которым принимаются без подписи, например проверки состояния сервера.
+	Public []string
+}
+
+// New - проверка подписи запросов без защиты от повторной отправки.
 func New(h Checker) func(next http.Handler) http.Handler {
+	return NewWithReplay(h, Options{})
+}
+
+// NewWithReplay - проверка подписи запросов с защитой от повторной отправки по параметрам o. Подписанные
+// запросы без времени и одноразового значения, со временем вне окна или с уже полученным одноразовым
+// значением отклоняются. Если h реализует KeyHolder и ключи заданы, то отклоняются и запросы без подписи,
+// кроме запросов к путям o.Public.
+func NewWithReplay(h Checker, o Options) func(next http.Handler) http.Handler {
 	// Подсмотрел в https://github.com/go-chi/chi/blob/master/middleware/content_type.go
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 			sign := r.Header.Get("HashSHA256")
+			if sign == "" && required(h) && !public(o, r.URL.Path) {
+				log.Error().Str("uri", r.RequestURI).Msg("request without signature")
+				http.Error(rw, "signature is required", http.StatusBadRequest)
+				return
+			}
+
 			if sign != "" {
 				ds, err := hex.DecodeString(sign)
 				if err != nil {
@@ -40,12 +96,37 @@ func New(h Checker) func(next http.Handler) http.Handler {
 					log.Error().Err(err).Msg("body close error while checksign")
 				}
 
-				if !h.Check(b, ds) {
+				ts := r.Header.Get("HashTimestamp")
+				nonce := r.Header.Get("HashNonce")
+				stamped := ts != "" || nonce != ""
+
+				var sec int64
+				data := b
+				if stamped {
+					sec, err = strconv.ParseInt(ts, 10, 64)
+					if err != nil || !validNonce(nonce) {
+						log.Error().Err(err).Msg("bad timestamp or nonce while checksign")
+						http.Error(rw, "bad timestamp or nonce", http.StatusBadRequest)
+						return
+					}
+
+					data = hash.Stamp(r.Method, r.RequestURI, ts, nonce, b)
+				} else if o.MaxSkew != 0 {
+					log.Error().Msg("request without timestamp and nonce")
+					http.Error(rw, "timestamp and nonce are required", http.StatusBadRequest)
+					return
+				}
+
+				if !check(h, r.Header.Get("HashKeyID"), data, ds) {
 					log.Error().Err(err).Msg("wrong signature")
 					http.Error(rw, "wrong signature", http.StatusBadRequest)
 					return
 				}
 
+				if stamped && o.MaxSkew != 0 && !fresh(rw, o, time.Unix(sec, 0), nonce) {
+					return
+				}
+
 				// Восстанавливаем тело запроса нашел на stackoverflow:
 				// https://stackoverflow.com/questions/46948050/how-to-read-request-body-twice-in-golang-middleware
 				r.Body = io.NopCloser(bytes.NewBuffer(b))
@@ -54,3 +135,91 @@ func New(h Checker) func(next http.Handler) http.Handler {
 		})
 	}
 }
+
+// Require - доступ к маршруту только с подписью запроса, например для удаления и выгрузки метрик. Подпись
+// проверяется NewWithReplay, поэтому middleware ставится на маршрут после него. Если ключи подписи на сервере
+// не заданы, то маршрут недоступен.
+func Require(h KeyHolder) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !h.HasKeys() {
+				log.Error().Str("uri", r.RequestURI).Msg("signing key is not configured for protected route")
+				http.Error(rw, "signing key is not configured", http.StatusForbidden)
+				return
+			}
+
+			if r.Header.Get("HashSHA256") == "" {
+				log.Error().Str("uri", r.RequestURI).Msg("request without signature to protected route")
+				http.Error(rw, "signature is required", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+func required(h Checker) bool {
+	kh, ok := h.(KeyHolder)
+	return ok && kh.HasKeys()
+}
+
+func public(o Options, path string) bool {
+	for _, p := range o.Public {
+		if p == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+func check(h Checker, id string, data []byte, sign []byte) bool {
+	if ic, ok := h.(IDChecker); ok && id != "" {
+		return ic.CheckID(id, data, sign)
+	}
+
+	return h.Check(data, sign)
+}
+
+// fresh - время t запроса в окне o.MaxSkew, а одноразовое значение nonce получено впервые. Иначе клиенту
+// отправляется ошибка.
+func fresh(rw http.ResponseWriter, o Options, t time.Time, nonce string) bool {
+	now := time.Now()
+	if t.Before(now.Add(-o.MaxSkew)) || t.After(now.Add(o.MaxSkew)) {
+		log.Error().Time("timestamp", t).Msg("request timestamp is out of window")
+		http.Error(rw, "request timestamp is out of window", http.StatusBadRequest)
+		return false
+	}
+
+	if o.Nonces == nil {
+		return true
+	}
+
+	seen, err := o.Nonces.Seen(nonce, t.Add(o.MaxSkew))
+	if err != nil {
+		// Агент повторит запрос, когда истечет срок уже запомненных значений.
+		log.Error().Err(err).Msg("nonce remember error")
+		http.Error(rw, "nonce remember error", http.StatusServiceUnavailable)
+		return false
+	}
+
+	if seen {
+		log.Error().Str("nonce", nonce).Msg("replayed request")
+		http.Error(rw, "replayed request", http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// validNonce - одноразовое значение в шестнадцатеричном виде допустимой длины, поэтому в подписываемых
+// данных его нельзя спутать с телом запроса.
+func validNonce(nonce string) bool {
+	if len(nonce) < minNonceLen || len(nonce) > maxNonceLen {
+		return false
+	}
+
+	_, err := hex.DecodeString(nonce)
+	return err == nil
+}
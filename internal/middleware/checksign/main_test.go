@@ -2,14 +2,18 @@ package checksign
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/k0st1a/metrics/internal/pkg/nonce"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +29,8 @@ func TestCheckSignature(t *testing.T) {
 	tests := []struct {
 		name     string
 		key      string
+		keyID    string
+		keyring  bool
 		sign     string
 		body     io.Reader
 		want     int
@@ -61,13 +67,45 @@ func TestCheckSignature(t *testing.T) {
 			want:     200,
 			wantBody: "",
 		},
+		{
+			name:     "Подпись прежним ключом с идентификатором",
+			key:      "some key",
+			keyID:    "v1",
+			keyring:  true,
+			sign:     "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+			body:     bytes.NewBuffer([]byte("подписываемые данные")),
+			want:     200,
+			wantBody: "",
+		},
+		{
+			name:     "Подпись ключом с другим идентификатором",
+			key:      "some key",
+			keyID:    "v2",
+			keyring:  true,
+			sign:     "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+			body:     bytes.NewBuffer([]byte("подписываемые данные")),
+			want:     400,
+			wantBody: "wrong signature\n",
+		},
+		{
+			name:     "Подпись прежним ключом без идентификатора",
+			key:      "some key",
+			keyring:  true,
+			sign:     "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+			body:     bytes.NewBuffer([]byte("подписываемые данные")),
+			want:     200,
+			wantBody: "",
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			recorder := httptest.NewRecorder()
 
-			h := hash.New(test.key)
+			var h Checker = hash.New(test.key)
+			if test.keyring {
+				h = hash.NewKeyring([]hash.Key{{ID: "v2", Secret: "new key"}, {ID: "v1", Secret: test.key}}, 0)
+			}
 
 			r := chi.NewRouter()
 			r.Use(New(h))
@@ -75,6 +113,7 @@ func TestCheckSignature(t *testing.T) {
 
 			req := httptest.NewRequest(http.MethodPost, "/", test.body)
 			req.Header.Set("HashSHA256", test.sign)
+			req.Header.Set("HashKeyID", test.keyID)
 
 			r.ServeHTTP(recorder, req)
 			res := recorder.Result()
@@ -91,3 +130,272 @@ func TestCheckSignature(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckSignatureReplay(t *testing.T) {
+	h := hash.New("some key")
+	body := []byte("подписываемые данные")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	stamp := func(ts, nonce string) string {
+		return hex.EncodeToString(h.Sign(hash.Stamp(http.MethodPost, "/", ts, nonce, body)))
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		ts       string
+		nonce    string
+		sign     string
+		seen     bool
+		fill     string
+		want     int
+		wantBody string
+	}{
+		{
+			name:  "Подпись со временем и одноразовым значением",
+			opts:  Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10)},
+			ts:    now,
+			nonce: "00112233445566778899aabbccddeeff",
+			sign:  stamp(now, "00112233445566778899aabbccddeeff"),
+			want:  http.StatusOK,
+		},
+		{
+			name:     "Повторная отправка запроса",
+			opts:     Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10)},
+			ts:       now,
+			nonce:    "00112233445566778899aabbccddeeff",
+			sign:     stamp(now, "00112233445566778899aabbccddeeff"),
+			seen:     true,
+			want:     http.StatusBadRequest,
+			wantBody: "replayed request\n",
+		},
+		{
+			name:     "Кеш одноразовых значений заполнен",
+			opts:     Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(1)},
+			ts:       now,
+			nonce:    "00112233445566778899aabbccddeeff",
+			sign:     stamp(now, "00112233445566778899aabbccddeeff"),
+			fill:     "ffeeddccbbaa99887766554433221100",
+			want:     http.StatusServiceUnavailable,
+			wantBody: "nonce remember error\n",
+		},
+		{
+			name:     "Время запроса вне окна",
+			opts:     Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10)},
+			ts:       old,
+			nonce:    "00112233445566778899aabbccddeeff",
+			sign:     stamp(old, "00112233445566778899aabbccddeeff"),
+			want:     http.StatusBadRequest,
+			wantBody: "request timestamp is out of window\n",
+		},
+		{
+			name:     "Подменено время запроса",
+			opts:     Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10)},
+			ts:       now,
+			nonce:    "00112233445566778899aabbccddeeff",
+			sign:     stamp(old, "00112233445566778899aabbccddeeff"),
+			want:     http.StatusBadRequest,
+			wantBody: "wrong signature\n",
+		},
+		{
+			name:     "Подпись только тела запроса",
+			opts:     Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10)},
+			sign:     hex.EncodeToString(h.Sign(body)),
+			want:     http.StatusBadRequest,
+			wantBody: "timestamp and nonce are required\n",
+		},
+		{
+			name: "Подпись только тела запроса без защиты",
+			sign: hex.EncodeToString(h.Sign(body)),
+			want: http.StatusOK,
+		},
+		{
+			name:  "Подпись со старым временем без защиты",
+			ts:    old,
+			nonce: "00112233445566778899aabbccddeeff",
+			sign:  stamp(old, "00112233445566778899aabbccddeeff"),
+			want:  http.StatusOK,
+		},
+		{
+			name:     "Одноразовое значение не в шестнадцатеричном виде",
+			opts:     Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10)},
+			ts:       now,
+			nonce:    "not hex nonce value",
+			sign:     stamp(now, "not hex nonce value"),
+			want:     http.StatusBadRequest,
+			wantBody: "bad timestamp or nonce\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.seen {
+				_, err := test.opts.Nonces.Seen(test.nonce, time.Now().Add(time.Minute))
+				require.NoError(t, err)
+			}
+
+			if test.fill != "" {
+				_, err := test.opts.Nonces.Seen(test.fill, time.Now().Add(time.Minute))
+				require.NoError(t, err)
+			}
+
+			r := chi.NewRouter()
+			r.Use(NewWithReplay(h, test.opts))
+			r.Post("/", func(w http.ResponseWriter, r *http.Request) {})
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+			req.Header.Set("HashSHA256", test.sign)
+			req.Header.Set("HashTimestamp", test.ts)
+			req.Header.Set("HashNonce", test.nonce)
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			res := recorder.Result()
+
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, test.want, res.StatusCode)
+			assert.Equal(t, test.wantBody, string(b))
+		})
+	}
+}
+
+// keyHolder - ключи подписи заданы, как у сервера с ключами.
+type keyHolder struct {
+	Checker
+}
+
+func (keyHolder) HasKeys() bool {
+	return true
+}
+
+func TestCheckSignatureRequired(t *testing.T) {
+	k := hash.New("some key")
+	h := keyHolder{k}
+	body := []byte("подписываемые данные")
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	n := "00112233445566778899aabbccddeeff"
+	n2 := "ffeeddccbbaa99887766554433221100"
+	opts := Options{MaxSkew: time.Minute, Nonces: nonce.NewCache(10), Public: []string{"/healthz"}}
+
+	r := chi.NewRouter()
+	r.Use(NewWithReplay(h, opts))
+	r.Post("/updates/", func(w http.ResponseWriter, r *http.Request) {})
+	r.Delete("/value/{type}/{name}", func(w http.ResponseWriter, r *http.Request) {})
+	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {})
+
+	send := func(method, path string, header http.Header) (int, string) {
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(body))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		recorder := httptest.NewRecorder()
+		r.ServeHTTP(recorder, req)
+		res := recorder.Result()
+
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		return res.StatusCode, string(b)
+	}
+
+	captured := http.Header{}
+	captured.Set("HashSHA256", hex.EncodeToString(k.Sign(hash.Stamp(http.MethodPost, "/updates/", ts, n, body))))
+	captured.Set("HashTimestamp", ts)
+	captured.Set("HashNonce", n)
+
+	code, _ := send(http.MethodPost, "/updates/", captured)
+	assert.Equal(t, http.StatusOK, code)
+
+	// Перехваченный запрос повторяется без заголовков подписи.
+	code, b := send(http.MethodPost, "/updates/", http.Header{})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "signature is required\n", b)
+
+	// Подпись без времени и одноразового значения.
+	stripped := http.Header{}
+	stripped.Set("HashSHA256", captured.Get("HashSHA256"))
+	code, b = send(http.MethodPost, "/updates/", stripped)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "timestamp and nonce are required\n", b)
+
+	code, b = send(http.MethodPost, "/updates/", captured)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "replayed request\n", b)
+
+	code, _ = send(http.MethodGet, "/healthz", http.Header{})
+	assert.Equal(t, http.StatusOK, code)
+
+	// Перехваченный до доставки запрос отправляется на другой маршрут с той же подписью.
+	retargeted := http.Header{}
+	retargeted.Set("HashSHA256", hex.EncodeToString(k.Sign(hash.Stamp(http.MethodPost, "/updates/", ts, n2, body))))
+	retargeted.Set("HashTimestamp", ts)
+	retargeted.Set("HashNonce", n2)
+
+	code, b = send(http.MethodDelete, "/value/counter/x", retargeted)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "wrong signature\n", b)
+
+	code, b = send(http.MethodPost, "/updates/?mode=atomic", retargeted)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "wrong signature\n", b)
+}
+
+// hasKeys - заданы ли ключи подписи сервера.
+type hasKeys bool
+
+func (h hasKeys) HasKeys() bool {
+	return bool(h)
+}
+
+func TestRequire(t *testing.T) {
+	tests := []struct {
+		name     string
+		sign     string
+		wantBody string
+		want     int
+		keys     hasKeys
+	}{
+		{
+			name:     "Ключи подписи не заданы",
+			sign:     "0123",
+			want:     http.StatusForbidden,
+			wantBody: "signing key is not configured\n",
+		},
+		{
+			name:     "Запрос без подписи",
+			keys:     true,
+			want:     http.StatusBadRequest,
+			wantBody: "signature is required\n",
+		},
+		{
+			name: "Подписанный запрос",
+			sign: "0123",
+			keys: true,
+			want: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.With(Require(test.keys)).Delete("/value/{type}/{name}", func(w http.ResponseWriter, r *http.Request) {})
+
+			req := httptest.NewRequest(http.MethodDelete, "/value/counter/PollCount", nil)
+			if test.sign != "" {
+				req.Header.Set("HashSHA256", test.sign)
+			}
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+
+			assert.Equal(t, test.want, recorder.Code)
+			assert.Equal(t, test.wantBody, recorder.Body.String())
+		})
+	}
+}
@@ -1,84 +1,266 @@
 // Package middleware сжатия данных для Content-Type application/json и text/html на стороне сервера.
+// Поддерживаются кодеки gzip, zstd и br.
 package middleware
 
 import (
-	"compress/gzip"
 	"fmt"
-	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"github.com/k0st1a/metrics/internal/pkg/codec"
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultCompressMinSize - минимальный размер ответа в байтах, начиная с которого ответ сжимается.
+// Меньшие ответы после сжатия почти не уменьшаются, а заголовки кодека съедают выигрыш.
+const DefaultCompressMinSize = 1024
+
 type compress struct {
-	rw     http.ResponseWriter
-	w      io.WriteCloser
-	uri    string
-	method string
+	rw       http.ResponseWriter
+	w        codec.Writer
+	buf      []byte
+	encoding string
+	uri      string
+	method   string
+	minSize  int
+	status   int
+	// decided - решение о сжатии принято в WriteHeader.
+	decided bool
+	// buffering - ответ подходит для сжатия, но его размер неизвестен и пока меньше minSize.
+	buffering bool
 }
 
-func newCompress(rw http.ResponseWriter, r *http.Request) *compress {
+func newCompress(rw http.ResponseWriter, r *http.Request, encoding string, minSize int) *compress {
 	return &compress{
-		rw:     rw,
-		w:      gzip.NewWriter(rw),
-		uri:    r.RequestURI,
-		method: r.Method,
+		rw:       rw,
+		encoding: encoding,
+		uri:      r.RequestURI,
+		method:   r.Method,
+		minSize:  minSize,
 	}
 }
 
-func (c *compress) close() {
-	_ = c.w.Close()
-}
-
-func (c compress) Header() http.Header {
+func (c *compress) Header() http.Header {
 	return c.rw.Header()
 }
 
-func (c compress) Write(data []byte) (int, error) {
-	ct := c.Header().Get("Content-Type")
+// WriteHeader - решение о сжатии принимается один раз по заголовкам и статусу ответа. Если размер ответа
+// неизвестен, то отправка заголовков откладывается до накопления minSize байт или завершения ответа.
+func (c *compress) WriteHeader(statusCode int) {
+	if c.decided {
+		return
+	}
+
+	c.decided = true
+	c.status = statusCode
+
+	h := c.rw.Header()
+	ct := h.Get("Content-Type")
 	in := isNeedCompress(ct)
+
+	// Ответ на тот же запрос зависит от Accept-Encoding, даже если этот клиент сжатие не принимает.
+	if in {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
 	log.Debug().Msgf("Is need compress for Content-Type:%v?(%v) method:%v, uri:%v", ct, in, c.method, c.uri)
-	if !in {
-		// otherwise check_no_compress_for_unknown_Content-Type test fails
-		c.rw.Header().Set("Content-Length", strconv.Itoa(len(data)))
 
-		n, err := c.rw.Write(data)
+	if !in || c.encoding == "" || !c.allowed(statusCode) {
+		c.rw.WriteHeader(statusCode)
+		return
+	}
+
+	cl, err := strconv.Atoi(h.Get("Content-Length"))
+	switch {
+	case err != nil:
+		c.buffering = true
+	case cl < c.minSize:
+		c.rw.WriteHeader(statusCode)
+	default:
+		c.start()
+	}
+}
+
+func (c *compress) Write(data []byte) (int, error) {
+	if !c.decided {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.buffering {
+		c.buf = append(c.buf, data...)
+		if len(c.buf) < c.minSize {
+			return len(data), nil
+		}
+
+		err := c.startBuffered()
 		if err != nil {
-			return n, fmt.Errorf("c.rw.Write error:%w", err)
+			return 0, err
 		}
 
-		return n, nil
+		return len(data), nil
 	}
 
-	c.Header().Set("Content-Encoding", "gzip")
+	if c.w != nil {
+		n, err := c.w.Write(data)
+		if err != nil {
+			return n, fmt.Errorf("c.w.Write error:%w", err)
+		}
+
+		return n, nil
+	}
 
-	n, err := c.w.Write(data)
+	n, err := c.rw.Write(data)
 	if err != nil {
-		return n, fmt.Errorf("c.w.Write error:%w", err)
+		return n, fmt.Errorf("c.rw.Write error:%w", err)
 	}
 
 	return n, nil
 }
 
-func (c compress) WriteHeader(statusCode int) {
-	c.rw.WriteHeader(statusCode)
+// Flush - отправка уже записанных данных клиенту для потоковых ответов. Ответ, размер которого еще
+// неизвестен, сжимается, так как дальше данные уходят частями.
+func (c *compress) Flush() {
+	if !c.decided {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	if c.buffering {
+		err := c.startBuffered()
+		if err != nil {
+			log.Error().Err(err).Msg("compress write error while flush")
+			return
+		}
+	}
+
+	if c.w != nil {
+		err := c.w.Flush()
+		if err != nil {
+			log.Error().Err(err).Msg("compress flush error")
+			return
+		}
+	}
+
+	err := http.NewResponseController(c.rw).Flush()
+	if err != nil {
+		log.Error().Err(err).Msg("response flush error")
+	}
+}
+
+// Unwrap - доступ к исходному http.ResponseWriter для http.ResponseController.
+func (c *compress) Unwrap() http.ResponseWriter {
+	return c.rw
+}
+
+func (c *compress) close() {
+	if c.buffering {
+		// Ответ меньше minSize отправляется без сжатия с известной длиной.
+		c.buffering = false
+		c.rw.Header().Set("Content-Length", strconv.Itoa(len(c.buf)))
+		c.rw.WriteHeader(c.status)
+
+		_, err := c.rw.Write(c.buf)
+		if err != nil {
+			log.Error().Err(err).Msg("c.rw.Write error")
+		}
+
+		return
+	}
+
+	if c.w == nil {
+		return
+	}
+
+	err := c.w.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("compress close error")
+	}
+
+	codec.PutWriter(c.encoding, c.w)
+	c.w = nil
+}
+
+// allowed - ответы без тела и уже закодированные ответы не сжимаются.
+func (c *compress) allowed(statusCode int) bool {
+	if c.method == http.MethodHead || c.rw.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+
+	switch {
+	case statusCode < http.StatusOK,
+		statusCode == http.StatusNoContent,
+		statusCode == http.StatusNotModified:
+		return false
+	default:
+		return true
+	}
+}
+
+// start - отправка заголовков сжатого ответа. Длина сжатого ответа заранее неизвестна,
+// поэтому Content-Length удаляется. Если писатель кодека получить не удалось, ответ идет без сжатия.
+func (c *compress) start() {
+	c.buffering = false
+
+	w, err := codec.GetWriter(c.encoding, c.rw)
+	if err != nil {
+		log.Error().Err(err).Msg("codec get writer error")
+		c.rw.WriteHeader(c.status)
+		return
+	}
+
+	h := c.rw.Header()
+	h.Set("Content-Encoding", c.encoding)
+	h.Del("Content-Length")
+	c.rw.WriteHeader(c.status)
+
+	c.w = w
+}
+
+func (c *compress) startBuffered() error {
+	c.start()
+
+	data := c.buf
+	c.buf = nil
+
+	var err error
+	if c.w != nil {
+		_, err = c.w.Write(data)
+	} else {
+		_, err = c.rw.Write(data)
+	}
+
+	if err != nil {
+		return fmt.Errorf("buffered data write error:%w", err)
+	}
+
+	return nil
 }
 
+// Compress - сжатие ответа от DefaultCompressMinSize байт кодеком, выбранным по q-значениям заголовка
+// Accept-Encoding.
 func Compress(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		if r.Header.Get("Accept-Encoding") == "gzip" {
-			c := newCompress(rw, r)
-			next.ServeHTTP(c, r)
+	return NewCompress(DefaultCompressMinSize)(next)
+}
+
+// NewCompress - создание middleware сжатия ответов размером от minSize байт кодеком, выбранным
+// по q-значениям заголовка Accept-Encoding. Писатели кодеков переиспользуются между ответами.
+func NewCompress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			encoding := codec.Negotiate(r.Header.Get("Accept-Encoding"), codec.Supported)
+
+			c := newCompress(rw, r, encoding, minSize)
 			defer c.close()
-		} else {
-			next.ServeHTTP(rw, r)
-		}
-	})
+
+			next.ServeHTTP(c, r)
+		})
+	}
 }
 
 func isNeedCompress(ct string) bool {
-	switch ct {
+	mt, _, _ := strings.Cut(ct, ";")
+
+	switch strings.TrimSpace(mt) {
 	case "application/json":
 		return true
 	case "text/html":
@@ -1,46 +1,151 @@
 package middleware
 
 import (
+	"bufio"
 	"compress/gzip"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+var largeBody = strings.Repeat("textstring", 200)
+
 func TestMiddlewareCompress(t *testing.T) {
 	tests := []struct {
 		name                    string
+		method                  string
 		acceptEncoding          string
 		path                    string
+		expectedBody            string
 		expectedContentEncoding string
+		expectedContentLength   int64
+		expectedVary            string
 	}{
 		{
 			name:                    "check compress application/json",
 			acceptEncoding:          "gzip",
 			path:                    "/get_application_json",
+			expectedBody:            largeBody,
 			expectedContentEncoding: "gzip",
+			expectedVary:            "Accept-Encoding",
 		},
 		{
 			name:                    "check compress text/html",
 			acceptEncoding:          "gzip",
 			path:                    "/get_text_html",
+			expectedBody:            largeBody,
 			expectedContentEncoding: "gzip",
+			expectedVary:            "Accept-Encoding",
+		},
+		{
+			name:                    "check compress zstd",
+			acceptEncoding:          "zstd",
+			path:                    "/get_application_json",
+			expectedBody:            largeBody,
+			expectedContentEncoding: "zstd",
+			expectedVary:            "Accept-Encoding",
 		},
 		{
-			name:                    "check no compress for no Accept-Encoding",
-			acceptEncoding:          "",
+			name:                    "check compress br",
+			acceptEncoding:          "br",
 			path:                    "/get_application_json",
-			expectedContentEncoding: "",
+			expectedBody:            largeBody,
+			expectedContentEncoding: "br",
+			expectedVary:            "Accept-Encoding",
+		},
+		{
+			name:                    "check codec with highest q-value",
+			acceptEncoding:          "zstd;q=0.2, gzip;q=0.8, br;q=0.5",
+			path:                    "/get_application_json",
+			expectedBody:            largeBody,
+			expectedContentEncoding: "gzip",
+			expectedVary:            "Accept-Encoding",
 		},
 		{
-			name:                    "check no compress for unknown Content-Type",
+			name:                    "check compress with Content-Length from handler",
 			acceptEncoding:          "gzip",
-			path:                    "/get_unknown_content_type",
-			expectedContentEncoding: "",
+			path:                    "/get_content_length",
+			expectedBody:            largeBody,
+			expectedContentEncoding: "gzip",
+			expectedVary:            "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress for q=0",
+			acceptEncoding:        "gzip;q=0",
+			path:                  "/get_application_json",
+			expectedBody:          largeBody,
+			expectedContentLength: int64(len(largeBody)),
+			expectedVary:          "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress for unknown Accept-Encoding",
+			acceptEncoding:        "deflate",
+			path:                  "/get_application_json",
+			expectedBody:          largeBody,
+			expectedContentLength: int64(len(largeBody)),
+			expectedVary:          "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress for no Accept-Encoding",
+			acceptEncoding:        "",
+			path:                  "/get_application_json",
+			expectedBody:          largeBody,
+			expectedContentLength: int64(len(largeBody)),
+			expectedVary:          "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress for unknown Content-Type",
+			acceptEncoding:        "gzip",
+			path:                  "/get_unknown_content_type",
+			expectedBody:          largeBody,
+			expectedContentLength: int64(len(largeBody)),
+		},
+		{
+			name:                  "check no compress below min size",
+			acceptEncoding:        "gzip",
+			path:                  "/get_small",
+			expectedBody:          "textstring",
+			expectedContentLength: int64(len("textstring")),
+			expectedVary:          "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress below min size with Content-Length from handler",
+			acceptEncoding:        "gzip",
+			path:                  "/get_small_content_length",
+			expectedBody:          "textstring",
+			expectedContentLength: int64(len("textstring")),
+			expectedVary:          "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress for already encoded body",
+			acceptEncoding:        "gzip",
+			path:                  "/get_encoded",
+			expectedBody:          largeBody,
+			expectedContentLength: int64(len(largeBody)),
+			expectedVary:          "Accept-Encoding",
+		},
+		{
+			name:           "check no compress for no content",
+			acceptEncoding: "gzip",
+			path:           "/no_content",
+			expectedVary:   "Accept-Encoding",
+		},
+		{
+			name:                  "check no compress for HEAD",
+			method:                http.MethodHead,
+			acceptEncoding:        "gzip",
+			path:                  "/get_content_length",
+			expectedContentLength: int64(len(largeBody)),
+			expectedVary:          "Accept-Encoding",
 		},
 	}
 
@@ -49,7 +154,7 @@ func TestMiddlewareCompress(t *testing.T) {
 
 	r.Get("/get_application_json", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		_, err := w.Write([]byte("textstring"))
+		_, err := w.Write([]byte(largeBody))
 		if err != nil {
 			panic(err)
 		}
@@ -57,21 +162,68 @@ func TestMiddlewareCompress(t *testing.T) {
 	})
 
 	r.Get("/get_text_html", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html")
-		_, err := w.Write([]byte("textstring"))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		// Запись частями меньше минимального размера.
+		for i := 0; i < len(largeBody); i += 100 {
+			_, err := w.Write([]byte(largeBody[i : i+100]))
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	r.Get("/get_unknown_content_type", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "unknown")
+		_, err := w.Write([]byte(largeBody))
 		if err != nil {
 			panic(err)
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 
-	r.Get("/get_unknown_content_type", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "unknown")
+	contentLength := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(largeBody)))
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(largeBody))
+		if err != nil && r.Method != http.MethodHead {
+			panic(err)
+		}
+	}
+
+	r.Get("/get_content_length", contentLength)
+	r.Head("/get_content_length", contentLength)
+
+	r.Get("/get_small", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
 		_, err := w.Write([]byte("textstring"))
 		if err != nil {
 			panic(err)
 		}
+	})
+
+	r.Get("/get_small_content_length", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", "10")
 		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("textstring"))
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	r.Get("/get_encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", "identity")
+		_, err := w.Write([]byte(largeBody))
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	r.Get("/no_content", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNoContent)
 	})
 
 	ts := httptest.NewServer(r)
@@ -84,7 +236,12 @@ func TestMiddlewareCompress(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			req, err := http.NewRequest(http.MethodGet, ts.URL+test.path, nil)
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req, err := http.NewRequest(method, ts.URL+test.path, nil)
 			assert.NoError(t, err)
 
 			req.Header.Set("Accept-Encoding", test.acceptEncoding)
@@ -97,13 +254,13 @@ func TestMiddlewareCompress(t *testing.T) {
 
 			var reader io.ReadCloser
 
-			switch resp.Header.Get("Content-Encoding") {
-			case "gzip":
+			switch ce := resp.Header.Get("Content-Encoding"); ce {
+			case "", "identity":
+				reader = resp.Body
+			default:
 				var err error
-				reader, err = gzip.NewReader(resp.Body)
+				reader, err = codec.NewReader(ce, resp.Body)
 				assert.NoError(t, err)
-			default:
-				reader = resp.Body
 			}
 
 			respBody, err := io.ReadAll(reader)
@@ -112,12 +269,140 @@ func TestMiddlewareCompress(t *testing.T) {
 			err = reader.Close()
 			assert.NoError(t, err)
 
-			assert.Equal(t, "textstring", string(respBody),
-				"response text doesn't match; expected:%q, got:%q", "textstring", string(respBody))
+			assert.Equal(t, test.expectedBody, string(respBody),
+				"response text doesn't match; expected:%q, got:%q", test.expectedBody, string(respBody))
 
 			respContentEncoding := resp.Header.Get("Content-Encoding")
-			assert.Equal(t, test.expectedContentEncoding, respContentEncoding,
-				"expected Content-Encoding %q but got %q", test.expectedContentEncoding, respContentEncoding)
+			if respContentEncoding != "identity" {
+				assert.Equal(t, test.expectedContentEncoding, respContentEncoding,
+					"expected Content-Encoding %q but got %q", test.expectedContentEncoding, respContentEncoding)
+			}
+
+			if test.expectedContentEncoding == "" {
+				assert.Equal(t, test.expectedContentLength, resp.ContentLength)
+			} else {
+				assert.Less(t, resp.ContentLength, int64(len(test.expectedBody)))
+			}
+
+			assert.Equal(t, test.expectedVary, resp.Header.Get("Vary"))
 		})
 	}
 }
+
+func TestMiddlewareCompressFlush(t *testing.T) {
+	chunks := make(chan string)
+
+	r := chi.NewRouter()
+	r.Use(NewLogging(nil), Compress)
+
+	r.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		f, ok := w.(http.Flusher)
+		assert.True(t, ok)
+
+		for c := range chunks {
+			_, err := w.Write([]byte(c + "\n"))
+			assert.NoError(t, err)
+			f.Flush()
+		}
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tc := &http.Client{
+		Transport: &http.Transport{
+			DisableCompression: true,
+		}}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/stream", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	go func() {
+		chunks <- "first"
+	}()
+
+	resp, err := tc.Do(req)
+	require.NoError(t, err)
+	//nolint:errcheck // not need check error in test
+	defer resp.Body.Close()
+
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, int64(-1), resp.ContentLength)
+
+	reader, err := codec.NewReader("gzip", resp.Body)
+	require.NoError(t, err)
+
+	// Каждая часть доходит до клиента до завершения ответа.
+	br := bufio.NewReader(reader)
+	for _, want := range []string{"first", "second", "third"} {
+		if want != "first" {
+			chunks <- want
+		}
+
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, want+"\n", line)
+	}
+
+	close(chunks)
+
+	rest, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+}
+
+func BenchmarkCompress(b *testing.B) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+
+	body := []byte(strings.Repeat(`{"id":"Alloc","type":"gauge","value":1.5},`, 100))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write(body)
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	for _, encoding := range codec.Supported {
+		h := Compress(handler)
+
+		b.Run(encoding, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				h.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+
+	// Прежняя схема с созданием писателя gzip на каждый ответ, без накладных расходов middleware.
+	b.Run("gzip_new_writer", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			rw := httptest.NewRecorder()
+			rw.Header().Set("Content-Encoding", "gzip")
+
+			w := gzip.NewWriter(rw)
+
+			_, err := w.Write(body)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			err = w.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+// Package compressrequest для сжатия тела запросов, отправляемых со стороны агента.
+package compressrequest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+)
+
+// New - сжатие тела запроса кодеком encoding (gzip, zstd или br) с установкой заголовка Content-Encoding.
+// Запросы без тела отправляются как есть. Тело сжимается до подписи и шифрования,
+// поэтому middleware должен быть внешним по отношению к sign и encrypt.
+func New(encoding string) (roundtrip.Middleware, error) {
+	// Проверка кодека при запуске агента, а не при первой отправке.
+	w, err := codec.GetWriter(encoding, io.Discard)
+	if err != nil {
+		return nil, fmt.Errorf("codec get writer error:%w", err)
+	}
+	codec.PutWriter(encoding, w)
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundtrip.HandlerFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Body == nil || r.Body == http.NoBody {
+				//nolint:wrapcheck //no need here
+				return next.RoundTrip(r)
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				return nil, fmt.Errorf("body read error while compress:%w", err)
+			}
+
+			err = r.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("body close error while compress:%w", err)
+			}
+
+			c, err := codec.Compress(encoding, body)
+			if err != nil {
+				return nil, fmt.Errorf("compress body error:%w", err)
+			}
+
+			r.Body = io.NopCloser(bytes.NewBuffer(c))
+			r.ContentLength = int64(len(c))
+			r.Header.Set("Content-Encoding", encoding)
+
+			//nolint:wrapcheck //no need here
+			return next.RoundTrip(r)
+		})
+	}, nil
+}
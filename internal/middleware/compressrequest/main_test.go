@@ -0,0 +1,154 @@
+package compressrequest
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/middleware/decompress"
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errReader int
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("test read error")
+}
+
+type errCloser int
+
+func (errCloser) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+func (errCloser) Close() error {
+	return errors.New("test close error")
+}
+
+var responseRoundTripper http.RoundTripper = testRoundTripper(0)
+
+type testRoundTripper int
+
+func (testRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Body:   r.Body,
+		Header: r.Header,
+	}, nil
+}
+
+func TestNewError(t *testing.T) {
+	m, err := New("deflate")
+	assert.Nil(t, m)
+	assert.ErrorIs(t, err, codec.ErrUnsupported)
+}
+
+func TestCompressError(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    io.Reader
+		doError string
+	}{
+		{
+			name:    "check body read error",
+			body:    errReader(0),
+			doError: "body read error while compress",
+		},
+		{
+			name:    "check body close error",
+			body:    errCloser(0),
+			doError: "body close error while compress",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, "http://localhost", test.body)
+			require.NoError(t, err)
+
+			m, err := New(codec.Gzip)
+			require.NoError(t, err)
+
+			c := &http.Client{
+				Transport: roundtrip.New(responseRoundTripper, m),
+			}
+
+			resp, err := c.Do(req)
+			assert.Nil(t, resp)
+			assert.ErrorContains(t, err, test.doError)
+
+			if resp != nil {
+				err = resp.Body.Close()
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCompressAndDecompress(t *testing.T) {
+	body := strings.Repeat(`{"id":"Alloc","type":"gauge","value":1.5}`, 50)
+
+	for _, encoding := range codec.Supported {
+		t.Run(encoding, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(decompress.New(1024 * 1024))
+
+			r.Post("/check", func(rw http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, body, string(b))
+
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			r.Get("/check", func(rw http.ResponseWriter, r *http.Request) {
+				assert.Empty(t, r.Header.Get("Content-Encoding"))
+
+				rw.WriteHeader(http.StatusOK)
+			})
+
+			testServer := httptest.NewServer(r)
+			defer testServer.Close()
+
+			m, err := New(encoding)
+			require.NoError(t, err)
+
+			var size int64
+			sniff := func(next http.RoundTripper) http.RoundTripper {
+				return roundtrip.HandlerFunc(func(r *http.Request) (*http.Response, error) {
+					size = r.ContentLength
+					assert.Equal(t, encoding, r.Header.Get("Content-Encoding"))
+					//nolint:wrapcheck //no need here
+					return next.RoundTrip(r)
+				})
+			}
+
+			c := &http.Client{
+				Transport: roundtrip.New(http.DefaultTransport, sniff, m),
+			}
+
+			req, err := http.NewRequest(http.MethodPost, testServer.URL+"/check", bytes.NewBufferString(body))
+			require.NoError(t, err)
+
+			resp, err := c.Do(req)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NoError(t, resp.Body.Close())
+			assert.Less(t, size, int64(len(body)))
+
+			c.Transport = roundtrip.New(http.DefaultTransport, m)
+
+			resp, err = c.Get(testServer.URL + "/check")
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NoError(t, resp.Body.Close())
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Package decompress для распаковки тела запросов, сжатых агентом.
+package decompress
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+	"github.com/rs/zerolog/log"
+)
+
+// New - распаковка тела запроса по заголовку Content-Encoding (gzip, zstd или br).
+// Размер распакованных данных ограничивается limit байтами, чтобы небольшое сжатое тело
+// не могло занять всю память сервера, при превышении запрос отклоняется с кодом 413.
+func New(limit int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			ce := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+			if ce == "" || ce == codec.Identity {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			zr, err := codec.NewReader(ce, r.Body)
+			if errors.Is(err, codec.ErrUnsupported) {
+				log.Error().Err(err).Msg("unsupported content encoding")
+				http.Error(rw, "unsupported content encoding", http.StatusUnsupportedMediaType)
+				return
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("body decompress error")
+				http.Error(rw, "body decompress error", http.StatusBadRequest)
+				return
+			}
+
+			b, err := io.ReadAll(io.LimitReader(zr, limit+1))
+
+			cerr := zr.Close()
+			if cerr != nil {
+				log.Error().Err(cerr).Msg("decompressor close error")
+			}
+
+			cerr = r.Body.Close()
+			if cerr != nil {
+				log.Error().Err(cerr).Msg("body close error while decompress")
+			}
+
+			if err != nil {
+				log.Error().Err(err).Msg("body decompress error")
+				http.Error(rw, "body decompress error", http.StatusBadRequest)
+				return
+			}
+
+			if int64(len(b)) > limit {
+				log.Error().Int64("limit", limit).Msg("decompressed body is too large")
+				http.Error(rw, "decompressed body is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewBuffer(b))
+
+			cl := len(b)
+			r.ContentLength = int64(cl)
+			r.Header.Set("Content-Length", strconv.Itoa(cl))
+			r.Header.Del("Content-Encoding")
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
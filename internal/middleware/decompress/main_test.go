@@ -0,0 +1,139 @@
+package decompress
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	body := []byte(strings.Repeat(`{"id":"PollCount","type":"counter","delta":1}`, 10))
+	bomb := bytes.Repeat([]byte{0}, 1<<20)
+
+	compress := func(name string, data []byte) []byte {
+		c, err := codec.Compress(name, data)
+		require.NoError(t, err)
+		return c
+	}
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            []byte
+		expectedStatus  int
+		expectedBody    []byte
+	}{
+		{
+			name:           "check uncompressed body",
+			body:           body,
+			expectedStatus: http.StatusOK,
+			expectedBody:   body,
+		},
+		{
+			name:            "check identity body",
+			contentEncoding: "identity",
+			body:            body,
+			expectedStatus:  http.StatusOK,
+			expectedBody:    body,
+		},
+		{
+			name:            "check gzip body",
+			contentEncoding: "gzip",
+			body:            compress(codec.Gzip, body),
+			expectedStatus:  http.StatusOK,
+			expectedBody:    body,
+		},
+		{
+			name:            "check zstd body",
+			contentEncoding: "zstd",
+			body:            compress(codec.Zstd, body),
+			expectedStatus:  http.StatusOK,
+			expectedBody:    body,
+		},
+		{
+			name:            "check br body",
+			contentEncoding: "BR",
+			body:            compress(codec.Brotli, body),
+			expectedStatus:  http.StatusOK,
+			expectedBody:    body,
+		},
+		{
+			name:            "check unsupported encoding",
+			contentEncoding: "deflate",
+			body:            body,
+			expectedStatus:  http.StatusUnsupportedMediaType,
+			expectedBody:    []byte("unsupported content encoding\n"),
+		},
+		{
+			name:            "check broken gzip body",
+			contentEncoding: "gzip",
+			body:            body,
+			expectedStatus:  http.StatusBadRequest,
+			expectedBody:    []byte("body decompress error\n"),
+		},
+		{
+			name:            "check broken zstd body",
+			contentEncoding: "zstd",
+			body:            compress(codec.Zstd, body)[:20],
+			expectedStatus:  http.StatusBadRequest,
+			expectedBody:    []byte("body decompress error\n"),
+		},
+		{
+			name:            "check gzip bomb",
+			contentEncoding: "gzip",
+			body:            compress(codec.Gzip, bomb),
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedBody:    []byte("decompressed body is too large\n"),
+		},
+		{
+			name:            "check zstd bomb",
+			contentEncoding: "zstd",
+			body:            compress(codec.Zstd, bomb),
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedBody:    []byte("decompressed body is too large\n"),
+		},
+		{
+			name:            "check br bomb",
+			contentEncoding: "br",
+			body:            compress(codec.Brotli, bomb),
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedBody:    []byte("decompressed body is too large\n"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(New(64 * 1024))
+			r.Post("/", func(rw http.ResponseWriter, r *http.Request) {
+				assert.NotContains(t, codec.Supported, r.Header.Get("Content-Encoding"))
+
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, int64(len(b)), r.ContentLength)
+
+				_, err = rw.Write(b)
+				require.NoError(t, err)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(test.body))
+			if test.contentEncoding != "" {
+				req.Header.Set("Content-Encoding", test.contentEncoding)
+			}
+
+			rw := httptest.NewRecorder()
+			r.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.expectedStatus, rw.Code)
+			assert.Equal(t, test.expectedBody, rw.Body.Bytes())
+		})
+	}
+}
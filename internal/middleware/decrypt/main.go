@@ -10,6 +10,8 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Decrypter - интерфейс расшифровки тела запроса. Схема шифрования определяется по самим данным, поэтому
+// сервер принимает и конверт гибридного шифрования, и данные, зашифрованные по частям агентами прежних версий.
 type Decrypter interface {
 	Decrypt([]byte) ([]byte, error)
 }
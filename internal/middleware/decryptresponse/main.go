@@ -0,0 +1,87 @@
+// Package decryptresponse для расшифровки ответов сервера на стороне HTTP-клиента закрытым ключом клиента.
+package decryptresponse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/k0st1a/metrics/internal/middleware/encryptresponse"
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+)
+
+// ErrNotEncrypted - успешный ответ сервера не зашифрован, хотя клиент передал открытый ключ.
+var ErrNotEncrypted = errors.New("response is not encrypted")
+
+// Decrypter - интерфейс расшифровки тела ответа.
+type Decrypter interface {
+	Decrypt([]byte) ([]byte, error)
+}
+
+// Signer - интерфейс подписи данных.
+type Signer interface {
+	Sign([]byte) []byte
+}
+
+// New - передача открытого ключа клиента der в формате PKIX DER серверу и расшифровка тела ответа.
+// Если s не nil, то ключ подписывается, чтобы его нельзя было подменить. Успешный ответ без шифрования
+// отклоняется с ошибкой, чтобы ответ нельзя было прочитать, убрав ключ из запроса. Тело расшифровывается
+// до проверки подписи ответа, поэтому middleware ставится ближе всего к транспорту.
+func New(d Decrypter, der []byte, s Signer) roundtrip.Middleware {
+	key := base64.StdEncoding.EncodeToString(der)
+
+	var sign string
+	if s != nil {
+		sign = hex.EncodeToString(s.Sign(der))
+	}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return roundtrip.HandlerFunc(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set(encryptresponse.HeaderKey, key)
+			if sign != "" {
+				r.Header.Set(encryptresponse.HeaderKeySign, sign)
+			}
+
+			resp, err := next.RoundTrip(r)
+			if err != nil {
+				//nolint:wrapcheck //no need here
+				return resp, err
+			}
+
+			if resp.Header.Get(encryptresponse.HeaderEncryption) != encryptresponse.Envelope {
+				if resp.StatusCode == http.StatusOK {
+					_ = resp.Body.Close()
+					return nil, fmt.Errorf("%w:status %v", ErrNotEncrypted, resp.StatusCode)
+				}
+
+				return resp, nil
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return nil, fmt.Errorf("body read error while decrypt response:%w", err)
+			}
+
+			err = resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("body close error while decrypt response:%w", err)
+			}
+
+			dec, err := d.Decrypt(body)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt response error:%w", err)
+			}
+
+			resp.Body = io.NopCloser(bytes.NewReader(dec))
+			resp.ContentLength = int64(len(dec))
+			resp.Header.Set("Content-Length", strconv.Itoa(len(dec)))
+
+			return resp, nil
+		})
+	}
+}
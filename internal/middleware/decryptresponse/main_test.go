@@ -0,0 +1,83 @@
+package decryptresponse
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/middleware/encryptresponse"
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecryptResponse(t *testing.T) {
+	prv, err := rsa.NewPrivateFromFile("../decrypt/private.pem")
+	require.NoError(t, err)
+
+	der, err := prv.Public().DER()
+	require.NoError(t, err)
+
+	h := hash.New("some key")
+
+	tests := []struct {
+		name    string
+		encrypt bool
+		status  int
+		err     error
+	}{
+		{
+			name:    "Ответ расшифрован",
+			encrypt: true,
+			status:  http.StatusOK,
+		},
+		{
+			name:   "Успешный ответ без шифрования",
+			status: http.StatusOK,
+			err:    ErrNotEncrypted,
+		},
+		{
+			name:   "Ответ с ошибкой без шифрования",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			if test.encrypt {
+				r.Use(encryptresponse.New(h))
+			}
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte("данные ответа"))
+			})
+
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			rt := roundtrip.New(http.DefaultTransport, New(prv, der, h))
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			require.NoError(t, err)
+
+			resp, err := rt.RoundTrip(req)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			b, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.NoError(t, resp.Body.Close())
+
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, "данные ответа", string(b))
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package encryptresponse для шифрования ответов HTTP-сервера открытым ключом, который передал клиент.
+package encryptresponse
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"slices"
+
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// HeaderKey - HTTP-заголовок запроса с открытым ключом клиента в формате PKIX DER, закодированным в base64.
+	HeaderKey = "ResponsePublicKey"
+	// HeaderKeySign - HTTP-заголовок запроса с подписью HashSHA256 открытого ключа клиента. Подпись не дает
+	// подменить ключ по дороге и прочитать ответ.
+	HeaderKeySign = "ResponsePublicKeySign"
+	// HeaderEncryption - HTTP-заголовок ответа со схемой шифрования тела ответа.
+	HeaderEncryption = "ResponseEncryption"
+	// Envelope - тело ответа зашифровано конвертом гибридного шифрования пакета rsa.
+	Envelope = "envelope"
+)
+
+// Checker - интерфейс проверки подписи данных.
+type Checker interface {
+	Check(data []byte, sign []byte) (equal bool)
+}
+
+// IDChecker - интерфейс проверки подписи данных ключом с идентификатором id из HTTP-заголовка HashKeyID.
+// Если Checker его не реализует, то заголовок не учитывается.
+type IDChecker interface {
+	CheckID(id string, data []byte, sign []byte) (equal bool)
+}
+
+// buffer - ответ обработчика, который отправляется клиенту после шифрования.
+type buffer struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (b *buffer) Write(data []byte) (int, error) {
+	//nolint:wrapcheck //no need here
+	return b.body.Write(data)
+}
+
+func (b *buffer) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+// Flush - шифруется тело ответа целиком, поэтому до завершения обработчика ничего не отправляется.
+func (b *buffer) Flush() {}
+
+// Unwrap - доступ к исходному http.ResponseWriter для http.ResponseController.
+func (b *buffer) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// New - шифрование тела ответа открытым ключом из HTTP-заголовка ResponsePublicKey запроса, без заголовка
+// ответ не шифруется. Подпись ключа проверяется так же, как подпись тела запроса. Шифруется тело до сжатия
+// и после подписи, поэтому middleware ставится между middleware сжатия и middleware подписи ответа.
+// Для шифрования ответ буферизуется целиком, поэтому потоковые ответы путей streaming не шифруются,
+// а запрос к ним с ключом клиента отклоняется.
+func New(c Checker, streaming ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if key == "" {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			if slices.Contains(streaming, r.URL.Path) {
+				log.Error().Str("uri", r.RequestURI).Msg("streaming response can not be encrypted")
+				http.Error(rw, "streaming response can not be encrypted", http.StatusBadRequest)
+				return
+			}
+
+			der, err := base64.StdEncoding.DecodeString(key)
+			if err != nil {
+				log.Error().Err(err).Msg("key decode error while encrypt response")
+				http.Error(rw, "key decode error while encrypt response", http.StatusBadRequest)
+				return
+			}
+
+			sign, err := hex.DecodeString(r.Header.Get(HeaderKeySign))
+			if err != nil {
+				log.Error().Err(err).Msg("key sign decode error while encrypt response")
+				http.Error(rw, "key sign decode error while encrypt response", http.StatusBadRequest)
+				return
+			}
+
+			if !check(c, r.Header.Get("HashKeyID"), der, sign) {
+				log.Error().Msg("wrong key signature while encrypt response")
+				http.Error(rw, "wrong key signature", http.StatusBadRequest)
+				return
+			}
+
+			pbl, err := rsa.NewPublicDER(der)
+			if err != nil {
+				log.Error().Err(err).Msg("public key parse error while encrypt response")
+				http.Error(rw, "public key parse error while encrypt response", http.StatusBadRequest)
+				return
+			}
+
+			b := &buffer{ResponseWriter: rw}
+			next.ServeHTTP(b, r)
+
+			enc, err := pbl.Encrypt(b.body.Bytes())
+			if err != nil {
+				log.Error().Err(err).Msg("encrypt response error")
+				http.Error(rw, "encrypt response error", http.StatusInternalServerError)
+				return
+			}
+
+			rw.Header().Set(HeaderEncryption, Envelope)
+			rw.Header().Set("Content-Type", "application/octet-stream")
+
+			if b.status != 0 {
+				rw.WriteHeader(b.status)
+			}
+
+			_, err = rw.Write(enc)
+			if err != nil {
+				log.Error().Err(err).Msg("response write error while encrypt response")
+			}
+		})
+	}
+}
+
+func check(c Checker, id string, data []byte, sign []byte) bool {
+	if ic, ok := c.(IDChecker); ok && id != "" {
+		return ic.CheckID(id, data, sign)
+	}
+
+	return c.Check(data, sign)
+}
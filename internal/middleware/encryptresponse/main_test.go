@@ -0,0 +1,154 @@
+package encryptresponse
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noKeys - проверка подписи без ключей, любая подпись верная.
+type noKeys struct{}
+
+func (noKeys) Check([]byte, []byte) bool {
+	return true
+}
+
+func TestEncryptResponse(t *testing.T) {
+	prv, err := rsa.NewPrivateFromFile("../decrypt/private.pem")
+	require.NoError(t, err)
+
+	der, err := prv.Public().DER()
+	require.NoError(t, err)
+
+	key := base64.StdEncoding.EncodeToString(der)
+	h := hash.New("some key")
+
+	tests := []struct {
+		name      string
+		checker   Checker
+		key       string
+		sign      string
+		path      string
+		status    int
+		encrypted bool
+		body      string
+	}{
+		{
+			name:    "Без ключа ответ не шифруется",
+			checker: noKeys{},
+			status:  http.StatusOK,
+			body:    "данные ответа",
+		},
+		{
+			name:      "Ответ шифруется ключом без подписи, если ключей подписи нет",
+			checker:   noKeys{},
+			key:       key,
+			status:    http.StatusOK,
+			encrypted: true,
+			body:      "данные ответа",
+		},
+		{
+			name:      "Ответ шифруется подписанным ключом",
+			checker:   h,
+			key:       key,
+			sign:      hex.EncodeToString(h.Sign(der)),
+			status:    http.StatusOK,
+			encrypted: true,
+			body:      "данные ответа",
+		},
+		{
+			name:    "Потоковый ответ не шифруется",
+			checker: noKeys{},
+			key:     key,
+			path:    "/stream",
+			status:  http.StatusBadRequest,
+			body:    "streaming response can not be encrypted\n",
+		},
+		{
+			name:    "Потоковый ответ без ключа",
+			checker: noKeys{},
+			path:    "/stream",
+			status:  http.StatusOK,
+			body:    "данные ответа",
+		},
+		{
+			name:    "Ключ без подписи",
+			checker: h,
+			key:     key,
+			status:  http.StatusBadRequest,
+			body:    "wrong key signature\n",
+		},
+		{
+			name:    "Ключ не в base64",
+			checker: noKeys{},
+			key:     "не base64",
+			status:  http.StatusBadRequest,
+			body:    "key decode error while encrypt response\n",
+		},
+		{
+			name:    "Не ключ",
+			checker: noKeys{},
+			key:     base64.StdEncoding.EncodeToString([]byte("не ключ")),
+			status:  http.StatusBadRequest,
+			body:    "public key parse error while encrypt response\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(New(test.checker, "/stream"))
+			r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				_, _ = w.Write([]byte("данные "))
+				// Ответ шифруется целиком, поэтому Flush ничего не отправляет.
+				assert.NoError(t, http.NewResponseController(w).Flush())
+				_, _ = w.Write([]byte("ответа"))
+			})
+
+			path := test.path
+			if path == "" {
+				path = "/"
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if test.key != "" {
+				req.Header.Set(HeaderKey, test.key)
+			}
+			if test.sign != "" {
+				req.Header.Set(HeaderKeySign, test.sign)
+			}
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			res := recorder.Result()
+
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, test.status, res.StatusCode)
+
+			if !test.encrypted {
+				assert.Empty(t, res.Header.Get(HeaderEncryption))
+				assert.Equal(t, test.body, string(b))
+				return
+			}
+
+			assert.Equal(t, Envelope, res.Header.Get(HeaderEncryption))
+
+			dec, err := prv.Decrypt(b)
+			require.NoError(t, err)
+			assert.Equal(t, test.body, string(dec))
+		})
+	}
+}
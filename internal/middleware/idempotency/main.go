@@ -0,0 +1,165 @@
+// Package idempotency is middleware which replays the original response for a retried request
+// with the same Idempotency-Key header instead of processing it twice.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Header - заголовок запроса с ключом идемпотентности.
+	Header = "Idempotency-Key"
+	// ReplayedHeader - заголовок ответа, выставляется, если ответ взят из хранилища.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Store - интерфейс хранилища ответов. Ключ резервируется до обработки запроса атомарно, поэтому запрос
+// с тем же ключом не будет обработан дважды, в том числе другим сервером с общим хранилищем.
+type Store interface {
+	// Reserve - резервирует ключ за запросом с хешем hash. Если ответ по ключу уже сохранен, то он
+	// возвращается без резервирования, если ключ зарезервирован другим запросом - возвращается
+	// idempotency.ErrInProgress.
+	Reserve(ctx context.Context, key, hash string) (*idempotency.Response, bool, error)
+	// Save - сохраняет ответ по зарезервированному ключу.
+	Save(ctx context.Context, key string, r *idempotency.Response) error
+	// Release - снимает резервирование ключа, по которому ответ не сохранен.
+	Release(ctx context.Context, key string) error
+}
+
+// recorder - ответ обработчика, который отправляется клиенту только после сохранения в хранилище.
+// Так при ошибке сохранения клиент получает ошибку сервера, а не ответ, который не будет повторен.
+type recorder struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+
+	//nolint:wrapcheck //no need here
+	return r.body.Write(data)
+}
+
+func (r *recorder) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+}
+
+// New - повтор сохраненного ответа на POST запрос с уже обработанным ключом из заголовка Idempotency-Key.
+// Запрос с ключом, который обрабатывается в данный момент, получает 409 и должен быть повторен клиентом.
+func New(s Store) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if key == "" || r.Method != http.MethodPost {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.Error().Err(err).Msg("body read error while idempotency check")
+				http.Error(rw, "body read error while idempotency check", http.StatusBadRequest)
+				return
+			}
+
+			err = r.Body.Close()
+			if err != nil {
+				log.Error().Err(err).Msg("body close error while idempotency check")
+			}
+
+			r.Body = io.NopCloser(bytes.NewBuffer(b))
+
+			sum := sha256.Sum256(append([]byte(r.URL.Path), b...))
+			hash := hex.EncodeToString(sum[:])
+
+			saved, reserved, err := s.Reserve(r.Context(), key, hash)
+			switch {
+			case errors.Is(err, idempotency.ErrInProgress):
+				http.Error(rw, "request with same idempotency key in progress", http.StatusConflict)
+				return
+			case err != nil:
+				log.Error().Err(err).Msg("idempotency store reserve error")
+				http.Error(rw, "idempotency store reserve error", http.StatusInternalServerError)
+				return
+			case !reserved:
+				if saved.RequestHash != hash {
+					http.Error(rw, "idempotency key reused for another request", http.StatusUnprocessableEntity)
+					return
+				}
+
+				log.Debug().Str("key", key).Msg("replay saved response")
+				rw.Header().Set(ReplayedHeader, "true")
+				write(rw, saved.Header, saved.Status, saved.Body)
+				return
+			}
+
+			rec := &recorder{header: make(http.Header)}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			// Изменения уже могли быть применены, поэтому хранилище обновляется и при отмене запроса клиентом.
+			ctx := context.WithoutCancel(r.Context())
+
+			// Ответ с ошибкой сервера не сохраняем, чтобы повторный запрос был обработан заново.
+			if rec.status >= http.StatusInternalServerError {
+				err = s.Release(ctx, key)
+				if err != nil {
+					log.Error().Err(err).Msg("idempotency store release error")
+				}
+
+				write(rw, rec.header, rec.status, rec.body.Bytes())
+				return
+			}
+
+			err = s.Save(ctx, key, &idempotency.Response{
+				Created:     time.Now(),
+				Header:      rec.header,
+				RequestHash: hash,
+				Body:        rec.body.Bytes(),
+				Status:      rec.status,
+			})
+			if err != nil {
+				// Резервирование не снимается: до конца окна повторы получают 409 и не применяются дважды.
+				log.Error().Err(err).Msg("idempotency store save error")
+				http.Error(rw, "idempotency store save error", http.StatusInternalServerError)
+				return
+			}
+
+			write(rw, rec.header, rec.status, rec.body.Bytes())
+		})
+	}
+}
+
+func write(rw http.ResponseWriter, header http.Header, status int, body []byte) {
+	for k, v := range header {
+		rw.Header()[k] = v
+	}
+	rw.WriteHeader(status)
+
+	_, err := rw.Write(body)
+	if err != nil {
+		log.Error().Err(err).Msg("rw.Write error while idempotency response write")
+	}
+}
@@ -0,0 +1,195 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		body         string
+		wantStatus   int
+		wantBody     string
+		wantReplayed string
+		wantCalls    int
+	}{
+		{
+			name:       "Первый запрос с ключом обрабатывается",
+			key:        "key1",
+			body:       "body1",
+			wantStatus: 200,
+			wantBody:   "processed body1",
+			wantCalls:  1,
+		},
+		{
+			name:         "Повторный запрос с ключом не обрабатывается, ответ берется из хранилища",
+			key:          "key1",
+			body:         "body1",
+			wantStatus:   200,
+			wantBody:     "processed body1",
+			wantReplayed: "true",
+			wantCalls:    1,
+		},
+		{
+			name:       "Повторный ключ с другим телом запроса",
+			key:        "key1",
+			body:       "body2",
+			wantStatus: 422,
+			wantBody:   "idempotency key reused for another request\n",
+			wantCalls:  1,
+		},
+		{
+			name:       "Запрос без ключа обрабатывается всегда",
+			body:       "body1",
+			wantStatus: 200,
+			wantBody:   "processed body1",
+			wantCalls:  2,
+		},
+	}
+
+	calls := 0
+
+	r := chi.NewRouter()
+	r.Use(New(idempotency.NewMemory(time.Minute)))
+	r.Post("/", func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		_, err = rw.Write(append([]byte("processed "), b...))
+		require.NoError(t, err)
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(test.body))
+			if test.key != "" {
+				req.Header.Set(Header, test.key)
+			}
+
+			r.ServeHTTP(recorder, req)
+			res := recorder.Result()
+
+			b, err := io.ReadAll(res.Body)
+			assert.NoError(t, err)
+
+			err = res.Body.Close()
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wantStatus, res.StatusCode)
+			assert.Equal(t, test.wantBody, string(b))
+			assert.Equal(t, test.wantReplayed, res.Header.Get(ReplayedHeader))
+			assert.Equal(t, test.wantCalls, calls)
+		})
+	}
+}
+
+// failSave - хранилище, которое не может сохранить ответ.
+type failSave struct {
+	*idempotency.Memory
+}
+
+func (f failSave) Save(context.Context, string, *idempotency.Response) error {
+	return errors.New("disk is full")
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	tests := []struct {
+		store      Store
+		name       string
+		status     int
+		wantStatus []int
+		wantCalls  int
+	}{
+		{
+			name:       "Ответ с ошибкой сервера не сохраняется, повтор обрабатывается заново",
+			store:      idempotency.NewMemory(time.Minute),
+			status:     http.StatusServiceUnavailable,
+			wantStatus: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantCalls:  2,
+		},
+		{
+			name:       "Ошибка сохранения ответа, повтор не обрабатывается",
+			store:      failSave{idempotency.NewMemory(time.Minute)},
+			status:     http.StatusOK,
+			wantStatus: []int{http.StatusInternalServerError, http.StatusConflict},
+			wantCalls:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+
+			h := New(test.store)(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				calls++
+				rw.WriteHeader(test.status)
+			}))
+
+			for _, want := range test.wantStatus {
+				req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("body"))
+				req.Header.Set(Header, "key")
+
+				recorder := httptest.NewRecorder()
+				h.ServeHTTP(recorder, req)
+
+				assert.Equal(t, want, recorder.Code)
+			}
+
+			assert.Equal(t, test.wantCalls, calls)
+		})
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	h := New(idempotency.NewMemory(time.Minute))(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("body"))
+		req.Header.Set(Header, "key")
+		return req
+	}
+
+	first := httptest.NewRecorder()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		h.ServeHTTP(first, newRequest())
+	}()
+
+	<-started
+
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, newRequest())
+	assert.Equal(t, http.StatusConflict, second.Code)
+
+	close(release)
+	<-done
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	third := httptest.NewRecorder()
+	h.ServeHTTP(third, newRequest())
+	assert.Equal(t, http.StatusOK, third.Code)
+	assert.Equal(t, "true", third.Header().Get(ReplayedHeader))
+}
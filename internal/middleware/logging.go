@@ -4,11 +4,20 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog/log"
 )
 
+// RequestObserver - интерфейс учета обработанных запросов в метриках сервера.
+type RequestObserver interface {
+	AddCounter(name string, delta int64)
+	ObserveDuration(name string, d time.Duration)
+}
+
 type responseData struct {
 	statusCode  int
 	contentSize int
@@ -32,7 +41,7 @@ func (lr logging) Header() http.Header {
 
 func (lr logging) Write(data []byte) (int, error) {
 	size, err := lr.rw.Write(data)
-	lr.rd.contentSize = size
+	lr.rd.contentSize += size
 	if err != nil {
 		return size, fmt.Errorf("lr.rw.Write error:%w", err)
 	}
@@ -44,21 +53,77 @@ func (lr logging) WriteHeader(statusCode int) {
 	lr.rd.statusCode = statusCode
 }
 
+// Unwrap - доступ к исходному http.ResponseWriter для http.ResponseController, например для Flush.
+func (lr logging) Unwrap() http.ResponseWriter {
+	return lr.rw
+}
+
 func Logging(next http.Handler) http.Handler {
-	logFn := func(rw http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+	return NewLogging(nil)(next)
+}
+
+// NewLogging - создание middleware логирования запросов, которое также учитывает количество и длительность
+// запросов в разрезе маршрута и статуса ответа в o, если o задан.
+func NewLogging(o RequestObserver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		logFn := func(rw http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			lr := NewLoggingResponse(rw)
+
+			next.ServeHTTP(lr, r)
+
+			duration := time.Since(start)
 
-		lr := NewLoggingResponse(rw)
+			if lr.rd.statusCode == 0 {
+				lr.rd.statusCode = http.StatusOK
+			}
 
-		next.ServeHTTP(lr, r)
+			log.Info().
+				Str("uri", r.RequestURI).
+				Str("method", r.Method).
+				Dur("duration", duration).
+				Int("status", lr.rd.statusCode).
+				Int("size", lr.rd.contentSize).
+				Msg("Logging info")
 
-		log.Info().
-			Str("uri", r.RequestURI).
-			Str("method", r.Method).
-			Dur("duration", time.Since(start)).
-			Int("status", lr.rd.statusCode).
-			Int("size", lr.rd.contentSize).
-			Msg("Logging info")
+			if o != nil {
+				route := routeName(r)
+				o.AddCounter("http_requests_total_"+route+"_"+strconv.Itoa(lr.rd.statusCode), 1)
+				o.ObserveDuration("http_request_duration_seconds_"+route+"_"+statusClass(lr.rd.statusCode), duration)
+			}
+		}
+		return http.HandlerFunc(logFn)
 	}
-	return http.HandlerFunc(logFn)
+}
+
+// statusClass - класс статуса ответа для имени гистограммы, например `2xx`. Класс вместо точного статуса
+// не дает плодить гистограммы с малым числом наблюдений.
+func statusClass(code int) string {
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// routeName - имя маршрута chi, пригодное для имени метрики, например `update_type_name_value`.
+func routeName(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || len(rctx.RoutePatterns) == 0 {
+		return "unmatched"
+	}
+
+	name := strings.Map(func(c rune) rune {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			return c
+		}
+		if c >= 'A' && c <= 'Z' {
+			return c - 'A' + 'a'
+		}
+		return '_'
+	}, rctx.RoutePattern())
+
+	name = strings.Join(strings.FieldsFunc(name, func(c rune) bool { return c == '_' }), "_")
+	if name == "" {
+		return "root"
+	}
+
+	return name
 }
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+type testObserver struct {
+	counter  map[string]int64
+	duration map[string]int
+}
+
+func (o *testObserver) AddCounter(name string, delta int64) {
+	o.counter[name] += delta
+}
+
+func (o *testObserver) ObserveDuration(name string, _ time.Duration) {
+	o.duration[name]++
+}
+
+func TestMiddlewareLoggingObserver(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		wantCounter  string
+		wantDuration string
+	}{
+		{
+			name:         "check route with params",
+			path:         "/value/gauge/Alloc",
+			wantCounter:  "http_requests_total_value_type_name_200",
+			wantDuration: "http_request_duration_seconds_value_type_name_2xx",
+		},
+		{
+			name:         "check root route",
+			path:         "/",
+			wantCounter:  "http_requests_total_root_404",
+			wantDuration: "http_request_duration_seconds_root_4xx",
+		},
+	}
+
+	o := &testObserver{
+		counter:  make(map[string]int64),
+		duration: make(map[string]int),
+	}
+
+	r := chi.NewRouter()
+	r.Use(NewLogging(o))
+	r.Get("/value/{type}/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte("1"))
+		if err != nil {
+			panic(err)
+		}
+	})
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			assert.Equal(t, int64(1), o.counter[test.wantCounter])
+			assert.Equal(t, 1, o.duration[test.wantDuration])
+		})
+	}
+}
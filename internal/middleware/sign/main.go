@@ -3,19 +3,35 @@ package sign
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
 )
 
+// nonceSize - размер одноразового значения в байтах.
+const nonceSize = 16
+
 // Signer - интерфейс подписи данных.
 type Signer interface {
 	Sign([]byte) []byte
 }
 
+// KeyIDer - интерфейс получения идентификатора ключа подписи. Если Signer его реализует, то непустой
+// идентификатор передается в HTTP-заголовке HashKeyID.
+type KeyIDer interface {
+	KeyID() string
+}
+
+// New - подпись тела запроса вместе с методом, адресом, временем отправки и одноразовым значением, которые
+// передаются в HTTP-заголовках HashTimestamp и HashNonce, чтобы сервер отклонил повторную отправку запроса,
+// в том числе на другой адрес.
 func New(s Signer) func(http.RoundTripper) http.RoundTripper {
 	return func(next http.RoundTripper) http.RoundTripper {
 		return roundtrip.HandlerFunc(func(r *http.Request) (*http.Response, error) {
@@ -31,10 +47,25 @@ func New(s Signer) func(http.RoundTripper) http.RoundTripper {
 
 			r.Body = io.NopCloser(bytes.NewBuffer(body))
 
-			signBody := s.Sign(body)
+			nonce := make([]byte, nonceSize)
+			_, err = rand.Read(nonce)
+			if err != nil {
+				return nil, fmt.Errorf("rand read error while sign:%w", err)
+			}
+
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			hn := hex.EncodeToString(nonce)
+			r.Header.Set("HashTimestamp", ts)
+			r.Header.Set("HashNonce", hn)
+
+			signBody := s.Sign(hash.Stamp(r.Method, r.URL.RequestURI(), ts, hn, body))
 			hex := hex.EncodeToString(signBody)
 			r.Header.Set("HashSHA256", hex)
 
+			if k, ok := s.(KeyIDer); ok && k.KeyID() != "" {
+				r.Header.Set("HashKeyID", k.KeyID())
+			}
+
 			//nolint:wrapcheck //no need here
 			return next.RoundTrip(r)
 		})
@@ -2,6 +2,7 @@ package sign
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
 	"io"
 	"net/http"
@@ -89,16 +90,21 @@ func TestSignError(t *testing.T) {
 
 func TestSign(t *testing.T) {
 	tests := []struct {
-		name string
-		key  string
-		body string
-		sign string
+		name  string
+		key   string
+		keyID string
+		body  string
 	}{
 		{
 			name: "check set HashSHA256",
 			key:  "some key",
 			body: "подписываемые данные",
-			sign: "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+		},
+		{
+			name:  "check set HashSHA256 and HashKeyID",
+			key:   "some key",
+			keyID: "v2",
+			body:  "подписываемые данные",
 		},
 	}
 
@@ -110,7 +116,7 @@ func TestSign(t *testing.T) {
 			req, err := http.NewRequest(http.MethodPost, testServer.URL, bytes.NewBuffer([]byte(test.body)))
 			assert.NoError(t, err)
 
-			h := hash.New(test.key)
+			h := hash.NewWithID(test.keyID, test.key)
 			rt := roundtrip.New(responseRoundTripper, New(h))
 			c := &http.Client{
 				Transport: rt,
@@ -119,7 +125,14 @@ func TestSign(t *testing.T) {
 			resp, err := c.Do(req)
 			assert.NoError(t, err)
 
-			assert.Equal(t, test.sign, resp.Header.Get("HashSHA256"))
+			ts := resp.Header.Get("HashTimestamp")
+			nonce := resp.Header.Get("HashNonce")
+			assert.NotEmpty(t, ts)
+			assert.Len(t, nonce, 2*nonceSize)
+
+			sign := hex.EncodeToString(h.Sign(hash.Stamp(http.MethodPost, "/", ts, nonce, []byte(test.body))))
+			assert.Equal(t, sign, resp.Header.Get("HashSHA256"))
+			assert.Equal(t, test.keyID, resp.Header.Get("HashKeyID"))
 
 			respBody, err := io.ReadAll(resp.Body)
 			assert.NoError(t, err)
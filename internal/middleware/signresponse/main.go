@@ -0,0 +1,94 @@
+// Package signresponse для подписи ответов HTTP-сервера в HTTP-заголовке HashSHA256.
+package signresponse
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Signer - интерфейс подписи данных ключом с идентификатором id из HTTP-заголовка HashKeyID запроса.
+// Возвращается подпись и идентификатор ключа, которым она сделана, при подписи nil ответ не подписывается.
+type Signer interface {
+	SignID(id string, data []byte) (sign []byte, keyID string)
+}
+
+// KeyHolder - интерфейс Signer, сообщающий, заданы ли ключи подписи. Без ключей ответ не буферизуется.
+type KeyHolder interface {
+	HasKeys() bool
+}
+
+// buffer - ответ обработчика, который отправляется клиенту после подписи.
+type buffer struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (b *buffer) Write(data []byte) (int, error) {
+	//nolint:wrapcheck //no need here
+	return b.body.Write(data)
+}
+
+func (b *buffer) WriteHeader(statusCode int) {
+	if b.status == 0 {
+		b.status = statusCode
+	}
+}
+
+// Flush - подписывается тело ответа целиком, поэтому до завершения обработчика ничего не отправляется.
+func (b *buffer) Flush() {}
+
+// Unwrap - доступ к исходному http.ResponseWriter для http.ResponseController.
+func (b *buffer) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// New - подпись тела ответа. Подписывается тело до сжатия, поэтому middleware ставится после middleware сжатия.
+// Для подписи ответ буферизуется целиком, поэтому ответы на неподписанные запросы, ответы без ключей
+// подписи и потоковые ответы путей streaming не подписываются и отправляются без буферизации.
+func New(s Signer, streaming ...string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			if !needSign(s, r, streaming) {
+				next.ServeHTTP(rw, r)
+				return
+			}
+
+			b := &buffer{ResponseWriter: rw}
+			next.ServeHTTP(b, r)
+
+			sign, id := s.SignID(r.Header.Get("HashKeyID"), b.body.Bytes())
+			if sign != nil {
+				rw.Header().Set("HashSHA256", hex.EncodeToString(sign))
+				if id != "" {
+					rw.Header().Set("HashKeyID", id)
+				}
+			}
+
+			if b.status != 0 {
+				rw.WriteHeader(b.status)
+			}
+
+			_, err := rw.Write(b.body.Bytes())
+			if err != nil {
+				log.Error().Err(err).Msg("response write error while sign response")
+			}
+		})
+	}
+}
+
+func needSign(s Signer, r *http.Request, streaming []string) bool {
+	if r.Header.Get("HashSHA256") == "" {
+		return false
+	}
+
+	if kh, ok := s.(KeyHolder); ok && !kh.HasKeys() {
+		return false
+	}
+
+	return !slices.Contains(streaming, r.URL.Path)
+}
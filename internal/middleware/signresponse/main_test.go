@@ -0,0 +1,144 @@
+package signresponse
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type noKeys struct{}
+
+func (noKeys) SignID(string, []byte) ([]byte, string) {
+	return nil, ""
+}
+
+// emptyKeyring - ключи подписи не заданы, ответ не должен буферизоваться.
+type emptyKeyring struct {
+	Signer
+}
+
+func (emptyKeyring) HasKeys() bool {
+	return false
+}
+
+func TestSignResponse(t *testing.T) {
+	keyring := hash.NewKeyring([]hash.Key{{ID: "v2", Secret: "new key"}, {ID: "v1", Secret: "some key"}}, 0)
+
+	tests := []struct {
+		name      string
+		signer    Signer
+		keyID     string
+		unsigned  bool
+		path      string
+		status    int
+		wantSign  string
+		wantKeyID string
+	}{
+		{
+			name:      "Подпись ключом из запроса",
+			signer:    keyring,
+			keyID:     "v1",
+			status:    http.StatusOK,
+			wantSign:  "e38c1bd0a6f6196624b914c454929f684c19ffbe0b8d59954bcb0498e17cc165",
+			wantKeyID: "v1",
+		},
+		{
+			name:      "Подпись текущим ключом",
+			signer:    keyring,
+			status:    http.StatusNotFound,
+			wantSign:  hex.EncodeToString(hash.New("new key").Sign([]byte("подписываемые данные"))),
+			wantKeyID: "v2",
+		},
+		{
+			name:   "Без ключей ответ не подписывается",
+			signer: noKeys{},
+			status: http.StatusOK,
+		},
+		{
+			name:   "Пустой набор ключей",
+			signer: emptyKeyring{keyring},
+			status: http.StatusOK,
+		},
+		{
+			name:     "Ответ на неподписанный запрос не подписывается",
+			signer:   keyring,
+			unsigned: true,
+			status:   http.StatusOK,
+		},
+		{
+			name:   "Потоковый ответ не подписывается",
+			signer: keyring,
+			path:   "/stream",
+			status: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			r.Use(New(test.signer, "/stream"))
+			r.Get("/*", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				_, _ = w.Write([]byte("подписываемые "))
+				_, _ = w.Write([]byte("данные"))
+			})
+
+			path := test.path
+			if path == "" {
+				path = "/"
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("HashKeyID", test.keyID)
+			if !test.unsigned {
+				req.Header.Set("HashSHA256", "0123")
+			}
+
+			recorder := httptest.NewRecorder()
+			r.ServeHTTP(recorder, req)
+			res := recorder.Result()
+
+			assert.Equal(t, test.status, res.StatusCode)
+			assert.Equal(t, test.wantSign, res.Header.Get("HashSHA256"))
+			assert.Equal(t, test.wantKeyID, res.Header.Get("HashKeyID"))
+
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, "подписываемые данные", string(b))
+		})
+	}
+}
+
+func TestSignResponseFlush(t *testing.T) {
+	keyring := hash.NewKeyring([]hash.Key{{ID: "v1", Secret: "some key"}}, 0)
+
+	r := chi.NewRouter()
+	r.Use(New(keyring))
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("подписываемые "))
+		// Ответ подписывается целиком, поэтому Flush ничего не отправляет.
+		require.NoError(t, http.NewResponseController(w).Flush())
+		_, _ = w.Write([]byte("данные"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("HashSHA256", "0123")
+
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, req)
+	res := recorder.Result()
+
+	assert.False(t, recorder.Flushed)
+	assert.Equal(t, hex.EncodeToString(hash.New("some key").Sign([]byte("подписываемые данные"))),
+		res.Header.Get("HashSHA256"))
+	require.NoError(t, res.Body.Close())
+}
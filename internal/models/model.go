@@ -64,3 +64,34 @@ func SerializeList(ml []Metrics) ([]byte, error) {
 
 	return b, nil
 }
+
+const (
+	// ResultStored - метрика из пакета сохранена.
+	ResultStored = "stored"
+	// ResultRejected - метрика из пакета отклонена.
+	ResultRejected = "rejected"
+)
+
+// Result - результат сохранения метрики из пакета.
+//
+//easyjson:json
+type Result struct {
+	ID     string `json:"id"`              // имя метрики
+	MType  string `json:"type"`            // параметр, принимающий значение gauge или counter
+	Status string `json:"status"`          // параметр, принимающий значение stored или rejected
+	Error  string `json:"error,omitempty"` // причина, по которой метрика отклонена
+}
+
+//easyjson:json
+type ResultList []Result
+
+// SerializeResultList - упаковка []Result в байты.
+func SerializeResultList(rl []Result) ([]byte, error) {
+	v := ResultList(rl)
+	b, err := easyjson.Marshal(&v)
+	if err != nil {
+		return nil, fmt.Errorf("easyjson.Marshal error:%w", err)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,153 @@
+// Package codec for compression codecs of HTTP bodies and negotiation of them by Accept-Encoding.
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Gzip     = "gzip"
+	Zstd     = "zstd"
+	Brotli   = "br"
+	Identity = "identity"
+	// None - отключение сжатия в настройках агента.
+	None = "none"
+)
+
+// ErrUnsupported - неизвестный кодек.
+var ErrUnsupported = errors.New("unsupported codec")
+
+// Supported - поддерживаемые кодеки в порядке предпочтения сервера при равных q-значениях.
+var Supported = []string{Zstd, Brotli, Gzip}
+
+// Valid - проверка имени кодека из настроек, None означает отказ от сжатия.
+func Valid(name string) error {
+	switch name {
+	case Gzip, Zstd, Brotli, None:
+		return nil
+	default:
+		return fmt.Errorf("%w:%q", ErrUnsupported, name)
+	}
+}
+
+// NewReader - создание читателя, распаковывающего данные кодека name из r.
+func NewReader(name string, r io.Reader) (io.ReadCloser, error) {
+	switch name {
+	case Gzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip new reader error:%w", err)
+		}
+		return gr, nil
+	case Zstd:
+		// Окно ограничивается, чтобы заголовок кадра не мог потребовать гигабайты памяти.
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(1<<23))
+		if err != nil {
+			return nil, fmt.Errorf("zstd new reader error:%w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("%w:%q", ErrUnsupported, name)
+	}
+}
+
+// Compress - сжатие data кодеком name.
+func Compress(name string, data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	w, err := GetWriter(name, &b)
+	if err != nil {
+		return nil, err
+	}
+	defer PutWriter(name, w)
+
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v write error:%w", name, err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("%v close error:%w", name, err)
+	}
+
+	return b.Bytes(), nil
+}
+
+// Negotiate - выбор кодека ответа по заголовку Accept-Encoding (RFC 9110, 12.5.3) среди supported.
+// Выбирается кодек с наибольшим q-значением, при равных значениях - первый в supported.
+// Кодеки с q=0 исключаются, "*" задаёт q-значение для не перечисленных явно кодеков.
+// Пустая строка означает, что ответ отправляется без сжатия.
+func Negotiate(header string, supported []string) string {
+	weights := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(header, ",") {
+		name, q, ok := parseCoding(part)
+		if !ok {
+			continue
+		}
+
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+
+		weights[name] = q
+	}
+
+	best := ""
+	bestQ := 0.0
+
+	for _, name := range supported {
+		q, ok := weights[name]
+		if !ok {
+			q = wildcard
+		}
+
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// parseCoding - разбор элемента Accept-Encoding вида "gzip;q=0.5", имена кодеков нечувствительны к регистру.
+func parseCoding(part string) (string, float64, bool) {
+	params := strings.Split(part, ";")
+
+	name := strings.ToLower(strings.TrimSpace(params[0]))
+	if name == "" {
+		return "", 0, false
+	}
+
+	q := 1.0
+
+	for _, p := range params[1:] {
+		k, v, found := strings.Cut(strings.TrimSpace(p), "=")
+		if !found || !strings.EqualFold(strings.TrimSpace(k), "q") {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || f < 0 || f > 1 {
+			return "", 0, false
+		}
+
+		q = f
+	}
+
+	return name, q, true
+}
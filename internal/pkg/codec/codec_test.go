@@ -0,0 +1,131 @@
+package codec
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"id":"Alloc","type":"gauge","value":1.5}`, 100))
+
+	for _, name := range Supported {
+		t.Run(name, func(t *testing.T) {
+			c, err := Compress(name, data)
+			require.NoError(t, err)
+			assert.Less(t, len(c), len(data))
+
+			r, err := NewReader(name, strings.NewReader(string(c)))
+			require.NoError(t, err)
+
+			got, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.NoError(t, r.Close())
+			assert.Equal(t, data, got)
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	_, err := GetWriter("deflate", io.Discard)
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = Compress("deflate", nil)
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	_, err = NewReader("deflate", strings.NewReader(""))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	assert.ErrorIs(t, Valid("lz4"), ErrUnsupported)
+	assert.NoError(t, Valid(None))
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{
+			name:   "empty header",
+			header: "",
+			want:   "",
+		},
+		{
+			name:   "single codec",
+			header: "gzip",
+			want:   Gzip,
+		},
+		{
+			name:   "server preference on equal q",
+			header: "gzip, br, zstd",
+			want:   Zstd,
+		},
+		{
+			name:   "highest q wins",
+			header: "zstd;q=0.5, gzip;q=0.9, br;q=0.1",
+			want:   Gzip,
+		},
+		{
+			name:   "q=0 excludes codec",
+			header: "gzip;q=0, identity",
+			want:   "",
+		},
+		{
+			name:   "wildcard",
+			header: "*;q=0.5, zstd;q=0",
+			want:   Brotli,
+		},
+		{
+			name:   "case insensitive and spaces",
+			header: " GZIP ; Q=0.8 ",
+			want:   Gzip,
+		},
+		{
+			name:   "invalid q is ignored",
+			header: "zstd;q=2, gzip;q=abc, br;q=0.3",
+			want:   Brotli,
+		},
+		{
+			name:   "unknown codec",
+			header: "deflate, compress",
+			want:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, Negotiate(test.header, Supported))
+		})
+	}
+}
+
+func TestWriterReuse(t *testing.T) {
+	for _, name := range Supported {
+		t.Run(name, func(t *testing.T) {
+			for _, data := range []string{"first response", "second response"} {
+				var b strings.Builder
+
+				w, err := GetWriter(name, &b)
+				require.NoError(t, err)
+
+				_, err = w.Write([]byte(data))
+				require.NoError(t, err)
+				require.NoError(t, w.Flush())
+				assert.NotZero(t, b.Len())
+				require.NoError(t, w.Close())
+				PutWriter(name, w)
+
+				r, err := NewReader(name, strings.NewReader(b.String()))
+				require.NoError(t, err)
+
+				got, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, data, string(got))
+			}
+		})
+	}
+}
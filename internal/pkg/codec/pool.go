@@ -0,0 +1,67 @@
+package codec
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Writer - писатель сжатых данных, который можно переиспользовать через Reset.
+type Writer interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Создание писателя кодека дорогое (gzip выделяет сотни килобайт на каждый писатель),
+// поэтому писатели переиспользуются между ответами.
+var pools = map[string]*sync.Pool{
+	Gzip: {New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}},
+	Zstd: {New: func() any {
+		// Без параллельного сжатия писатель не запускает горутины и подходит для небольших ответов.
+		zw, err := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil
+		}
+		return zw
+	}},
+	Brotli: {New: func() any {
+		return brotli.NewWriter(io.Discard)
+	}},
+}
+
+// GetWriter - получение писателя кодека name из пула, сжатые данные пишутся в w.
+// После Close писатель возвращается в пул через PutWriter.
+func GetWriter(name string, w io.Writer) (Writer, error) {
+	p, ok := pools[name]
+	if !ok {
+		return nil, fmt.Errorf("%w:%q", ErrUnsupported, name)
+	}
+
+	cw, ok := p.Get().(Writer)
+	if !ok {
+		return nil, fmt.Errorf("%v writer create error", name)
+	}
+
+	cw.Reset(w)
+
+	return cw, nil
+}
+
+// PutWriter - возврат писателя кодека name в пул. Писатель отвязывается от прежнего получателя данных,
+// чтобы пул не удерживал его в памяти.
+func PutWriter(name string, cw Writer) {
+	p, ok := pools[name]
+	if !ok {
+		return
+	}
+
+	cw.Reset(io.Discard)
+	p.Put(cw)
+}
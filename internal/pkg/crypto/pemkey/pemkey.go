@@ -0,0 +1,143 @@
+// Package pemkey for parsing of private and public keys in PEM format, including passphrase-protected keys.
+package pemkey
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrNoPEM - в данных нет блока PEM.
+	ErrNoPEM = errors.New("no PEM data is found")
+	// ErrKeyType - тип блока PEM не поддерживается.
+	ErrKeyType = errors.New("unsupported PEM key type")
+	// ErrPassphrase - для зашифрованного ключа не задана парольная фраза или она неверная.
+	ErrPassphrase = errors.New("wrong or missing key passphrase")
+)
+
+// ParsePrivate - закрытый ключ из data в формате PEM. Поддерживаются ключи PKCS#1 (`RSA PRIVATE KEY`),
+// SEC 1 (`EC PRIVATE KEY`), PKCS#8 (`PRIVATE KEY`, например, от `openssl genpkey`), зашифрованные ключи
+// PKCS#8 (`ENCRYPTED PRIVATE KEY`) и ключи, зашифрованные по RFC 1423 (`Proc-Type: 4,ENCRYPTED`).
+// Для зашифрованного ключа нужна парольная фраза passphrase.
+func ParsePrivate(data []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEM
+	}
+
+	der := block.Bytes
+
+	//nolint:staticcheck //RFC 1423 is insecure, but such keys are still produced by `openssl rsa -aes256`
+	if x509.IsEncryptedPEMBlock(block) {
+		if len(passphrase) == 0 {
+			return nil, ErrPassphrase
+		}
+
+		//nolint:staticcheck //see above
+		dec, err := x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w:%w", ErrPassphrase, err)
+		}
+
+		der = dec
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("x509 pkcs1 private key parse error:%w", err)
+		}
+
+		return key, nil
+
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("x509 ec private key parse error:%w", err)
+		}
+
+		return key, nil
+
+	case "ENCRYPTED PRIVATE KEY":
+		if len(passphrase) == 0 {
+			return nil, ErrPassphrase
+		}
+
+		dec, err := decryptPKCS8(der, passphrase)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := x509.ParsePKCS8PrivateKey(dec)
+		if err != nil {
+			// Неверная парольная фраза иногда дает правильное дополнение AES-CBC.
+			return nil, fmt.Errorf("%w:x509 pkcs8 private key parse error:%w", ErrPassphrase, err)
+		}
+
+		return key, nil
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("x509 pkcs8 private key parse error:%w", err)
+		}
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w:%q", ErrKeyType, block.Type)
+}
+
+// ParsePublic - открытый ключ из data в формате PEM: PKIX (`PUBLIC KEY`) или PKCS#1 (`RSA PUBLIC KEY`).
+func ParsePublic(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrNoPEM
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("x509 pkix public key parse error:%w", err)
+		}
+
+		return key, nil
+
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("x509 pkcs1 public key parse error:%w", err)
+		}
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("%w:%q", ErrKeyType, block.Type)
+}
+
+// Passphrase - парольная фраза закрытых ключей из файла file, а если он не задан - из переменной окружения env.
+// Перевод строки в конце файла не входит во фразу. Без файла и переменной окружения возвращается nil.
+func Passphrase(file string, env string) ([]byte, error) {
+	if file == "" {
+		p, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, nil
+		}
+
+		return []byte(p), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("passphrase file read error:%w", err)
+	}
+
+	return bytes.TrimRight(data, "\r\n"), nil
+}
@@ -0,0 +1,153 @@
+package pemkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrivate(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		passphrase string
+		check      func(t *testing.T, key any)
+		err        error
+	}{
+		{
+			name:  "PKCS#8 RSA",
+			file:  "./pkcs8.pem",
+			check: func(t *testing.T, key any) { assert.IsType(t, &rsa.PrivateKey{}, key) },
+		},
+		{
+			name:       "Зашифрованный PKCS#8 RSA, PBKDF2 с HMAC-SHA256 и AES-256-CBC",
+			file:       "./pkcs8_encrypted.pem",
+			passphrase: "secret",
+			check:      func(t *testing.T, key any) { assert.IsType(t, &rsa.PrivateKey{}, key) },
+		},
+		{
+			name:       "Зашифрованный PKCS#8 Ed25519, PBKDF2 с HMAC-SHA1 и AES-128-CBC",
+			file:       "./ed25519_encrypted.pem",
+			passphrase: "secret",
+			check:      func(t *testing.T, key any) { assert.IsType(t, ed25519.PrivateKey{}, key) },
+		},
+		{
+			name:       "PKCS#1 RSA, зашифрованный по RFC 1423",
+			file:       "./pkcs1_encrypted.pem",
+			passphrase: "secret",
+			check:      func(t *testing.T, key any) { assert.IsType(t, &rsa.PrivateKey{}, key) },
+		},
+		{
+			name:  "PKCS#8 Ed25519",
+			file:  "./ed25519.pem",
+			check: func(t *testing.T, key any) { assert.IsType(t, ed25519.PrivateKey{}, key) },
+		},
+		{
+			name:  "SEC 1 ECDSA",
+			file:  "./ec.pem",
+			check: func(t *testing.T, key any) { assert.IsType(t, &ecdsa.PrivateKey{}, key) },
+		},
+		{
+			name: "Зашифрованный PKCS#8 без парольной фразы",
+			file: "./pkcs8_encrypted.pem",
+			err:  ErrPassphrase,
+		},
+		{
+			name:       "Зашифрованный PKCS#8 с неверной парольной фразой",
+			file:       "./pkcs8_encrypted.pem",
+			passphrase: "wrong",
+			err:        ErrPassphrase,
+		},
+		{
+			name: "Зашифрованный по RFC 1423 без парольной фразы",
+			file: "./pkcs1_encrypted.pem",
+			err:  ErrPassphrase,
+		},
+		{
+			name:       "Зашифрованный по RFC 1423 с неверной парольной фразой",
+			file:       "./pkcs1_encrypted.pem",
+			passphrase: "wrong",
+			err:        ErrPassphrase,
+		},
+		{
+			name: "Открытый ключ вместо закрытого",
+			file: "./ed25519_public.pem",
+			err:  ErrKeyType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := os.ReadFile(test.file)
+			require.NoError(t, err)
+
+			key, err := ParsePrivate(data, []byte(test.passphrase))
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			test.check(t, key)
+		})
+	}
+
+	_, err := ParsePrivate([]byte("no PEM data"), nil)
+	assert.ErrorIs(t, err, ErrNoPEM)
+}
+
+func TestParsePublic(t *testing.T) {
+	prv, err := os.ReadFile("./pkcs8.pem")
+	require.NoError(t, err)
+
+	key, err := ParsePrivate(prv, nil)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile("./pkcs1_public.pem")
+	require.NoError(t, err)
+
+	pub, err := ParsePublic(data)
+	require.NoError(t, err)
+	assert.True(t, key.(*rsa.PrivateKey).PublicKey.Equal(pub))
+
+	data, err = os.ReadFile("./ed25519_public.pem")
+	require.NoError(t, err)
+
+	pub, err = ParsePublic(data)
+	require.NoError(t, err)
+	assert.IsType(t, ed25519.PublicKey{}, pub)
+
+	_, err = ParsePublic(prv)
+	assert.ErrorIs(t, err, ErrKeyType)
+
+	_, err = ParsePublic([]byte("no PEM data"))
+	assert.ErrorIs(t, err, ErrNoPEM)
+}
+
+func TestPassphrase(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(file, []byte("from file\n"), 0o600))
+
+	t.Setenv("TEST_PASSPHRASE", "from env")
+
+	p, err := Passphrase(file, "TEST_PASSPHRASE")
+	require.NoError(t, err)
+	assert.Equal(t, "from file", string(p))
+
+	p, err = Passphrase("", "TEST_PASSPHRASE")
+	require.NoError(t, err)
+	assert.Equal(t, "from env", string(p))
+
+	p, err = Passphrase("", "TEST_NO_PASSPHRASE")
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = Passphrase("bad path to file", "TEST_PASSPHRASE")
+	assert.Error(t, err)
+}
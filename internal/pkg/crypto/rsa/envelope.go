@@ -0,0 +1,130 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Конверт гибридного шифрования:
+//
+//	"MENC" | версия (1 байт) | длина ключа (2 байта) | ключ AES-256, зашифрованный RSA-OAEP |
+//	nonce (12 байт) | данные, зашифрованные AES-256-GCM, с тегом (16 байт)
+//
+// Заголовок до ключа включительно участвует в проверке целостности AES-GCM.
+const (
+	envelopeMagic = "MENC"
+	// EnvelopeV1 - версия конверта с ключом AES-256-GCM, зашифрованным RSA-OAEP с SHA-256.
+	EnvelopeV1 byte = 1
+
+	envelopeHeaderSize = len(envelopeMagic) + 1 + 2
+	aesKeySize         = 32
+)
+
+// ErrEnvelope - конверт поврежден или его версия не поддерживается.
+var ErrEnvelope = errors.New("bad envelope")
+
+// isEnvelope - данные в конверте гибридного шифрования?
+func isEnvelope(data []byte) bool {
+	return len(data) >= envelopeHeaderSize && bytes.HasPrefix(data, []byte(envelopeMagic))
+}
+
+// seal - упаковка данных в конверт версии EnvelopeV1.
+func (p *public) seal(data []byte) ([]byte, error) {
+	key := make([]byte, aesKeySize)
+
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("rand read error:%w", err)
+	}
+
+	encKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, p.key, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("rsa OAEP encrypt error:%w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, fmt.Errorf("rand read error:%w", err)
+	}
+
+	size := envelopeHeaderSize + len(encKey) + len(nonce) + len(data) + gcm.Overhead()
+	env := make([]byte, 0, size)
+	env = append(env, envelopeMagic...)
+	env = append(env, EnvelopeV1)
+	env = binary.BigEndian.AppendUint16(env, uint16(len(encKey)))
+	env = append(env, encKey...)
+	ad := env
+
+	env = append(env, nonce...)
+
+	return gcm.Seal(env, nonce, data, ad), nil
+}
+
+// openEnvelope - распаковка конверта.
+func (p *private) openEnvelope(env []byte) ([]byte, error) {
+	version := env[len(envelopeMagic)]
+	if version != EnvelopeV1 {
+		return nil, fmt.Errorf("%w:unknown version %v", ErrEnvelope, version)
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(env[len(envelopeMagic)+1:]))
+	if len(env) < envelopeHeaderSize+keyLen {
+		return nil, fmt.Errorf("%w:too short", ErrEnvelope)
+	}
+
+	ad := env[:envelopeHeaderSize+keyLen]
+
+	key, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, p.key, ad[envelopeHeaderSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("rsa decrypt OAEP error:%w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := env[len(ad):]
+	if len(rest) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("%w:too short", ErrEnvelope)
+	}
+
+	data, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], ad)
+	if err != nil {
+		return nil, fmt.Errorf("%w:gcm open error:%w", ErrEnvelope, err)
+	}
+
+	return data, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != aesKeySize {
+		return nil, fmt.Errorf("%w:bad key size %v", ErrEnvelope, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes new cipher error:%w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("cipher new gcm error:%w", err)
+	}
+
+	return gcm, nil
+}
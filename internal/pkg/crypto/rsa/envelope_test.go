@@ -0,0 +1,112 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	pbl, err := NewPublicFromFile("./public.pem")
+	require.NoError(t, err)
+
+	prv, err := NewPrivateFromFile("./private.pem")
+	require.NoError(t, err)
+
+	data := []byte(testBigData)
+
+	env, err := pbl.Encrypt(data)
+	require.NoError(t, err)
+	assert.True(t, isEnvelope(env))
+
+	dec, err := prv.Decrypt(env)
+	require.NoError(t, err)
+	assert.Equal(t, data, dec)
+
+	// Данные, зашифрованные по частям, по-прежнему расшифровываются.
+	legacy, err := encryptChunks(pbl, data)
+	require.NoError(t, err)
+
+	dec, err = prv.Decrypt(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, data, dec)
+
+	tampered := bytes.Clone(env)
+	tampered[len(tampered)-1] ^= 1
+	_, err = prv.Decrypt(tampered)
+	assert.ErrorIs(t, err, ErrEnvelope)
+
+	unknown := bytes.Clone(env)
+	unknown[len(envelopeMagic)] = EnvelopeV1 + 1
+	_, err = prv.Decrypt(unknown)
+	assert.ErrorIs(t, err, ErrEnvelope)
+
+	_, err = prv.Decrypt(env[:envelopeHeaderSize+10])
+	assert.ErrorIs(t, err, ErrEnvelope)
+}
+
+func BenchmarkEncryptDecrypt(b *testing.B) {
+	pbl, err := NewPublicFromFile("./public.pem")
+	require.NoError(b, err)
+
+	prv, err := NewPrivateFromFile("./private.pem")
+	require.NoError(b, err)
+
+	for _, size := range []int{1 << 10, 16 << 10, 64 << 10} {
+		data := bytes.Repeat([]byte("x"), size)
+
+		b.Run(fmt.Sprintf("chunks/%vKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				enc, err := encryptChunks(pbl, data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = prv.Decrypt(enc)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("envelope/%vKiB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				enc, err := pbl.Encrypt(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = prv.Decrypt(enc)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// encryptChunks - шифрование данных по частям ключом RSA, как до появления конверта.
+func encryptChunks(p *public, data []byte) ([]byte, error) {
+	var encData []byte
+
+	// Размер части - размер ключа без двух размеров хеша и еще 2 байт, см. rsa.EncryptOAEP.
+	step := p.key.Size() - 2*sha256.Size - 2
+	for begin := 0; begin < len(data); begin += step {
+		end := min(begin+step, len(data))
+
+		encChunk, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, p.key, data[begin:end], nil)
+		if err != nil {
+			return nil, fmt.Errorf("rsa OAEP encrypt error:%w", err)
+		}
+
+		encData = append(encData, encChunk...)
+	}
+
+	return encData, nil
+}
@@ -0,0 +1,47 @@
+package rsa
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+)
+
+// Public - открытый ключ, соответствующий закрытому.
+func (p *private) Public() *public {
+	return &public{key: &p.key.PublicKey}
+}
+
+// DER - открытый ключ в формате PKIX DER.
+func (p *public) DER() ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(p.key)
+	if err != nil {
+		return nil, fmt.Errorf("x509 marshal pkix public key error:%w", err)
+	}
+
+	return der, nil
+}
+
+// PEM - открытый ключ в формате PEM, как в файле открытого ключа.
+func (p *public) PEM() ([]byte, error) {
+	der, err := p.DER()
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// Fingerprint - отпечаток открытого ключа: SHA-256 от ключа в формате PKIX DER в шестнадцатеричном виде,
+// как у `openssl pkey -pubin -in public.pem -outform DER | sha256sum`.
+func (p *public) Fingerprint() (string, error) {
+	der, err := p.DER()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,93 @@
+package rsa
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublicKey(t *testing.T) {
+	file, err := os.ReadFile("./public.pem")
+	require.NoError(t, err)
+
+	prv, err := NewPrivateFromFile("./private.pem")
+	require.NoError(t, err)
+
+	pbl := prv.Public()
+
+	pem, err := pbl.PEM()
+	require.NoError(t, err)
+	assert.Equal(t, string(file), string(pem))
+
+	fp, err := pbl.Fingerprint()
+	require.NoError(t, err)
+	assert.Equal(t, "ef70a05de18b6b133eb0a9df0e2cd65ebcb96b953ba35f1e00aa0feced9d0b00", fp)
+
+	der, err := pbl.DER()
+	require.NoError(t, err)
+
+	pbl, err = NewPublicDER(der)
+	require.NoError(t, err)
+
+	enc, err := pbl.Encrypt([]byte("данные"))
+	require.NoError(t, err)
+
+	dec, err := prv.Decrypt(enc)
+	require.NoError(t, err)
+	assert.Equal(t, "данные", string(dec))
+
+	_, err = NewPublicDER([]byte("не ключ"))
+	assert.Error(t, err)
+}
+
+func TestPrivateKeyFormats(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		passphrase string
+		err        string
+	}{
+		{
+			name: "PKCS#8",
+			file: "../pemkey/pkcs8.pem",
+		},
+		{
+			name:       "Зашифрованный PKCS#8",
+			file:       "../pemkey/pkcs8_encrypted.pem",
+			passphrase: "secret",
+		},
+		{
+			name:       "PKCS#1, зашифрованный по RFC 1423",
+			file:       "../pemkey/pkcs1_encrypted.pem",
+			passphrase: "secret",
+		},
+		{
+			name: "Не ключ RSA",
+			file: "../pemkey/ed25519.pem",
+			err:  "bad type assertion to *rsa.PrivateKey",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prv, err := NewPrivateFromFileWithPassphrase(test.file, []byte(test.passphrase))
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+
+			pbl, err := NewPublicFromFile("../pemkey/pkcs1_public.pem")
+			require.NoError(t, err)
+
+			enc, err := pbl.Encrypt([]byte("данные"))
+			require.NoError(t, err)
+
+			dec, err := prv.Decrypt(enc)
+			require.NoError(t, err)
+			assert.Equal(t, "данные", string(dec))
+		})
+	}
+}
@@ -6,47 +6,67 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
-	"hash"
 	"os"
+
+	"github.com/k0st1a/metrics/internal/pkg/crypto/pemkey"
 )
 
 type private struct {
-	key  *rsa.PrivateKey
-	hash hash.Hash
+	key *rsa.PrivateKey
 }
 
-// NewPrivate create rsa private side by data of private key.
+// NewPrivate create rsa private side by data of private key in PKCS#1 or PKCS#8 format.
 func NewPrivate(data string) (*private, error) {
-	block, _ := pem.Decode([]byte(data))
-	if block == nil {
-		return nil, fmt.Errorf("no PEM data is found")
-	}
+	return NewPrivateWithPassphrase(data, nil)
+}
 
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+// NewPrivateWithPassphrase create rsa private side by data of private key, which may be encrypted
+// with passphrase.
+func NewPrivateWithPassphrase(data string, passphrase []byte) (*private, error) {
+	prv, err := pemkey.ParsePrivate([]byte(data), passphrase)
 	if err != nil {
-		return nil, fmt.Errorf("x509 pkcs1 private key parse error:%w", err)
+		//nolint:wrapcheck //no need here
+		return nil, err
+	}
+
+	key, ok := prv.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("bad type assertion to *rsa.PrivateKey")
 	}
 
 	return &private{
-		key:  key,
-		hash: sha256.New(),
+		key: key,
 	}, nil
 }
 
 // NewPrivateFromFile create rsa private side by file of private key.
 func NewPrivateFromFile(path string) (*private, error) {
+	return NewPrivateFromFileWithPassphrase(path, nil)
+}
+
+// NewPrivateFromFileWithPassphrase create rsa private side by file of private key, which may be encrypted
+// with passphrase.
+func NewPrivateFromFileWithPassphrase(path string, passphrase []byte) (*private, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("private key file read error:%w", err)
 	}
 
-	return NewPrivate(string(data))
+	return NewPrivateWithPassphrase(string(data), passphrase)
 }
 
-// Decrypt data.
+// Decrypt data. Данные в конверте гибридного шифрования расшифровываются по версии из его заголовка,
+// иначе данные считаются зашифрованными по частям ключом RSA, как до появления конверта.
 func (p *private) Decrypt(data []byte) ([]byte, error) {
+	if isEnvelope(data) {
+		return p.openEnvelope(data)
+	}
+
+	return p.decryptChunks(data)
+}
+
+func (p *private) decryptChunks(data []byte) ([]byte, error) {
 	var decData []byte
 
 	dataLen := len(data)
@@ -69,7 +89,7 @@ func (p *private) Decrypt(data []byte) ([]byte, error) {
 }
 
 func (p *private) decryptChunk(data []byte) ([]byte, error) {
-	b, err := rsa.DecryptOAEP(p.hash, rand.Reader, p.key, data, nil)
+	b, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, p.key, data, nil)
 	if err != nil {
 		return nil, fmt.Errorf("rsa decrypt OAEP error:%w", err)
 	}
@@ -82,30 +102,38 @@ func (p *private) chunkSize() int {
 }
 
 type public struct {
-	key  *rsa.PublicKey
-	hash hash.Hash
+	key *rsa.PublicKey
 }
 
-// NewPublic create rsa public side by data of public key.
+// NewPublic create rsa public side by data of public key in PKIX or PKCS#1 format.
 func NewPublic(data string) (*public, error) {
-	block, _ := pem.Decode([]byte(data))
-	if block == nil {
-		return nil, fmt.Errorf("no PEM data is found")
+	pub, err := pemkey.ParsePublic([]byte(data))
+	if err != nil {
+		//nolint:wrapcheck //no need here
+		return nil, err
 	}
 
-	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	return newPublic(pub)
+}
+
+// NewPublicDER create rsa public side by public key in PKIX DER format.
+func NewPublicDER(der []byte) (*public, error) {
+	pub, err := x509.ParsePKIXPublicKey(der)
 	if err != nil {
 		return nil, fmt.Errorf("x509 pkix public key parse error:%w", err)
 	}
 
+	return newPublic(pub)
+}
+
+func newPublic(pub any) (*public, error) {
 	key, ok := pub.(*rsa.PublicKey)
 	if !ok {
 		return nil, fmt.Errorf("bad type assertion to *rsa.PublicKey")
 	}
 
 	return &public{
-		key:  key,
-		hash: sha256.New(),
+		key: key,
 	}, nil
 }
 
@@ -119,41 +147,8 @@ func NewPublicFromFile(path string) (*public, error) {
 	return NewPublic(string(data))
 }
 
-// Encryp data.
+// Encrypt data. Данные шифруются случайным ключом AES-256-GCM, который шифруется ключом RSA, и
+// упаковываются в конверт с версией схемы шифрования в заголовке.
 func (p *public) Encrypt(data []byte) ([]byte, error) {
-	var encData []byte
-
-	dataLen := len(data)
-	step := p.chunkSize()
-	for begin := 0; begin < dataLen; begin += step {
-		end := begin + step
-		if end > dataLen {
-			end = dataLen
-		}
-
-		encChunk, err := p.encryptChunk(data[begin:end])
-		if err != nil {
-			return nil, fmt.Errorf("encrypt chunk error:%w", err)
-		}
-
-		encData = append(encData, encChunk...)
-	}
-
-	return encData, nil
-}
-
-func (p *public) encryptChunk(data []byte) ([]byte, error) {
-	b, err := rsa.EncryptOAEP(p.hash, rand.Reader, p.key, data, nil)
-	if err != nil {
-		return nil, fmt.Errorf("rsa OAEP encrypt error:%w", err)
-	}
-
-	return b, nil
-}
-
-// The message must be no longer than the length of the public modulus minus
-// twice the hash length, minus a further 2.
-func (p *public) chunkSize() int {
-	//nolint:gomnd // migic number 2 is from rsa package comment of DecryptOAEP function
-	return p.key.Size() - 2*p.hash.Size() - 2
+	return p.seal(data)
 }
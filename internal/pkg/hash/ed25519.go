@@ -0,0 +1,96 @@
+package hash
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"os"
+
+	"github.com/k0st1a/metrics/internal/pkg/crypto/pemkey"
+)
+
+// ed - подпись Ed25519 вместо HMAC с общим ключом: агент подписывает данные своим закрытым ключом,
+// а сервер проверяет подпись открытым ключом агента. Поэтому ключ одного агента не позволяет подделать
+// данные других агентов.
+type ed struct {
+	id  string
+	prv ed25519.PrivateKey
+	pub ed25519.PublicKey
+}
+
+// NewEd25519 - создание сущности подпись закрытым ключом Ed25519 prv с идентификатором id.
+func NewEd25519(id string, prv ed25519.PrivateKey) *ed {
+	return &ed{
+		id:  id,
+		prv: prv,
+		pub: prv.Public().(ed25519.PublicKey),
+	}
+}
+
+// NewEd25519Public - создание сущности проверки подписи открытым ключом Ed25519 pub с идентификатором id,
+// подписывать ей нельзя.
+func NewEd25519Public(id string, pub ed25519.PublicKey) *ed {
+	return &ed{
+		id:  id,
+		pub: pub,
+	}
+}
+
+// NewEd25519FromFile - создание сущности подпись закрытым ключом Ed25519 из файла path в формате PEM,
+// который может быть зашифрован парольной фразой passphrase.
+func NewEd25519FromFile(id, path string, passphrase []byte) (*ed, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("private key file read error:%w", err)
+	}
+
+	key, err := pemkey.ParsePrivate(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("private key parse error:%w", err)
+	}
+
+	prv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("bad type assertion to ed25519.PrivateKey")
+	}
+
+	return NewEd25519(id, prv), nil
+}
+
+// ParsePublicKey - открытый ключ Ed25519 из файла path в формате PEM.
+func ParsePublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("public key file read error:%w", err)
+	}
+
+	key, err := pemkey.ParsePublic(data)
+	if err != nil {
+		return nil, fmt.Errorf("public key parse error:%w", err)
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("bad type assertion to ed25519.PublicKey")
+	}
+
+	return pub, nil
+}
+
+// KeyID - идентификатор ключа подписи, пустая строка, если он не задан.
+func (e *ed) KeyID() string {
+	return e.id
+}
+
+// Sign - подпись Ed25519, без закрытого ключа - nil.
+func (e *ed) Sign(data []byte) []byte {
+	if e.prv == nil {
+		return nil
+	}
+
+	return ed25519.Sign(e.prv, data)
+}
+
+// Check - проверка подписи Ed25519.
+func (e *ed) Check(data []byte, sign []byte) bool {
+	return len(sign) == ed25519.SignatureSize && ed25519.Verify(e.pub, data, sign)
+}
@@ -0,0 +1,33 @@
+package hash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEd25519(t *testing.T) {
+	data := []byte("подписываемые данные")
+
+	prv, err := NewEd25519FromFile("agent-1", "../crypto/pemkey/ed25519_encrypted.pem", []byte("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, "agent-1", prv.KeyID())
+
+	pub, err := ParsePublicKey("../crypto/pemkey/ed25519_public.pem")
+	require.NoError(t, err)
+
+	v := NewEd25519Public("agent-1", pub)
+	assert.Nil(t, v.Sign(data))
+
+	sign := prv.Sign(data)
+	assert.True(t, v.Check(data, sign))
+	assert.False(t, v.Check([]byte("измененные данные"), sign))
+	assert.False(t, v.Check(data, sign[:10]))
+
+	_, err = NewEd25519FromFile("agent-1", "../crypto/pemkey/pkcs8.pem", nil)
+	assert.EqualError(t, err, "bad type assertion to ed25519.PrivateKey")
+
+	_, err = ParsePublicKey("../crypto/pemkey/pkcs1_public.pem")
+	assert.EqualError(t, err, "bad type assertion to ed25519.PublicKey")
+}
@@ -8,6 +8,7 @@ import (
 )
 
 type hash struct {
+	id  string
 	key []byte
 }
 
@@ -18,6 +19,20 @@ func New(key string) *hash {
 	}
 }
 
+// NewWithID - создания сущности подпись ключом key с идентификатором id, по которому получатель выбирает ключ
+// для проверки подписи.
+func NewWithID(id, key string) *hash {
+	return &hash{
+		id:  id,
+		key: []byte(key),
+	}
+}
+
+// KeyID - идентификатор ключа подписи, пустая строка, если он не задан.
+func (h *hash) KeyID() string {
+	return h.id
+}
+
 // Sign - подпись в формате sha256.
 func (h *hash) Sign(data []byte) []byte {
 	// подписываем алгоритмом HMAC, используя SHA-256
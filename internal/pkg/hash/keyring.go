@@ -0,0 +1,166 @@
+package hash
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrKeyFormat - ключи заданы не в формате `<ИДЕНТИФИКАТОР>:<КЛЮЧ>[,<ИДЕНТИФИКАТОР>:<КЛЮЧ>...]`.
+var ErrKeyFormat = errors.New("hash keys format error")
+
+// Key - ключ подписи с идентификатором: общий ключ HMAC Secret или открытый ключ Ed25519 Public агента.
+// Открытым ключом можно только проверить подпись, и он действует, пока его не уберут из набора.
+type Key struct {
+	ID     string
+	Secret string
+	Public ed25519.PublicKey
+}
+
+// ParseKeys - разбор ключей подписи из строки вида `<ИДЕНТИФИКАТОР>:<КЛЮЧ>[,<ИДЕНТИФИКАТОР>:<КЛЮЧ>...]`.
+// Пустая строка - нет ключей.
+func ParseKeys(s string) ([]Key, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var keys []Key
+
+	for _, kv := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(kv, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("%w:%q", ErrKeyFormat, kv)
+		}
+
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+
+	return keys, nil
+}
+
+// signer - подпись и ее проверка ключом с идентификатором.
+type signer interface {
+	KeyID() string
+	Sign(data []byte) []byte
+	Check(data []byte, sign []byte) bool
+}
+
+type ringKey struct {
+	hash signer
+	// public - ключ только для проверки подписи.
+	public bool
+	until  time.Time
+}
+
+// Keyring - набор ключей подписи, позволяющий менять ключ без одновременного перезапуска всех агентов и
+// серверов. Подписывает текущий ключ, а проверяется подпись любым действующим ключом.
+type Keyring struct {
+	keys []ringKey
+	now  func() time.Time
+}
+
+// NewKeyring - создание набора ключей подписи, где:
+//   - keys - ключи, первый из которых текущий, а остальные - прежние ключи, подписи которыми еще принимаются,
+//     открытые ключи агентов не считаются прежними и ставятся после общих ключей;
+//   - grace - сколько с момента создания набора принимаются подписи прежними ключами, `0` - без ограничения.
+func NewKeyring(keys []Key, grace time.Duration) *Keyring {
+	return newKeyring(keys, grace, time.Now)
+}
+
+func newKeyring(keys []Key, grace time.Duration, now func() time.Time) *Keyring {
+	k := &Keyring{now: now}
+
+	var until time.Time
+	if grace != 0 {
+		until = k.now().Add(grace)
+	}
+
+	for i, key := range keys {
+		var rk ringKey
+		switch {
+		case key.Public != nil:
+			rk = ringKey{hash: NewEd25519Public(key.ID, key.Public), public: true}
+		case i == 0:
+			rk = ringKey{hash: NewWithID(key.ID, key.Secret)}
+		default:
+			rk = ringKey{hash: NewWithID(key.ID, key.Secret), until: until}
+		}
+
+		k.keys = append(k.keys, rk)
+	}
+
+	return k
+}
+
+// Retain - сохранение сроков действия прежних ключей из набора prev, которым заменяется набор k. Ключ, который
+// уже был прежним в prev, действует до своего прежнего срока, если он раньше нового, поэтому повторное создание
+// набора с тем же ключом его срок не продлевает.
+func (k *Keyring) Retain(prev *Keyring) {
+	for i := 1; i < len(k.keys); i++ {
+		rk := &k.keys[i]
+		if rk.public || rk.until.IsZero() {
+			continue
+		}
+
+		for j := 1; j < len(prev.keys); j++ {
+			pk := prev.keys[j]
+			if pk.public || pk.until.IsZero() || pk.hash.KeyID() != rk.hash.KeyID() {
+				continue
+			}
+
+			if pk.until.Before(rk.until) {
+				rk.until = pk.until
+			}
+		}
+	}
+}
+
+// Sign - подпись в формате sha256 текущим ключом, если текущий ключ открытый - nil.
+func (k *Keyring) Sign(data []byte) []byte {
+	return k.keys[0].hash.Sign(data)
+}
+
+// KeyID - идентификатор текущего ключа.
+func (k *Keyring) KeyID() string {
+	return k.keys[0].hash.KeyID()
+}
+
+// SignID - подпись в формате sha256 действующим ключом с идентификатором id, а если такого нет - текущим ключом.
+// Возвращается подпись и идентификатор ключа, которым она сделана.
+func (k *Keyring) SignID(id string, data []byte) ([]byte, string) {
+	for _, rk := range k.keys {
+		if id != "" && rk.hash.KeyID() == id && !rk.public && k.active(rk) {
+			return rk.hash.Sign(data), id
+		}
+	}
+
+	return k.Sign(data), k.KeyID()
+}
+
+// Check - проверка подписи в формате sha256 или Ed25519 по очереди каждым действующим ключом.
+func (k *Keyring) Check(data []byte, sign []byte) bool {
+	for _, rk := range k.keys {
+		if k.active(rk) && rk.hash.Check(data, sign) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CheckID - проверка подписи в формате sha256 или Ed25519 действующим ключом с идентификатором id.
+func (k *Keyring) CheckID(id string, data []byte, sign []byte) bool {
+	for _, rk := range k.keys {
+		if rk.hash.KeyID() == id {
+			return k.active(rk) && rk.hash.Check(data, sign)
+		}
+	}
+
+	return false
+}
+
+func (k *Keyring) active(rk ringKey) bool {
+	return rk.until.IsZero() || k.now().Before(rk.until)
+}
@@ -0,0 +1,164 @@
+package hash
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		keys []Key
+		err  bool
+	}{
+		{
+			name: "Пустая строка",
+			s:    "",
+		},
+		{
+			name: "Несколько ключей",
+			s:    "v2:new,v1:old:with:colons",
+			keys: []Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old:with:colons"}},
+		},
+		{
+			name: "Нет идентификатора",
+			s:    "v2:new,old",
+			err:  true,
+		},
+		{
+			name: "Пустой ключ",
+			s:    "v2:",
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := ParseKeys(test.s)
+			if test.err {
+				assert.ErrorIs(t, err, ErrKeyFormat)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.keys, keys)
+		})
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	data := []byte("подписываемые данные")
+	newSign := NewWithID("v2", "new").Sign(data)
+	oldSign := NewWithID("v1", "old").Sign(data)
+
+	k := NewKeyring([]Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old"}}, time.Minute)
+
+	assert.Equal(t, "v2", k.KeyID())
+	assert.Equal(t, newSign, k.Sign(data))
+
+	assert.True(t, k.Check(data, newSign))
+	assert.True(t, k.Check(data, oldSign))
+	assert.False(t, k.Check(data, []byte("wrong")))
+
+	sign, id := k.SignID("v1", data)
+	assert.Equal(t, oldSign, sign)
+	assert.Equal(t, "v1", id)
+
+	sign, id = k.SignID("v0", data)
+	assert.Equal(t, newSign, sign)
+	assert.Equal(t, "v2", id)
+
+	assert.True(t, k.CheckID("v2", data, newSign))
+	assert.True(t, k.CheckID("v1", data, oldSign))
+	assert.False(t, k.CheckID("v2", data, oldSign))
+	assert.False(t, k.CheckID("v0", data, oldSign))
+
+	// По истечении grace подписи прежним ключом не принимаются, а текущим - принимаются.
+	now := time.Now().Add(time.Hour)
+	k.now = func() time.Time { return now }
+
+	assert.True(t, k.Check(data, newSign))
+	assert.False(t, k.Check(data, oldSign))
+	assert.False(t, k.CheckID("v1", data, oldSign))
+
+	sign, id = k.SignID("v1", data)
+	assert.Equal(t, newSign, sign)
+	assert.Equal(t, "v2", id)
+
+	k = NewKeyring([]Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old"}}, 0)
+	k.now = func() time.Time { return now }
+	assert.True(t, k.CheckID("v1", data, oldSign))
+}
+
+func TestKeyringRetain(t *testing.T) {
+	data := []byte("подписываемые данные")
+	oldSign := NewWithID("v1", "old").Sign(data)
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+	keys := []Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old"}}
+
+	prev := newKeyring(keys, time.Minute, clock)
+
+	// Повторное создание набора с тем же прежним ключом не продлевает его срок.
+	now = now.Add(50 * time.Second)
+	k := newKeyring(keys, time.Minute, clock)
+	k.Retain(prev)
+	assert.True(t, k.CheckID("v1", data, oldSign))
+
+	now = now.Add(20 * time.Second)
+	assert.False(t, k.CheckID("v1", data, oldSign))
+
+	// Ключ, который был текущим, получает срок с момента создания набора.
+	prev = newKeyring([]Key{{ID: "v1", Secret: "old"}}, time.Minute, clock)
+	k = newKeyring(keys, time.Minute, clock)
+	k.Retain(prev)
+	now = now.Add(50 * time.Second)
+	assert.True(t, k.CheckID("v1", data, oldSign))
+
+	// Срок прежнего ключа без ограничения отсчитывается с момента создания набора.
+	prev = newKeyring(keys, 0, clock)
+	k = newKeyring(keys, time.Minute, clock)
+	k.Retain(prev)
+	now = now.Add(50 * time.Second)
+	assert.True(t, k.CheckID("v1", data, oldSign))
+	now = now.Add(20 * time.Second)
+	assert.False(t, k.CheckID("v1", data, oldSign))
+}
+
+func TestKeyringPublic(t *testing.T) {
+	data := []byte("подписываемые данные")
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	agent := NewEd25519("agent-1", prv)
+	sign := agent.Sign(data)
+
+	k := NewKeyring([]Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old"}, {ID: "agent-1", Public: pub}},
+		time.Minute)
+
+	assert.True(t, k.CheckID("agent-1", data, sign))
+	assert.True(t, k.Check(data, sign))
+	assert.False(t, k.CheckID("v2", data, sign))
+	assert.False(t, k.CheckID("agent-1", []byte("измененные данные"), sign))
+
+	// Открытым ключом агента ответ не подписать, ответ подписывается текущим ключом.
+	s, id := k.SignID("agent-1", data)
+	assert.Equal(t, "v2", id)
+	assert.Equal(t, NewWithID("v2", "new").Sign(data), s)
+
+	// Открытые ключи агентов не прежние ключи и действуют после grace.
+	k.now = func() time.Time { return time.Now().Add(time.Hour) }
+	assert.False(t, k.CheckID("v1", data, NewWithID("v1", "old").Sign(data)))
+	assert.True(t, k.CheckID("agent-1", data, sign))
+
+	// Без общих ключей ответы не подписываются.
+	k = NewKeyring([]Key{{ID: "agent-1", Public: pub}}, 0)
+	s, _ = k.SignID("agent-1", data)
+	assert.Nil(t, s)
+}
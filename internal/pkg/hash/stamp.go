@@ -0,0 +1,15 @@
+package hash
+
+// Stamp - подписываемые данные запроса с защитой от повторной отправки: метод method, адрес uri (путь
+// с параметрами запроса), время отправки timestamp (Unix-время в секундах), одноразовое значение nonce и тело
+// data через перевод строки. Получатель отклоняет запросы со старым временем и уже полученным одноразовым
+// значением, а подменить их, как и метод с адресом, нельзя без ключа подписи.
+func Stamp(method, uri, timestamp, nonce string, data []byte) []byte {
+	s := make([]byte, 0, len(method)+len(uri)+len(timestamp)+len(nonce)+4+len(data))
+	for _, v := range []string{method, uri, timestamp, nonce} {
+		s = append(s, v...)
+		s = append(s, '\n')
+	}
+
+	return append(s, data...)
+}
@@ -0,0 +1,147 @@
+// Package idempotency for keeping responses of already processed requests within a dedup window.
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("idempotency: key not found")
+	// ErrInProgress - ключ зарезервирован запросом, ответ на который еще не сохранен.
+	ErrInProgress = errors.New("idempotency: request in progress")
+)
+
+// Response - сохраненный ответ на запрос с заголовком Idempotency-Key.
+type Response struct {
+	Created     time.Time   `json:"created"`
+	Header      http.Header `json:"header"`
+	RequestHash string      `json:"request_hash"`
+	Body        []byte      `json:"body"`
+	Status      int         `json:"status"`
+}
+
+// Pending - ключ зарезервирован, но ответ еще не сохранен: запрос обрабатывается или его обработка прервалась.
+func (r *Response) Pending() bool {
+	return r.Status == 0
+}
+
+// NewKey - создание случайного ключа идемпотентности для запроса клиента.
+func NewKey() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("rand read error:%w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Memory - хранилище ответов в RAM, ответы старше окна window удаляются.
+type Memory struct {
+	responses map[string]*Response
+	now       func() time.Time
+	window    time.Duration
+	mutex     sync.Mutex
+}
+
+// NewMemory - создание хранилища ответов в RAM, где:
+//   - window - время, в течении которого ответ на запрос хранится.
+func NewMemory(window time.Duration) *Memory {
+	return &Memory{
+		responses: make(map[string]*Response),
+		now:       time.Now,
+		window:    window,
+	}
+}
+
+// Reserve - резервирует ключ key за запросом с хешем hash до сохранения ответа, попутно удаляя ответы
+// вышедшие за окно. Если по ключу уже сохранен ответ, то он возвращается без резервирования,
+// если ответ еще не сохранен - возвращается ErrInProgress.
+func (m *Memory) Reserve(_ context.Context, key, hash string) (*Response, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expire()
+
+	r, ok := m.responses[key]
+	switch {
+	case !ok:
+		m.responses[key] = &Response{Created: m.now(), RequestHash: hash}
+		return nil, true, nil
+	case r.Pending():
+		return nil, false, ErrInProgress
+	default:
+		return r, false, nil
+	}
+}
+
+// Release - снимает резервирование ключа key, если ответ по нему не сохранен.
+func (m *Memory) Release(_ context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	r, ok := m.responses[key]
+	if ok && r.Pending() {
+		delete(m.responses, key)
+	}
+
+	return nil
+}
+
+// Save - сохраняет ответ r по ключу key, попутно удаляя ответы вышедшие за окно.
+func (m *Memory) Save(_ context.Context, key string, r *Response) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.expire()
+	m.responses[key] = r
+
+	return nil
+}
+
+// Snapshot - возвращает копию всех ответов, которые еще находятся в окне.
+func (m *Memory) Snapshot() map[string]*Response {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	s := make(map[string]*Response, len(m.responses))
+	for k, v := range m.responses {
+		if !m.expired(v) {
+			s[k] = v
+		}
+	}
+
+	return s
+}
+
+// Restore - загружает ранее сохраненные ответы, ответы вне окна пропускаются.
+func (m *Memory) Restore(responses map[string]*Response) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for k, v := range responses {
+		if !m.expired(v) {
+			m.responses[k] = v
+		}
+	}
+}
+
+func (m *Memory) expire() {
+	for k, v := range m.responses {
+		if m.expired(v) {
+			delete(m.responses, k)
+		}
+	}
+}
+
+func (m *Memory) expired(r *Response) bool {
+	return m.now().Sub(r.Created) > m.window
+}
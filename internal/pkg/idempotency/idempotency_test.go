@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	m := NewMemory(time.Minute)
+	m.now = func() time.Time { return now }
+
+	err := m.Save(ctx, "key1", &Response{Status: 200, Created: now})
+	require.NoError(t, err)
+
+	assert.Contains(t, m.Snapshot(), "key1")
+
+	now = now.Add(2 * time.Minute)
+
+	assert.Empty(t, m.Snapshot())
+
+	m.Restore(map[string]*Response{
+		"key2": {Status: 200, Created: now},
+		"key3": {Status: 200, Created: now.Add(-time.Hour)},
+	})
+
+	s := m.Snapshot()
+	assert.Len(t, s, 1)
+	assert.Contains(t, s, "key2")
+}
+
+func TestMemoryReserve(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	m := NewMemory(time.Minute)
+	m.now = func() time.Time { return now }
+
+	_, reserved, err := m.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	_, reserved, err = m.Reserve(ctx, "key1", "hash1")
+	assert.ErrorIs(t, err, ErrInProgress)
+	assert.False(t, reserved)
+
+	err = m.Save(ctx, "key1", &Response{Status: 200, RequestHash: "hash1", Created: now})
+	require.NoError(t, err)
+
+	r, reserved, err := m.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 200, r.Status)
+
+	// Снимается только резервирование, сохраненный ответ остается.
+	require.NoError(t, m.Release(ctx, "key1"))
+	r, _, err = m.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.Equal(t, 200, r.Status)
+
+	_, reserved, err = m.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	require.NoError(t, m.Release(ctx, "key2"))
+
+	_, reserved, err = m.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Зарезервированный ключ освобождается по окончании окна.
+	now = now.Add(2 * time.Minute)
+
+	_, reserved, err = m.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestNewKey(t *testing.T) {
+	k1, err := NewKey()
+	require.NoError(t, err)
+	assert.Len(t, k1, 32)
+
+	k2, err := NewKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, k1, k2)
+}
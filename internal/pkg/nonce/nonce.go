@@ -0,0 +1,68 @@
+// Package nonce for bounded cache of already seen one-time values of signed requests.
+package nonce
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrFull - кеш заполнен значениями, срок которых еще не истек. Вытеснить такое значение нельзя, иначе
+// запрос с ним можно будет отправить повторно, поэтому новое значение не принимается.
+var ErrFull = errors.New("nonce cache is full")
+
+// Cache - ограниченный по размеру кеш одноразовых значений. Значение хранится до истечения его срока,
+// поэтому размер кеша должен покрывать число запросов за срок хранения.
+type Cache struct {
+	seen  map[string]time.Time
+	order []string
+	next  int
+	now   func() time.Time
+	mutex sync.Mutex
+}
+
+// NewCache - создание кеша одноразовых значений, где size - максимальное число хранимых значений.
+func NewCache(size int) *Cache {
+	if size < 1 {
+		size = 1
+	}
+
+	return &Cache{
+		seen:  make(map[string]time.Time, size),
+		order: make([]string, size),
+		now:   time.Now,
+	}
+}
+
+// Seen - было ли значение nonce уже получено. Если нет или срок значения истек, то значение запоминается
+// до момента until на место самого старого значения. Если срок самого старого значения не истек,
+// то возвращается ErrFull.
+func (c *Cache) Seen(nonce string, until time.Time) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+
+	u, ok := c.seen[nonce]
+	if ok && now.Before(u) {
+		return true, nil
+	}
+
+	if !ok {
+		old := c.order[c.next]
+		if old != "" {
+			if now.Before(c.seen[old]) {
+				return false, ErrFull
+			}
+
+			delete(c.seen, old)
+		}
+
+		c.order[c.next] = nonce
+		c.next = (c.next + 1) % len(c.order)
+	}
+
+	c.seen[nonce] = until
+
+	return false, nil
+}
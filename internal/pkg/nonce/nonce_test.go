@@ -0,0 +1,71 @@
+package nonce
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewCache(2)
+	c.now = func() time.Time { return now }
+
+	until := now.Add(time.Minute)
+
+	seen := func(nonce string, until time.Time) bool {
+		s, err := c.Seen(nonce, until)
+		require.NoError(t, err)
+		return s
+	}
+
+	assert.False(t, seen("n1", until))
+	assert.True(t, seen("n1", until))
+	assert.False(t, seen("n2", until.Add(time.Minute)))
+	assert.True(t, seen("n1", until))
+
+	// Срок самого старого значения истек, оно вытесняется.
+	now = now.Add(90 * time.Second)
+	assert.False(t, seen("n3", now.Add(time.Minute)))
+	assert.Len(t, c.seen, 2)
+	assert.True(t, seen("n2", until))
+	assert.True(t, seen("n3", until))
+
+	// Срок значения истек.
+	now = now.Add(2 * time.Minute)
+	assert.False(t, seen("n3", now.Add(time.Minute)))
+	assert.True(t, seen("n3", now.Add(time.Minute)))
+	assert.Len(t, c.seen, 2)
+}
+
+func TestCacheFull(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c := NewCache(2)
+	c.now = func() time.Time { return now }
+
+	until := now.Add(time.Minute)
+
+	for _, n := range []string{"captured", "n1"} {
+		s, err := c.Seen(n, until)
+		require.NoError(t, err)
+		require.False(t, s)
+	}
+
+	// Новые значения не вытесняют перехваченное, пока не истек его срок.
+	s, err := c.Seen("n2", until)
+	assert.ErrorIs(t, err, ErrFull)
+	assert.False(t, s)
+
+	s, err = c.Seen("captured", until)
+	assert.NoError(t, err)
+	assert.True(t, s)
+
+	now = until
+	s, err = c.Seen("n2", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, s)
+}
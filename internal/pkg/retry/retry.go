@@ -4,58 +4,178 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"syscall"
 	"time"
 
-	"github.com/jackc/pgerrcode"
-	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
 )
 
 var ErrMaxRetryReached = errors.New("retry: maximum number of retry reached")
 
+// Observer - интерфейс учета повторных выполнений функции в метриках.
+type Observer interface {
+	AddCounter(name string, delta int64)
+}
+
+// Classifier - классификатор ошибок: true, если выполнение функции, вернувшей ошибку, можно повторить.
+type Classifier func(error) bool
+
+// Policy - политика повторных выполнений функции.
+type Policy struct {
+	// MaxAttempts - максимальное число выполнений функции, включая первое, значение меньше 1 равносильно 1.
+	MaxAttempts int
+	// InitialInterval - верхняя граница ожидания перед первым повтором.
+	InitialInterval time.Duration
+	// MaxInterval - максимальная верхняя граница ожидания перед повтором.
+	MaxInterval time.Duration
+	// MaxElapsed - общее время выполнения с учетом всех повторов, после которого повторы прекращаются,
+	// значение 0 снимает ограничение.
+	MaxElapsed time.Duration
+	// Multiplier - во сколько раз растет верхняя граница ожидания с каждым повтором.
+	Multiplier float64
+}
+
+// DefaultPolicy - политика по умолчанию: до 3-х повторов с ожиданием до 1, 2 и 4 секунд, не дольше 15 секунд.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     4,
+		InitialInterval: time.Second,
+		MaxInterval:     5 * time.Second,
+		MaxElapsed:      15 * time.Second,
+		Multiplier:      2,
+	}
+}
+
+// backoff - верхняя граница ожидания перед повтором номер n (начиная с 0): экспоненциальный рост
+// от InitialInterval, но не больше MaxInterval.
+func (p Policy) backoff(n int) time.Duration {
+	d := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(n))
+	if p.MaxInterval > 0 && d > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+
+	return time.Duration(d)
+}
+
 type retry struct {
-	intervals []time.Duration
+	observer Observer
+	classify Classifier
+	jitter   func(time.Duration) time.Duration
+	now      func() time.Time
+	policy   Policy
 }
 
-// New - создание ретрайера, повтореное выполнение функции в зависимости от возвращаемой ею ошибки.
-func New() *retry {
+// New - создание ретрайера, повтореное выполнение функции в зависимости от возвращаемой ею ошибки, где:
+//   - p - политика повторных выполнений;
+//   - c - классификатор ошибок, если nil, то функция не повторяется;
+//   - o - учет повторных выполнений функции в метриках, может быть nil.
+func New(p Policy, c Classifier, o Observer) *retry {
 	return &retry{
-		intervals: []time.Duration{
-			time.Duration(1) * time.Second,
-			//nolint //2-ой повтор через 3 секунды
-			time.Duration(3) * time.Second,
-			//nolint //3-ой повтор через 5 секунд
-			time.Duration(5) * time.Second,
-		},
+		observer: o,
+		classify: c,
+		jitter:   fullJitter,
+		now:      time.Now,
+		policy:   p,
 	}
 }
 
 // Retry - запуск ретрайера, где:
 //   - ctx - контекст для отмены выполнения ретрайера;
-//   - check - функция проверки ошибки выполнения функции fnc;
-//   - fnc - данная фукнция выполняется повторно, если функция check возвращает true.
-func (r *retry) Retry(ctx context.Context, check func(error) bool, fnc func() error) error {
+//   - fnc - данная фукнция выполняется повторно, если классификатор относит ее ошибку к повторяемым.
+//
+// Перед каждым повтором ретрайер ждет случайное время от 0 до текущей верхней границы ожидания (full jitter).
+// Если повторы исчерпаны, то возвращается ErrMaxRetryReached вместе с последней ошибкой функции.
+func (r *retry) Retry(ctx context.Context, fnc func() error) error {
+	start := r.now()
 	err := fnc()
 
-	for _, interval := range r.intervals {
-		if check(err) {
-			err = wait(ctx, interval)
-			if err != nil {
-				return err
-			}
-			err = fnc()
-			continue
+	for attempt := 1; ; attempt++ {
+		if err == nil || r.classify == nil || !r.classify(err) {
+			return err
+		}
+
+		if attempt >= r.policy.MaxAttempts {
+			return r.giveUp(attempt, err)
+		}
+
+		d := r.jitter(r.policy.backoff(attempt - 1))
+		if r.policy.MaxElapsed > 0 && r.now().Add(d).Sub(start) > r.policy.MaxElapsed {
+			return r.giveUp(attempt, err)
+		}
+
+		log.Warn().Err(err).Int("attempt", attempt).Dur("wait", d).Msg("retry")
+
+		wErr := wait(ctx, d)
+		if wErr != nil {
+			return wErr
+		}
+
+		if r.observer != nil {
+			r.observer.AddCounter("retry_attempts_total", 1)
 		}
 
-		return err
+		err = fnc()
+	}
+}
+
+func (r *retry) giveUp(attempts int, err error) error {
+	log.Error().Err(err).Int("attempts", attempts).Msg("retry give up")
+
+	if r.observer != nil {
+		r.observer.AddCounter("retry_give_ups_total", 1)
 	}
 
-	return ErrMaxRetryReached
+	return fmt.Errorf("%w:%w", ErrMaxRetryReached, err)
 }
 
-// IsConnectionException - проверка ошибки соединения с БД PostgreSQL.
-func IsConnectionException(err error) bool {
-	var pgErr *pgconn.PgError
-	return errors.As(err, &pgErr) && pgerrcode.IsConnectionException(pgErr.Code)
+// fullJitter - случайное время ожидания от 0 до d.
+func fullJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+
+	//nolint:gosec // для разброса времени ожидания криптостойкость не нужна
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// Any - классификатор, относящий ошибку к повторяемым, если так ее классифицирует хотя бы один из cs.
+func Any(cs ...Classifier) Classifier {
+	return func(err error) bool {
+		for _, c := range cs {
+			if c(err) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// IsTemporary - проверка временной ошибки, общей для всех хранилищ: истечение времени ожидания,
+// ошибки сети и прерванные или временно невозможные системные вызовы.
+func IsTemporary(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var nErr net.Error
+	if errors.As(err, &nErr) && nErr.Timeout() {
+		return true
+	}
+
+	for _, e := range []error{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.EPIPE,
+		syscall.EAGAIN, syscall.EINTR, syscall.EBUSY} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func wait(ctx context.Context, interval time.Duration) error {
@@ -0,0 +1,192 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTemporary = errors.New("temporary")
+
+type observer struct {
+	counters map[string]int64
+}
+
+func (o *observer) AddCounter(name string, delta int64) {
+	if o.counters == nil {
+		o.counters = make(map[string]int64)
+	}
+	o.counters[name] += delta
+}
+
+func isTemporary(err error) bool {
+	return errors.Is(err, errTemporary)
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	p := Policy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		want time.Duration
+	}{
+		{name: "first retry", n: 0, want: 100 * time.Millisecond},
+		{name: "second retry", n: 1, want: 200 * time.Millisecond},
+		{name: "fourth retry", n: 3, want: 800 * time.Millisecond},
+		{name: "capped by max interval", n: 4, want: time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, p.backoff(test.n))
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Policy
+		classify  Classifier
+		errs      []error
+		wantErr   error
+		wantCalls int
+		counters  map[string]int64
+	}{
+		{
+			name:      "success on first call",
+			policy:    Policy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 2},
+			classify:  isTemporary,
+			errs:      []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "success after retries",
+			policy:    Policy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 2},
+			classify:  isTemporary,
+			errs:      []error{errTemporary, errTemporary, nil},
+			wantCalls: 3,
+			counters:  map[string]int64{"retry_attempts_total": 2},
+		},
+		{
+			name:      "give up after max attempts",
+			policy:    Policy{MaxAttempts: 2, InitialInterval: time.Millisecond, Multiplier: 2},
+			classify:  isTemporary,
+			errs:      []error{errTemporary, errTemporary, nil},
+			wantErr:   ErrMaxRetryReached,
+			wantCalls: 2,
+			counters:  map[string]int64{"retry_attempts_total": 1, "retry_give_ups_total": 1},
+		},
+		{
+			name:      "give up after max elapsed",
+			policy:    Policy{MaxAttempts: 10, InitialInterval: time.Second, MaxElapsed: 1500 * time.Millisecond, Multiplier: 2},
+			classify:  isTemporary,
+			errs:      []error{errTemporary, errTemporary, errTemporary},
+			wantErr:   ErrMaxRetryReached,
+			wantCalls: 2,
+			counters:  map[string]int64{"retry_attempts_total": 1, "retry_give_ups_total": 1},
+		},
+		{
+			name:      "non retryable error",
+			policy:    Policy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 2},
+			classify:  isTemporary,
+			errs:      []error{errors.New("fatal"), nil},
+			wantErr:   errors.New("fatal"),
+			wantCalls: 1,
+		},
+		{
+			name:      "nil classifier disables retry",
+			policy:    Policy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 2},
+			errs:      []error{errTemporary, nil},
+			wantErr:   errTemporary,
+			wantCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := &observer{}
+			r := New(test.policy, test.classify, o)
+
+			// Время идет только во время ожидания, ожидание всегда равно верхней границе.
+			now := time.Now()
+			r.now = func() time.Time { return now }
+			r.jitter = func(d time.Duration) time.Duration {
+				now = now.Add(d)
+				return 0
+			}
+
+			calls := 0
+			err := r.Retry(context.Background(), func() error {
+				err := test.errs[calls]
+				calls++
+				return err
+			})
+
+			assert.Equal(t, test.wantCalls, calls)
+			if test.wantErr == nil {
+				assert.NoError(t, err)
+			} else if errors.Is(test.wantErr, ErrMaxRetryReached) {
+				assert.ErrorIs(t, err, ErrMaxRetryReached)
+				assert.ErrorIs(t, err, errTemporary)
+			} else {
+				assert.EqualError(t, err, test.wantErr.Error())
+			}
+			assert.Equal(t, test.counters, o.counters)
+		})
+	}
+}
+
+func TestRetryContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r := New(Policy{MaxAttempts: 3, InitialInterval: time.Hour, Multiplier: 2}, isTemporary, nil)
+
+	calls := 0
+	err := r.Retry(ctx, func() error {
+		calls++
+		return errTemporary
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
+
+func TestIsTemporary(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "deadline exceeded", err: fmt.Errorf("query error:%w", context.DeadlineExceeded), want: true},
+		{name: "connection refused", err: fmt.Errorf("dial error:%w", syscall.ECONNREFUSED), want: true},
+		{name: "connection reset", err: syscall.ECONNRESET, want: true},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "other", err: errors.New("other"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, IsTemporary(test.err))
+		})
+	}
+}
+
+func TestAny(t *testing.T) {
+	c := Any(IsTemporary, isTemporary)
+
+	assert.True(t, c(errTemporary))
+	assert.True(t, c(syscall.EPIPE))
+	assert.False(t, c(errors.New("other")))
+}
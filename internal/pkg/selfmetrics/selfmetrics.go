@@ -0,0 +1,95 @@
+// Package selfmetrics for collecting metrics of the server itself under the reserved prefix.
+package selfmetrics
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Prefix - зарезервированный префикс имен метрик самого сервера.
+const Prefix = "metrics_server_"
+
+// buckets - верхние границы (в секундах) корзин гистограмм длительности.
+var buckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// Registry - реестр метрик сервера.
+type Registry struct {
+	counter map[string]int64
+	gauge   map[string]float64
+	mutex   sync.Mutex
+}
+
+// New - создание реестра метрик сервера.
+func New() *Registry {
+	return &Registry{
+		counter: make(map[string]int64),
+		gauge:   make(map[string]float64),
+	}
+}
+
+// IsReserved - имя метрики name относится к метрикам сервера?
+func IsReserved(name string) bool {
+	return strings.HasPrefix(name, Prefix)
+}
+
+// AddCounter - увеличивает метрику типа counter с именем Prefix+name на delta.
+func (r *Registry) AddCounter(name string, delta int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.counter[Prefix+name] += delta
+}
+
+// SetGauge - устанавливает метрике типа gauge с именем Prefix+name значение value.
+func (r *Registry) SetGauge(name string, value float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.gauge[Prefix+name] = value
+}
+
+// ObserveDuration - добавляет длительность d в гистограмму с именем Prefix+name. Гистограмма представлена
+// метриками типа counter `<name>_bucket_le_<граница>` и `<name>_count` и метрикой типа gauge `<name>_sum`.
+func (r *Registry) ObserveDuration(name string, d time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := d.Seconds()
+	for _, b := range buckets {
+		if s <= b {
+			r.counter[Prefix+name+"_bucket_le_"+strconv.FormatFloat(b, 'f', -1, 64)]++
+		}
+	}
+	r.counter[Prefix+name+"_bucket_le_inf"]++
+	r.counter[Prefix+name+"_count"]++
+	r.gauge[Prefix+name+"_sum"] += s
+}
+
+// GetAll - возвращает копию всех метрик сервера вместе с текущей статистикой рантайма Go.
+func (r *Registry) GetAll() (map[string]int64, map[string]float64) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	c := make(map[string]int64, len(r.counter)+1)
+	for k, v := range r.counter {
+		c[k] = v
+	}
+	c[Prefix+"go_gc_count"] = int64(ms.NumGC)
+
+	g := make(map[string]float64, len(r.gauge)+5)
+	for k, v := range r.gauge {
+		g[k] = v
+	}
+	g[Prefix+"go_goroutines"] = float64(runtime.NumGoroutine())
+	g[Prefix+"go_heap_alloc_bytes"] = float64(ms.HeapAlloc)
+	g[Prefix+"go_heap_sys_bytes"] = float64(ms.HeapSys)
+	g[Prefix+"go_gc_pause_total_seconds"] = time.Duration(ms.PauseTotalNs).Seconds()
+
+	return c, g
+}
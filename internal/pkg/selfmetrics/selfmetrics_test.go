@@ -0,0 +1,32 @@
+package selfmetrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := New()
+
+	r.AddCounter("requests_total", 1)
+	r.AddCounter("requests_total", 2)
+	r.SetGauge("file_flush_size_bytes", 10)
+	r.ObserveDuration("request_duration_seconds", 20*time.Millisecond)
+
+	c, g := r.GetAll()
+
+	assert.Equal(t, int64(3), c["metrics_server_requests_total"])
+	assert.Equal(t, float64(10), g["metrics_server_file_flush_size_bytes"])
+
+	assert.Equal(t, int64(1), c["metrics_server_request_duration_seconds_count"])
+	assert.Equal(t, int64(1), c["metrics_server_request_duration_seconds_bucket_le_0.05"])
+	assert.Equal(t, int64(1), c["metrics_server_request_duration_seconds_bucket_le_inf"])
+	assert.NotContains(t, c, "metrics_server_request_duration_seconds_bucket_le_0.01")
+	assert.InDelta(t, 0.02, g["metrics_server_request_duration_seconds_sum"], 1e-9)
+
+	assert.Contains(t, g, "metrics_server_go_goroutines")
+	assert.True(t, IsReserved("metrics_server_go_goroutines"))
+	assert.False(t, IsReserved("Alloc"))
+}
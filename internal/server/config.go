@@ -9,7 +9,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/k0st1a/metrics/internal/pkg/crypto/pemkey"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
 	"github.com/k0st1a/metrics/internal/pkg/netaddr"
+	"github.com/k0st1a/metrics/internal/pkg/retry"
 )
 
 // Config - структура с конфигурационными параметрами сервера.
@@ -17,6 +20,49 @@ type Config struct {
 	// DatabaseDSN - cтрока с адресом подключения к БД.
 	// Задается через флаг `-d=<ЗНАЧЕНИЕ>` или переменную окружения `DATABASE_DSN=<ЗНАЧЕНИЕ>`
 	DatabaseDSN string
+	// HistoryRetentionDays - сколько дней хранится история изменений метрик в БД (по умолчанию 7 дней,
+	// значение `0` отключает удаление истории).
+	// Задается через флаг `-history-retention-days=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `HISTORY_RETENTION_DAYS=<ЗНАЧЕНИЕ>`
+	HistoryRetentionDays int
+	// BreakerThreshold - число ошибок БД подряд, после которого сервер перестает обращаться к БД: метрики
+	// копятся в буфере и переносятся в БД, когда она снова доступна, а чтение идет из последних известных
+	// значений (по умолчанию 3, значение `0` отключает функцию).
+	// Задается через флаг `-breaker-threshold=<ЗНАЧЕНИЕ>` или переменную окружения `BREAKER_THRESHOLD=<ЗНАЧЕНИЕ>`
+	BreakerThreshold int
+	// BreakerOpenTimeout - время, по истечении которого сервер снова пробует обратиться к недоступной БД
+	// (по умолчанию `10s`).
+	// Задается через флаг `-breaker-open-timeout=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `BREAKER_OPEN_TIMEOUT=<ЗНАЧЕНИЕ>`
+	BreakerOpenTimeout time.Duration
+	// BreakerBufferSize - максимальное число разных метрик в буфере на время недоступности БД
+	// (по умолчанию 10000, значение `0` снимает ограничение).
+	// Задается через флаг `-breaker-buffer-size=<ЗНАЧЕНИЕ>` или переменную окружения `BREAKER_BUFFER_SIZE=<ЗНАЧЕНИЕ>`
+	BreakerBufferSize int
+	// BreakerSpillPath - полное имя файла, в котором дублируется буфер на время недоступности БД, чтобы он
+	// пережил перезапуск сервера (по умолчанию пустая строка, что отключает функцию).
+	// Задается через флаг `-breaker-spill-path=<ЗНАЧЕНИЕ>` или переменную окружения `BREAKER_SPILL_PATH=<ЗНАЧЕНИЕ>`
+	BreakerSpillPath string
+	// SQLitePath - путь до файла встроенной БД SQLite (по умолчанию пустая строка). Если путь задан и не задан
+	// DatabaseDSN, то метрики хранятся в SQLite.
+	// Задается через флаг `-sqlite-path=<ЗНАЧЕНИЕ>` или переменную окружения `SQLITE_PATH=<ЗНАЧЕНИЕ>`
+	SQLitePath string
+	// BoltPath - путь до файла встроенной БД bbolt (по умолчанию пустая строка). Если путь задан и не заданы
+	// DatabaseDSN и SQLitePath, то метрики хранятся в bbolt, а копию БД можно скачать по `GET /api/v1/backup`
+	// с подписью запроса ключом HashKey или HashKeys.
+	// Задается через флаг `-bolt-path=<ЗНАЧЕНИЕ>` или переменную окружения `BOLT_PATH=<ЗНАЧЕНИЕ>`
+	BoltPath string
+	// BoltHistory - булево значение (`true/false`), определяющее, сохранять ли в bbolt историю изменений каждой
+	// метрики (по умолчанию `false`).
+	// Задается через флаг `-bolt-history=<ЗНАЧЕНИЕ>` или переменную окружения `BOLT_HISTORY=<ЗНАЧЕНИЕ>`
+	BoltHistory bool
+	// RedisAddr - адрес Redis (по умолчанию пустая строка). Если адрес задан и не заданы DatabaseDSN, SQLitePath
+	// и BoltPath, то метрики хранятся в Redis, и несколько серверов могут использовать общие метрики.
+	// Задается через флаг `-redis-addr=<ЗНАЧЕНИЕ>` или переменную окружения `REDIS_ADDR=<ЗНАЧЕНИЕ>`
+	RedisAddr string
+	// RedisNamespace - пространство имен ключей метрик в Redis (по умолчанию `metrics`).
+	// Задается через флаг `-redis-namespace=<ЗНАЧЕНИЕ>` или переменную окружения `REDIS_NAMESPACE=<ЗНАЧЕНИЕ>`
+	RedisNamespace string
 	// ServerAddr - адрес эндпоинта HTTP-сервера (по умолчанию `localhost:8080`).
 	// Задается через флаг `-a=<ЗНАЧЕНИЕ>` или переменную окружения `ADDRESS=<ЗНАЧЕНИЕ>`
 	ServerAddr string
@@ -25,15 +71,66 @@ type Config struct {
 	// Задается через флаг `-f=<ЗНАЧЕНИЕ>` или переменную окружения `FILE_STORAGE_PATH=<ЗНАЧЕНИЕ>`
 	FileStoragePath string
 	// HashKey - ключ для подписи передаваемых данных по алгоритму SHA256 (по умолчанию пустая строка).
+	// Если задан хотя бы один ключ подписи, то запросы без подписи отклоняются, кроме `/ping`, `/healthz`,
+	// `/readyz` и `/api/v1/public-key`. Удаление метрики `DELETE /value/{type}/{name}` и выгрузка всех метрик
+	// `GET /values/`, а также копия БД `GET /api/v1/backup` доступны только с подписью, поэтому без ключей
+	// подписи они отклоняются.
 	// Задается через флаг `-k=<ЗНАЧЕНИЕ>` или переменную окружения `KEY=<ЗНАЧЕНИЕ>`
 	HashKey string
+	// HashKeys - ключи подписи с идентификаторами в виде `<ИДЕНТИФИКАТОР>:<КЛЮЧ>[,<ИДЕНТИФИКАТОР>:<КЛЮЧ>...]`
+	// (по умолчанию пустая строка). Подпись запроса проверяется ключом с идентификатором из заголовка
+	// `HashKeyID`, а без заголовка - по очереди всеми ключами, включая HashKey. Первый ключ текущий, остальные -
+	// прежние, которые принимаются в течении HashKeyGrace, что позволяет менять ключ без одновременного
+	// перезапуска всех агентов и серверов.
+	// Задается через флаг `-keys=<ЗНАЧЕНИЕ>` или переменную окружения `KEYS=<ЗНАЧЕНИЕ>`
+	HashKeys string
+	// HashKeyGrace - сколько после запуска сервера или применения конфигурации по SIGHUP, в которой ключ стал
+	// прежним, принимаются подписи прежними ключами из HashKeys и ключом HashKey, если заданы HashKeys
+	// (по умолчанию `0`, без ограничения). Повторное применение конфигурации срок прежнего ключа не продлевает.
+	// Задается через флаг `-key-grace=<ЗНАЧЕНИЕ>` или переменную окружения `KEY_GRACE=<ЗНАЧЕНИЕ>`
+	HashKeyGrace time.Duration
+	// HashPublicKeys - открытые ключи Ed25519 агентов с идентификаторами в виде
+	// `<ИДЕНТИФИКАТОР>:<ПУТЬ>[,<ИДЕНТИФИКАТОР>:<ПУТЬ>...]`, где путь - файл ключа в формате PEM (по умолчанию
+	// пустая строка). Агент подписывает запросы своим закрытым ключом, поэтому не может подделать данные
+	// других агентов, а сервер проверяет подпись так же, как подпись ключами HashKeys.
+	// Задается через флаг `-public-keys=<ЗНАЧЕНИЕ>` или переменную окружения `PUBLIC_KEYS=<ЗНАЧЕНИЕ>`
+	HashPublicKeys string
+	// SignMaxSkew - допустимое расхождение времени подписанного запроса из заголовка `HashTimestamp` со временем
+	// сервера (по умолчанию `5m`). Запросы вне окна и запросы с уже полученным значением заголовка `HashNonce`
+	// отклоняются, что защищает от повторной отправки перехваченного запроса. Значение `0` выключает защиту
+	// и разрешает подписи только тела запроса от агентов прежних версий на время их обновления.
+	// Задается через флаг `-sign-max-skew=<ЗНАЧЕНИЕ>` или переменную окружения `SIGN_MAX_SKEW=<ЗНАЧЕНИЕ>`
+	SignMaxSkew time.Duration
+	// SignNonceCacheSize - максимальное число запоминаемых значений заголовка `HashNonce` (по умолчанию 100000),
+	// должно покрывать число подписанных запросов за 2*SignMaxSkew. Пока кеш заполнен значениями с неистекшим
+	// сроком, новые подписанные запросы отклоняются с кодом 503.
+	// Задается через флаг `-sign-nonce-cache-size=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `SIGN_NONCE_CACHE_SIZE=<ЗНАЧЕНИЕ>`
+	SignNonceCacheSize int
 	// CryptoKey - путь до файла с приватным ключом (по умолчанию пустая строка). Если путь задан, то
 	// с помощью приватного ключа будут дешифровываться сообщения, получаемые сервером.
 	// Задается через флаг `-crypto-key=<ЗНАЧЕНИЕ>` или переменную окружения `CRYPTO_KEY=<ЗНАЧЕНИЕ>`
 	CryptoKey string
+	// KeyPassphraseFile - путь до файла с парольной фразой зашифрованного ключа CryptoKey (по умолчанию пустая
+	// строка). Без файла парольная фраза берется из переменной окружения `KEY_PASSPHRASE`.
+	// Задается через флаг `-key-passphrase-file=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `KEY_PASSPHRASE_FILE=<ЗНАЧЕНИЕ>`
+	KeyPassphraseFile string
+	// DecompressLimit - максимальный размер тела запроса в байтах после распаковки по заголовку
+	// `Content-Encoding` (по умолчанию 10485760). Защищает сервер от сжатых данных, распаковывающихся
+	// в гигабайты, запросы сверх ограничения отклоняются с кодом 413.
+	// Задается через флаг `-decompress-limit=<ЗНАЧЕНИЕ>` или переменную окружения `DECOMPRESS_LIMIT=<ЗНАЧЕНИЕ>`
+	DecompressLimit int64
+	// CompressMinSize - минимальный размер ответа в байтах, начиная с которого ответ сжимается
+	// (по умолчанию 1024). Задается через флаг `-compress-min-size=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `COMPRESS_MIN_SIZE=<ЗНАЧЕНИЕ>`
+	CompressMinSize int
 	// PprofServerAddr - адрес эндпоинта HTTP-сервера профилировщика pprof (по умолчанию `localhost:8086`).
 	// Задается через флаг `-p=<ЗНАЧЕНИЕ>` или переменную окружения `PPROF_ADDRESS=<ЗНАЧЕНИЕ>`
 	PprofServerAddr string
+	// LogLevel - уровень логирования: `trace`, `debug`, `info`, `warn`, `error` (по умолчанию `debug`).
+	// Задается через флаг `-log-level=<ЗНАЧЕНИЕ>` или переменную окружения `LOG_LEVEL=<ЗНАЧЕНИЕ>`
+	LogLevel string
 	// Config - путь до файла конфигурации сервера (по умолчанию пустая строка).
 	// Задается через флаг `-c=<ЗНАЧЕНИЕ>` или переменную окружения `CONFIG=<ЗНАЧЕНИЕ>`
 	Config string
@@ -45,25 +142,92 @@ type Config struct {
 	// указанного файла при старте сервера (по умолчанию `true`).
 	// Задается через флаг `-r=<ЗНАЧЕНИЕ>` или переменную окружения `RESTORE=<ЗНАЧЕНИЕ>`
 	Restore bool
+	// WAL - булево значение (`true/false`), определяющее, писать ли каждое изменение метрик в журнал
+	// `<FileStoragePath>.wal` (по умолчанию `false`). С журналом значения по пути FileStoragePath сохраняются
+	// снимком раз в StoreInterval секунд (при `0` - по достижении журналом размера 4 МиБ), после чего журнал
+	// очищается, а при старте сервера с Restore снимок дополняется записями журнала.
+	// Задается через флаг `-wal=<ЗНАЧЕНИЕ>` или переменную окружения `WAL=<ЗНАЧЕНИЕ>`
+	WAL bool
+	// IdempotencyWindow - время в секундах, в течении которого сервер хранит ответы на запросы с заголовком
+	// `Idempotency-Key` и отдает их на повторные запросы с тем же ключом (по умолчанию 300 секунд,
+	// значение `0` отключает функцию). Ответы хранятся в том же хранилище, что и метрики, с Redis ключи
+	// идемпотентности общие для всех серверов пространства имен. С БД и включенным BreakerThreshold ответы
+	// хранятся на сервере (в файле `<BreakerSpillPath>.idempotency`, если задан BreakerSpillPath), чтобы
+	// пакеты агента попадали в буфер и при недоступной БД.
+	// Задается через флаг `-idempotency-window=<ЗНАЧЕНИЕ>` или переменную окружения `IDEMPOTENCY_WINDOW=<ЗНАЧЕНИЕ>`
+	IdempotencyWindow int
+	// RetryMaxAttempts - максимальное число обращений к хранилищу при временных ошибках, включая первое
+	// (по умолчанию 4).
+	// Задается через флаг `-retry-max-attempts=<ЗНАЧЕНИЕ>` или переменную окружения `RETRY_MAX_ATTEMPTS=<ЗНАЧЕНИЕ>`
+	RetryMaxAttempts int
+	// RetryInitialInterval - верхняя граница случайного ожидания перед первым повторным обращением к хранилищу,
+	// с каждым повтором она удваивается (по умолчанию `1s`).
+	// Задается через флаг `-retry-initial-interval=<ЗНАЧЕНИЕ>` или переменную окружения
+	// `RETRY_INITIAL_INTERVAL=<ЗНАЧЕНИЕ>`
+	RetryInitialInterval time.Duration
+	// RetryMaxInterval - максимальная верхняя граница ожидания перед повторным обращением к хранилищу
+	// (по умолчанию `5s`).
+	// Задается через флаг `-retry-max-interval=<ЗНАЧЕНИЕ>` или переменную окружения `RETRY_MAX_INTERVAL=<ЗНАЧЕНИЕ>`
+	RetryMaxInterval time.Duration
+	// RetryMaxElapsed - общее время обращения к хранилищу с учетом повторов, после которого повторы прекращаются
+	// (по умолчанию `15s`, значение `0` снимает ограничение).
+	// Задается через флаг `-retry-max-elapsed=<ЗНАЧЕНИЕ>` или переменную окружения `RETRY_MAX_ELAPSED=<ЗНАЧЕНИЕ>`
+	RetryMaxElapsed time.Duration
 }
 
 const (
-	defaultServerAddr      = "localhost:8080"
-	defaultStoreInterval   = 300
-	defaultFileStoragePath = "/tmp/metrics-db.json"
-	defaultRestore         = true
-	defaultDatabaseDSN     = ""
-	defaultHashKey         = ""
-	defaultCryptoKey       = ""
-	defaultPprofServerAddr = "localhost:8086"
-	defaultConfig          = ""
+	defaultServerAddr        = "localhost:8080"
+	defaultStoreInterval     = 300
+	defaultFileStoragePath   = "/tmp/metrics-db.json"
+	defaultRestore           = true
+	defaultWAL               = false
+	defaultDatabaseDSN       = ""
+	defaultSQLitePath        = ""
+	defaultHistoryRetention  = 7
+	defaultBreakerThreshold  = 3
+	defaultBreakerTimeout    = 10 * time.Second
+	defaultBreakerBufferSize = 10000
+	defaultBreakerSpillPath  = ""
+	defaultBoltPath          = ""
+	defaultBoltHistory       = false
+	defaultRedisAddr         = ""
+	defaultRedisNamespace    = "metrics"
+	defaultHashKey           = ""
+	defaultHashKeys          = ""
+	defaultHashKeyGrace      = 0
+	defaultHashPublicKeys    = ""
+	defaultSignMaxSkew       = 5 * time.Minute
+	defaultSignNonceCache    = 100000
+	defaultCryptoKey         = ""
+	defaultKeyPassphraseFile = ""
+	defaultDecompressLimit   = 10 * 1024 * 1024
+	defaultCompressMinSize   = 1024
+	defaultPprofServerAddr   = "localhost:8086"
+	defaultConfig            = ""
+	defaultLogLevel          = "debug"
+	defaultIdempotencyWindow = 300
+	defaultRetryMaxAttempts  = 4
+	defaultRetryInitial      = time.Second
+	defaultRetryMaxInterval  = 5 * time.Second
+	defaultRetryMaxElapsed   = 15 * time.Second
+	retryMultiplier          = 2
 )
 
 // NewConfig - создать конфигурацию сервера из файла конфигурации, аргументов командой строки и переменных окружения.
 func NewConfig() (*Config, error) {
+	return newConfig(flag.CommandLine, os.Args[1:])
+}
+
+// ReloadConfig - повторно прочитать конфигурацию сервера из тех же источников, что и NewConfig, не трогая
+// флаги командной строки процесса. Используется для применения конфигурации без перезапуска сервера.
+func ReloadConfig() (*Config, error) {
+	return newConfig(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+}
+
+func newConfig(fs *flag.FlagSet, args []string) (*Config, error) {
 	var path string
 
-	flag.StringVar(&path, "c", defaultConfig,
+	fs.StringVar(&path, "c", defaultConfig,
 		"Путь до файла конфигурации сервера (по умолчанию пустая строка).\n"+
 			"Задается через флаг `-c=<ЗНАЧЕНИЕ>` или переменную окружения `CONFIG=<ЗНАЧЕНИЕ>`")
 
@@ -82,7 +246,7 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
-	err := cfg.applyFromArgsAndEnv()
+	err := cfg.applyFromArgsAndEnv(fs, args)
 	if err != nil {
 		return nil, fmt.Errorf("apply config from args and env:%w", err)
 	}
@@ -92,51 +256,202 @@ func NewConfig() (*Config, error) {
 
 func newDefaultConfig() *Config {
 	return &Config{
-		DatabaseDSN:     defaultDatabaseDSN,
-		ServerAddr:      defaultServerAddr,
-		FileStoragePath: defaultFileStoragePath,
-		HashKey:         defaultHashKey,
-		CryptoKey:       defaultCryptoKey,
-		PprofServerAddr: defaultPprofServerAddr,
-		Config:          defaultConfig,
-		StoreInterval:   defaultStoreInterval,
-		Restore:         defaultRestore,
+		DatabaseDSN:          defaultDatabaseDSN,
+		ServerAddr:           defaultServerAddr,
+		FileStoragePath:      defaultFileStoragePath,
+		HashKey:              defaultHashKey,
+		HashKeys:             defaultHashKeys,
+		HashKeyGrace:         defaultHashKeyGrace,
+		HashPublicKeys:       defaultHashPublicKeys,
+		SignMaxSkew:          defaultSignMaxSkew,
+		SignNonceCacheSize:   defaultSignNonceCache,
+		CryptoKey:            defaultCryptoKey,
+		KeyPassphraseFile:    defaultKeyPassphraseFile,
+		DecompressLimit:      defaultDecompressLimit,
+		CompressMinSize:      defaultCompressMinSize,
+		PprofServerAddr:      defaultPprofServerAddr,
+		Config:               defaultConfig,
+		LogLevel:             defaultLogLevel,
+		StoreInterval:        defaultStoreInterval,
+		Restore:              defaultRestore,
+		SQLitePath:           defaultSQLitePath,
+		HistoryRetentionDays: defaultHistoryRetention,
+		BreakerThreshold:     defaultBreakerThreshold,
+		BreakerOpenTimeout:   defaultBreakerTimeout,
+		BreakerBufferSize:    defaultBreakerBufferSize,
+		BreakerSpillPath:     defaultBreakerSpillPath,
+		BoltPath:             defaultBoltPath,
+		BoltHistory:          defaultBoltHistory,
+		RedisAddr:            defaultRedisAddr,
+		RedisNamespace:       defaultRedisNamespace,
+		WAL:                  defaultWAL,
+		IdempotencyWindow:    defaultIdempotencyWindow,
+		RetryMaxAttempts:     defaultRetryMaxAttempts,
+		RetryInitialInterval: defaultRetryInitial,
+		RetryMaxInterval:     defaultRetryMaxInterval,
+		RetryMaxElapsed:      defaultRetryMaxElapsed,
+	}
+}
+
+// RetryPolicy - политика повторных обращений к хранилищу, заданная конфигурацией.
+func (c *Config) RetryPolicy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts:     c.RetryMaxAttempts,
+		InitialInterval: c.RetryInitialInterval,
+		MaxInterval:     c.RetryMaxInterval,
+		MaxElapsed:      c.RetryMaxElapsed,
+		Multiplier:      retryMultiplier,
+	}
+}
+
+// SignKeys - ключи подписи запросов: ключи HashKeys, начиная с текущего, ключ HashKey без идентификатора
+// и последними открытые ключи агентов из файлов HashPublicKeys.
+func (c *Config) SignKeys() ([]hash.Key, error) {
+	keys, err := hash.ParseKeys(c.HashKeys)
+	if err != nil {
+		return nil, fmt.Errorf("hash keys parse error:%w", err)
+	}
+
+	if c.HashKey != "" {
+		keys = append(keys, hash.Key{Secret: c.HashKey})
+	}
+
+	pks, err := hash.ParseKeys(c.HashPublicKeys)
+	if err != nil {
+		return nil, fmt.Errorf("public keys parse error:%w", err)
+	}
+
+	for _, pk := range pks {
+		pub, err := hash.ParsePublicKey(pk.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("public key(%v) error:%w", pk.ID, err)
+		}
+
+		keys = append(keys, hash.Key{ID: pk.ID, Public: pub})
 	}
+
+	return keys, nil
 }
 
-func (c *Config) applyFromArgsAndEnv() error {
+// KeyPassphrase - парольная фраза зашифрованного ключа CryptoKey из файла KeyPassphraseFile или
+// из переменной окружения `KEY_PASSPHRASE`.
+func (c *Config) KeyPassphrase() ([]byte, error) {
+	p, err := pemkey.Passphrase(c.KeyPassphraseFile, "KEY_PASSPHRASE")
+	if err != nil {
+		return nil, fmt.Errorf("key passphrase error:%w", err)
+	}
+
+	return p, nil
+}
+
+func (c *Config) applyFromArgsAndEnv(fs *flag.FlagSet, args []string) error {
 	addr := &netaddr.NetAddress{}
 	err := addr.Set(c.ServerAddr)
 	if err != nil {
 		return fmt.Errorf("addr set error:%w", err)
 	}
 
-	flag.Var(addr, "a", "server network address")
-	flag.IntVar(&c.StoreInterval, "i", c.StoreInterval,
+	fs.Var(addr, "a", "server network address")
+	fs.IntVar(&c.StoreInterval, "i", c.StoreInterval,
 		"Интервал времени в секундах, по истечении которого текущие показания сервера сохраняются на диск "+
 			"(значение 0 делает запись синхронной).\nСоответствует переменной окружения STORE_INTERVAL")
-	flag.StringVar(&c.FileStoragePath, "f", c.FileStoragePath,
+	fs.StringVar(&c.FileStoragePath, "f", c.FileStoragePath,
 		"Полное имя файла, куда сохраняются текущие значения (пустое значение отключает функцию записи на диск).\n"+
 			"Соответствует переменной окружения FILE_STORAGE_PATH")
-	flag.BoolVar(&c.Restore, "r", c.Restore,
+	fs.BoolVar(&c.Restore, "r", c.Restore,
 		"Загружать или нет ранее сохранённые значения из указанного файла при старте сервера."+
 			"Соответствует переменной окружения RESTORE")
-	flag.StringVar(&c.DatabaseDSN, "d", c.DatabaseDSN,
+	fs.BoolVar(&c.WAL, "wal", c.WAL,
+		"Писать или нет каждое изменение метрик в журнал <имя файла>.wal, сохраняя снимок значений "+
+			"периодически.\nСоответствует переменной окружения WAL")
+	fs.StringVar(&c.DatabaseDSN, "d", c.DatabaseDSN,
 		"Адрес подключения к БД. Соответствует переменной окружения DATABASE_DSN")
-	flag.StringVar(&c.HashKey, "k", c.HashKey,
+	fs.IntVar(&c.HistoryRetentionDays, "history-retention-days", c.HistoryRetentionDays,
+		"Сколько дней хранится история изменений метрик в БД (значение 0 отключает удаление истории).\n"+
+			"Соответствует переменной окружения HISTORY_RETENTION_DAYS")
+	fs.IntVar(&c.BreakerThreshold, "breaker-threshold", c.BreakerThreshold,
+		"Число ошибок БД подряд, после которого метрики копятся в буфере до восстановления БД "+
+			"(значение 0 отключает функцию).\nСоответствует переменной окружения BREAKER_THRESHOLD")
+	fs.DurationVar(&c.BreakerOpenTimeout, "breaker-open-timeout", c.BreakerOpenTimeout,
+		"Время, по истечении которого сервер снова пробует обратиться к недоступной БД.\n"+
+			"Соответствует переменной окружения BREAKER_OPEN_TIMEOUT")
+	fs.IntVar(&c.BreakerBufferSize, "breaker-buffer-size", c.BreakerBufferSize,
+		"Максимальное число разных метрик в буфере на время недоступности БД (значение 0 снимает ограничение).\n"+
+			"Соответствует переменной окружения BREAKER_BUFFER_SIZE")
+	fs.StringVar(&c.BreakerSpillPath, "breaker-spill-path", c.BreakerSpillPath,
+		"Полное имя файла, в котором дублируется буфер на время недоступности БД.\n"+
+			"Соответствует переменной окружения BREAKER_SPILL_PATH")
+	fs.StringVar(&c.SQLitePath, "sqlite-path", c.SQLitePath,
+		"Путь до файла встроенной БД SQLite, используется, если не задан адрес подключения к БД.\n"+
+			"Соответствует переменной окружения SQLITE_PATH")
+	fs.StringVar(&c.BoltPath, "bolt-path", c.BoltPath,
+		"Путь до файла встроенной БД bbolt, используется, если не заданы адрес подключения к БД и путь до SQLite.\n"+
+			"Соответствует переменной окружения BOLT_PATH")
+	fs.BoolVar(&c.BoltHistory, "bolt-history", c.BoltHistory,
+		"Сохранять или нет в bbolt историю изменений каждой метрики.\nСоответствует переменной окружения BOLT_HISTORY")
+	fs.StringVar(&c.RedisAddr, "redis-addr", c.RedisAddr,
+		"Адрес Redis, используется, если не заданы адрес подключения к БД, путь до SQLite и путь до bbolt.\n"+
+			"Соответствует переменной окружения REDIS_ADDR")
+	fs.StringVar(&c.RedisNamespace, "redis-namespace", c.RedisNamespace,
+		"Пространство имен ключей метрик в Redis.\nСоответствует переменной окружения REDIS_NAMESPACE")
+	fs.StringVar(&c.HashKey, "k", c.HashKey,
 		"При наличии ключа во время обработки запроса сервер проверяет соответие полученного и "+
 			"вычесленного(от всего тела запроса) хеша.\nПри несовпадении сервер отбрасывает данные и отвечает 400.\n"+
 			"При наличии ключа на этапе формирования ответа сервер вычисляет хеш и передает его в HTTP-заголовке"+
 			"ответа с именем HashSHA256.")
-	flag.StringVar(&c.CryptoKey, "crypto-key", c.CryptoKey,
+	fs.StringVar(&c.HashKeys, "keys", c.HashKeys,
+		"Ключи подписи с идентификаторами в виде <идентификатор>:<ключ>[,<идентификатор>:<ключ>...], "+
+			"первый ключ текущий.\nСоответствует переменной окружения KEYS")
+	fs.DurationVar(&c.HashKeyGrace, "key-grace", c.HashKeyGrace,
+		"Сколько принимаются подписи прежними ключами (значение 0 снимает ограничение).\n"+
+			"Соответствует переменной окружения KEY_GRACE")
+	fs.StringVar(&c.HashPublicKeys, "public-keys", c.HashPublicKeys,
+		"Открытые ключи Ed25519 агентов в виде <идентификатор>:<путь>[,<идентификатор>:<путь>...].\n"+
+			"Соответствует переменной окружения PUBLIC_KEYS")
+	fs.DurationVar(&c.SignMaxSkew, "sign-max-skew", c.SignMaxSkew,
+		"Допустимое расхождение времени подписанного запроса со временем сервера "+
+			"(значение 0 выключает защиту от повторной отправки).\nСоответствует переменной окружения SIGN_MAX_SKEW")
+	fs.IntVar(&c.SignNonceCacheSize, "sign-nonce-cache-size", c.SignNonceCacheSize,
+		"Максимальное число запоминаемых одноразовых значений подписанных запросов.\n"+
+			"Соответствует переменной окружения SIGN_NONCE_CACHE_SIZE")
+	fs.StringVar(&c.CryptoKey, "crypto-key", c.CryptoKey,
 		"Путь до файла с приватным ключом (по умолчанию пустая строка).\nЕсли путь задан, то "+
 			"с помощью приватного ключа будут дешифровываться сообщения, получаемые сервером.")
-	flag.StringVar(&c.PprofServerAddr, "p", c.PprofServerAddr, "pprof server address")
-
-	flag.Parse()
+	fs.StringVar(&c.KeyPassphraseFile, "key-passphrase-file", c.KeyPassphraseFile,
+		"Путь до файла с парольной фразой зашифрованного приватного ключа.\n"+
+			"Соответствует переменной окружения KEY_PASSPHRASE_FILE")
+	fs.Int64Var(&c.DecompressLimit, "decompress-limit", c.DecompressLimit,
+		"Максимальный размер тела запроса в байтах после распаковки.\n"+
+			"Соответствует переменной окружения DECOMPRESS_LIMIT")
+	fs.IntVar(&c.CompressMinSize, "compress-min-size", c.CompressMinSize,
+		"Минимальный размер ответа в байтах, начиная с которого ответ сжимается.\n"+
+			"Соответствует переменной окружения COMPRESS_MIN_SIZE")
+	fs.StringVar(&c.PprofServerAddr, "p", c.PprofServerAddr, "pprof server address")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel,
+		"Уровень логирования: trace, debug, info, warn, error.\nСоответствует переменной окружения LOG_LEVEL")
+	fs.IntVar(&c.IdempotencyWindow, "idempotency-window", c.IdempotencyWindow,
+		"Время в секундах, в течении которого сервер хранит ответы на запросы с заголовком Idempotency-Key "+
+			"(значение 0 отключает функцию).\nСоответствует переменной окружения IDEMPOTENCY_WINDOW")
+	fs.IntVar(&c.RetryMaxAttempts, "retry-max-attempts", c.RetryMaxAttempts,
+		"Максимальное число обращений к хранилищу при временных ошибках, включая первое.\n"+
+			"Соответствует переменной окружения RETRY_MAX_ATTEMPTS")
+	fs.DurationVar(&c.RetryInitialInterval, "retry-initial-interval", c.RetryInitialInterval,
+		"Верхняя граница случайного ожидания перед первым повторным обращением к хранилищу.\n"+
+			"Соответствует переменной окружения RETRY_INITIAL_INTERVAL")
+	fs.DurationVar(&c.RetryMaxInterval, "retry-max-interval", c.RetryMaxInterval,
+		"Максимальная верхняя граница ожидания перед повторным обращением к хранилищу.\n"+
+			"Соответствует переменной окружения RETRY_MAX_INTERVAL")
+	fs.DurationVar(&c.RetryMaxElapsed, "retry-max-elapsed", c.RetryMaxElapsed,
+		"Общее время обращения к хранилищу с учетом повторов (значение 0 снимает ограничение).\n"+
+			"Соответствует переменной окружения RETRY_MAX_ELAPSED")
+
+	err = fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("flags parse error:%w", err)
+	}
 
-	if len(flag.Args()) != 0 {
-		return fmt.Errorf("unknown args:%v", flag.Args())
+	if len(fs.Args()) != 0 {
+		return fmt.Errorf("unknown args:%v", fs.Args())
 	}
 
 	c.ServerAddr = addr.String()
@@ -146,6 +461,80 @@ func (c *Config) applyFromArgsAndEnv() error {
 		c.DatabaseDSN = dbdsn
 	}
 
+	hr, ok := os.LookupEnv("HISTORY_RETENTION_DAYS")
+	if ok {
+		hrInt, err := strconv.Atoi(hr)
+		if err != nil {
+			return fmt.Errorf("HISTORY_RETENTION_DAYS parse error:%w", err)
+		}
+
+		c.HistoryRetentionDays = hrInt
+	}
+
+	bt, ok := os.LookupEnv("BREAKER_THRESHOLD")
+	if ok {
+		btInt, err := strconv.Atoi(bt)
+		if err != nil {
+			return fmt.Errorf("BREAKER_THRESHOLD parse error:%w", err)
+		}
+
+		c.BreakerThreshold = btInt
+	}
+
+	bot, ok := os.LookupEnv("BREAKER_OPEN_TIMEOUT")
+	if ok {
+		botDur, err := time.ParseDuration(bot)
+		if err != nil {
+			return fmt.Errorf("BREAKER_OPEN_TIMEOUT parse error:%w", err)
+		}
+
+		c.BreakerOpenTimeout = botDur
+	}
+
+	bbs, ok := os.LookupEnv("BREAKER_BUFFER_SIZE")
+	if ok {
+		bbsInt, err := strconv.Atoi(bbs)
+		if err != nil {
+			return fmt.Errorf("BREAKER_BUFFER_SIZE parse error:%w", err)
+		}
+
+		c.BreakerBufferSize = bbsInt
+	}
+
+	bsp, ok := os.LookupEnv("BREAKER_SPILL_PATH")
+	if ok {
+		c.BreakerSpillPath = bsp
+	}
+
+	sp, ok := os.LookupEnv("SQLITE_PATH")
+	if ok {
+		c.SQLitePath = sp
+	}
+
+	bp, ok := os.LookupEnv("BOLT_PATH")
+	if ok {
+		c.BoltPath = bp
+	}
+
+	bh, ok := os.LookupEnv("BOLT_HISTORY")
+	if ok {
+		bhBool, err := strconv.ParseBool(bh)
+		if err != nil {
+			return fmt.Errorf("BOLT_HISTORY parse error:%w", err)
+		}
+		c.BoltHistory = bhBool
+	}
+
+	ra, ok := os.LookupEnv("REDIS_ADDR")
+	if ok {
+		c.RedisAddr = ra
+	}
+
+	rn, ok := os.LookupEnv("REDIS_NAMESPACE")
+	if ok {
+		c.RedisNamespace = rn
+	}
+
 	sa, ok := os.LookupEnv("ADDRESS")
 	if ok {
 		c.ServerAddr = sa
@@ -161,11 +550,76 @@ func (c *Config) applyFromArgsAndEnv() error {
 		c.HashKey = k
 	}
 
+	ks, ok := os.LookupEnv("KEYS")
+	if ok {
+		c.HashKeys = ks
+	}
+
+	kg, ok := os.LookupEnv("KEY_GRACE")
+	if ok {
+		kgDur, err := time.ParseDuration(kg)
+		if err != nil {
+			return fmt.Errorf("KEY_GRACE parse error:%w", err)
+		}
+
+		c.HashKeyGrace = kgDur
+	}
+
+	pks, ok := os.LookupEnv("PUBLIC_KEYS")
+	if ok {
+		c.HashPublicKeys = pks
+	}
+
+	sms, ok := os.LookupEnv("SIGN_MAX_SKEW")
+	if ok {
+		smsDur, err := time.ParseDuration(sms)
+		if err != nil {
+			return fmt.Errorf("SIGN_MAX_SKEW parse error:%w", err)
+		}
+
+		c.SignMaxSkew = smsDur
+	}
+
+	snc, ok := os.LookupEnv("SIGN_NONCE_CACHE_SIZE")
+	if ok {
+		sncInt, err := strconv.Atoi(snc)
+		if err != nil {
+			return fmt.Errorf("SIGN_NONCE_CACHE_SIZE parse error:%w", err)
+		}
+
+		c.SignNonceCacheSize = sncInt
+	}
+
 	ck, ok := os.LookupEnv("CRYPTO_KEY")
 	if ok {
 		c.CryptoKey = ck
 	}
 
+	kpf, ok := os.LookupEnv("KEY_PASSPHRASE_FILE")
+	if ok {
+		c.KeyPassphraseFile = kpf
+	}
+
+	dl, ok := os.LookupEnv("DECOMPRESS_LIMIT")
+	if ok {
+		dlInt, err := strconv.ParseInt(dl, 10, 64)
+		if err != nil {
+			return fmt.Errorf("DECOMPRESS_LIMIT parse error:%w", err)
+		}
+
+		c.DecompressLimit = dlInt
+	}
+
+	cms, ok := os.LookupEnv("COMPRESS_MIN_SIZE")
+	if ok {
+		cmsInt, err := strconv.Atoi(cms)
+		if err != nil {
+			return fmt.Errorf("COMPRESS_MIN_SIZE parse error:%w", err)
+		}
+
+		c.CompressMinSize = cmsInt
+	}
+
 	si, ok := os.LookupEnv("STORE_INTERVAL")
 	if ok {
 		siInt, err := strconv.Atoi(si)
@@ -185,11 +639,75 @@ func (c *Config) applyFromArgsAndEnv() error {
 		c.Restore = rsBool
 	}
 
+	wl, ok := os.LookupEnv("WAL")
+	if ok {
+		wlBool, err := strconv.ParseBool(wl)
+		if err != nil {
+			return fmt.Errorf("WAL parse error:%w", err)
+		}
+		c.WAL = wlBool
+	}
+
 	ppa, ok := os.LookupEnv("PPROF_ADDRESS")
 	if ok {
 		c.PprofServerAddr = ppa
 	}
 
+	ll, ok := os.LookupEnv("LOG_LEVEL")
+	if ok {
+		c.LogLevel = ll
+	}
+
+	iw, ok := os.LookupEnv("IDEMPOTENCY_WINDOW")
+	if ok {
+		iwInt, err := strconv.Atoi(iw)
+		if err != nil {
+			return fmt.Errorf("IDEMPOTENCY_WINDOW parse error:%w", err)
+		}
+
+		c.IdempotencyWindow = iwInt
+	}
+
+	rma, ok := os.LookupEnv("RETRY_MAX_ATTEMPTS")
+	if ok {
+		rmaInt, err := strconv.Atoi(rma)
+		if err != nil {
+			return fmt.Errorf("RETRY_MAX_ATTEMPTS parse error:%w", err)
+		}
+
+		c.RetryMaxAttempts = rmaInt
+	}
+
+	rii, ok := os.LookupEnv("RETRY_INITIAL_INTERVAL")
+	if ok {
+		riiDur, err := time.ParseDuration(rii)
+		if err != nil {
+			return fmt.Errorf("RETRY_INITIAL_INTERVAL parse error:%w", err)
+		}
+
+		c.RetryInitialInterval = riiDur
+	}
+
+	rmi, ok := os.LookupEnv("RETRY_MAX_INTERVAL")
+	if ok {
+		rmiDur, err := time.ParseDuration(rmi)
+		if err != nil {
+			return fmt.Errorf("RETRY_MAX_INTERVAL parse error:%w", err)
+		}
+
+		c.RetryMaxInterval = rmiDur
+	}
+
+	rme, ok := os.LookupEnv("RETRY_MAX_ELAPSED")
+	if ok {
+		rmeDur, err := time.ParseDuration(rme)
+		if err != nil {
+			return fmt.Errorf("RETRY_MAX_ELAPSED parse error:%w", err)
+		}
+
+		c.RetryMaxElapsed = rmeDur
+	}
+
 	return nil
 }
 
@@ -197,12 +715,36 @@ func (c *Config) applyFromArgsAndEnv() error {
 // Использользуется для Unmarshal-инга файла в формате JSON в данную структуру.
 // Далее данные данной структуры будут использованы для формирования структуры Config.
 type JSONConfig struct {
-	Address         string `json:"address"`
-	DatabaseDSN     string `json:"database_dsn"`
-	FileStoragePath string `json:"file_storage_path"`
-	CryptoKey       string `json:"crypto_key"`
-	StoreInterval   string `json:"store_interval"`
-	Restore         bool   `json:"restore"`
+	Address              string `json:"address"`
+	DatabaseDSN          string `json:"database_dsn"`
+	SQLitePath           string `json:"sqlite_path"`
+	HistoryRetentionDays *int   `json:"history_retention_days"`
+	BreakerThreshold     *int   `json:"breaker_threshold"`
+	BreakerOpenTimeout   string `json:"breaker_open_timeout"`
+	BreakerBufferSize    *int   `json:"breaker_buffer_size"`
+	BreakerSpillPath     string `json:"breaker_spill_path"`
+	BoltPath             string `json:"bolt_path"`
+	BoltHistory          bool   `json:"bolt_history"`
+	RedisAddr            string `json:"redis_addr"`
+	RedisNamespace       string `json:"redis_namespace"`
+	FileStoragePath      string `json:"file_storage_path"`
+	HashKeyGrace         string `json:"key_grace"`
+	HashPublicKeys       string `json:"public_keys"`
+	SignMaxSkew          string `json:"sign_max_skew"`
+	SignNonceCacheSize   *int   `json:"sign_nonce_cache_size"`
+	CryptoKey            string `json:"crypto_key"`
+	KeyPassphraseFile    string `json:"key_passphrase_file"`
+	DecompressLimit      *int64 `json:"decompress_limit"`
+	CompressMinSize      *int   `json:"compress_min_size"`
+	LogLevel             string `json:"log_level"`
+	StoreInterval        string `json:"store_interval"`
+	Restore              bool   `json:"restore"`
+	WAL                  bool   `json:"wal"`
+	IdempotencyWindow    string `json:"idempotency_window"`
+	RetryMaxAttempts     int    `json:"retry_max_attempts"`
+	RetryInitialInterval string `json:"retry_initial_interval"`
+	RetryMaxInterval     string `json:"retry_max_interval"`
+	RetryMaxElapsed      string `json:"retry_max_elapsed"`
 }
 
 func (c *Config) applyFromFile(path string) error {
@@ -222,6 +764,7 @@ func (c *Config) applyFromFile(path string) error {
 	}
 
 	c.Restore = cfg.Restore
+	c.WAL = cfg.WAL
 
 	if cfg.StoreInterval != "" {
 		i, err := time.ParseDuration(cfg.StoreInterval)
@@ -240,9 +783,136 @@ func (c *Config) applyFromFile(path string) error {
 		c.DatabaseDSN = cfg.DatabaseDSN
 	}
 
+	if cfg.HistoryRetentionDays != nil {
+		c.HistoryRetentionDays = *cfg.HistoryRetentionDays
+	}
+
+	if cfg.BreakerThreshold != nil {
+		c.BreakerThreshold = *cfg.BreakerThreshold
+	}
+
+	if cfg.BreakerOpenTimeout != "" {
+		i, err := time.ParseDuration(cfg.BreakerOpenTimeout)
+		if err != nil {
+			return fmt.Errorf("breaker open timeout parse error:%w", err)
+		}
+
+		c.BreakerOpenTimeout = i
+	}
+
+	if cfg.BreakerBufferSize != nil {
+		c.BreakerBufferSize = *cfg.BreakerBufferSize
+	}
+
+	if cfg.BreakerSpillPath != "" {
+		c.BreakerSpillPath = cfg.BreakerSpillPath
+	}
+
+	if cfg.SQLitePath != "" {
+		c.SQLitePath = cfg.SQLitePath
+	}
+
+	if cfg.BoltPath != "" {
+		c.BoltPath = cfg.BoltPath
+	}
+
+	if cfg.BoltHistory {
+		c.BoltHistory = cfg.BoltHistory
+	}
+
+	if cfg.RedisAddr != "" {
+		c.RedisAddr = cfg.RedisAddr
+	}
+
+	if cfg.RedisNamespace != "" {
+		c.RedisNamespace = cfg.RedisNamespace
+	}
+
+	if cfg.HashKeyGrace != "" {
+		i, err := time.ParseDuration(cfg.HashKeyGrace)
+		if err != nil {
+			return fmt.Errorf("key grace parse error:%w", err)
+		}
+
+		c.HashKeyGrace = i
+	}
+
+	if cfg.HashPublicKeys != "" {
+		c.HashPublicKeys = cfg.HashPublicKeys
+	}
+
+	if cfg.SignMaxSkew != "" {
+		i, err := time.ParseDuration(cfg.SignMaxSkew)
+		if err != nil {
+			return fmt.Errorf("sign max skew parse error:%w", err)
+		}
+
+		c.SignMaxSkew = i
+	}
+
+	if cfg.SignNonceCacheSize != nil {
+		c.SignNonceCacheSize = *cfg.SignNonceCacheSize
+	}
+
 	if cfg.CryptoKey != "" {
 		c.CryptoKey = cfg.CryptoKey
 	}
 
+	if cfg.KeyPassphraseFile != "" {
+		c.KeyPassphraseFile = cfg.KeyPassphraseFile
+	}
+
+	if cfg.DecompressLimit != nil {
+		c.DecompressLimit = *cfg.DecompressLimit
+	}
+
+	if cfg.CompressMinSize != nil {
+		c.CompressMinSize = *cfg.CompressMinSize
+	}
+
+	if cfg.LogLevel != "" {
+		c.LogLevel = cfg.LogLevel
+	}
+
+	if cfg.IdempotencyWindow != "" {
+		i, err := time.ParseDuration(cfg.IdempotencyWindow)
+		if err != nil {
+			return fmt.Errorf("idempotency window parse error:%w", err)
+		}
+
+		c.IdempotencyWindow = int(i.Seconds())
+	}
+
+	if cfg.RetryMaxAttempts != 0 {
+		c.RetryMaxAttempts = cfg.RetryMaxAttempts
+	}
+
+	if cfg.RetryInitialInterval != "" {
+		i, err := time.ParseDuration(cfg.RetryInitialInterval)
+		if err != nil {
+			return fmt.Errorf("retry initial interval parse error:%w", err)
+		}
+
+		c.RetryInitialInterval = i
+	}
+
+	if cfg.RetryMaxInterval != "" {
+		i, err := time.ParseDuration(cfg.RetryMaxInterval)
+		if err != nil {
+			return fmt.Errorf("retry max interval parse error:%w", err)
+		}
+
+		c.RetryMaxInterval = i
+	}
+
+	if cfg.RetryMaxElapsed != "" {
+		i, err := time.ParseDuration(cfg.RetryMaxElapsed)
+		if err != nil {
+			return fmt.Errorf("retry max elapsed parse error:%w", err)
+		}
+
+		c.RetryMaxElapsed = i
+	}
+
 	return nil
 }
@@ -4,8 +4,11 @@ import (
 	"flag"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/k0st1a/metrics/internal/pkg/hash"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConfigFromFile(t *testing.T) {
@@ -38,12 +41,23 @@ func TestConfigFromFile(t *testing.T) {
 				"-c", "./config_test.json",
 			},
 			cfg: Config{
-				DatabaseDSN:     "DATABASE_DSN_FROM_FILE",
-				ServerAddr:      "localhost:8090",
-				FileStoragePath: "FILE_STORAGE_PATH_FROM_FILE",
-				CryptoKey:       "CRYPTO_KEY_FROM_FILE",
-				StoreInterval:   500,
-				Restore:         false,
+				DatabaseDSN:          "DATABASE_DSN_FROM_FILE",
+				ServerAddr:           "localhost:8090",
+				FileStoragePath:      "FILE_STORAGE_PATH_FROM_FILE",
+				CryptoKey:            "CRYPTO_KEY_FROM_FILE",
+				StoreInterval:        500,
+				Restore:              false,
+				RetryMaxAttempts:     3,
+				RetryInitialInterval: 500 * time.Millisecond,
+				RetryMaxInterval:     5 * time.Second,
+				LogLevel:             "info",
+				HashKeyGrace:         24 * time.Hour,
+				HashPublicKeys:       "agent-1:PUBLIC_KEY_FROM_FILE",
+				SignMaxSkew:          time.Minute,
+				SignNonceCacheSize:   50,
+				KeyPassphraseFile:    "KEY_PASSPHRASE_FILE_FROM_FILE",
+				DecompressLimit:      2048,
+				CompressMinSize:      512,
 			},
 		},
 	}
@@ -69,6 +83,17 @@ func TestConfigFromFile(t *testing.T) {
 			assert.Equal(t, test.cfg.CryptoKey, cfg.CryptoKey)
 			assert.Equal(t, test.cfg.StoreInterval, cfg.StoreInterval)
 			assert.Equal(t, test.cfg.Restore, cfg.Restore)
+			assert.Equal(t, test.cfg.RetryMaxAttempts, cfg.RetryMaxAttempts)
+			assert.Equal(t, test.cfg.RetryInitialInterval, cfg.RetryInitialInterval)
+			assert.Equal(t, test.cfg.RetryMaxInterval, cfg.RetryMaxInterval)
+			assert.Equal(t, test.cfg.LogLevel, cfg.LogLevel)
+			assert.Equal(t, test.cfg.HashKeyGrace, cfg.HashKeyGrace)
+			assert.Equal(t, test.cfg.HashPublicKeys, cfg.HashPublicKeys)
+			assert.Equal(t, test.cfg.SignMaxSkew, cfg.SignMaxSkew)
+			assert.Equal(t, test.cfg.SignNonceCacheSize, cfg.SignNonceCacheSize)
+			assert.Equal(t, test.cfg.KeyPassphraseFile, cfg.KeyPassphraseFile)
+			assert.Equal(t, test.cfg.DecompressLimit, cfg.DecompressLimit)
+			assert.Equal(t, test.cfg.CompressMinSize, cfg.CompressMinSize)
 			origStateFun()
 		})
 	}
@@ -96,24 +121,74 @@ func TestConfigFromEnv(t *testing.T) {
 		{
 			name: "Check config from env",
 			env: map[string]string{
-				"DATABASE_DSN":      "DATABASE_DSN_FROM_ENV",
-				"ADDRESS":           "localhost:8080",
-				"FILE_STORAGE_PATH": "FILE_STORAGE_PATH_FROM_ENV",
-				"KEY":               "KEY_FROM_ENV",
-				"CRYPTO_KEY":        "CRYPTO_KEY_FROM_ENV",
-				"STORE_INTERVAL":    "100",
-				"RESTORE":           "true",
-				"PPROF_ADDRESS":     "localhost:9090",
+				"DATABASE_DSN":           "DATABASE_DSN_FROM_ENV",
+				"ADDRESS":                "localhost:8080",
+				"FILE_STORAGE_PATH":      "FILE_STORAGE_PATH_FROM_ENV",
+				"KEY":                    "KEY_FROM_ENV",
+				"CRYPTO_KEY":             "CRYPTO_KEY_FROM_ENV",
+				"STORE_INTERVAL":         "100",
+				"RESTORE":                "true",
+				"PPROF_ADDRESS":          "localhost:9090",
+				"IDEMPOTENCY_WINDOW":     "60",
+				"SQLITE_PATH":            "SQLITE_PATH_FROM_ENV",
+				"HISTORY_RETENTION_DAYS": "30",
+				"BOLT_PATH":              "BOLT_PATH_FROM_ENV",
+				"BOLT_HISTORY":           "true",
+				"REDIS_ADDR":             "REDIS_ADDR_FROM_ENV",
+				"REDIS_NAMESPACE":        "REDIS_NAMESPACE_FROM_ENV",
+				"WAL":                    "true",
+				"RETRY_MAX_ATTEMPTS":     "2",
+				"RETRY_INITIAL_INTERVAL": "100ms",
+				"RETRY_MAX_INTERVAL":     "1s",
+				"RETRY_MAX_ELAPSED":      "3s",
+				"BREAKER_THRESHOLD":      "5",
+				"BREAKER_OPEN_TIMEOUT":   "30s",
+				"BREAKER_BUFFER_SIZE":    "100",
+				"BREAKER_SPILL_PATH":     "BREAKER_SPILL_PATH_FROM_ENV",
+				"LOG_LEVEL":              "warn",
+				"KEYS":                   "v2:KEY2_FROM_ENV",
+				"KEY_GRACE":              "1h",
+				"PUBLIC_KEYS":            "agent-1:PUBLIC_KEY_FROM_ENV",
+				"SIGN_MAX_SKEW":          "2m",
+				"SIGN_NONCE_CACHE_SIZE":  "500",
+				"KEY_PASSPHRASE_FILE":    "KEY_PASSPHRASE_FILE_FROM_ENV",
+				"DECOMPRESS_LIMIT":       "4096",
+				"COMPRESS_MIN_SIZE":      "128",
 			},
 			cfg: Config{
-				DatabaseDSN:     "DATABASE_DSN_FROM_ENV",
-				ServerAddr:      "localhost:8080",
-				FileStoragePath: "FILE_STORAGE_PATH_FROM_ENV",
-				HashKey:         "KEY_FROM_ENV",
-				CryptoKey:       "CRYPTO_KEY_FROM_ENV",
-				StoreInterval:   100,
-				Restore:         true,
-				PprofServerAddr: "localhost:9090",
+				DatabaseDSN:          "DATABASE_DSN_FROM_ENV",
+				SQLitePath:           "SQLITE_PATH_FROM_ENV",
+				HistoryRetentionDays: 30,
+				BreakerThreshold:     5,
+				BreakerOpenTimeout:   30 * time.Second,
+				BreakerBufferSize:    100,
+				BreakerSpillPath:     "BREAKER_SPILL_PATH_FROM_ENV",
+				BoltPath:             "BOLT_PATH_FROM_ENV",
+				BoltHistory:          true,
+				RedisAddr:            "REDIS_ADDR_FROM_ENV",
+				RedisNamespace:       "REDIS_NAMESPACE_FROM_ENV",
+				ServerAddr:           "localhost:8080",
+				FileStoragePath:      "FILE_STORAGE_PATH_FROM_ENV",
+				HashKey:              "KEY_FROM_ENV",
+				HashKeys:             "v2:KEY2_FROM_ENV",
+				HashKeyGrace:         time.Hour,
+				HashPublicKeys:       "agent-1:PUBLIC_KEY_FROM_ENV",
+				SignMaxSkew:          2 * time.Minute,
+				SignNonceCacheSize:   500,
+				CryptoKey:            "CRYPTO_KEY_FROM_ENV",
+				KeyPassphraseFile:    "KEY_PASSPHRASE_FILE_FROM_ENV",
+				DecompressLimit:      4096,
+				CompressMinSize:      128,
+				StoreInterval:        100,
+				Restore:              true,
+				WAL:                  true,
+				PprofServerAddr:      "localhost:9090",
+				LogLevel:             "warn",
+				IdempotencyWindow:    60,
+				RetryMaxAttempts:     2,
+				RetryInitialInterval: 100 * time.Millisecond,
+				RetryMaxInterval:     time.Second,
+				RetryMaxElapsed:      3 * time.Second,
 			},
 		},
 	}
@@ -166,16 +241,61 @@ func TestConfigFromFlags(t *testing.T) {
 				"-i", "200",
 				"-r=false",
 				"-p", "localhost:9091",
+				"-idempotency-window", "120",
+				"-wal",
+				"-sqlite-path", "SQLITE_PATH_FROM_FLAG",
+				"-history-retention-days", "0",
+				"-bolt-path", "BOLT_PATH_FROM_FLAG",
+				"-bolt-history",
+				"-redis-addr", "REDIS_ADDR_FROM_FLAG",
+				"-redis-namespace", "REDIS_NAMESPACE_FROM_FLAG",
+				"-retry-max-attempts", "1",
+				"-retry-initial-interval", "200ms",
+				"-retry-max-interval", "2s",
+				"-retry-max-elapsed", "0",
+				"-breaker-threshold", "0",
+				"-breaker-open-timeout", "1m",
+				"-breaker-buffer-size", "0",
+				"-breaker-spill-path", "BREAKER_SPILL_PATH_FROM_FLAG",
+				"-log-level", "error",
+				"-keys", "v2:KEY2_FROM_FLAG,v1:KEY1_FROM_FLAG",
+				"-key-grace", "30m",
+				"-public-keys", "agent-1:PUBLIC_KEY_FROM_FLAG",
+				"-sign-max-skew", "0",
+				"-sign-nonce-cache-size", "1000",
+				"-key-passphrase-file", "KEY_PASSPHRASE_FILE_FROM_FLAG",
+				"-decompress-limit", "8192",
+				"-compress-min-size", "256",
 			},
 			cfg: Config{
-				DatabaseDSN:     "DATABASE_DSN_FROM_FLAG",
-				ServerAddr:      "localhost:8081",
-				FileStoragePath: "FILE_STORAGE_PATH_FROM_FLAG",
-				HashKey:         "KEY_FROM_FLAG",
-				CryptoKey:       "CRYPTO_KEY_FROM_FLAG",
-				StoreInterval:   200,
-				Restore:         false,
-				PprofServerAddr: "localhost:9091",
+				DatabaseDSN:          "DATABASE_DSN_FROM_FLAG",
+				SQLitePath:           "SQLITE_PATH_FROM_FLAG",
+				BoltPath:             "BOLT_PATH_FROM_FLAG",
+				BoltHistory:          true,
+				RedisAddr:            "REDIS_ADDR_FROM_FLAG",
+				RedisNamespace:       "REDIS_NAMESPACE_FROM_FLAG",
+				ServerAddr:           "localhost:8081",
+				FileStoragePath:      "FILE_STORAGE_PATH_FROM_FLAG",
+				HashKey:              "KEY_FROM_FLAG",
+				HashKeys:             "v2:KEY2_FROM_FLAG,v1:KEY1_FROM_FLAG",
+				HashKeyGrace:         30 * time.Minute,
+				HashPublicKeys:       "agent-1:PUBLIC_KEY_FROM_FLAG",
+				SignNonceCacheSize:   1000,
+				CryptoKey:            "CRYPTO_KEY_FROM_FLAG",
+				KeyPassphraseFile:    "KEY_PASSPHRASE_FILE_FROM_FLAG",
+				DecompressLimit:      8192,
+				CompressMinSize:      256,
+				StoreInterval:        200,
+				Restore:              false,
+				WAL:                  true,
+				PprofServerAddr:      "localhost:9091",
+				LogLevel:             "error",
+				IdempotencyWindow:    120,
+				RetryMaxAttempts:     1,
+				BreakerOpenTimeout:   time.Minute,
+				BreakerSpillPath:     "BREAKER_SPILL_PATH_FROM_FLAG",
+				RetryInitialInterval: 200 * time.Millisecond,
+				RetryMaxInterval:     2 * time.Second,
 			},
 		},
 	}
@@ -239,14 +359,29 @@ func TestConfig(t *testing.T) {
 				"-p", "localhost:9091",
 			},
 			cfg: Config{
-				DatabaseDSN:     "DATABASE_DSN_FROM_ENV",
-				ServerAddr:      "localhost:8080",
-				FileStoragePath: "FILE_STORAGE_PATH_FROM_ENV",
-				HashKey:         "KEY_FROM_ENV",
-				CryptoKey:       "CRYPTO_KEY_FROM_ENV",
-				StoreInterval:   300,
-				Restore:         true,
-				PprofServerAddr: "localhost:9090",
+				DatabaseDSN:          "DATABASE_DSN_FROM_ENV",
+				ServerAddr:           "localhost:8080",
+				FileStoragePath:      "FILE_STORAGE_PATH_FROM_ENV",
+				HashKey:              "KEY_FROM_ENV",
+				CryptoKey:            "CRYPTO_KEY_FROM_ENV",
+				StoreInterval:        300,
+				Restore:              true,
+				RedisNamespace:       "metrics",
+				PprofServerAddr:      "localhost:9090",
+				LogLevel:             "debug",
+				IdempotencyWindow:    300,
+				HistoryRetentionDays: 7,
+				BreakerThreshold:     3,
+				BreakerOpenTimeout:   10 * time.Second,
+				BreakerBufferSize:    10000,
+				RetryMaxAttempts:     4,
+				RetryInitialInterval: time.Second,
+				RetryMaxInterval:     5 * time.Second,
+				RetryMaxElapsed:      15 * time.Second,
+				SignMaxSkew:          5 * time.Minute,
+				SignNonceCacheSize:   100000,
+				DecompressLimit:      10485760,
+				CompressMinSize:      1024,
 			},
 		},
 	}
@@ -269,3 +404,29 @@ func TestConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestSignKeys(t *testing.T) {
+	cfg := &Config{
+		HashKeys:       "v2:KEY2,v1:KEY1",
+		HashKey:        "KEY",
+		HashPublicKeys: "agent-1:../pkg/crypto/pemkey/ed25519_public.pem",
+	}
+
+	keys, err := cfg.SignKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 4)
+
+	assert.Equal(t, hash.Key{ID: "v2", Secret: "KEY2"}, keys[0])
+	assert.Equal(t, hash.Key{ID: "v1", Secret: "KEY1"}, keys[1])
+	assert.Equal(t, hash.Key{Secret: "KEY"}, keys[2])
+	assert.Equal(t, "agent-1", keys[3].ID)
+	assert.NotNil(t, keys[3].Public)
+
+	cfg.HashPublicKeys = "agent-1:../pkg/crypto/pemkey/pkcs1_public.pem"
+	_, err = cfg.SignKeys()
+	assert.Error(t, err)
+
+	cfg.HashPublicKeys = "agent-1"
+	_, err = cfg.SignKeys()
+	assert.ErrorIs(t, err, hash.ErrKeyFormat)
+}
@@ -6,7 +6,7 @@ import (
 	"net/http"
 
 	"github.com/k0st1a/metrics/internal/storage/db"
-	v1 "github.com/k0st1a/metrics/internal/storage/db/migration/v1"
+	"github.com/k0st1a/metrics/internal/storage/db/migration"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/k0st1a/metrics/internal/handlers"
@@ -26,12 +26,12 @@ func Example() { //nolint:testableexamples // no output here
 
 	pool, _ := pgxpool.New(ctx, cfg.DatabaseDSN)
 
-	m := v1.NewMigration(pool)
-	_ = m.Migrate(ctx)
+	m, _ := migration.New(pool)
+	_ = m.Up(ctx)
 
 	s := db.NewStorage(pool)
 
-	rt := retry.New()
+	rt := retry.New(retry.DefaultPolicy(), nil, nil)
 	jh := json.NewHandler(s, rt)
 
 	var middlewares []func(http.Handler) http.Handler
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/k0st1a/metrics/internal/storage/db/migration"
+)
+
+const migrateUsage = "usage: metrics-server migrate [-d=<DATABASE_DSN>] up|down|status"
+
+var ErrMigrateUsage = errors.New(migrateUsage)
+
+// RunMigrate - выполнение подкоманды `migrate` сервера, где:
+//   - args - аргументы командной строки после `migrate`;
+//   - out - вывод результата команды `status`.
+//
+// Адрес подключения к БД задается через флаг `-d=<ЗНАЧЕНИЕ>` или переменную окружения `DATABASE_DSN=<ЗНАЧЕНИЕ>`,
+// как и для сервера.
+func RunMigrate(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dsn := fs.String("d", defaultDatabaseDSN, "Адрес подключения к БД. Соответствует переменной окружения DATABASE_DSN")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w:%w", ErrMigrateUsage, err)
+	}
+
+	if v, ok := os.LookupEnv("DATABASE_DSN"); ok {
+		*dsn = v
+	}
+
+	if fs.NArg() != 1 {
+		return ErrMigrateUsage
+	}
+
+	cmd := fs.Arg(0)
+	if cmd != "up" && cmd != "down" && cmd != "status" {
+		return ErrMigrateUsage
+	}
+
+	if *dsn == "" {
+		return errors.New("database dsn is empty")
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, *dsn)
+	if err != nil {
+		return fmt.Errorf("pgxpool new error:%w", err)
+	}
+	defer pool.Close()
+
+	m, err := migration.New(pool)
+	if err != nil {
+		return fmt.Errorf("migration new error:%w", err)
+	}
+
+	switch cmd {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		err = m.Down(ctx)
+	default:
+		var st []migration.Status
+		st, err = m.Status(ctx)
+		if err == nil {
+			err = printMigrateStatus(out, st)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("migrate %v error:%w", cmd, err)
+	}
+
+	return nil
+}
+
+func printMigrateStatus(out io.Writer, st []migration.Status) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range st {
+		status := "pending"
+		at := ""
+		if s.Applied {
+			status = "applied"
+			at = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", s.Version, s.Name, status, at)
+	}
+
+	err := w.Flush()
+	if err != nil {
+		return fmt.Errorf("status write error:%w", err)
+	}
+
+	return nil
+}
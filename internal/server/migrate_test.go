@@ -0,0 +1,63 @@
+package server
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/storage/db/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMigrateUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "no command",
+			args: []string{},
+		},
+		{
+			name: "unknown command",
+			args: []string{"-d", "postgres://localhost/metrics", "sideways"},
+		},
+		{
+			name: "extra args",
+			args: []string{"up", "down"},
+		},
+		{
+			name: "unknown flag",
+			args: []string{"-x", "up"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := RunMigrate(test.args, &bytes.Buffer{})
+			assert.ErrorIs(t, err, ErrMigrateUsage)
+		})
+	}
+}
+
+func TestPrintMigrateStatus(t *testing.T) {
+	var b bytes.Buffer
+
+	err := printMigrateStatus(&b, []migration.Status{
+		{
+			Migration: migration.Migration{Version: 1, Name: "create_metrics"},
+			Applied:   true,
+			AppliedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+		{
+			Migration: migration.Migration{Version: 2, Name: "create_idempotency_keys"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ""+
+		"VERSION  NAME                     STATUS   APPLIED AT\n"+
+		"1        create_metrics           applied  2024-01-02T03:04:05Z\n"+
+		"2        create_idempotency_keys  pending  \n", b.String())
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/k0st1a/metrics/internal/storage/bolt"
+	"github.com/k0st1a/metrics/internal/storage/db"
+	"github.com/k0st1a/metrics/internal/storage/db/migration"
+	"github.com/k0st1a/metrics/internal/storage/file"
+	fileio "github.com/k0st1a/metrics/internal/storage/file/io"
+	"github.com/k0st1a/metrics/internal/storage/file/wal"
+	"github.com/k0st1a/metrics/internal/storage/redis"
+	"github.com/k0st1a/metrics/internal/storage/sqlite"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const migrateStorageUsage = "usage: metrics-server migrate-storage -from=<STORAGE> -to=<STORAGE> " +
+	"[-counters=replace|merge] [-dry-run] [-verify]"
+
+var (
+	ErrMigrateStorageUsage = errors.New(migrateStorageUsage)
+	// ErrVerify - метрики в хранилище-приемнике после переноса не совпали с ожидаемыми.
+	ErrVerify = errors.New("verify failed")
+)
+
+// Режимы переноса метрик типа counter.
+const (
+	// CountersReplace - значение counter в приемнике становится равным значению в источнике,
+	// повторный перенос ничего не меняет.
+	CountersReplace = "replace"
+	// CountersMerge - значение counter из источника прибавляется к значению в приемнике.
+	CountersMerge = "merge"
+)
+
+// maxVerifyErrors - сколько расхождений выводится при проверке переноса.
+const maxVerifyErrors = 10
+
+// RunMigrateStorage - выполнение подкоманды `migrate-storage` сервера, которая переносит все метрики
+// из одного хранилища в другое при остановленных серверах, где:
+//   - args - аргументы командной строки после `migrate-storage`;
+//   - out - вывод отчета о переносе.
+//
+// Хранилища задаются флагами `-from` и `-to` в виде:
+//   - `file:<ПУТЬ>` - файл, как у флага сервера `-f`, в источнике учитывается и журнал `<ПУТЬ>.wal`;
+//   - `postgres://...` - БД PostgreSQL, как у флага сервера `-d`, к приемнику применяются миграции;
+//   - `sqlite:<ПУТЬ>` - БД SQLite, как у флага сервера `-sqlite-path`;
+//   - `bolt:<ПУТЬ>` - БД bbolt, как у флага сервера `-bolt-path`;
+//   - `redis://<АДРЕС>[?namespace=<ПРОСТРАНСТВО ИМЕН>]` - Redis, как у флагов сервера `-redis-addr`
+//     и `-redis-namespace`.
+//
+// Флаг `-counters` задает режим переноса counter: CountersReplace (по умолчанию) или CountersMerge,
+// значения gauge всегда заменяются. С флагом `-dry-run` выводится только отчет о переносе, с флагом
+// `-verify` после переноса метрики приемника сверяются с ожидаемыми.
+func RunMigrateStorage(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("migrate-storage", flag.ContinueOnError)
+	from := fs.String("from", "", "Хранилище-источник метрик")
+	to := fs.String("to", "", "Хранилище-приемник метрик")
+	counters := fs.String("counters", CountersReplace, "Режим переноса counter: replace или merge")
+	dryRun := fs.Bool("dry-run", false, "Только вывести отчет о переносе, ничего не записывая")
+	verify := fs.Bool("verify", false, "После переноса сверить метрики приемника с ожидаемыми")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w:%w", ErrMigrateStorageUsage, err)
+	}
+
+	if fs.NArg() != 0 || *from == "" || *to == "" || *from == *to {
+		return ErrMigrateStorageUsage
+	}
+
+	if *counters != CountersReplace && *counters != CountersMerge {
+		return ErrMigrateStorageUsage
+	}
+
+	ctx := context.Background()
+
+	sc, sg, err := readStorage(ctx, *from)
+	if err != nil {
+		return fmt.Errorf("read from(%v) error:%w", *from, err)
+	}
+
+	dst, closeDst, err := openStorage(ctx, *to, true)
+	if err != nil {
+		return fmt.Errorf("open to(%v) error:%w", *to, err)
+	}
+	defer closeDst()
+
+	dc, dg, err := dst.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("read to(%v) error:%w", *to, err)
+	}
+
+	p := newMigratePlan(*counters, sc, sg, dc, dg)
+	p.print(out, len(sc), len(sg))
+
+	if *dryRun {
+		fmt.Fprintln(out, "dry run: nothing written")
+		return nil
+	}
+
+	if len(p.counter) != 0 || len(p.gauge) != 0 {
+		err = dst.StoreAll(ctx, p.counter, p.gauge)
+		if err != nil {
+			return fmt.Errorf("store all error:%w", err)
+		}
+	}
+
+	if f, ok := dst.(Flusher); ok {
+		err = f.Flush(ctx)
+		if err != nil {
+			return fmt.Errorf("flush error:%w", err)
+		}
+	}
+
+	fmt.Fprintln(out, "written")
+
+	if !*verify {
+		return nil
+	}
+
+	c, g, err := dst.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("verify read error:%w", err)
+	}
+
+	err = p.verify(c, g)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "verified: %v metrics\n", len(p.wantCounter)+len(p.wantGauge))
+
+	return nil
+}
+
+// migratePlan - что нужно записать в приемник и какие значения в нем ожидаются после записи.
+type migratePlan struct {
+	counter     map[string]int64
+	gauge       map[string]float64
+	wantCounter map[string]int64
+	wantGauge   map[string]float64
+	newCounters int
+	newGauges   int
+}
+
+func newMigratePlan(mode string, sc map[string]int64, sg map[string]float64,
+	dc map[string]int64, dg map[string]float64) *migratePlan {
+	p := &migratePlan{
+		counter:     make(map[string]int64),
+		gauge:       make(map[string]float64),
+		wantCounter: make(map[string]int64, len(sc)),
+		wantGauge:   make(map[string]float64, len(sg)),
+	}
+
+	for k, v := range sc {
+		cur, ok := dc[k]
+		if !ok {
+			p.newCounters++
+		}
+
+		// Хранилища прибавляют counter к текущему значению, поэтому для замены записывается разница.
+		want, delta := v, v-cur
+		if mode == CountersMerge {
+			want, delta = cur+v, v
+		}
+
+		p.wantCounter[k] = want
+		if delta != 0 || !ok {
+			p.counter[k] = delta
+		}
+	}
+
+	for k, v := range sg {
+		cur, ok := dg[k]
+		if !ok {
+			p.newGauges++
+		}
+
+		p.wantGauge[k] = v
+		if cur != v || !ok {
+			p.gauge[k] = v
+		}
+	}
+
+	return p
+}
+
+func (p *migratePlan) print(out io.Writer, counters, gauges int) {
+	fmt.Fprintf(out, "counters: %v in source, %v new, %v to write\n", counters, p.newCounters, len(p.counter))
+	fmt.Fprintf(out, "gauges: %v in source, %v new, %v to write\n", gauges, p.newGauges, len(p.gauge))
+}
+
+// verify - сверка метрик приемника c, g с ожидаемыми, выводятся первые maxVerifyErrors расхождений.
+func (p *migratePlan) verify(c map[string]int64, g map[string]float64) error {
+	var diffs []string
+
+	for k, want := range p.wantCounter {
+		got, ok := c[k]
+		if !ok || got != want {
+			diffs = append(diffs, fmt.Sprintf("counter %v: want %v, got %v", k, want, got))
+		}
+	}
+
+	for k, want := range p.wantGauge {
+		got, ok := g[k]
+		if !ok || got != want {
+			diffs = append(diffs, fmt.Sprintf("gauge %v: want %v, got %v", k, want, got))
+		}
+	}
+
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	sort.Strings(diffs)
+	n := len(diffs)
+	if n > maxVerifyErrors {
+		diffs = diffs[:maxVerifyErrors]
+	}
+
+	return fmt.Errorf("%w: %v mismatches: %v", ErrVerify, n, strings.Join(diffs, "; "))
+}
+
+// readStorage - чтение всех метрик хранилища spec. Файл читается напрямую вместе с журналом,
+// не изменяя его.
+func readStorage(ctx context.Context, spec string) (map[string]int64, map[string]float64, error) {
+	if path, ok := strings.CutPrefix(spec, "file:"); ok {
+		return readFileStorage(path)
+	}
+
+	s, closeFn, err := openStorage(ctx, spec, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer closeFn()
+
+	c, g, err := s.GetAll(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get all error:%w", err)
+	}
+
+	return c, g, nil
+}
+
+func readFileStorage(path string) (map[string]int64, map[string]float64, error) {
+	c, g, seq, err := fileio.ReadSeq(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read file error:%w", err)
+	}
+
+	_, err = os.Stat(path + file.WALSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, g, nil
+	}
+
+	err = wal.Replay(path+file.WALSuffix, seq, func(counter map[string]int64, gauge map[string]float64) error {
+		for k, v := range counter {
+			c[k] += v
+		}
+		for k, v := range gauge {
+			g[k] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("wal replay error:%w", err)
+	}
+
+	return c, g, nil
+}
+
+// openStorage - открытие хранилища spec, возвращается хранилище и функция его закрытия.
+// При migrate к БД PostgreSQL применяются миграции.
+func openStorage(ctx context.Context, spec string, migrate bool) (Storage, func(), error) {
+	switch {
+	case strings.HasPrefix(spec, "file:"):
+		// Журнал приемника продолжает вестись, чтобы его записи не легли поверх перенесенных метрик
+		// при следующем запуске сервера.
+		path := strings.TrimPrefix(spec, "file:")
+		_, err := os.Stat(path)
+		restore := err == nil
+		_, err = os.Stat(path + file.WALSuffix)
+		walEnabled := err == nil
+
+		return file.NewStorage(ctx, path, 0, restore, walEnabled, nil), func() {}, nil
+
+	case strings.HasPrefix(spec, "postgres://"), strings.HasPrefix(spec, "postgresql://"):
+		pool, err := pgxpool.New(ctx, spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("pgxpool new error:%w", err)
+		}
+
+		if migrate {
+			m, err := migration.New(pool)
+			if err == nil {
+				err = m.Up(ctx)
+			}
+			if err != nil {
+				pool.Close()
+				return nil, nil, fmt.Errorf("migrate error:%w", err)
+			}
+		}
+
+		return db.NewStorage(pool), pool.Close, nil
+
+	case strings.HasPrefix(spec, "sqlite:"):
+		s, err := sqlite.NewStorage(ctx, strings.TrimPrefix(spec, "sqlite:"))
+		if err != nil {
+			return nil, nil, fmt.Errorf("sqlite new storage error:%w", err)
+		}
+
+		return s, func() { _ = s.Close() }, nil
+
+	case strings.HasPrefix(spec, "bolt:"):
+		s, err := bolt.NewStorage(strings.TrimPrefix(spec, "bolt:"), false)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bolt new storage error:%w", err)
+		}
+
+		return s, func() { _ = s.Close() }, nil
+
+	case strings.HasPrefix(spec, "redis://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("redis url parse error:%w", err)
+		}
+
+		q := u.Query()
+		ns := q.Get("namespace")
+		if ns == "" {
+			ns = redis.DefaultNamespace
+		}
+		q.Del("namespace")
+		u.RawQuery = q.Encode()
+
+		opts, err := goredis.ParseURL(u.String())
+		if err != nil {
+			return nil, nil, fmt.Errorf("redis url parse error:%w", err)
+		}
+
+		c := goredis.NewClient(opts)
+
+		return redis.NewStorage(c, ns), func() { _ = c.Close() }, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w:unknown storage %v", ErrMigrateStorageUsage, spec)
+}
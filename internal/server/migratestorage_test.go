@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k0st1a/metrics/internal/storage/bolt"
+	"github.com/k0st1a/metrics/internal/storage/file"
+	"github.com/k0st1a/metrics/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMigrateStorageUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "no flags",
+			args: []string{},
+		},
+		{
+			name: "no to",
+			args: []string{"-from", "file:/tmp/metrics.json"},
+		},
+		{
+			name: "same storage",
+			args: []string{"-from", "file:/tmp/metrics.json", "-to", "file:/tmp/metrics.json"},
+		},
+		{
+			name: "unknown counters mode",
+			args: []string{"-from", "file:/tmp/a.json", "-to", "file:/tmp/b.json", "-counters", "sum"},
+		},
+		{
+			name: "extra args",
+			args: []string{"-from", "file:/tmp/a.json", "-to", "file:/tmp/b.json", "up"},
+		},
+		{
+			name: "unknown storage",
+			args: []string{"-from", "mysql://localhost/metrics", "-to", "file:/tmp/b.json"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := RunMigrateStorage(test.args, &bytes.Buffer{})
+			assert.ErrorIs(t, err, ErrMigrateStorageUsage)
+		})
+	}
+}
+
+func TestRunMigrateStorage(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	from := filepath.Join(dir, "metrics.json")
+	to := filepath.Join(dir, "metrics.db")
+
+	// Источник - снимок и журнал, которые оставил сервер с флагом -wal.
+	src := file.NewStorage(ctx, from, 0, false, true, nil)
+	require.NoError(t, src.StoreAll(ctx, map[string]int64{"PollCount": 5}, map[string]float64{"Alloc": 1.5}))
+	f, ok := src.(Flusher)
+	require.True(t, ok)
+	require.NoError(t, f.Flush(ctx))
+	require.NoError(t, src.StoreAll(ctx, map[string]int64{"PollCount": 2}, map[string]float64{"HeapAlloc": 3}))
+
+	dst, err := sqlite.NewStorage(ctx, to)
+	require.NoError(t, err)
+	require.NoError(t, dst.StoreAll(ctx, map[string]int64{"PollCount": 4, "Other": 1},
+		map[string]float64{"Alloc": 1.5}))
+	require.NoError(t, dst.Close())
+
+	tests := []struct {
+		name    string
+		args    []string
+		out     string
+		counter map[string]int64
+		gauge   map[string]float64
+	}{
+		{
+			name: "dry run",
+			args: []string{"-dry-run"},
+			out: "" +
+				"counters: 1 in source, 0 new, 1 to write\n" +
+				"gauges: 2 in source, 1 new, 1 to write\n" +
+				"dry run: nothing written\n",
+			counter: map[string]int64{"PollCount": 4, "Other": 1},
+			gauge:   map[string]float64{"Alloc": 1.5},
+		},
+		{
+			name: "replace",
+			args: []string{"-verify"},
+			out: "" +
+				"counters: 1 in source, 0 new, 1 to write\n" +
+				"gauges: 2 in source, 1 new, 1 to write\n" +
+				"written\n" +
+				"verified: 3 metrics\n",
+			counter: map[string]int64{"PollCount": 7, "Other": 1},
+			gauge:   map[string]float64{"Alloc": 1.5, "HeapAlloc": 3},
+		},
+		{
+			name: "replace again",
+			args: []string{"-verify", "-counters", CountersReplace},
+			out: "" +
+				"counters: 1 in source, 0 new, 0 to write\n" +
+				"gauges: 2 in source, 0 new, 0 to write\n" +
+				"written\n" +
+				"verified: 3 metrics\n",
+			counter: map[string]int64{"PollCount": 7, "Other": 1},
+			gauge:   map[string]float64{"Alloc": 1.5, "HeapAlloc": 3},
+		},
+		{
+			name: "merge",
+			args: []string{"-verify", "-counters", CountersMerge},
+			out: "" +
+				"counters: 1 in source, 0 new, 1 to write\n" +
+				"gauges: 2 in source, 0 new, 0 to write\n" +
+				"written\n" +
+				"verified: 3 metrics\n",
+			counter: map[string]int64{"PollCount": 14, "Other": 1},
+			gauge:   map[string]float64{"Alloc": 1.5, "HeapAlloc": 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			args := append([]string{"-from", "file:" + from, "-to", "sqlite:" + to}, test.args...)
+			err := RunMigrateStorage(args, &out)
+			require.NoError(t, err)
+			assert.Equal(t, test.out, out.String())
+
+			s, err := sqlite.NewStorage(ctx, to)
+			require.NoError(t, err)
+			defer func() {
+				_ = s.Close()
+			}()
+
+			c, g, err := s.GetAll(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, test.counter, c)
+			assert.Equal(t, test.gauge, g)
+		})
+	}
+}
+
+func TestRunMigrateStorageDryRunSource(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	from := filepath.Join(dir, "metrics.json")
+	to := filepath.Join(dir, "metrics.db")
+
+	src := file.NewStorage(ctx, from, 0, false, true, nil)
+	require.NoError(t, src.StoreAll(ctx, map[string]int64{"PollCount": 5}, nil))
+	fl, ok := src.(Flusher)
+	require.True(t, ok)
+	require.NoError(t, fl.Flush(ctx))
+	require.NoError(t, src.StoreAll(ctx, nil, map[string]float64{"Alloc": 1.5}))
+
+	// Недописанная при падении сервера запись журнала.
+	f, err := os.OpenFile(from+file.WALSuffix, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"checksum":"00","list":[{"id":"PollCount","type":"counter","del`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	before, err := os.ReadFile(from + file.WALSuffix)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	err = RunMigrateStorage([]string{"-from", "file:" + from, "-to", "sqlite:" + to, "-dry-run"}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, ""+
+		"counters: 1 in source, 1 new, 1 to write\n"+
+		"gauges: 1 in source, 1 new, 1 to write\n"+
+		"dry run: nothing written\n", out.String())
+
+	after, err := os.ReadFile(from + file.WALSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+}
+
+func TestRunMigrateStorageToFile(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	from := filepath.Join(dir, "metrics.bolt")
+	to := filepath.Join(dir, "metrics.json")
+
+	src, err := bolt.NewStorage(from, false)
+	require.NoError(t, err)
+	require.NoError(t, src.StoreAll(ctx, map[string]int64{"PollCount": 5}, map[string]float64{"Alloc": 1.5}))
+	require.NoError(t, src.Close())
+
+	var out bytes.Buffer
+	err = RunMigrateStorage([]string{"-from", "bolt:" + from, "-to", "file:" + to, "-verify"}, &out)
+	require.NoError(t, err)
+
+	dst := file.NewStorage(ctx, to, 0, true, false, nil)
+	c, g, err := dst.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 5}, c)
+	assert.Equal(t, map[string]float64{"Alloc": 1.5}, g)
+}
+
+func TestMigratePlanVerify(t *testing.T) {
+	p := newMigratePlan(CountersReplace, map[string]int64{"c": 2}, map[string]float64{"g": 1}, nil, nil)
+
+	err := p.verify(map[string]int64{"c": 2}, map[string]float64{"g": 1})
+	assert.NoError(t, err)
+
+	err = p.verify(map[string]int64{"c": 3}, map[string]float64{})
+	assert.ErrorIs(t, err, ErrVerify)
+	assert.EqualError(t, err, "verify failed: 2 mismatches: counter c: want 2, got 3; gauge g: want 1, got 0")
+}
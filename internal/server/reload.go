@@ -0,0 +1,208 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/k0st1a/metrics/internal/storage/file"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// reloadable - поля Config, изменения которых применяются по сигналу SIGHUP без перезапуска сервера.
+// Изменения остальных полей пропускаются до перезапуска.
+var reloadable = map[string]bool{
+	"LogLevel":       true,
+	"HashKey":        true,
+	"HashKeys":       true,
+	"HashKeyGrace":   true,
+	"HashPublicKeys": true,
+	"StoreInterval":  true,
+}
+
+// keySet - проверка подписи запросов и подпись ответов ключами, которые можно сменить без перезапуска
+// сервера. Без ключей подпись не проверяется, а ответы не подписываются.
+type keySet struct {
+	keyring *hash.Keyring
+	mutex   sync.RWMutex
+}
+
+func newKeySet(keys []hash.Key, grace time.Duration) *keySet {
+	k := &keySet{}
+	k.set(keys, grace)
+	return k
+}
+
+func (k *keySet) set(keys []hash.Key, grace time.Duration) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	prev := k.keyring
+
+	k.keyring = nil
+	if len(keys) != 0 {
+		k.keyring = hash.NewKeyring(keys, grace)
+	}
+
+	// Прежние ключи не получают новый срок при каждом применении конфигурации по SIGHUP.
+	if k.keyring != nil && prev != nil {
+		k.keyring.Retain(prev)
+	}
+}
+
+// HasKeys - заданы ли ключи подписи, без ключей запросы без подписи принимаются.
+func (k *keySet) HasKeys() bool {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return k.keyring != nil
+}
+
+// Check - проверка подписи sign данных data по очереди действующими ключами.
+func (k *keySet) Check(data []byte, sign []byte) bool {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if k.keyring == nil {
+		return true
+	}
+
+	return k.keyring.Check(data, sign)
+}
+
+// CheckID - проверка подписи sign данных data действующим ключом с идентификатором id.
+func (k *keySet) CheckID(id string, data []byte, sign []byte) bool {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if k.keyring == nil {
+		return true
+	}
+
+	return k.keyring.CheckID(id, data, sign)
+}
+
+// SignID - подпись ответа на запрос, подписанный ключом с идентификатором id, тем же ключом, если он
+// действует, иначе текущим ключом. Без ключей возвращается nil.
+func (k *keySet) SignID(id string, data []byte) ([]byte, string) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if k.keyring == nil {
+		return nil, ""
+	}
+
+	return k.keyring.SignID(id, data)
+}
+
+// setLogLevel - установка уровня логирования level.
+func setLogLevel(level string) error {
+	l, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("log level parse error:%w", err)
+	}
+
+	zerolog.SetGlobalLevel(l)
+
+	return nil
+}
+
+// reloader - применение конфигурации сервера без перезапуска.
+type reloader struct {
+	cfg  *Config
+	keys *keySet
+	// file - файловое хранилище, если метрики хранятся в файле, иначе nil.
+	file *file.FileStorage
+	load func() (*Config, error)
+}
+
+// Run - повторное чтение и применение конфигурации на каждый сигнал из hup до отмены ctx.
+func (r *reloader) Run(ctx context.Context, hup <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Info().Msg("Reload config")
+
+			cfg, err := r.load()
+			if err != nil {
+				log.Error().Err(err).Msg("reload config error")
+				continue
+			}
+
+			applied, skipped, err := r.reload(ctx, cfg)
+			if err != nil {
+				log.Error().Err(err).Msg("reload config error")
+				continue
+			}
+
+			log.Info().
+				Strs("applied", applied).
+				Strs("skipped", skipped).
+				Msg("Config reloaded")
+		}
+	}
+}
+
+// reload - применение изменений конфигурации cfg относительно текущей. Возвращаются имена примененных
+// полей и полей, которые требуют перезапуска сервера. При ошибке в конфигурации не применяется ни одно изменение.
+func (r *reloader) reload(ctx context.Context, cfg *Config) (applied, skipped []string, err error) {
+	_, err = zerolog.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, nil, fmt.Errorf("log level parse error:%w", err)
+	}
+
+	keys, err := cfg.SignKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cur := reflect.ValueOf(r.cfg).Elem()
+	next := reflect.ValueOf(cfg).Elem()
+
+	for i := 0; i < cur.NumField(); i++ {
+		name := cur.Type().Field(i).Name
+		if reflect.DeepEqual(cur.Field(i).Interface(), next.Field(i).Interface()) {
+			continue
+		}
+
+		if !reloadable[name] {
+			log.Warn().Str("field", name).Msg("Config field changed, restart required")
+			skipped = append(skipped, name)
+			continue
+		}
+
+		applied = append(applied, name)
+	}
+
+	for _, name := range applied {
+		switch name {
+		case "LogLevel":
+			_ = setLogLevel(cfg.LogLevel)
+			r.cfg.LogLevel = cfg.LogLevel
+		case "HashKey", "HashKeys", "HashKeyGrace", "HashPublicKeys":
+			r.keys.set(keys, cfg.HashKeyGrace)
+			r.cfg.HashKey = cfg.HashKey
+			r.cfg.HashKeys = cfg.HashKeys
+			r.cfg.HashKeyGrace = cfg.HashKeyGrace
+			r.cfg.HashPublicKeys = cfg.HashPublicKeys
+		case "StoreInterval":
+			// Интервал меняется и при ошибке записи метрик, запись повторится при следующем изменении.
+			if r.file != nil {
+				err := r.file.SetInterval(ctx, cfg.StoreInterval)
+				if err != nil {
+					log.Error().Err(err).Msg("file storage set interval error")
+				}
+			}
+			r.cfg.StoreInterval = cfg.StoreInterval
+		}
+	}
+
+	return applied, skipped, nil
+}
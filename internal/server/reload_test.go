@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyChecker(t *testing.T) {
+	data := []byte("data")
+	sign := hash.New("old").Sign(data)
+
+	k := newKeySet(nil, 0)
+	assert.True(t, k.Check(data, []byte("any")))
+	assert.True(t, k.CheckID("v1", data, []byte("any")))
+	assert.False(t, k.HasKeys())
+
+	k.set([]hash.Key{{Secret: "old"}}, 0)
+	assert.True(t, k.Check(data, sign))
+	assert.True(t, k.HasKeys())
+
+	k.set([]hash.Key{{ID: "v2", Secret: "new"}}, 0)
+	assert.False(t, k.Check(data, sign))
+	assert.True(t, k.Check(data, hash.New("new").Sign(data)))
+	assert.True(t, k.CheckID("v2", data, hash.New("new").Sign(data)))
+
+	k.set([]hash.Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old"}}, time.Minute)
+	assert.True(t, k.CheckID("v1", data, sign))
+}
+
+func TestKeyCheckerReloadKeepsGrace(t *testing.T) {
+	data := []byte("data")
+	sign := hash.NewWithID("v1", "old").Sign(data)
+	keys := []hash.Key{{ID: "v2", Secret: "new"}, {ID: "v1", Secret: "old"}}
+	grace := 200 * time.Millisecond
+
+	k := newKeySet(keys, grace)
+	assert.True(t, k.CheckID("v1", data, sign))
+
+	// Применение той же конфигурации по SIGHUP не продлевает срок прежнего ключа.
+	time.Sleep(150 * time.Millisecond)
+	k.set(keys, grace)
+	assert.True(t, k.CheckID("v1", data, sign))
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, k.CheckID("v1", data, sign))
+}
+
+func TestReloaderReload(t *testing.T) {
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+
+	cfg := newDefaultConfig()
+	cfg.HashKey = "old"
+
+	r := &reloader{cfg: cfg, keys: newKeySet([]hash.Key{{Secret: cfg.HashKey}}, 0)}
+
+	next := *cfg
+	next.HashKey = "new"
+	next.LogLevel = "warn"
+	next.StoreInterval = 10
+	next.ServerAddr = "localhost:9999"
+
+	applied, skipped, err := r.reload(context.Background(), &next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HashKey", "LogLevel", "StoreInterval"}, applied)
+	assert.Equal(t, []string{"ServerAddr"}, skipped)
+
+	assert.Equal(t, zerolog.WarnLevel, zerolog.GlobalLevel())
+	assert.True(t, r.keys.Check([]byte("data"), hash.New("new").Sign([]byte("data"))))
+	assert.Equal(t, "new", r.cfg.HashKey)
+	assert.Equal(t, 10, r.cfg.StoreInterval)
+	assert.Equal(t, defaultServerAddr, r.cfg.ServerAddr)
+
+	next.LogLevel = "loud"
+	next.HashKey = "other"
+	_, _, err = r.reload(context.Background(), &next)
+	assert.Error(t, err)
+	assert.Equal(t, "new", r.cfg.HashKey)
+
+	next.LogLevel = "warn"
+	next.HashKeys = "v2"
+	_, _, err = r.reload(context.Background(), &next)
+	assert.ErrorIs(t, err, hash.ErrKeyFormat)
+	assert.Equal(t, "new", r.cfg.HashKey)
+}
+
+func TestReloaderRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := newDefaultConfig()
+	loaded := make(chan struct{})
+
+	r := &reloader{
+		cfg:  cfg,
+		keys: newKeySet(nil, 0),
+		load: func() (*Config, error) {
+			defer close(loaded)
+			next := *cfg
+			next.HashKey = "new"
+			return &next, nil
+		},
+	}
+
+	hup := make(chan os.Signal, 1)
+	go r.Run(ctx, hup)
+
+	hup <- syscall.SIGHUP
+
+	select {
+	case <-loaded:
+	case <-time.After(time.Second):
+		t.Fatal("config is not reloaded")
+	}
+
+	assert.Eventually(t, func() bool {
+		return !r.keys.Check([]byte("data"), []byte("sign"))
+	}, time.Second, 10*time.Millisecond)
+}
@@ -5,12 +5,19 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/k0st1a/metrics/internal/handlers/backup"
 	hping "github.com/k0st1a/metrics/internal/handlers/db/ping"
+	"github.com/k0st1a/metrics/internal/handlers/health"
+	"github.com/k0st1a/metrics/internal/handlers/publickey"
 	"github.com/k0st1a/metrics/internal/storage/db"
-	v1 "github.com/k0st1a/metrics/internal/storage/db/migration/v1"
+	dbidempotency "github.com/k0st1a/metrics/internal/storage/db/idempotency"
+	"github.com/k0st1a/metrics/internal/storage/db/migration"
+	"github.com/k0st1a/metrics/internal/storage/db/partition"
 	dbping "github.com/k0st1a/metrics/internal/storage/db/ping"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,14 +26,27 @@ import (
 	"github.com/k0st1a/metrics/internal/handlers/text"
 	"github.com/k0st1a/metrics/internal/middleware"
 	"github.com/k0st1a/metrics/internal/middleware/checksign"
+	"github.com/k0st1a/metrics/internal/middleware/decompress"
 	"github.com/k0st1a/metrics/internal/middleware/decrypt"
+	"github.com/k0st1a/metrics/internal/middleware/encryptresponse"
+	"github.com/k0st1a/metrics/internal/middleware/idempotency"
+	"github.com/k0st1a/metrics/internal/middleware/signresponse"
 	"github.com/k0st1a/metrics/internal/pkg/crypto/rsa"
-	"github.com/k0st1a/metrics/internal/pkg/hash"
+	pkgidempotency "github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/k0st1a/metrics/internal/pkg/nonce"
 	"github.com/k0st1a/metrics/internal/pkg/profiler"
 	"github.com/k0st1a/metrics/internal/pkg/retry"
+	"github.com/k0st1a/metrics/internal/pkg/selfmetrics"
 	"github.com/k0st1a/metrics/internal/pkg/server"
+	"github.com/k0st1a/metrics/internal/storage/bolt"
+	"github.com/k0st1a/metrics/internal/storage/breaker"
 	"github.com/k0st1a/metrics/internal/storage/file"
+	fileidempotency "github.com/k0st1a/metrics/internal/storage/file/idempotency"
 	"github.com/k0st1a/metrics/internal/storage/inmemory"
+	"github.com/k0st1a/metrics/internal/storage/instrumented"
+	"github.com/k0st1a/metrics/internal/storage/redis"
+	"github.com/k0st1a/metrics/internal/storage/sqlite"
+	goredis "github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog/log"
 )
 
@@ -37,6 +57,9 @@ type Storage interface {
 	GetCounter(ctx context.Context, name string) (*int64, error)
 	StoreCounter(ctx context.Context, name string, value int64) error
 
+	DeleteGauge(ctx context.Context, name string) error
+	DeleteCounter(ctx context.Context, name string) error
+
 	StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
 	GetAll(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
 }
@@ -45,6 +68,11 @@ type Pinger interface {
 	Ping(ctx context.Context) error
 }
 
+// Flusher - интерфейс хранилища, которому нужно сохранить метрики перед завершением работы сервера.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
 func Run() error {
 	log.Debug().Msg("Run server")
 
@@ -55,8 +83,24 @@ func Run() error {
 
 	log.Printf("Cfg:%+v", cfg)
 
+	err = setLogLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+
 	var s Storage
 	var p Pinger
+	var is idempotency.Store
+	var checks []health.Check
+	var flushers []Flusher
+	var bh backup.Backuper
+	var fst *file.FileStorage
+	// classify - классификатор ошибок хранилища для повторных обращений, у хранилища в RAM их не бывает.
+	var classify retry.Classifier
+
+	iw := time.Duration(cfg.IdempotencyWindow) * time.Second
+
+	reg := selfmetrics.New()
 
 	ctx, cancelFunc := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer cancelFunc()
@@ -69,52 +113,183 @@ func Run() error {
 			return fmt.Errorf("pgxpool new error:%w", err)
 		}
 
-		m := v1.NewMigration(pool)
-		err = m.Migrate(ctx)
+		m, err := migration.New(pool)
+		if err != nil {
+			return fmt.Errorf("migration new error:%w", err)
+		}
+
+		err = m.Up(ctx)
 		if err != nil {
 			return fmt.Errorf("migrate error:%w", err)
 		}
 
+		// Ошибка обслуживания секций не мешает запуску: записи попадают в секцию по умолчанию
+		// и переносятся в дневные секции при следующем успешном обслуживании.
+		pm := partition.NewManager(pool, cfg.HistoryRetentionDays)
+		err = pm.Maintain(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("metric_samples partitions maintain error")
+		}
+		go pm.Run(ctx, time.Hour)
+
 		p = dbping.NewPinger(pool)
 		s = db.NewStorage(pool)
+		is = dbidempotency.NewStore(pool, iw)
+
+		if cfg.BreakerThreshold > 0 {
+			bs, err := breaker.NewStorage(s, db.IsRetryable, breaker.Options{
+				FailureThreshold: cfg.BreakerThreshold,
+				OpenTimeout:      cfg.BreakerOpenTimeout,
+				BufferSize:       cfg.BreakerBufferSize,
+				SpillPath:        cfg.BreakerSpillPath,
+			}, reg)
+			if err != nil {
+				return fmt.Errorf("breaker new storage error:%w", err)
+			}
+			defer func() {
+				err := bs.Close()
+				if err != nil {
+					log.Error().Err(err).Msg("breaker close error")
+				}
+			}()
+
+			s = bs
+			is = newLocalIdempotencyStore(cfg.BreakerSpillPath, iw)
+			flushers = append(flushers, bs)
+		}
+
+		checks = append(checks, health.Check{Name: "db", Check: p.Ping})
+		classify = db.IsRetryable
+
+	case cfg.SQLitePath != "":
+		log.Debug().Msg("Using sqlite storage")
+		ss, err := sqlite.NewStorage(ctx, cfg.SQLitePath)
+		if err != nil {
+			return fmt.Errorf("sqlite new storage error:%w", err)
+		}
+		defer func() {
+			err := ss.Close()
+			if err != nil {
+				log.Error().Err(err).Msg("sqlite close error")
+			}
+		}()
+
+		p = ss
+		s = ss
+		checks = append(checks, health.Check{Name: "sqlite", Check: ss.Ping})
+		is = sqlite.NewIdempotencyStore(ss, iw)
+		classify = sqlite.IsRetryable
+
+	case cfg.BoltPath != "":
+		log.Debug().Msg("Using bolt storage")
+		bs, err := bolt.NewStorage(cfg.BoltPath, cfg.BoltHistory)
+		if err != nil {
+			return fmt.Errorf("bolt new storage error:%w", err)
+		}
+		defer func() {
+			err := bs.Close()
+			if err != nil {
+				log.Error().Err(err).Msg("bolt close error")
+			}
+		}()
+
+		p = bs
+		s = bs
+		bh = bs
+		checks = append(checks, health.Check{Name: "bolt", Check: bs.Ping})
+		is = bolt.NewIdempotencyStore(bs, iw)
+		classify = bolt.IsRetryable
+
+	case cfg.RedisAddr != "":
+		log.Debug().Msg("Using redis storage")
+		rc := goredis.NewClient(&goredis.Options{Addr: cfg.RedisAddr})
+		defer func() {
+			err := rc.Close()
+			if err != nil {
+				log.Error().Err(err).Msg("redis close error")
+			}
+		}()
+
+		rs := redis.NewStorage(rc, cfg.RedisNamespace)
+		p = rs
+		s = rs
+		checks = append(checks, health.Check{Name: "redis", Check: rs.Ping})
+		is = redis.NewIdempotencyStore(rc, cfg.RedisNamespace, iw)
+		classify = redis.IsRetryable
 
 	case cfg.FileStoragePath != "":
 		log.Debug().Msg("Using file storage")
-		s = file.NewStorage(ctx, cfg.FileStoragePath, cfg.StoreInterval, cfg.Restore)
+		s = file.NewStorage(ctx, cfg.FileStoragePath, cfg.StoreInterval, cfg.Restore, cfg.WAL, reg)
+		if fs, ok := s.(*file.FileStorage); ok {
+			checks = append(checks,
+				health.Check{Name: "file_writable", Check: fs.CheckWritable},
+				health.Check{Name: "file_flush", Check: fs.CheckFlush})
+			flushers = append(flushers, fs)
+			fst = fs
+		}
+		is = fileidempotency.NewStore(cfg.FileStoragePath+".idempotency", iw)
+		classify = file.IsRetryable
 
 	default:
 		log.Debug().Msg("Using memory storage")
 		s = inmemory.NewStorage()
+		is = pkgidempotency.NewMemory(iw)
 	}
 
-	rt := retry.New()
+	s = instrumented.NewStorage(s, reg)
+
+	rt := retry.New(cfg.RetryPolicy(), classify, reg)
 	th := text.NewHandler(s, rt)
 	jh := json.NewHandler(s, rt)
 	dbph := hping.NewHandler(p)
+	hh := health.NewHandler(checks)
 
-	var middlewares []func(http.Handler) http.Handler
-
-	if cfg.HashKey != "" {
-		h := hash.New(cfg.HashKey)
-		middlewares = append(middlewares, checksign.New(h))
+	// Ключ подписи можно сменить по SIGHUP, поэтому проверка подписи включена всегда: без ключа она
+	// пропускает запросы, а с ключом отклоняет запросы без подписи, кроме проверок состояния сервера
+	// и получения его открытого ключа.
+	keys, err := cfg.SignKeys()
+	if err != nil {
+		return err
 	}
 
+	kc := newKeySet(keys, cfg.HashKeyGrace)
+
+	var (
+		pk  publickey.PEMer
+		dec func(http.Handler) http.Handler
+	)
+
 	if cfg.CryptoKey != "" {
-		prv, err := rsa.NewPrivateFromFile(cfg.CryptoKey)
+		passphrase, err := cfg.KeyPassphrase()
+		if err != nil {
+			return err
+		}
+
+		prv, err := rsa.NewPrivateFromFileWithPassphrase(cfg.CryptoKey, passphrase)
 		if err != nil {
 			return fmt.Errorf("rsa new private from file error:%w", err)
 		}
 
-		middlewares = append(middlewares, decrypt.New(prv))
+		dec = decrypt.New(prv)
+		pk = prv.Public()
 	}
 
-	middlewares = append(middlewares, middleware.Logging, middleware.Compress)
-
-	r := handlers.NewRouter(middlewares)
+	r := handlers.NewRouter(newMiddlewares(cfg, kc, dec, reg, is))
 
-	text.BuildRouter(r, th)
-	json.BuildRouter(r, jh)
+	// Удаление и выгрузка всех метрик, а также копия хранилища доступны только с подписью запроса.
+	admin := checksign.Require(kc)
+	text.BuildRouter(r, th, admin)
+	json.BuildRouter(r, jh, admin)
 	hping.BuildRouter(r, dbph)
+	health.BuildRouter(r, hh)
+
+	if bh != nil {
+		backup.BuildRouter(r, backup.NewHandler(bh, "metrics"), admin)
+	}
+
+	if pk != nil {
+		publickey.BuildRouter(r, publickey.NewHandler(pk))
+	}
 
 	srv, err := server.New(ctx, cfg.ServerAddr, r)
 	if err != nil {
@@ -148,6 +323,13 @@ func Run() error {
 		}
 	}()
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	rl := &reloader{cfg: cfg, keys: kc, file: fst, load: ReloadConfig}
+	go rl.Run(ctx, hup)
+
 	<-ctx.Done()
 
 	err = srv.Shutdown(context.Background())
@@ -160,5 +342,59 @@ func Run() error {
 		log.Error().Err(err).Msg("error of shutdown profiler server")
 	}
 
+	// Сервер уже не принимает запросы, поэтому сохраняем итоговое состояние метрик.
+	for _, f := range flushers {
+		err = f.Flush(context.Background())
+		if err != nil {
+			log.Error().Err(err).Msg("error of final flush of storage")
+		}
+	}
+
 	return nil
 }
+
+// newLocalIdempotencyStore - хранилище ответов для БД с предохранителем. Ответы хранятся на сервере, а не в БД:
+// иначе при недоступной БД резервирование ключа отклоняло бы пакеты агента раньше, чем предохранитель
+// сохранит их в буфер. Если задан файл буфера spillPath, то ответы сохраняются рядом с ним.
+func newLocalIdempotencyStore(spillPath string, window time.Duration) idempotency.Store {
+	if spillPath == "" {
+		return pkgidempotency.NewMemory(window)
+	}
+
+	return fileidempotency.NewStore(spillPath+".idempotency", window)
+}
+
+// newMiddlewares - цепочка middleware сервера, где:
+//   - kc - ключи подписи запросов и ответов;
+//   - dec - расшифровка запросов, nil если ключ шифрования не задан;
+//   - o - учет количества и длительности запросов;
+//   - is - хранилище ответов на запросы с ключом идемпотентности.
+func newMiddlewares(cfg *Config, kc *keySet, dec func(http.Handler) http.Handler, o middleware.RequestObserver,
+	is idempotency.Store) []func(http.Handler) http.Handler {
+	replay := checksign.Options{
+		MaxSkew: cfg.SignMaxSkew,
+		Nonces:  nonce.NewCache(cfg.SignNonceCacheSize),
+		Public:  []string{"/ping", "/healthz", "/readyz", "/api/v1/public-key"},
+	}
+	middlewares := []func(http.Handler) http.Handler{checksign.NewWithReplay(kc, replay)}
+
+	if dec != nil {
+		middlewares = append(middlewares, dec)
+	}
+
+	// Агент сжимает тело до подписи и шифрования, поэтому оно распаковывается после расшифровки.
+	middlewares = append(middlewares, decompress.New(cfg.DecompressLimit))
+
+	// Подписывается тело ответа до шифрования и сжатия, а ответы из кеша идемпотентности подписываются
+	// и шифруются так же, как новые. Ответ шифруется, только если агент передал свой открытый ключ.
+	// Потоковые ответы не буферизуются для подписи и шифрования.
+	streaming := []string{backup.Path}
+	middlewares = append(middlewares, middleware.NewLogging(o), middleware.NewCompress(cfg.CompressMinSize),
+		encryptresponse.New(kc, streaming...), signresponse.New(kc, streaming...))
+
+	if cfg.IdempotencyWindow != 0 {
+		middlewares = append(middlewares, idempotency.New(is))
+	}
+
+	return middlewares
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/handlers"
+	"github.com/k0st1a/metrics/internal/handlers/backup"
+	"github.com/k0st1a/metrics/internal/handlers/json"
+	"github.com/k0st1a/metrics/internal/middleware/idempotency"
+	"github.com/k0st1a/metrics/internal/middleware/roundtrip"
+	"github.com/k0st1a/metrics/internal/middleware/sign"
+	"github.com/k0st1a/metrics/internal/pkg/codec"
+	"github.com/k0st1a/metrics/internal/pkg/hash"
+	pkgidempotency "github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/k0st1a/metrics/internal/pkg/retry"
+	"github.com/k0st1a/metrics/internal/storage/breaker"
+	"github.com/k0st1a/metrics/internal/storage/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMiddlewaresStreaming - потоковый ответ проходит через всю цепочку middleware сервера с ключами подписи
+// частями, а не после завершения обработчика.
+func TestMiddlewaresStreaming(t *testing.T) {
+	chunks := make(chan string)
+
+	cfg := newDefaultConfig()
+	kc := newKeySet([]hash.Key{{Secret: "key"}}, 0)
+
+	r := handlers.NewRouter(newMiddlewares(cfg, kc, nil, nil, pkgidempotency.NewMemory(time.Minute)))
+	r.Get(backup.Path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		rc := http.NewResponseController(w)
+		for c := range chunks {
+			_, err := w.Write([]byte(c + "\n"))
+			assert.NoError(t, err)
+			assert.NoError(t, rc.Flush())
+		}
+	})
+
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	tc := &http.Client{
+		Transport: roundtrip.New(&http.Transport{DisableCompression: true}, sign.New(hash.New("key"))),
+	}
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+backup.Path, http.NoBody)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	go func() {
+		chunks <- "first"
+	}()
+
+	resp, err := tc.Do(req)
+	require.NoError(t, err)
+	//nolint:errcheck // not need check error in test
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+	assert.Empty(t, resp.Header.Get("HashSHA256"))
+
+	reader, err := codec.NewReader("gzip", resp.Body)
+	require.NoError(t, err)
+
+	br := bufio.NewReader(reader)
+	for _, want := range []string{"first", "second", "third"} {
+		if want != "first" {
+			chunks <- want
+		}
+
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, want+"\n", line)
+	}
+
+	close(chunks)
+
+	rest, err := io.ReadAll(br)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+}
+
+var errDown = errors.New("db is down")
+
+// downStorage - хранилище в RAM, которое отвечает ошибкой errDown на сохранение, пока down равно true.
+type downStorage struct {
+	*inmemory.Storage
+	down bool
+}
+
+func (d *downStorage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	if d.down {
+		return errDown
+	}
+	//nolint // Не за чем оборачивать ошибку
+	return d.Storage.StoreAll(ctx, counter, gauge)
+}
+
+// TestBreakerIdempotency - пакет агента с ключом идемпотентности при недоступной БД попадает в буфер
+// предохранителя и переносится в БД после ее восстановления, а повтор пакета не учитывается дважды.
+func TestBreakerIdempotency(t *testing.T) {
+	tests := []struct {
+		name      string
+		spillPath string
+	}{
+		{
+			name: "memory",
+		},
+		{
+			name:      "spill file",
+			spillPath: filepath.Join(t.TempDir(), "spill"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			ds := &downStorage{Storage: inmemory.NewStorage(), down: true}
+
+			bs, err := breaker.NewStorage(ds, func(err error) bool { return errors.Is(err, errDown) },
+				breaker.Options{FailureThreshold: 1, OpenTimeout: time.Hour, SpillPath: test.spillPath}, nil)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, bs.Close())
+			}()
+
+			cfg := newDefaultConfig()
+			is := newLocalIdempotencyStore(test.spillPath, time.Minute)
+
+			r := handlers.NewRouter(newMiddlewares(cfg, newKeySet(nil, 0), nil, nil, is))
+			json.BuildRouter(r, json.NewHandler(bs, retry.New(retry.Policy{MaxAttempts: 1}, nil, nil)))
+
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+
+			send := func() *http.Response {
+				req, err := http.NewRequest(http.MethodPost, ts.URL+"/updates/",
+					bytes.NewBufferString(`[{"id":"PollCount","type":"counter","delta":5}]`))
+				require.NoError(t, err)
+				req.Header.Set("Content-Type", "application/json")
+				req.Header.Set(idempotency.Header, "key1")
+
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				require.NoError(t, resp.Body.Close())
+
+				return resp
+			}
+
+			resp := send()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, breaker.Open, bs.State())
+
+			ds.down = false
+			require.NoError(t, bs.Flush(ctx))
+
+			v, err := ds.Storage.GetCounter(ctx, "PollCount")
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), *v)
+
+			resp = send()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NotEmpty(t, resp.Header.Get(idempotency.ReplayedHeader))
+
+			v, err = ds.Storage.GetCounter(ctx, "PollCount")
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), *v)
+		})
+	}
+}
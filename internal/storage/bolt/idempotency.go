@@ -0,0 +1,163 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"go.etcd.io/bbolt"
+)
+
+type idempotencyStore struct {
+	db     *bbolt.DB
+	now    func() time.Time
+	window time.Duration
+}
+
+// NewIdempotencyStore - создание хранилища ответов в той же БД bbolt, что и метрики, где:
+//   - s - storage метрик;
+//   - window - время, в течении которого ответ на запрос хранится.
+func NewIdempotencyStore(s *BoltStorage, window time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		db:     s.db,
+		now:    time.Now,
+		window: window,
+	}
+}
+
+// Reserve - резервирует ключ key за запросом с хешем hash. Резервирование вышедшего за окно ключа
+// перезаписывается.
+func (s *idempotencyStore) Reserve(_ context.Context, key, hash string) (*idempotency.Response, bool, error) {
+	var (
+		saved    *idempotency.Response
+		reserved bool
+	)
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(idempotencyBucket)
+
+		r, err := s.get(b, key)
+		switch {
+		case errors.Is(err, idempotency.ErrNotFound):
+			reserved = true
+			return put(b, key, &idempotency.Response{Created: s.now(), RequestHash: hash})
+		case err != nil:
+			return err
+		default:
+			saved = r
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("reserve idempotency key error:%w", err)
+	}
+
+	if !reserved && saved.Pending() {
+		return nil, false, idempotency.ErrInProgress
+	}
+
+	return saved, reserved, nil
+}
+
+// Save - сохраняет ответ r по ключу key, попутно удаляя ответы вышедшие за окно.
+func (s *idempotencyStore) Save(_ context.Context, key string, r *idempotency.Response) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(idempotencyBucket)
+
+		var expired [][]byte
+
+		err := b.ForEach(func(k, v []byte) error {
+			var r idempotency.Response
+
+			err := json.Unmarshal(v, &r)
+			if err != nil {
+				return fmt.Errorf("json unmarshal error:%w", err)
+			}
+
+			if s.expired(&r) {
+				expired = append(expired, k)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("for each error:%w", err)
+		}
+
+		// Удаление ключей во время обхода ForEach не допускается.
+		for _, k := range expired {
+			err = b.Delete(k)
+			if err != nil {
+				return fmt.Errorf("delete expired key error:%w", err)
+			}
+		}
+
+		return put(b, key, r)
+	})
+	if err != nil {
+		return fmt.Errorf("save idempotency key error:%w", err)
+	}
+
+	return nil
+}
+
+// Release - снимает резервирование ключа key, если ответ по нему не сохранен.
+func (s *idempotencyStore) Release(_ context.Context, key string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(idempotencyBucket)
+
+		r, err := s.get(b, key)
+		if errors.Is(err, idempotency.ErrNotFound) {
+			return nil
+		}
+		if err != nil || !r.Pending() {
+			return err
+		}
+
+		//nolint:wrapcheck //no need here
+		return b.Delete([]byte(key))
+	})
+	if err != nil {
+		return fmt.Errorf("release idempotency key error:%w", err)
+	}
+
+	return nil
+}
+
+// get - ответ по ключу key, ответ вне окна считается отсутствующим и возвращается idempotency.ErrNotFound.
+func (s *idempotencyStore) get(b *bbolt.Bucket, key string) (*idempotency.Response, error) {
+	v := b.Get([]byte(key))
+	if v == nil {
+		return nil, idempotency.ErrNotFound
+	}
+
+	var r idempotency.Response
+
+	err := json.Unmarshal(v, &r)
+	if err != nil {
+		return nil, fmt.Errorf("json unmarshal error:%w", err)
+	}
+
+	if s.expired(&r) {
+		return nil, idempotency.ErrNotFound
+	}
+
+	return &r, nil
+}
+
+func (s *idempotencyStore) expired(r *idempotency.Response) bool {
+	return s.now().Sub(r.Created) > s.window
+}
+
+func put(b *bbolt.Bucket, key string, r *idempotency.Response) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("json marshal error:%w", err)
+	}
+
+	//nolint:wrapcheck //no need here
+	return b.Put([]byte(key), v)
+}
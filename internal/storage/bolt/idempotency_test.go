@@ -0,0 +1,75 @@
+package bolt
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	bs, err := NewStorage(filepath.Join(t.TempDir(), "metrics.db"), false)
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, bs.Close())
+	}()
+
+	s := NewIdempotencyStore(bs, time.Minute)
+	s.now = func() time.Time { return now }
+
+	_, reserved, err := s.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	_, reserved, err = s.Reserve(ctx, "key1", "hash1")
+	assert.ErrorIs(t, err, idempotency.ErrInProgress)
+	assert.False(t, reserved)
+
+	err = s.Save(ctx, "key1", &idempotency.Response{
+		Created:     now,
+		Header:      http.Header{"Content-Type": {"application/json"}},
+		RequestHash: "hash1",
+		Body:        []byte("ok"),
+		Status:      200,
+	})
+	require.NoError(t, err)
+
+	r, reserved, err := s.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 200, r.Status)
+	assert.Equal(t, []byte("ok"), r.Body)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.True(t, now.Equal(r.Created))
+
+	_, reserved, err = s.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	require.NoError(t, s.Release(ctx, "key2"))
+
+	_, reserved, err = s.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Сохраненный ответ снятием резервирования не удаляется, а по окончании окна ключ резервируется заново.
+	require.NoError(t, s.Release(ctx, "key1"))
+
+	_, reserved, err = s.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+
+	now = now.Add(2 * time.Minute)
+
+	_, reserved, err = s.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
@@ -0,0 +1,417 @@
+// Package bolt for save metrics to embedded bbolt key-value DB.
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/retry"
+	"github.com/k0st1a/metrics/internal/utils"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	countersBucket = []byte("counters")
+	gaugesBucket   = []byte("gauges")
+	historyBucket  = []byte("history")
+	// idempotencyBucket - ответы на запросы с заголовком Idempotency-Key, см. NewIdempotencyStore.
+	idempotencyBucket = []byte("idempotency")
+)
+
+// Типы метрик для History.
+const (
+	CounterType = "counter"
+	GaugeType   = "gauge"
+)
+
+const (
+	fileMode    = 0600
+	openTimeout = time.Second
+)
+
+var ErrNoHistory = errors.New("bolt storage: history is disabled")
+
+type BoltStorage struct {
+	db      *bbolt.DB
+	now     func() time.Time
+	history bool
+}
+
+// Sample - запись истории изменения метрики.
+type Sample struct {
+	Time time.Time
+	// Delta - приращение метрики типа counter.
+	Delta int64
+	// Value - значение метрики типа gauge.
+	Value float64
+}
+
+// NewStorage - создать storage для хранения метрик во встроенной БД bbolt, где:
+//   - path - путь на файловой системе до файла БД, если файла нет, то он будет создан;
+//   - history - сохранять ли историю изменений каждой метрики.
+func NewStorage(path string, history bool) (*BoltStorage, error) {
+	db, err := bbolt.Open(path, fileMode, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("bbolt open error:%w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		buckets := [][]byte{countersBucket, gaugesBucket, idempotencyBucket}
+		if history {
+			buckets = append(buckets, historyBucket)
+		}
+
+		for _, b := range buckets {
+			_, err := tx.CreateBucketIfNotExists(b)
+			if err != nil {
+				return fmt.Errorf("create bucket(%s) error:%w", b, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("bbolt init error:%w", err)
+	}
+
+	return &BoltStorage{
+		db:      db,
+		now:     time.Now,
+		history: history,
+	}, nil
+}
+
+// StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
+func (s *BoltStorage) StoreGauge(_ context.Context, name string, value float64) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return s.storeGauge(tx, name, value)
+	})
+	if err != nil {
+		return fmt.Errorf("store gauge error:%w", err)
+	}
+
+	return nil
+}
+
+// GetGauge - возвращает метрику типа gauge с именем name.
+func (s *BoltStorage) GetGauge(_ context.Context, name string) (*float64, error) {
+	var (
+		v  float64
+		ok bool
+	)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v, ok = getGauge(tx.Bucket(gaugesBucket), name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get gauge error:%w", err)
+	}
+
+	if !ok {
+		return nil, utils.ErrMetricsNoGauge
+	}
+
+	return &v, nil
+}
+
+// StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
+func (s *BoltStorage) StoreCounter(_ context.Context, name string, value int64) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return s.storeCounter(tx, name, value)
+	})
+	if err != nil {
+		return fmt.Errorf("store counter error:%w", err)
+	}
+
+	return nil
+}
+
+// GetCounter - возвращает метрику типа counter с именем name.
+func (s *BoltStorage) GetCounter(_ context.Context, name string) (*int64, error) {
+	var (
+		d  int64
+		ok bool
+	)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		d, ok = getCounter(tx.Bucket(countersBucket), name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get counter error:%w", err)
+	}
+
+	if !ok {
+		return nil, utils.ErrMetricsNoCounter
+	}
+
+	return &d, nil
+}
+
+// DeleteGauge - удаляет метрику типа gauge с именем name вместе с историей ее изменений.
+func (s *BoltStorage) DeleteGauge(_ context.Context, name string) error {
+	return s.delete(gaugesBucket, GaugeType, name, utils.ErrMetricsNoGauge)
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name вместе с историей ее изменений.
+func (s *BoltStorage) DeleteCounter(_ context.Context, name string) error {
+	return s.delete(countersBucket, CounterType, name, utils.ErrMetricsNoCounter)
+}
+
+// delete - удаляет метрику из бакета bucket, если метрики нет, то возвращается notFound.
+func (s *BoltStorage) delete(bucket []byte, mtype, name string, notFound error) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if b.Get([]byte(name)) == nil {
+			return notFound
+		}
+
+		err := b.Delete([]byte(name))
+		if err != nil {
+			return fmt.Errorf("delete error:%w", err)
+		}
+
+		hb := tx.Bucket(historyBucket)
+		if hb == nil || hb.Bucket(historyKey(mtype, name)) == nil {
+			return nil
+		}
+
+		err = hb.DeleteBucket(historyKey(mtype, name))
+		if err != nil {
+			return fmt.Errorf("delete history error:%w", err)
+		}
+
+		return nil
+	})
+	if errors.Is(err, notFound) {
+		return notFound
+	}
+	if err != nil {
+		return fmt.Errorf("delete %s error:%w", mtype, err)
+	}
+
+	return nil
+}
+
+// StoreAll - сохраняет группу метрик типа counter и gauge в одной транзакции: либо сохраняются все метрики,
+// либо ни одна.
+func (s *BoltStorage) StoreAll(_ context.Context, counter map[string]int64, gauge map[string]float64) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for k, v := range counter {
+			err := s.storeCounter(tx, k, v)
+			if err != nil {
+				return err
+			}
+		}
+
+		for k, v := range gauge {
+			err := s.storeGauge(tx, k, v)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store all error:%w", err)
+	}
+
+	return nil
+}
+
+// GetAll - возвращает все метрики типа counter и gauge из одного согласованного снимка БД.
+func (s *BoltStorage) GetAll(_ context.Context) (map[string]int64, map[string]float64, error) {
+	c := make(map[string]int64)
+	g := make(map[string]float64)
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		err := tx.Bucket(countersBucket).ForEach(func(k, v []byte) error {
+			c[string(k)] = int64(binary.BigEndian.Uint64(v))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("counters read error:%w", err)
+		}
+
+		err = tx.Bucket(gaugesBucket).ForEach(func(k, v []byte) error {
+			g[string(k)] = math.Float64frombits(binary.BigEndian.Uint64(v))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("gauges read error:%w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("get all error:%w", err)
+	}
+
+	return c, g, nil
+}
+
+// History - возвращает историю изменений метрики типа mtype с именем name в порядке записи.
+func (s *BoltStorage) History(_ context.Context, mtype, name string) ([]Sample, error) {
+	if !s.history {
+		return nil, ErrNoHistory
+	}
+
+	var h []Sample
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket(historyKey(mtype, name))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(_, v []byte) error {
+			sm := Sample{
+				Time: time.Unix(0, int64(binary.BigEndian.Uint64(v[:8]))),
+			}
+
+			raw := binary.BigEndian.Uint64(v[8:])
+			if mtype == CounterType {
+				sm.Delta = int64(raw)
+			} else {
+				sm.Value = math.Float64frombits(raw)
+			}
+
+			h = append(h, sm)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get history error:%w", err)
+	}
+
+	return h, nil
+}
+
+// Backup - записывает в w согласованную копию файла БД, не останавливая запись метрик.
+func (s *BoltStorage) Backup(_ context.Context, w io.Writer) (int64, error) {
+	var n int64
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	if err != nil {
+		return n, fmt.Errorf("backup error:%w", err)
+	}
+
+	return n, nil
+}
+
+// Ping - проверка доступности БД.
+func (s *BoltStorage) Ping(_ context.Context) error {
+	err := s.db.View(func(*bbolt.Tx) error { return nil })
+	if err != nil {
+		return fmt.Errorf("bbolt ping error:%w", err)
+	}
+
+	return nil
+}
+
+// Close - закрывает БД.
+func (s *BoltStorage) Close() error {
+	err := s.db.Close()
+	if err != nil {
+		return fmt.Errorf("bbolt close error:%w", err)
+	}
+
+	return nil
+}
+
+func (s *BoltStorage) storeCounter(tx *bbolt.Tx, name string, value int64) error {
+	b := tx.Bucket(countersBucket)
+
+	d, _ := getCounter(b, name)
+
+	err := b.Put([]byte(name), encode(uint64(d+value)))
+	if err != nil {
+		return fmt.Errorf("put counter(%v) error:%w", name, err)
+	}
+
+	return s.appendHistory(tx, CounterType, name, uint64(value))
+}
+
+func (s *BoltStorage) storeGauge(tx *bbolt.Tx, name string, value float64) error {
+	err := tx.Bucket(gaugesBucket).Put([]byte(name), encode(math.Float64bits(value)))
+	if err != nil {
+		return fmt.Errorf("put gauge(%v) error:%w", name, err)
+	}
+
+	return s.appendHistory(tx, GaugeType, name, math.Float64bits(value))
+}
+
+// appendHistory - дописывает изменение метрики в историю, если она включена. Ключ записи - порядковый номер
+// в бакете метрики, значение - время записи и сырое значение метрики.
+func (s *BoltStorage) appendHistory(tx *bbolt.Tx, mtype, name string, raw uint64) error {
+	if !s.history {
+		return nil
+	}
+
+	b, err := tx.Bucket(historyBucket).CreateBucketIfNotExists(historyKey(mtype, name))
+	if err != nil {
+		return fmt.Errorf("create history bucket(%v:%v) error:%w", mtype, name, err)
+	}
+
+	seq, err := b.NextSequence()
+	if err != nil {
+		return fmt.Errorf("history sequence error:%w", err)
+	}
+
+	v := make([]byte, 16)
+	binary.BigEndian.PutUint64(v[:8], uint64(s.now().UnixNano()))
+	binary.BigEndian.PutUint64(v[8:], raw)
+
+	err = b.Put(encode(seq), v)
+	if err != nil {
+		return fmt.Errorf("put history(%v:%v) error:%w", mtype, name, err)
+	}
+
+	return nil
+}
+
+func getCounter(b *bbolt.Bucket, name string) (int64, bool) {
+	v := b.Get([]byte(name))
+	if v == nil {
+		return 0, false
+	}
+
+	return int64(binary.BigEndian.Uint64(v)), true
+}
+
+func getGauge(b *bbolt.Bucket, name string) (float64, bool) {
+	v := b.Get([]byte(name))
+	if v == nil {
+		return 0, false
+	}
+
+	return math.Float64frombits(binary.BigEndian.Uint64(v)), true
+}
+
+func historyKey(mtype, name string) []byte {
+	return []byte(mtype + ":" + name)
+}
+
+func encode(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// IsRetryable - классификатор ошибок хранилища для повторных обращений: истекло ожидание блокировки файла БД,
+// временные ошибки файловой системы.
+func IsRetryable(err error) bool {
+	return errors.Is(err, bbolt.ErrTimeout) || retry.IsTemporary(err)
+}
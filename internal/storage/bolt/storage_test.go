@@ -0,0 +1,128 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, path string, history bool) *BoltStorage {
+	t.Helper()
+
+	s, err := NewStorage(path, history)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+
+	return s
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "metrics.db"), false)
+
+	_, err := s.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoCounter)
+
+	_, err = s.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoGauge)
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 2))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", -2.5))
+
+	c, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *c)
+
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, -2.5, *g)
+
+	err = s.StoreAll(ctx,
+		map[string]int64{"PollCount": -10, "Other": 5},
+		map[string]float64{"Alloc": 3.5, "RandomValue": 0.1})
+	require.NoError(t, err)
+
+	cs, gs, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": -7, "Other": 5}, cs)
+	assert.Equal(t, map[string]float64{"Alloc": 3.5, "RandomValue": 0.1}, gs)
+
+	_, err = s.History(ctx, CounterType, "PollCount")
+	assert.ErrorIs(t, err, ErrNoHistory)
+
+	assert.NoError(t, s.Ping(ctx))
+}
+
+func TestStorageHistory(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "metrics.db"), true)
+
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreAll(ctx, map[string]int64{"PollCount": 2}, map[string]float64{"Alloc": 1.5}))
+
+	h, err := s.History(ctx, CounterType, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, []Sample{{Time: now, Delta: 1}, {Time: now, Delta: 2}}, h)
+
+	h, err = s.History(ctx, GaugeType, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, []Sample{{Time: now, Value: 1.5}}, h)
+
+	h, err = s.History(ctx, GaugeType, "Unknown")
+	require.NoError(t, err)
+	assert.Empty(t, h)
+}
+
+func TestStorageBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := newTestStorage(t, filepath.Join(dir, "metrics.db"), false)
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+
+	var buf bytes.Buffer
+	n, err := s.Backup(ctx, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	path := filepath.Join(dir, "backup.db")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), fileMode))
+
+	b := newTestStorage(t, path, false)
+
+	c, err := b.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *c)
+}
+
+func TestStorageDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "metrics.db"), true)
+
+	require.NoError(t, s.StoreAll(ctx, map[string]int64{"PollCount": 1}, map[string]float64{"Alloc": 1.5}))
+
+	require.NoError(t, s.DeleteCounter(ctx, "PollCount"))
+	require.NoError(t, s.DeleteGauge(ctx, "Alloc"))
+
+	assert.ErrorIs(t, s.DeleteCounter(ctx, "PollCount"), utils.ErrMetricsNoCounter)
+	assert.ErrorIs(t, s.DeleteGauge(ctx, "Alloc"), utils.ErrMetricsNoGauge)
+
+	c, g, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, c)
+	assert.Empty(t, g)
+}
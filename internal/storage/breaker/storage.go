@@ -0,0 +1,549 @@
+// Package breaker for storage decorator with circuit breaker, which buffers metrics while the storage is
+// unavailable and replays them once it recovers.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/storage/file/wal"
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// State - состояние предохранителя.
+type State int
+
+const (
+	// Closed - хранилище доступно, операции выполняются в хранилище.
+	Closed State = iota
+	// Open - хранилище недоступно, запись идет в буфер, чтение - из последних известных значений.
+	Open
+	// HalfOpen - буфер переносится в хранилище, чтобы проверить его доступность.
+	HalfOpen
+)
+
+var (
+	// ErrOpen - хранилище недоступно, а последнее известное значение метрики отсутствует.
+	ErrOpen = fmt.Errorf("breaker: %w", utils.ErrStorageUnavailable)
+	// ErrBufferFull - в буфере нет места под новую метрику.
+	ErrBufferFull = errors.New("breaker: buffer is full")
+)
+
+// Storage - интерфейс работы с хранилищем метрик.
+type Storage interface {
+	GetGauge(ctx context.Context, name string) (*float64, error)
+	StoreGauge(ctx context.Context, name string, value float64) error
+
+	GetCounter(ctx context.Context, name string) (*int64, error)
+	StoreCounter(ctx context.Context, name string, value int64) error
+
+	DeleteGauge(ctx context.Context, name string) error
+	DeleteCounter(ctx context.Context, name string) error
+
+	StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
+	GetAll(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
+}
+
+// Observer - интерфейс учета работы предохранителя в метриках.
+type Observer interface {
+	AddCounter(name string, delta int64)
+	SetGauge(name string, value float64)
+}
+
+// Options - параметры предохранителя.
+type Options struct {
+	// FailureThreshold - число ошибок хранилища подряд, после которого предохранитель размыкается.
+	FailureThreshold int
+	// OpenTimeout - время, по истечении которого разомкнутый предохранитель пробует перенести буфер в хранилище.
+	OpenTimeout time.Duration
+	// BufferSize - максимальное число разных метрик в буфере, значение 0 снимает ограничение.
+	BufferSize int
+	// SpillPath - полное имя файла, в котором дублируется буфер, чтобы он пережил перезапуск сервера,
+	// пустое значение отключает функцию.
+	SpillPath string
+}
+
+// BreakerStorage - хранилище с предохранителем.
+type BreakerStorage struct {
+	storage  Storage
+	classify func(error) bool
+	observer Observer
+	spill    *wal.Log
+	now      func() time.Time
+	// lastCounter, lastGauge - последние известные значения метрик в хранилище.
+	lastCounter map[string]int64
+	lastGauge   map[string]float64
+	// bufCounter, bufGauge - буфер еще не сохраненных в хранилище приращений counter и значений gauge.
+	bufCounter map[string]int64
+	bufGauge   map[string]float64
+	openedAt   time.Time
+	opts       Options
+	failures   int
+	state      State
+	mutex      sync.Mutex
+}
+
+// NewStorage - создать хранилище с предохранителем, где:
+//   - s - хранилище метрик;
+//   - c - классификатор ошибок: ошибки, которые он относит к временным, считаются отказами хранилища;
+//   - opts - параметры предохранителя;
+//   - o - учет работы предохранителя в метриках, может быть nil.
+//
+// Если в файле opts.SpillPath остались метрики с прошлого запуска, то предохранитель создается разомкнутым
+// и переносит их в хранилище при первой же операции.
+func NewStorage(s Storage, c func(error) bool, opts Options, o Observer) (*BreakerStorage, error) {
+	b := &BreakerStorage{
+		storage:     s,
+		classify:    c,
+		observer:    o,
+		now:         time.Now,
+		lastCounter: make(map[string]int64),
+		lastGauge:   make(map[string]float64),
+		bufCounter:  make(map[string]int64),
+		bufGauge:    make(map[string]float64),
+		opts:        opts,
+	}
+
+	if opts.SpillPath != "" {
+		l, err := wal.Open(opts.SpillPath, 0, func(counter map[string]int64, gauge map[string]float64) error {
+			b.add(counter, gauge)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("open spill error:%w", err)
+		}
+		b.spill = l
+	}
+
+	if len(b.bufCounter)+len(b.bufGauge) != 0 {
+		log.Warn().Int("metrics", len(b.bufCounter)+len(b.bufGauge)).Msg("breaker: buffer restored from spill")
+		b.state = Open
+	}
+
+	b.report()
+
+	return b, nil
+}
+
+// StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
+func (b *BreakerStorage) StoreGauge(ctx context.Context, name string, value float64) error {
+	return b.store(ctx, nil, map[string]float64{name: value}, func() error {
+		//nolint // Не за чем оборачивать ошибку
+		return b.storage.StoreGauge(ctx, name, value)
+	})
+}
+
+// StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
+func (b *BreakerStorage) StoreCounter(ctx context.Context, name string, value int64) error {
+	return b.store(ctx, map[string]int64{name: value}, nil, func() error {
+		//nolint // Не за чем оборачивать ошибку
+		return b.storage.StoreCounter(ctx, name, value)
+	})
+}
+
+// StoreAll - сохраняет группу метрик типа counter и gauge.
+func (b *BreakerStorage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	return b.store(ctx, counter, gauge, func() error {
+		//nolint // Не за чем оборачивать ошибку
+		return b.storage.StoreAll(ctx, counter, gauge)
+	})
+}
+
+// store - сохраняет метрики функцией fn, если хранилище доступно, иначе - в буфер. Метрики, на которых
+// предохранитель разомкнулся, тоже сохраняются в буфер.
+func (b *BreakerStorage) store(ctx context.Context, counter map[string]int64, gauge map[string]float64,
+	fn func() error) error {
+	if !b.allow(ctx) {
+		return b.buffer(counter, gauge)
+	}
+
+	err := fn()
+	if b.failed(err) {
+		if b.State() != Closed {
+			return b.buffer(counter, gauge)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for k, v := range counter {
+		if _, ok := b.lastCounter[k]; ok {
+			b.lastCounter[k] += v
+		}
+	}
+	for k, v := range gauge {
+		b.lastGauge[k] = v
+	}
+
+	return nil
+}
+
+// GetGauge - возвращает метрику типа gauge с именем name.
+func (b *BreakerStorage) GetGauge(ctx context.Context, name string) (*float64, error) {
+	if b.allow(ctx) {
+		v, err := b.storage.GetGauge(ctx, name)
+		if !b.failed(err) || b.State() == Closed {
+			if err != nil || v == nil {
+				return v, err //nolint // Не за чем оборачивать ошибку
+			}
+
+			b.mutex.Lock()
+			b.lastGauge[name] = *v
+			b.mutex.Unlock()
+
+			return v, nil
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	v, ok := b.bufGauge[name]
+	if !ok {
+		v, ok = b.lastGauge[name]
+	}
+	if !ok {
+		return nil, ErrOpen
+	}
+
+	return &v, nil
+}
+
+// GetCounter - возвращает метрику типа counter с именем name.
+func (b *BreakerStorage) GetCounter(ctx context.Context, name string) (*int64, error) {
+	if b.allow(ctx) {
+		v, err := b.storage.GetCounter(ctx, name)
+		if !b.failed(err) || b.State() == Closed {
+			if err != nil || v == nil {
+				return v, err //nolint // Не за чем оборачивать ошибку
+			}
+
+			b.mutex.Lock()
+			b.lastCounter[name] = *v
+			b.mutex.Unlock()
+
+			return v, nil
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	v, ok := b.lastCounter[name]
+	if !ok {
+		return nil, ErrOpen
+	}
+	v += b.bufCounter[name]
+
+	return &v, nil
+}
+
+// GetAll - возвращает все метрики. Если хранилище недоступно, то возвращаются последние известные значения
+// метрик с учетом буфера, а метрики типа counter с неизвестным значением в хранилище пропускаются.
+func (b *BreakerStorage) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
+	if b.allow(ctx) {
+		c, g, err := b.storage.GetAll(ctx)
+		if !b.failed(err) || b.State() == Closed {
+			if err != nil {
+				return nil, nil, err //nolint // Не за чем оборачивать ошибку
+			}
+
+			b.mutex.Lock()
+			for k, v := range c {
+				b.lastCounter[k] = v
+			}
+			for k, v := range g {
+				b.lastGauge[k] = v
+			}
+			b.mutex.Unlock()
+
+			return c, g, nil
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	c := make(map[string]int64, len(b.lastCounter))
+	for k, v := range b.lastCounter {
+		c[k] = v + b.bufCounter[k]
+	}
+
+	g := make(map[string]float64, len(b.lastGauge)+len(b.bufGauge))
+	for k, v := range b.lastGauge {
+		g[k] = v
+	}
+	for k, v := range b.bufGauge {
+		g[k] = v
+	}
+
+	return c, g, nil
+}
+
+// DeleteGauge - удаляет метрику типа gauge с именем name. Буфер не хранит удаления, поэтому, пока хранилище
+// недоступно, удаление возвращает ErrOpen.
+func (b *BreakerStorage) DeleteGauge(ctx context.Context, name string) error {
+	return b.delete(ctx, func() error {
+		//nolint // Не за чем оборачивать ошибку
+		return b.storage.DeleteGauge(ctx, name)
+	}, func() {
+		delete(b.lastGauge, name)
+	})
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name. Буфер не хранит удаления, поэтому, пока
+// хранилище недоступно, удаление возвращает ErrOpen.
+func (b *BreakerStorage) DeleteCounter(ctx context.Context, name string) error {
+	return b.delete(ctx, func() error {
+		//nolint // Не за чем оборачивать ошибку
+		return b.storage.DeleteCounter(ctx, name)
+	}, func() {
+		delete(b.lastCounter, name)
+	})
+}
+
+// delete - удаляет метрику функцией fn и, если удалось, забывает ее последнее известное значение функцией forget.
+func (b *BreakerStorage) delete(ctx context.Context, fn func() error, forget func()) error {
+	if !b.allow(ctx) {
+		return ErrOpen
+	}
+
+	err := fn()
+	if b.failed(err) && b.State() != Closed {
+		return ErrOpen
+	}
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	forget()
+	b.mutex.Unlock()
+
+	return nil
+}
+
+// State - текущее состояние предохранителя.
+func (b *BreakerStorage) State() State {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.state
+}
+
+// Flush - переносит буфер в хранилище независимо от состояния предохранителя. Используется при завершении
+// работы сервера.
+func (b *BreakerStorage) Flush(ctx context.Context) error {
+	b.mutex.Lock()
+	if b.state == HalfOpen || len(b.bufCounter)+len(b.bufGauge) == 0 {
+		b.mutex.Unlock()
+		return nil
+	}
+	b.state = HalfOpen
+	b.mutex.Unlock()
+
+	err := b.replay(ctx)
+	if err != nil {
+		return fmt.Errorf("replay error:%w", err)
+	}
+
+	return nil
+}
+
+// Close - закрывает файл буфера.
+func (b *BreakerStorage) Close() error {
+	if b.spill == nil {
+		return nil
+	}
+
+	err := b.spill.Close()
+	if err != nil {
+		return fmt.Errorf("close spill error:%w", err)
+	}
+
+	return nil
+}
+
+// allow - можно ли обращаться к хранилищу. По истечении OpenTimeout разомкнутый предохранитель переносит
+// буфер в хранилище, и, если это удалось, замыкается.
+func (b *BreakerStorage) allow(ctx context.Context) bool {
+	b.mutex.Lock()
+	switch {
+	case b.state == Closed:
+		b.mutex.Unlock()
+		return true
+	case b.state == HalfOpen || b.now().Sub(b.openedAt) < b.opts.OpenTimeout:
+		b.mutex.Unlock()
+		return false
+	}
+	b.state = HalfOpen
+	b.mutex.Unlock()
+
+	return b.replay(ctx) == nil
+}
+
+// replay - переносит буфер в хранилище, пока он не опустеет, и замыкает предохранитель. Метрики, попавшие в
+// буфер во время переноса, переносятся следующим заходом. При ошибке предохранитель снова размыкается.
+func (b *BreakerStorage) replay(ctx context.Context) error {
+	for {
+		b.mutex.Lock()
+		if len(b.bufCounter)+len(b.bufGauge) == 0 {
+			b.close()
+			b.mutex.Unlock()
+			return nil
+		}
+		c, g := maps.Clone(b.bufCounter), maps.Clone(b.bufGauge)
+		b.mutex.Unlock()
+
+		err := b.storage.StoreAll(ctx, c, g)
+
+		b.mutex.Lock()
+		if err != nil {
+			log.Error().Err(err).Msg("breaker: replay error")
+			b.open()
+			b.mutex.Unlock()
+			return err //nolint // Не за чем оборачивать ошибку
+		}
+
+		b.sub(c, g)
+		if b.observer != nil {
+			b.observer.AddCounter("breaker_replayed_total", int64(len(c)+len(g)))
+		}
+		b.mutex.Unlock()
+	}
+}
+
+// failed - учитывает результат обращения к хранилищу, возвращает true, если ошибка err - отказ хранилища.
+func (b *BreakerStorage) failed(err error) bool {
+	fail := err != nil && b.classify != nil && b.classify(err)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !fail {
+		b.failures = 0
+		return false
+	}
+
+	b.failures++
+	if b.state == Closed && b.failures >= b.opts.FailureThreshold {
+		log.Error().Err(err).Int("failures", b.failures).Msg("breaker: storage is unavailable")
+		b.open()
+	}
+
+	return true
+}
+
+// buffer - сохраняет метрики в буфер и в файл буфера.
+func (b *BreakerStorage) buffer(counter map[string]int64, gauge map[string]float64) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.opts.BufferSize > 0 {
+		n := len(b.bufCounter) + len(b.bufGauge)
+		for k := range counter {
+			if _, ok := b.bufCounter[k]; !ok {
+				n++
+			}
+		}
+		for k := range gauge {
+			if _, ok := b.bufGauge[k]; !ok {
+				n++
+			}
+		}
+		if n > b.opts.BufferSize {
+			return ErrBufferFull
+		}
+	}
+
+	if b.spill != nil {
+		err := b.spill.Append(counter, gauge)
+		if err != nil {
+			return fmt.Errorf("spill append error:%w", err)
+		}
+	}
+
+	b.add(counter, gauge)
+	b.report()
+
+	return nil
+}
+
+func (b *BreakerStorage) add(counter map[string]int64, gauge map[string]float64) {
+	for k, v := range counter {
+		b.bufCounter[k] += v
+	}
+	for k, v := range gauge {
+		b.bufGauge[k] = v
+	}
+}
+
+// sub - убирает из буфера перенесенные в хранилище метрики. Если буфер дублируется в файле, то файл
+// переписывается остатком буфера, чтобы после перезапуска метрики не перенеслись повторно.
+func (b *BreakerStorage) sub(counter map[string]int64, gauge map[string]float64) {
+	for k, v := range counter {
+		b.bufCounter[k] -= v
+		if b.bufCounter[k] == 0 {
+			delete(b.bufCounter, k)
+		}
+		if _, ok := b.lastCounter[k]; ok {
+			b.lastCounter[k] += v
+		}
+	}
+	for k, v := range gauge {
+		if b.bufGauge[k] == v {
+			delete(b.bufGauge, k)
+		}
+		b.lastGauge[k] = v
+	}
+
+	if b.spill == nil {
+		return
+	}
+
+	err := b.spill.Truncate()
+	if err == nil && len(b.bufCounter)+len(b.bufGauge) != 0 {
+		err = b.spill.Append(b.bufCounter, b.bufGauge)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("breaker: spill rewrite error")
+	}
+
+	b.report()
+}
+
+func (b *BreakerStorage) open() {
+	b.state = Open
+	b.openedAt = b.now()
+	if b.observer != nil {
+		b.observer.AddCounter("breaker_opens_total", 1)
+	}
+	b.report()
+}
+
+func (b *BreakerStorage) close() {
+	if b.state != Closed {
+		log.Info().Msg("breaker: storage is available")
+	}
+	b.state = Closed
+	b.failures = 0
+	b.report()
+}
+
+func (b *BreakerStorage) report() {
+	if b.observer == nil {
+		return
+	}
+	b.observer.SetGauge("breaker_state", float64(b.state))
+	b.observer.SetGauge("breaker_buffered", float64(len(b.bufCounter)+len(b.bufGauge)))
+}
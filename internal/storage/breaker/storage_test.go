@@ -0,0 +1,207 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/storage/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errDown = errors.New("storage is down")
+
+// flaky - хранилище в RAM, которое отвечает ошибкой errDown, пока down равно true.
+type flaky struct {
+	*inmemory.Storage
+	down bool
+}
+
+func (f *flaky) StoreCounter(ctx context.Context, name string, value int64) error {
+	if f.down {
+		return errDown
+	}
+	//nolint // Не за чем оборачивать ошибку
+	return f.Storage.StoreCounter(ctx, name, value)
+}
+
+func (f *flaky) StoreGauge(ctx context.Context, name string, value float64) error {
+	if f.down {
+		return errDown
+	}
+	//nolint // Не за чем оборачивать ошибку
+	return f.Storage.StoreGauge(ctx, name, value)
+}
+
+func (f *flaky) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	if f.down {
+		return errDown
+	}
+	//nolint // Не за чем оборачивать ошибку
+	return f.Storage.StoreAll(ctx, counter, gauge)
+}
+
+func (f *flaky) GetCounter(ctx context.Context, name string) (*int64, error) {
+	if f.down {
+		return nil, errDown
+	}
+	//nolint // Не за чем оборачивать ошибку
+	return f.Storage.GetCounter(ctx, name)
+}
+
+func (f *flaky) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
+	if f.down {
+		return nil, nil, errDown
+	}
+	//nolint // Не за чем оборачивать ошибку
+	return f.Storage.GetAll(ctx)
+}
+
+func isDown(err error) bool {
+	return errors.Is(err, errDown)
+}
+
+func newTestStorage(t *testing.T, f *flaky, opts Options) (*BreakerStorage, *time.Time) {
+	t.Helper()
+
+	b, err := NewStorage(f, isDown, opts, nil)
+	require.NoError(t, err)
+
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	return b, &now
+}
+
+func TestBreakerOpenAndReplay(t *testing.T) {
+	ctx := context.Background()
+	f := &flaky{Storage: inmemory.NewStorageWith(map[string]int64{"c": 10}, map[string]float64{"g": 1})}
+	b, now := newTestStorage(t, f, Options{FailureThreshold: 2, OpenTimeout: time.Second})
+
+	_, _, err := b.GetAll(ctx)
+	require.NoError(t, err)
+
+	f.down = true
+
+	err = b.StoreCounter(ctx, "c", 1)
+	assert.ErrorIs(t, err, errDown)
+	assert.Equal(t, Closed, b.State())
+
+	// Ошибка, на которой предохранитель разомкнулся, не теряет метрику.
+	err = b.StoreCounter(ctx, "c", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, Open, b.State())
+
+	err = b.StoreAll(ctx, map[string]int64{"c": 3}, map[string]float64{"g": 2})
+	assert.NoError(t, err)
+
+	c, err := b.GetCounter(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), *c)
+
+	g, err := b.GetGauge(ctx, "g")
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), *g)
+
+	_, err = b.GetCounter(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrOpen)
+
+	// До истечения OpenTimeout хранилище не опрашивается.
+	f.down = false
+	err = b.StoreGauge(ctx, "g", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, Open, b.State())
+
+	*now = now.Add(time.Second)
+
+	err = b.StoreCounter(ctx, "c", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, Closed, b.State())
+
+	counter, gauge, err := f.Storage.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"c": 19}, counter)
+	assert.Equal(t, map[string]float64{"g": 3}, gauge)
+
+	c, err = b.GetCounter(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(19), *c)
+}
+
+func TestBreakerReplayFailure(t *testing.T) {
+	ctx := context.Background()
+	f := &flaky{Storage: inmemory.NewStorage(), down: true}
+	b, now := newTestStorage(t, f, Options{FailureThreshold: 1, OpenTimeout: time.Second})
+
+	err := b.StoreCounter(ctx, "c", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, Open, b.State())
+
+	*now = now.Add(time.Second)
+
+	err = b.StoreCounter(ctx, "c", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, Open, b.State())
+
+	f.down = false
+	err = b.Flush(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Closed, b.State())
+
+	c, err := f.Storage.GetCounter(ctx, "c")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *c)
+}
+
+func TestBreakerBufferFull(t *testing.T) {
+	ctx := context.Background()
+	f := &flaky{Storage: inmemory.NewStorage(), down: true}
+	b, _ := newTestStorage(t, f, Options{FailureThreshold: 1, OpenTimeout: time.Second, BufferSize: 2})
+
+	err := b.StoreAll(ctx, map[string]int64{"c": 1}, map[string]float64{"g": 1})
+	assert.NoError(t, err)
+
+	err = b.StoreCounter(ctx, "c", 1)
+	assert.NoError(t, err)
+
+	err = b.StoreGauge(ctx, "other", 1)
+	assert.ErrorIs(t, err, ErrBufferFull)
+}
+
+func TestBreakerSpill(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "breaker.wal")
+	f := &flaky{Storage: inmemory.NewStorage(), down: true}
+	opts := Options{FailureThreshold: 1, OpenTimeout: time.Second, SpillPath: path}
+
+	b, _ := newTestStorage(t, f, opts)
+
+	err := b.StoreAll(ctx, map[string]int64{"c": 1}, map[string]float64{"g": 1})
+	assert.NoError(t, err)
+	err = b.StoreCounter(ctx, "c", 2)
+	assert.NoError(t, err)
+	require.NoError(t, b.Close())
+
+	// После перезапуска буфер восстанавливается из файла и переносится в хранилище при первой операции.
+	f.down = false
+	b, _ = newTestStorage(t, f, opts)
+	assert.Equal(t, Open, b.State())
+
+	err = b.StoreCounter(ctx, "c", 4)
+	assert.NoError(t, err)
+	assert.Equal(t, Closed, b.State())
+	require.NoError(t, b.Close())
+
+	counter, gauge, err := f.Storage.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"c": 7}, counter)
+	assert.Equal(t, map[string]float64{"g": 1}, gauge)
+
+	// Перенесенный буфер не переносится повторно.
+	b, _ = newTestStorage(t, f, opts)
+	assert.Equal(t, Closed, b.State())
+	require.NoError(t, b.Close())
+}
@@ -0,0 +1,119 @@
+// Package idempotency for save responses of idempotent requests to PostgreSQL DB.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+)
+
+type store struct {
+	c      *pgxpool.Pool
+	window time.Duration
+}
+
+// NewStore - создание хранилища ответов в БД, где:
+//   - c - пулл коннекций до БД;
+//   - window - время, в течении которого ответ на запрос хранится.
+func NewStore(c *pgxpool.Pool, window time.Duration) *store {
+	return &store{
+		c:      c,
+		window: window,
+	}
+}
+
+// Reserve - резервирует ключ key за запросом с хешем hash. Резервирование вышедшего за окно ключа
+// перезаписывается, одновременные запросы с одним ключом резервирует только один из них.
+func (s *store) Reserve(ctx context.Context, key, hash string) (*idempotency.Response, bool, error) {
+	now := time.Now()
+
+	tag, err := s.c.Exec(ctx, "INSERT INTO idempotency_keys (key, request_hash, status, created_at) "+
+		"VALUES($1, $2, 0, $3) ON CONFLICT (key) DO UPDATE SET request_hash = $2, status = 0, header = NULL, "+
+		"body = NULL, created_at = $3 WHERE idempotency_keys.created_at <= $4", key, hash, now, now.Add(-s.window))
+	if err != nil {
+		return nil, false, fmt.Errorf("reserve idempotency key query error:%w", err)
+	}
+
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	r, err := s.get(ctx, key)
+	switch {
+	case errors.Is(err, idempotency.ErrNotFound):
+		// Ключ удален другим запросом между резервированием и чтением.
+		return nil, false, idempotency.ErrInProgress
+	case err != nil:
+		return nil, false, err
+	case r.Pending():
+		return nil, false, idempotency.ErrInProgress
+	default:
+		return r, false, nil
+	}
+}
+
+// Release - снимает резервирование ключа key, если ответ по нему не сохранен.
+func (s *store) Release(ctx context.Context, key string) error {
+	_, err := s.c.Exec(ctx, "DELETE FROM idempotency_keys WHERE key = $1 AND status = 0", key)
+	if err != nil {
+		return fmt.Errorf("release idempotency key query error:%w", err)
+	}
+
+	return nil
+}
+
+func (s *store) get(ctx context.Context, key string) (*idempotency.Response, error) {
+	var (
+		r      idempotency.Response
+		header []byte
+	)
+
+	err := s.c.QueryRow(ctx, "SELECT request_hash, status, header, body, created_at FROM idempotency_keys "+
+		"WHERE key = $1 AND created_at > $2", key, time.Now().Add(-s.window)).
+		Scan(&r.RequestHash, &r.Status, &header, &r.Body, &r.Created)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, idempotency.ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get idempotency key query error:%w", err)
+	}
+
+	if header == nil {
+		return &r, nil
+	}
+
+	err = json.Unmarshal(header, &r.Header)
+	if err != nil {
+		return nil, fmt.Errorf("header json unmarshal error:%w", err)
+	}
+
+	return &r, nil
+}
+
+// Save - сохраняет ответ r по ключу key, попутно удаляя ответы вышедшие за окно.
+func (s *store) Save(ctx context.Context, key string, r *idempotency.Response) error {
+	header, err := json.Marshal(r.Header)
+	if err != nil {
+		return fmt.Errorf("header json marshal error:%w", err)
+	}
+
+	var b pgx.Batch
+
+	b.Queue("DELETE FROM idempotency_keys WHERE created_at < $1", time.Now().Add(-s.window))
+	b.Queue("INSERT INTO idempotency_keys (key, request_hash, status, header, body, created_at) "+
+		"VALUES($1, $2, $3, $4, $5, $6) ON CONFLICT (key) DO UPDATE SET request_hash = $2, status = $3, "+
+		"header = $4, body = $5, created_at = $6", key, r.RequestHash, r.Status, header, r.Body, r.Created)
+
+	err = s.c.SendBatch(ctx, &b).Close()
+	if err != nil {
+		return fmt.Errorf("save idempotency key batch error:%w", err)
+	}
+
+	return nil
+}
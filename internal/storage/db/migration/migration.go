@@ -0,0 +1,351 @@
+// Package migration for versioned migrations of PostgreSQL DB.
+//
+// Миграции лежат в каталоге sql в файлах `<версия>_<имя>.up.sql` и `<версия>_<имя>.down.sql` и встраиваются
+// в бинарный файл. Примененные миграции записываются в таблицу schema_migrations вместе с контрольной суммой
+// up-скрипта. Каждая миграция применяется в отдельной транзакции под advisory lock, поэтому одновременно
+// запущенные серверы не применяют одну миграцию дважды.
+package migration
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+//go:embed sql/*.sql
+var scripts embed.FS
+
+// lockID - ключ advisory lock миграций.
+const lockID int64 = 7_240_001
+
+var (
+	ErrChecksumMismatch = errors.New("migration: checksum of applied migration mismatch")
+	ErrUnknownVersion   = errors.New("migration: database has migration unknown to this build")
+	ErrNoApplied        = errors.New("migration: no applied migrations")
+)
+
+var fileRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Conn - интерфейс подключения к БД, реализуется pgxpool.Pool.
+type Conn interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Migration - миграция схемы БД.
+type Migration struct {
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+	Version  int64
+}
+
+// Status - состояние миграции в БД.
+type Status struct {
+	AppliedAt time.Time
+	Migration
+	Applied bool
+}
+
+type migrator struct {
+	c          Conn
+	migrations []Migration
+}
+
+// New - создание сущности "миграции" со встроенными миграциями, где:
+//   - c - подключение к БД.
+func New(c Conn) (*migrator, error) {
+	m, err := Load(scripts)
+	if err != nil {
+		return nil, fmt.Errorf("load migrations error:%w", err)
+	}
+
+	return NewWith(c, m), nil
+}
+
+// NewWith - создание сущности "миграции" с миграциями m, упорядоченными по версии.
+func NewWith(c Conn, m []Migration) *migrator {
+	return &migrator{
+		c:          c,
+		migrations: m,
+	}
+}
+
+// Load - загрузка миграций из файловой системы fsys (файлы ищутся в каталоге sql), миграции упорядочены
+// по версии. У каждой миграции должны быть up и down скрипты.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("read dir error:%w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, e := range entries {
+		sm := fileRe.FindStringSubmatch(e.Name())
+		if sm == nil {
+			return nil, fmt.Errorf("bad migration file name:%v", e.Name())
+		}
+
+		v, err := strconv.ParseInt(sm[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad migration version(%v) error:%w", e.Name(), err)
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join("sql", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration(%v) error:%w", e.Name(), err)
+		}
+
+		m, ok := byVersion[v]
+		if !ok {
+			m = &Migration{Version: v, Name: sm[2]}
+			byVersion[v] = m
+		}
+
+		if m.Name != sm[2] {
+			return nil, fmt.Errorf("migration version(%v) has different names:%v, %v", v, m.Name, sm[2])
+		}
+
+		if sm[3] == "up" {
+			m.Up = string(b)
+			sum := sha256.Sum256(b)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(b)
+		}
+	}
+
+	res := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration(%v_%v) must have up and down scripts", m.Version, m.Name)
+		}
+		res = append(res, *m)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+
+	return res, nil
+}
+
+// Up - применяет все еще не примененные миграции.
+func (m *migrator) Up(ctx context.Context) error {
+	for {
+		done, err := m.step(ctx, m.up)
+		if err != nil {
+			return err
+		}
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// Down - откатывает последнюю примененную миграцию.
+func (m *migrator) Down(ctx context.Context) error {
+	_, err := m.step(ctx, m.down)
+	return err
+}
+
+// Status - возвращает состояние всех известных миграций.
+func (m *migrator) Status(ctx context.Context) ([]Status, error) {
+	var res []Status
+
+	_, err := m.step(ctx, func(ctx context.Context, tx pgx.Tx, applied map[int64]applied) (bool, error) {
+		res = make([]Status, 0, len(m.migrations))
+		for _, mg := range m.migrations {
+			a, ok := applied[mg.Version]
+			res = append(res, Status{Migration: mg, Applied: ok, AppliedAt: a.at})
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+type applied struct {
+	at       time.Time
+	checksum string
+}
+
+type stepFunc func(ctx context.Context, tx pgx.Tx, applied map[int64]applied) (bool, error)
+
+// step - выполняет fn в транзакции под advisory lock миграций, передавая примененные миграции.
+func (m *migrator) step(ctx context.Context, fn stepFunc) (bool, error) {
+	tx, err := m.c.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("migration transaction begin error:%w", err)
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error().Err(err).Msg("migration transaction rollback error")
+		}
+	}()
+
+	// Блокировка снимается при завершении транзакции.
+	_, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1)", lockID)
+	if err != nil {
+		return false, fmt.Errorf("migration lock error:%w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations(
+                version    bigint       PRIMARY KEY,
+                name       text         NOT NULL,
+                checksum   varchar(64)  NOT NULL,
+                applied_at timestamptz  NOT NULL DEFAULT now()
+        )`)
+	if err != nil {
+		return false, fmt.Errorf("create schema_migrations error:%w", err)
+	}
+
+	a, err := m.applied(ctx, tx)
+	if err != nil {
+		return false, err
+	}
+
+	done, err := fn(ctx, tx, a)
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, fmt.Errorf("migration transaction commit error:%w", err)
+	}
+
+	return done, nil
+}
+
+func (m *migrator) applied(ctx context.Context, tx pgx.Tx) (map[int64]applied, error) {
+	rows, err := tx.Query(ctx, "SELECT version,checksum,applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("select schema_migrations error:%w", err)
+	}
+	defer rows.Close()
+
+	res := make(map[int64]applied)
+
+	for rows.Next() {
+		var (
+			v int64
+			a applied
+		)
+
+		err = rows.Scan(&v, &a.checksum, &a.at)
+		if err != nil {
+			return nil, fmt.Errorf("schema_migrations scan error:%w", err)
+		}
+
+		res[v] = a
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("schema_migrations rows error:%w", err)
+	}
+
+	return res, nil
+}
+
+// verify - проверяет, что все примененные миграции известны и не изменялись после применения.
+func (m *migrator) verify(a map[int64]applied) error {
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, mg := range m.migrations {
+		known[mg.Version] = mg
+	}
+
+	for v, ap := range a {
+		mg, ok := known[v]
+		if !ok {
+			return fmt.Errorf("%w:%v", ErrUnknownVersion, v)
+		}
+
+		if mg.Checksum != ap.checksum {
+			return fmt.Errorf("%w:%v_%v", ErrChecksumMismatch, mg.Version, mg.Name)
+		}
+	}
+
+	return nil
+}
+
+// up - применяет первую не примененную миграцию, возвращает true, если применять нечего.
+func (m *migrator) up(ctx context.Context, tx pgx.Tx, a map[int64]applied) (bool, error) {
+	err := m.verify(a)
+	if err != nil {
+		return false, err
+	}
+
+	for _, mg := range m.migrations {
+		if _, ok := a[mg.Version]; ok {
+			continue
+		}
+
+		_, err = tx.Exec(ctx, mg.Up)
+		if err != nil {
+			return false, fmt.Errorf("migration(%v_%v) up error:%w", mg.Version, mg.Name, err)
+		}
+
+		_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version,name,checksum) VALUES($1, $2, $3)",
+			mg.Version, mg.Name, mg.Checksum)
+		if err != nil {
+			return false, fmt.Errorf("insert schema_migrations(%v) error:%w", mg.Version, err)
+		}
+
+		log.Printf("migration %v_%v applied", mg.Version, mg.Name)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// down - откатывает последнюю примененную миграцию.
+func (m *migrator) down(ctx context.Context, tx pgx.Tx, a map[int64]applied) (bool, error) {
+	err := m.verify(a)
+	if err != nil {
+		return false, err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		mg := m.migrations[i]
+		if _, ok := a[mg.Version]; !ok {
+			continue
+		}
+
+		_, err = tx.Exec(ctx, mg.Down)
+		if err != nil {
+			return false, fmt.Errorf("migration(%v_%v) down error:%w", mg.Version, mg.Name, err)
+		}
+
+		_, err = tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", mg.Version)
+		if err != nil {
+			return false, fmt.Errorf("delete schema_migrations(%v) error:%w", mg.Version, err)
+		}
+
+		log.Printf("migration %v_%v rolled back", mg.Version, mg.Name)
+		return true, nil
+	}
+
+	return false, ErrNoApplied
+}
@@ -0,0 +1,166 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	m, err := Load(scripts)
+	require.NoError(t, err)
+	require.Len(t, m, 3)
+	assert.Equal(t, int64(1), m[0].Version)
+	assert.Equal(t, "create_metrics", m[0].Name)
+	assert.Equal(t, int64(2), m[1].Version)
+	assert.Equal(t, "create_idempotency_keys", m[1].Name)
+	assert.Len(t, m[0].Checksum, 64)
+
+	tests := []struct {
+		fs   fstest.MapFS
+		name string
+	}{
+		{
+			name: "bad file name",
+			fs: fstest.MapFS{
+				"sql/create.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "no down script",
+			fs: fstest.MapFS{
+				"sql/0001_create.up.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "different names of one version",
+			fs: fstest.MapFS{
+				"sql/0001_create.up.sql":   {Data: []byte("SELECT 1")},
+				"sql/0001_drop.down.sql":   {Data: []byte("SELECT 1")},
+				"sql/0001_create.down.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Load(test.fs)
+			assert.Error(t, err)
+		})
+	}
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "one", Up: "CREATE TABLE one", Down: "DROP TABLE one", Checksum: "sum1"},
+	{Version: 2, Name: "two", Up: "CREATE TABLE two", Down: "DROP TABLE two", Checksum: "sum2"},
+}
+
+func expectStep(mock pgxmock.PgxPoolIface, applied ...int64) {
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT pg_advisory_xact_lock").WithArgs(lockID).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(pgxmock.NewResult("CREATE", 0))
+
+	rows := pgxmock.NewRows([]string{"version", "checksum", "applied_at"})
+	for _, v := range applied {
+		rows.AddRow(v, testMigrations[v-1].Checksum, time.Unix(1700000000, 0))
+	}
+	mock.ExpectQuery("SELECT version,checksum,applied_at FROM schema_migrations").WillReturnRows(rows)
+}
+
+func newMock(t *testing.T) pgxmock.PgxPoolIface {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		mock.Close()
+	})
+
+	return mock
+}
+
+func TestUp(t *testing.T) {
+	mock := newMock(t)
+
+	expectStep(mock)
+	mock.ExpectExec("CREATE TABLE one").WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(1), "one", "sum1").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	expectStep(mock, 1)
+	mock.ExpectExec("CREATE TABLE two").WillReturnResult(pgxmock.NewResult("CREATE", 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(int64(2), "two", "sum2").
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	expectStep(mock, 1, 2)
+	mock.ExpectCommit()
+
+	err := NewWith(mock, testMigrations).Up(context.Background())
+	require.NoError(t, err)
+}
+
+func TestUpChecksumMismatch(t *testing.T) {
+	mock := newMock(t)
+
+	expectStep(mock, 1)
+	mock.ExpectRollback()
+
+	changed := []Migration{testMigrations[0], testMigrations[1]}
+	changed[0].Checksum = "changed"
+
+	err := NewWith(mock, changed).Up(context.Background())
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestUpUnknownVersion(t *testing.T) {
+	mock := newMock(t)
+
+	expectStep(mock, 1, 2)
+	mock.ExpectRollback()
+
+	err := NewWith(mock, testMigrations[:1]).Up(context.Background())
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
+
+func TestDown(t *testing.T) {
+	mock := newMock(t)
+
+	expectStep(mock, 1, 2)
+	mock.ExpectExec("DROP TABLE two").WillReturnResult(pgxmock.NewResult("DROP", 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(int64(2)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+
+	expectStep(mock)
+	mock.ExpectRollback()
+
+	m := NewWith(mock, testMigrations)
+
+	require.NoError(t, m.Down(context.Background()))
+	assert.ErrorIs(t, m.Down(context.Background()), ErrNoApplied)
+}
+
+func TestStatus(t *testing.T) {
+	mock := newMock(t)
+
+	expectStep(mock, 1)
+	mock.ExpectCommit()
+
+	s, err := NewWith(mock, testMigrations).Status(context.Background())
+	require.NoError(t, err)
+	require.Len(t, s, 2)
+	assert.True(t, s[0].Applied)
+	assert.Equal(t, time.Unix(1700000000, 0), s[0].AppliedAt)
+	assert.False(t, s[1].Applied)
+	assert.True(t, s[1].AppliedAt.IsZero())
+}
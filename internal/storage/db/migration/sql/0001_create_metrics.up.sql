@@ -0,0 +1,15 @@
+-- IF NOT EXISTS: до появления schema_migrations таблицы создавались при каждом старте сервера,
+-- поэтому в существующих БД миграция только фиксирует текущую схему.
+CREATE TABLE IF NOT EXISTS counters(
+        name  varchar(40)      PRIMARY KEY,
+        delta bigint           NULL
+);
+
+CREATE INDEX IF NOT EXISTS counter_idx ON counters (name);
+
+CREATE TABLE IF NOT EXISTS gauges(
+        name  varchar(40)      PRIMARY KEY,
+        value double precision NULL
+);
+
+CREATE INDEX IF NOT EXISTS gauge_idx ON gauges (name);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+        key          varchar(128)     PRIMARY KEY,
+        request_hash varchar(64)      NOT NULL,
+        status       integer          NOT NULL,
+        header       jsonb            NULL,
+        body         bytea            NULL,
+        created_at   timestamptz      NOT NULL
+);
@@ -0,0 +1,14 @@
+-- История изменений метрик: каждое изменение gauge и каждое приращение counter.
+-- Секции по дням создает и удаляет partition.Manager, секция по умолчанию принимает записи,
+-- для которых дневная секция еще не создана.
+CREATE TABLE metric_samples(
+        type       varchar(16)      NOT NULL,
+        name       varchar(40)      NOT NULL,
+        delta      bigint           NULL,
+        value      double precision NULL,
+        created_at timestamptz      NOT NULL DEFAULT now()
+) PARTITION BY RANGE (created_at);
+
+CREATE TABLE metric_samples_default PARTITION OF metric_samples DEFAULT;
+
+CREATE INDEX metric_samples_name_idx ON metric_samples (type, name, created_at);
@@ -0,0 +1,247 @@
+// Package partition for daily partitions of metric_samples table in PostgreSQL DB.
+package partition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	table            = "metric_samples"
+	defaultPartition = table + "_default"
+	dateLayout       = "20060102"
+	day              = 24 * time.Hour
+
+	// aheadDays - на сколько дней вперед создаются секции, чтобы записи не попадали в секцию по умолчанию.
+	aheadDays = 2
+)
+
+// Conn - интерфейс подключения к БД, реализуется pgxpool.Pool.
+type Conn interface {
+	querier
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// querier - общие для подключения и транзакции запросы.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type manager struct {
+	c             Conn
+	now           func() time.Time
+	retentionDays int
+}
+
+// NewManager - создание менеджера дневных секций таблицы истории метрик, где:
+//   - c - подключение к БД;
+//   - retentionDays - сколько дней хранится история, секции старше удаляются, значение 0 отключает удаление.
+func NewManager(c Conn, retentionDays int) *manager {
+	return &manager{
+		c:             c,
+		now:           time.Now,
+		retentionDays: retentionDays,
+	}
+}
+
+// Run - запуск менеджера: секции обслуживаются раз в interval до отмены ctx. Первый раз Maintain нужно вызвать
+// до начала записи метрик, иначе записи текущего дня попадут в секцию по умолчанию. Такие записи
+// переносятся в дневную секцию при ее создании.
+func (m *manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := m.Maintain(ctx)
+			if err != nil {
+				log.Error().Err(err).Msg("metric_samples partitions maintain error")
+			}
+		case <-ctx.Done():
+			log.Printf("Partition manager closed with cause:%s", ctx.Err())
+			return
+		}
+	}
+}
+
+// Maintain - создает секции на текущий и aheadDays следующих дней и удаляет секции и записи секции
+// по умолчанию старше retentionDays дней.
+func (m *manager) Maintain(ctx context.Context) error {
+	today := m.now().UTC().Truncate(day)
+
+	for i := 0; i <= aheadDays; i++ {
+		from := today.Add(time.Duration(i) * day)
+		err := m.create(ctx, from)
+		if err != nil {
+			return err
+		}
+	}
+
+	if m.retentionDays == 0 {
+		return nil
+	}
+
+	return m.drop(ctx, today.Add(-time.Duration(m.retentionDays)*day))
+}
+
+// create - создает дневную секцию, начинающуюся с from. Секция по умолчанию может уже содержать записи этого дня,
+// тогда CREATE TABLE ... PARTITION OF завершится ошибкой нарушения ограничения секции. Поэтому секция создается
+// отдельной таблицей, записи дня переносятся в нее из секции по умолчанию и она подключается к таблице
+// в одной транзакции. Секция по умолчанию блокируется до конца транзакции, чтобы в нее не попали новые записи дня.
+func (m *manager) create(ctx context.Context, from time.Time) error {
+	name := partitionName(from)
+
+	ok, err := exists(ctx, m.c, name)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return nil
+	}
+
+	tx, err := m.c.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("transaction begin error:%w", err)
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error().Err(err).Msg("create partition transaction rollback error")
+		}
+	}()
+
+	_, err = tx.Exec(ctx, "LOCK TABLE "+defaultPartition+" IN ACCESS EXCLUSIVE MODE")
+	if err != nil {
+		return fmt.Errorf("lock default partition error:%w", err)
+	}
+
+	// Секцию мог создать другой экземпляр сервера, пока ожидалась блокировка.
+	ok, err = exists(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return nil
+	}
+
+	to := from.Add(day)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING DEFAULTS)", name, table))
+	if err != nil {
+		return fmt.Errorf("create partition(%v) error:%w", name, err)
+	}
+
+	tag, err := tx.Exec(ctx, fmt.Sprintf("WITH moved AS (DELETE FROM %s WHERE created_at >= $1 AND created_at < $2 "+
+		"RETURNING *) INSERT INTO %s SELECT * FROM moved", defaultPartition, name), from, to)
+	if err != nil {
+		return fmt.Errorf("move rows to partition(%v) error:%w", name, err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("ALTER TABLE %s ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')",
+		table, name, from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	if err != nil {
+		return fmt.Errorf("attach partition(%v) error:%w", name, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("transaction commit error:%w", err)
+	}
+
+	log.Printf("partition %v created, rows moved from default partition:%v", name, tag.RowsAffected())
+
+	return nil
+}
+
+// exists - проверка наличия таблицы name.
+func exists(ctx context.Context, q querier, name string) (bool, error) {
+	var ok bool
+
+	err := q.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", name).Scan(&ok)
+	if err != nil {
+		return false, fmt.Errorf("check partition(%v) exists error:%w", name, err)
+	}
+
+	return ok, nil
+}
+
+// drop - удаляет дневные секции, все записи которых старше before, и такие же записи секции по умолчанию.
+func (m *manager) drop(ctx context.Context, before time.Time) error {
+	rows, err := m.c.Query(ctx, "SELECT c.relname FROM pg_inherits i "+
+		"JOIN pg_class c ON c.oid = i.inhrelid "+
+		"JOIN pg_class p ON p.oid = i.inhparent "+
+		"WHERE p.relname = $1", table)
+	if err != nil {
+		return fmt.Errorf("list partitions error:%w", err)
+	}
+
+	var expired []string
+
+	for rows.Next() {
+		var name string
+
+		err = rows.Scan(&name)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("partition rows scan error:%w", err)
+		}
+
+		from, ok := partitionDate(name)
+		if ok && !from.Add(day).After(before) {
+			expired = append(expired, name)
+		}
+	}
+
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("partition rows error:%w", err)
+	}
+
+	for _, name := range expired {
+		_, err = m.c.Exec(ctx, "DROP TABLE IF EXISTS "+name)
+		if err != nil {
+			return fmt.Errorf("drop partition(%v) error:%w", name, err)
+		}
+		log.Printf("partition %v dropped", name)
+	}
+
+	_, err = m.c.Exec(ctx, "DELETE FROM "+defaultPartition+" WHERE created_at < $1", before)
+	if err != nil {
+		return fmt.Errorf("delete expired rows from default partition error:%w", err)
+	}
+
+	return nil
+}
+
+func partitionName(from time.Time) string {
+	return table + "_" + from.Format(dateLayout)
+}
+
+// partitionDate - дата начала дневной секции по ее имени, секция по умолчанию дневной не является.
+func partitionDate(name string) (time.Time, bool) {
+	s, ok := strings.CutPrefix(name, table+"_")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
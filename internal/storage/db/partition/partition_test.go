@@ -0,0 +1,172 @@
+package partition
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintain(t *testing.T) {
+	now := time.Date(2024, 3, 10, 14, 0, 0, 0, time.UTC)
+
+	exists := func(mock pgxmock.PgxPoolIface, name string, ok bool) {
+		mock.ExpectQuery(regexp.QuoteMeta("SELECT to_regclass($1) IS NOT NULL")).WithArgs(name).
+			WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(ok))
+	}
+
+	create := func(mock pgxmock.PgxPoolIface, name, from, to string, moved int64) {
+		lower, err := time.Parse(time.RFC3339, from)
+		require.NoError(t, err)
+		upper, err := time.Parse(time.RFC3339, to)
+		require.NoError(t, err)
+
+		exists(mock, name, false)
+		mock.ExpectBegin()
+		mock.ExpectExec("LOCK TABLE metric_samples_default IN ACCESS EXCLUSIVE MODE").
+			WillReturnResult(pgxmock.NewResult("LOCK", 0))
+		exists(mock, name, false)
+		mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE " + name + " (LIKE metric_samples INCLUDING DEFAULTS)")).
+			WillReturnResult(pgxmock.NewResult("CREATE", 0))
+		mock.ExpectExec(regexp.QuoteMeta("WITH moved AS (DELETE FROM metric_samples_default "+
+			"WHERE created_at >= $1 AND created_at < $2 RETURNING *) INSERT INTO "+name+" SELECT * FROM moved")).
+			WithArgs(lower, upper).
+			WillReturnResult(pgxmock.NewResult("INSERT", moved))
+		mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE metric_samples ATTACH PARTITION " + name +
+			" FOR VALUES FROM ('" + from + "') TO ('" + to + "')")).
+			WillReturnResult(pgxmock.NewResult("ALTER", 0))
+		mock.ExpectCommit()
+	}
+
+	tests := []struct {
+		expect        func(mock pgxmock.PgxPoolIface)
+		name          string
+		retentionDays int
+		wantErr       bool
+	}{
+		{
+			name:          "create partitions, retention disabled",
+			retentionDays: 0,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				create(mock, "metric_samples_20240310", "2024-03-10T00:00:00Z", "2024-03-11T00:00:00Z", 0)
+				create(mock, "metric_samples_20240311", "2024-03-11T00:00:00Z", "2024-03-12T00:00:00Z", 0)
+				create(mock, "metric_samples_20240312", "2024-03-12T00:00:00Z", "2024-03-13T00:00:00Z", 0)
+			},
+		},
+		{
+			name:          "existing partitions are not created",
+			retentionDays: 0,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				exists(mock, "metric_samples_20240310", true)
+				exists(mock, "metric_samples_20240311", true)
+				exists(mock, "metric_samples_20240312", true)
+			},
+		},
+		{
+			name:          "move rows of the day from default partition",
+			retentionDays: 0,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				create(mock, "metric_samples_20240310", "2024-03-10T00:00:00Z", "2024-03-11T00:00:00Z", 42)
+				exists(mock, "metric_samples_20240311", true)
+				exists(mock, "metric_samples_20240312", true)
+			},
+		},
+		{
+			name:          "partition created by another server while waiting for lock",
+			retentionDays: 0,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				exists(mock, "metric_samples_20240310", false)
+				mock.ExpectBegin()
+				mock.ExpectExec("LOCK TABLE metric_samples_default").
+					WillReturnResult(pgxmock.NewResult("LOCK", 0))
+				exists(mock, "metric_samples_20240310", true)
+				mock.ExpectRollback()
+				exists(mock, "metric_samples_20240311", true)
+				exists(mock, "metric_samples_20240312", true)
+			},
+		},
+		{
+			name:          "create partitions and drop expired",
+			retentionDays: 7,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				exists(mock, "metric_samples_20240310", true)
+				exists(mock, "metric_samples_20240311", true)
+				create(mock, "metric_samples_20240312", "2024-03-12T00:00:00Z", "2024-03-13T00:00:00Z", 0)
+				mock.ExpectQuery("SELECT c.relname FROM pg_inherits").WithArgs("metric_samples").
+					WillReturnRows(pgxmock.NewRows([]string{"relname"}).
+						AddRow("metric_samples_default").
+						AddRow("metric_samples_20240301").
+						AddRow("metric_samples_20240302").
+						AddRow("metric_samples_20240303").
+						AddRow("metric_samples_20240310"))
+				mock.ExpectExec("DROP TABLE IF EXISTS metric_samples_20240301").
+					WillReturnResult(pgxmock.NewResult("DROP", 0))
+				mock.ExpectExec("DROP TABLE IF EXISTS metric_samples_20240302").
+					WillReturnResult(pgxmock.NewResult("DROP", 0))
+				mock.ExpectExec(regexp.QuoteMeta("DELETE FROM metric_samples_default WHERE created_at < $1")).
+					WithArgs(time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)).
+					WillReturnResult(pgxmock.NewResult("DELETE", 3))
+			},
+		},
+		{
+			name:          "create partition error",
+			retentionDays: 7,
+			wantErr:       true,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				exists(mock, "metric_samples_20240310", false)
+				mock.ExpectBegin()
+				mock.ExpectExec("LOCK TABLE metric_samples_default").
+					WillReturnResult(pgxmock.NewResult("LOCK", 0))
+				exists(mock, "metric_samples_20240310", false)
+				mock.ExpectExec("CREATE TABLE metric_samples_20240310").
+					WillReturnError(errors.New("no such table"))
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:          "attach partition error",
+			retentionDays: 7,
+			wantErr:       true,
+			expect: func(mock pgxmock.PgxPoolIface) {
+				exists(mock, "metric_samples_20240310", false)
+				mock.ExpectBegin()
+				mock.ExpectExec("LOCK TABLE metric_samples_default").
+					WillReturnResult(pgxmock.NewResult("LOCK", 0))
+				exists(mock, "metric_samples_20240310", false)
+				mock.ExpectExec("CREATE TABLE metric_samples_20240310").
+					WillReturnResult(pgxmock.NewResult("CREATE", 0))
+				mock.ExpectExec("WITH moved AS").WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+				mock.ExpectExec("ALTER TABLE metric_samples ATTACH PARTITION metric_samples_20240310").
+					WillReturnError(errors.New("partition constraint is violated"))
+				mock.ExpectRollback()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock, err := pgxmock.NewPool()
+			require.NoError(t, err)
+			defer mock.Close()
+
+			test.expect(mock)
+
+			m := NewManager(mock, test.retentionDays)
+			m.now = func() time.Time { return now }
+
+			err = m.Maintain(context.Background())
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
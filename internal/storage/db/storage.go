@@ -6,21 +6,51 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/k0st1a/metrics/internal/pkg/retry"
 	"github.com/k0st1a/metrics/internal/utils"
 	"github.com/rs/zerolog/log"
 )
 
+// Типы метрик в истории изменений metric_samples.
+const (
+	CounterType = "counter"
+	GaugeType   = "gauge"
+)
+
+var ErrBadType = errors.New("db storage: bad metric type")
+
+// Conn - интерфейс подключения к БД, реализуется pgxpool.Pool.
+type Conn interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
 type DBStorage struct {
-	c *pgxpool.Pool
+	c Conn
 	m sync.Mutex
 }
 
+// Sample - запись истории изменения метрики.
+type Sample struct {
+	Time time.Time
+	// Delta - приращение метрики типа counter.
+	Delta int64
+	// Value - значение метрики типа gauge.
+	Value float64
+}
+
 // NewStorage - создать storage для хранения метрик в БД, где:
 //   - c - пулл коннекций до БД.
-func NewStorage(c *pgxpool.Pool) *DBStorage {
+//
+// Каждое изменение метрики, кроме текущего значения, записывается в историю metric_samples.
+func NewStorage(c Conn) *DBStorage {
 	return &DBStorage{
 		c: c,
 	}
@@ -28,12 +58,9 @@ func NewStorage(c *pgxpool.Pool) *DBStorage {
 
 // StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
 func (s *DBStorage) StoreGauge(ctx context.Context, name string, value float64) error {
-	s.m.Lock()
-	defer s.m.Unlock()
-
-	_, err := s.c.Exec(ctx, "INSERT INTO gauges (name,value) VALUES($1, $2)", name, value)
+	err := s.store(ctx, nil, map[string]float64{name: value})
 	if err != nil {
-		return fmt.Errorf("store gauge query error:%w", err)
+		return fmt.Errorf("store gauge error:%w", err)
 	}
 
 	return nil
@@ -57,13 +84,9 @@ func (s *DBStorage) GetGauge(ctx context.Context, name string) (*float64, error)
 
 // StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
 func (s *DBStorage) StoreCounter(ctx context.Context, name string, value int64) error {
-	s.m.Lock()
-	defer s.m.Unlock()
-
-	_, err := s.c.Exec(ctx, "INSERT INTO counters (name,delta) VALUES($1, $2)"+
-		"ON CONFLICT (name) DO UPDATE SET delta = counters.delta + $2", name, value)
+	err := s.store(ctx, map[string]int64{name: value}, nil)
 	if err != nil {
-		return fmt.Errorf("store counter query error:%w", err)
+		return fmt.Errorf("store counter error:%w", err)
 	}
 
 	return nil
@@ -85,40 +108,133 @@ func (s *DBStorage) GetCounter(ctx context.Context, name string) (*int64, error)
 	return &d, nil
 }
 
-// StoreAll - сохраняет группу метрик типа counter и gauge.
+// DeleteGauge - удаляет метрику типа gauge с именем name, история ее изменений сохраняется.
+func (s *DBStorage) DeleteGauge(ctx context.Context, name string) error {
+	err := s.c.QueryRow(ctx, "DELETE FROM gauges WHERE name = $1 RETURNING name", name).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return utils.ErrMetricsNoGauge
+	}
+	if err != nil {
+		return fmt.Errorf("delete gauge query error:%w", err)
+	}
+
+	return nil
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name, история ее изменений сохраняется.
+func (s *DBStorage) DeleteCounter(ctx context.Context, name string) error {
+	err := s.c.QueryRow(ctx, "DELETE FROM counters WHERE name = $1 RETURNING name", name).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return utils.ErrMetricsNoCounter
+	}
+	if err != nil {
+		return fmt.Errorf("delete counter query error:%w", err)
+	}
+
+	return nil
+}
+
+// StoreAll - сохраняет группу метрик типа counter и gauge в одной транзакции: либо сохраняются все метрики,
+// либо ни одна.
 func (s *DBStorage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	log.Printf("StoreAll, counter:%v gauge:%v", counter, gauge)
+
+	err := s.store(ctx, counter, gauge)
+	if err != nil {
+		return fmt.Errorf("store all error:%w", err)
+	}
+
+	return nil
+}
+
+// store - сохраняет текущие значения метрик и записи истории их изменений в одной транзакции.
+func (s *DBStorage) store(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
 	s.m.Lock()
 	defer s.m.Unlock()
 
-	var b pgx.Batch
+	tx, err := s.c.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("transaction begin error:%w", err)
+	}
+	defer func() {
+		err := tx.Rollback(ctx)
+		if err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			log.Error().Err(err).Msg("store transaction rollback error")
+		}
+	}()
 
-	log.Printf("StoreAll, counter:%v gauge:%v", counter, gauge)
+	var b pgx.Batch
 
 	for k, v := range counter {
 		b.Queue("INSERT INTO counters (name,delta) VALUES($1, $2)"+
 			"ON CONFLICT (name) DO UPDATE SET delta = counters.delta + $2", k, v)
+		b.Queue("INSERT INTO metric_samples (type,name,delta) VALUES($1, $2, $3)", CounterType, k, v)
 	}
 
 	for k2, v2 := range gauge {
 		b.Queue("INSERT INTO gauges (name,value) VALUES($1, $2) ON CONFLICT (name) DO UPDATE SET value = $2", k2, v2)
+		b.Queue("INSERT INTO metric_samples (type,name,value) VALUES($1, $2, $3)", GaugeType, k2, v2)
 	}
 
-	br := s.c.SendBatch(ctx, &b)
-	defer func() {
-		err := br.Close()
+	// Close дочитывает результаты всех запросов пакета и возвращает первую ошибку.
+	err = tx.SendBatch(ctx, &b).Close()
+	if err != nil {
+		return fmt.Errorf("batch error:%w", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("transaction commit error:%w", err)
+	}
+
+	return nil
+}
+
+// GetRange - возвращает историю изменений метрики типа mtype (CounterType или GaugeType) с именем name
+// за период [from, to) в порядке времени записи.
+func (s *DBStorage) GetRange(ctx context.Context, mtype, name string, from, to time.Time) ([]Sample, error) {
+	if mtype != CounterType && mtype != GaugeType {
+		return nil, fmt.Errorf("%w:%v", ErrBadType, mtype)
+	}
+
+	rows, err := s.c.Query(ctx, "SELECT created_at,delta,value FROM metric_samples "+
+		"WHERE type = $1 AND name = $2 AND created_at >= $3 AND created_at < $4 ORDER BY created_at",
+		mtype, name, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get range query error:%w", err)
+	}
+	defer rows.Close()
+
+	var res []Sample
+
+	for rows.Next() {
+		var (
+			sm    Sample
+			delta *int64
+			value *float64
+		)
+
+		err = rows.Scan(&sm.Time, &delta, &value)
 		if err != nil {
-			log.Error().Err(err).Msg("store all br close error")
+			return nil, fmt.Errorf("sample rows scan error:%w", err)
 		}
-	}()
 
-	for i := 1; i < len(counter)+len(gauge); i++ {
-		_, err := br.Exec()
-		if err != nil {
-			return fmt.Errorf("br.exec error:%w", err)
+		if delta != nil {
+			sm.Delta = *delta
 		}
+		if value != nil {
+			sm.Value = *value
+		}
+
+		res = append(res, sm)
 	}
 
-	return nil
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("sample rows error:%w", err)
+	}
+
+	return res, nil
 }
 
 // GetAll - возвращает все метрики типа counter и gauge.
@@ -188,3 +304,16 @@ func (s *DBStorage) GetAll(ctx context.Context) (map[string]int64, map[string]fl
 
 	return c, g, nil
 }
+
+// IsRetryable - классификатор ошибок хранилища для повторных обращений: ошибки соединения с БД,
+// конфликты сериализации и взаимоблокировки транзакций, временные ошибки сети.
+func IsRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgerrcode.IsConnectionException(pgErr.Code) ||
+			pgErr.Code == pgerrcode.SerializationFailure ||
+			pgErr.Code == pgerrcode.DeadlockDetected
+	}
+
+	return retry.IsTemporary(err)
+}
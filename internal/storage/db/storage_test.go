@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMock(t *testing.T) pgxmock.PgxPoolIface {
+	t.Helper()
+
+	mock, err := pgxmock.NewPool()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		mock.Close()
+	})
+
+	return mock
+}
+
+func TestGetRange(t *testing.T) {
+	from := time.Date(2024, 3, 9, 14, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	delta := func(v int64) *int64 { return &v }
+	value := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		rows  *pgxmock.Rows
+		name  string
+		mtype string
+		want  []Sample
+	}{
+		{
+			name:  "gauge history",
+			mtype: GaugeType,
+			rows: pgxmock.NewRows([]string{"created_at", "delta", "value"}).
+				AddRow(from.Add(time.Minute), (*int64)(nil), value(1.5)).
+				AddRow(from.Add(2*time.Minute), (*int64)(nil), value(2.5)),
+			want: []Sample{
+				{Time: from.Add(time.Minute), Value: 1.5},
+				{Time: from.Add(2 * time.Minute), Value: 2.5},
+			},
+		},
+		{
+			name:  "counter history",
+			mtype: CounterType,
+			rows: pgxmock.NewRows([]string{"created_at", "delta", "value"}).
+				AddRow(from.Add(time.Minute), delta(3), (*float64)(nil)),
+			want: []Sample{
+				{Time: from.Add(time.Minute), Delta: 3},
+			},
+		},
+		{
+			name:  "empty history",
+			mtype: GaugeType,
+			rows:  pgxmock.NewRows([]string{"created_at", "delta", "value"}),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mock := newMock(t)
+			mock.ExpectQuery("SELECT created_at,delta,value FROM metric_samples").
+				WithArgs(test.mtype, "HeapAlloc", from, to).
+				WillReturnRows(test.rows)
+
+			got, err := NewStorage(mock).GetRange(context.Background(), test.mtype, "HeapAlloc", from, to)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestGetRangeBadType(t *testing.T) {
+	mock := newMock(t)
+
+	_, err := NewStorage(mock).GetRange(context.Background(), "histogram", "HeapAlloc", time.Now(), time.Now())
+	assert.ErrorIs(t, err, ErrBadType)
+}
+
+func TestGetNoMetric(t *testing.T) {
+	mock := newMock(t)
+	mock.ExpectQuery("SELECT delta FROM counters").WithArgs("PollCount").WillReturnError(pgx.ErrNoRows)
+	mock.ExpectQuery("SELECT value FROM gauges").WithArgs("Alloc").WillReturnError(pgx.ErrNoRows)
+
+	s := NewStorage(mock)
+
+	_, err := s.GetCounter(context.Background(), "PollCount")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoCounter)
+
+	_, err = s.GetGauge(context.Background(), "Alloc")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoGauge)
+}
+
+func TestDelete(t *testing.T) {
+	mock := newMock(t)
+	mock.ExpectQuery("DELETE FROM counters").WithArgs("PollCount").
+		WillReturnRows(pgxmock.NewRows([]string{"name"}).AddRow("PollCount"))
+	mock.ExpectQuery("DELETE FROM gauges").WithArgs("Alloc").WillReturnError(pgx.ErrNoRows)
+
+	s := NewStorage(mock)
+
+	assert.NoError(t, s.DeleteCounter(context.Background(), "PollCount"))
+	assert.ErrorIs(t, s.DeleteGauge(context.Background(), "Alloc"), utils.ErrMetricsNoGauge)
+}
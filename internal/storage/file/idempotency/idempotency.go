@@ -0,0 +1,125 @@
+// Package idempotency for save responses of idempotent requests to file system.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	fileio "github.com/k0st1a/metrics/internal/storage/file/io"
+	"github.com/rs/zerolog/log"
+)
+
+type store struct {
+	memory *idempotency.Memory
+	path   string
+	mutex  sync.Mutex
+}
+
+// NewStore - создание хранилища ответов на файловой системе, где:
+//   - path - полное имя файла, куда сохраняются ответы;
+//   - window - время, в течении которого ответ на запрос хранится.
+func NewStore(path string, window time.Duration) *store {
+	m := idempotency.NewMemory(window)
+
+	r, err := read(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		log.Error().Err(err).Msg("read idempotency responses error")
+	default:
+		m.Restore(r)
+	}
+
+	return &store{
+		memory: m,
+		path:   path,
+	}
+}
+
+// Reserve - резервирует ключ key за запросом с хешем hash и записывает все ответы окна на файловую систему,
+// чтобы резервирование пережило перезапуск сервера.
+func (s *store) Reserve(ctx context.Context, key, hash string) (*idempotency.Response, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r, reserved, err := s.memory.Reserve(ctx, key, hash)
+	if err != nil {
+		return nil, false, fmt.Errorf("memory reserve error:%w", err)
+	}
+
+	if !reserved {
+		return r, false, nil
+	}
+
+	err = s.write()
+	if err != nil {
+		// Незаписанное резервирование снимается, иначе повторы запроса получали бы 409 до конца окна.
+		_ = s.memory.Release(ctx, key)
+		return nil, false, err
+	}
+
+	return nil, true, nil
+}
+
+// Release - снимает резервирование ключа key и записывает все ответы окна на файловую систему.
+func (s *store) Release(ctx context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.memory.Release(ctx, key)
+	if err != nil {
+		return fmt.Errorf("memory release error:%w", err)
+	}
+
+	return s.write()
+}
+
+// Save - сохраняет ответ r по ключу key и записывает все ответы окна на файловую систему.
+func (s *store) Save(ctx context.Context, key string, r *idempotency.Response) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.memory.Save(ctx, key, r)
+	if err != nil {
+		return fmt.Errorf("memory save error:%w", err)
+	}
+
+	return s.write()
+}
+
+func (s *store) write() error {
+	b, err := json.Marshal(s.memory.Snapshot())
+	if err != nil {
+		return fmt.Errorf("json marshal error:%w", err)
+	}
+
+	err = fileio.WriteFile(s.path, b)
+	if err != nil {
+		return fmt.Errorf("write file error:%w", err)
+	}
+
+	return nil
+}
+
+func read(path string) (map[string]*idempotency.Response, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile error:%w", err)
+	}
+
+	r := make(map[string]*idempotency.Response)
+
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		return nil, fmt.Errorf("json unmarshal error:%w", err)
+	}
+
+	return r, nil
+}
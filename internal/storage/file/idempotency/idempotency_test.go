@@ -0,0 +1,67 @@
+package idempotency
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+
+	s := NewStore(path, time.Minute)
+
+	err := s.Save(ctx, "key1", &idempotency.Response{
+		Created:     time.Now(),
+		RequestHash: "hash1",
+		Body:        []byte("body1"),
+		Status:      200,
+	})
+	require.NoError(t, err)
+
+	s2 := NewStore(path, time.Minute)
+
+	r, reserved, err := s2.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, "hash1", r.RequestHash)
+	assert.Equal(t, []byte("body1"), r.Body)
+	assert.Equal(t, 200, r.Status)
+
+	_, reserved, err = s2.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
+
+func TestStoreReserveRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+
+	s := NewStore(path, time.Minute)
+
+	_, reserved, err := s.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	_, reserved, err = s.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	require.NoError(t, s.Release(ctx, "key2"))
+
+	// Резервирование переживает перезапуск сервера, прерванный запрос не обрабатывается повторно.
+	s2 := NewStore(path, time.Minute)
+
+	_, _, err = s2.Reserve(ctx, "key1", "hash1")
+	assert.ErrorIs(t, err, idempotency.ErrInProgress)
+
+	_, reserved, err = s2.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
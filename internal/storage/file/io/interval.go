@@ -32,13 +32,21 @@ func NewIntervalWriter(w Writer, s StorageGeter) *intervalWriter {
 func (w *intervalWriter) Run(ctx context.Context, interval int) {
 	log.Debug().Msg("Run interval writer")
 	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
 
-	for range ticker.C {
-		log.Debug().Msg("Tick of interval writer")
-		err := w.writer.Write(ctx, w.storage)
-		if err != nil {
-			log.Error().Err(err).Msg("write error storage to file")
+	for {
+		select {
+		case <-ticker.C:
+			log.Debug().Msg("Tick of interval writer")
+			err := w.writer.Write(ctx, w.storage)
+			if err != nil {
+				log.Error().Err(err).Msg("write error storage to file")
+			}
+			log.Debug().Msg("Storage writed to file")
+		case <-ctx.Done():
+			// Последнюю запись при завершении работы делает FileStorage.Flush.
+			log.Printf("Interval writer closed with cause:%s\n", ctx.Err())
+			return
 		}
-		log.Debug().Msg("Storage writed to file")
 	}
 }
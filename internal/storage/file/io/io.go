@@ -2,8 +2,13 @@ package io
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/k0st1a/metrics/internal/storage/file/model"
 	"github.com/rs/zerolog/log"
@@ -14,63 +19,224 @@ type StorageGeter interface {
 	GetAll(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
 }
 
+// SeqGeter - интерфейс получения номера последней записи журнала (WAL), учтенной в метриках.
+type SeqGeter interface {
+	Seq() uint64
+}
+
 // Writer - интерфейс зафиси на файловую систему.
 type Writer interface {
 	Write(context.Context, StorageGeter) error
+	// LastWrite - время последней успешной записи и ошибка последней попытки записи.
+	LastWrite() (time.Time, error)
+}
+
+// Observer - интерфейс учета длительности и размера записей на файловую систему.
+type Observer interface {
+	ObserveDuration(name string, d time.Duration)
+	SetGauge(name string, value float64)
 }
 
 type file struct {
-	path string
+	lastWrite  time.Time
+	lastErr    error
+	observer   Observer
+	path       string
+	mutex      sync.Mutex
+	writeMutex sync.Mutex
 }
 
 // NewWriter - писать на файловую систему, где:
-//   - p - полное имя файла, куда сохраняются текущие значения метрик.
-func NewWriter(p string) Writer {
-	return &file{path: p}
+//   - p - полное имя файла, куда сохраняются текущие значения метрик;
+//   - o - учет длительности и размера записей, может быть nil.
+func NewWriter(p string, o Observer) Writer {
+	return &file{path: p, observer: o}
 }
 
 const FileMode = 0600
 
+// PrevSuffix - суффикс файла с предыдущей удачной записью метрик.
+const PrevSuffix = ".prev"
+
 // Write - запись метрик на файловую систему, где:
 //   - ctx - контекст отмены записи;
 //   - s - интерфейс получения метрик.
+//
+// Запись атомарна: метрики пишутся во временный файл, который после fsync переименовывается в path,
+// предыдущая версия файла остается доступной по пути path+PrevSuffix.
 func (f *file) Write(ctx context.Context, s StorageGeter) error {
+	f.writeMutex.Lock()
+	defer f.writeMutex.Unlock()
+
+	err := f.write(ctx, s)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.lastErr = err
+	if err == nil {
+		f.lastWrite = time.Now()
+	}
+
+	return err
+}
+
+// LastWrite - время последней успешной записи и ошибка последней попытки записи.
+func (f *file) LastWrite() (time.Time, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.lastWrite, f.lastErr
+}
+
+func (f *file) write(ctx context.Context, s StorageGeter) error {
 	log.Printf("Write storage to file:%v", f.path)
 
+	start := time.Now()
+
 	c, g, err := s.GetAll(ctx)
 	if err != nil {
 		return fmt.Errorf("get all error:%w", err)
 	}
 
-	p, err := model.Serialize(c, g)
+	var seq uint64
+	if sg, ok := s.(SeqGeter); ok {
+		seq = sg.Seq()
+	}
+
+	p, err := model.SerializeSeq(c, g, seq)
 	if err != nil {
-		return fmt.Errorf("model.Serialize error:%w", err)
+		return fmt.Errorf("model.SerializeSeq error:%w", err)
 	}
 
-	err = os.WriteFile(f.path, p, FileMode)
+	err = WriteFile(f.path, p)
 	if err != nil {
-		return fmt.Errorf("os.WriteFile error:%w", err)
+		return fmt.Errorf("write file error:%w", err)
+	}
+
+	if f.observer != nil {
+		f.observer.ObserveDuration("file_flush_duration_seconds", time.Since(start))
+		f.observer.SetGauge("file_flush_size_bytes", float64(len(p)))
 	}
 
 	log.Printf("Storage writed to file:%v", f.path)
 	return nil
 }
 
+// WriteFile - атомарная запись данных data в файл path через временный файл, fsync и переименование.
+// Предыдущая версия файла сохраняется по пути path+PrevSuffix.
+func WriteFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file error:%w", err)
+	}
+	defer func() {
+		// После успешного переименования временного файла уже нет.
+		err := os.Remove(tmp.Name())
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Error().Err(err).Msg("remove temp file error")
+		}
+	}()
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write temp file error:%w", err)
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("sync temp file error:%w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("close temp file error:%w", err)
+	}
+
+	prev := path + PrevSuffix
+
+	err = os.Remove(prev)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove prev file error:%w", err)
+	}
+
+	err = os.Link(path, prev)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("link prev file error:%w", err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("rename temp file error:%w", err)
+	}
+
+	return syncDir(dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open dir error:%w", err)
+	}
+
+	err = d.Sync()
+	if err != nil {
+		_ = d.Close()
+		return fmt.Errorf("sync dir error:%w", err)
+	}
+
+	err = d.Close()
+	if err != nil {
+		return fmt.Errorf("close dir error:%w", err)
+	}
+
+	return nil
+}
+
 // Read - чтение метрик из файловой системы, где:
 //   - path - полное имя файла, куда ранее были сохранены метрики.
+//
+// Если файл path поврежден или отсутствует, то метрики читаются из предыдущей удачной записи path+PrevSuffix.
 func Read(path string) (map[string]int64, map[string]float64, error) {
+	c, g, _, err := ReadSeq(path)
+	return c, g, err
+}
+
+// ReadSeq - чтение метрик и номера последней записи журнала (WAL), учтенной в метриках, из файловой системы.
+// Поведение аналогично Read.
+func ReadSeq(path string) (map[string]int64, map[string]float64, uint64, error) {
+	c, g, seq, err := read(path)
+	if err == nil {
+		return c, g, seq, nil
+	}
+
+	log.Error().Err(err).Msgf("Read storage from file:%v error, try previous snapshot", path)
+
+	c, g, seq, prevErr := read(path + PrevSuffix)
+	if prevErr != nil {
+		return nil, nil, 0, errors.Join(err, prevErr)
+	}
+
+	return c, g, seq, nil
+}
+
+func read(path string) (map[string]int64, map[string]float64, uint64, error) {
 	log.Printf("Read storage from file:%v", path)
 
 	p, err := os.ReadFile(path)
 	if err != nil {
-		return nil, nil, fmt.Errorf("os.ReadFile error:%w", err)
+		return nil, nil, 0, fmt.Errorf("os.ReadFile error:%w", err)
 	}
 
-	c, g, err := model.Deserialize(p)
+	c, g, seq, err := model.DeserializeSeq(p)
 	if err != nil {
-		return nil, nil, fmt.Errorf("model.Deserialize error:%w", err)
+		return nil, nil, 0, fmt.Errorf("model.DeserializeSeq error:%w", err)
 	}
 
 	log.Printf("Storage readed from file:%v", path)
-	return c, g, nil
+	return c, g, seq, nil
 }
@@ -0,0 +1,76 @@
+package io
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStorage struct {
+	counter map[string]int64
+	gauge   map[string]float64
+}
+
+func (s *testStorage) GetAll(_ context.Context) (map[string]int64, map[string]float64, error) {
+	return s.counter, s.gauge, nil
+}
+
+func TestWriteRead(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	w := NewWriter(path, nil)
+
+	s1 := &testStorage{counter: map[string]int64{"PollCount": 1}, gauge: map[string]float64{"Alloc": 1.5}}
+	err := w.Write(ctx, s1)
+	require.NoError(t, err)
+
+	c, g, err := Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, s1.counter, c)
+	assert.Equal(t, s1.gauge, g)
+
+	s2 := &testStorage{counter: map[string]int64{"PollCount": 2}, gauge: map[string]float64{"Alloc": 2.5}}
+	err = w.Write(ctx, s2)
+	require.NoError(t, err)
+
+	last, err := w.LastWrite()
+	assert.NoError(t, err)
+	assert.False(t, last.IsZero())
+
+	// Имитируем повреждение файла при сбое, метрики читаются из предыдущей удачной записи.
+	err = os.WriteFile(path, []byte(`{"checksum":"bad","list":[`), FileMode)
+	require.NoError(t, err)
+
+	c, g, err = Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, s1.counter, c)
+	assert.Equal(t, s1.gauge, g)
+
+	err = os.Remove(path + PrevSuffix)
+	require.NoError(t, err)
+
+	_, _, err = Read(path)
+	assert.Error(t, err)
+
+	matches, err := filepath.Glob(path + ".tmp-*")
+	require.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestIntervalWriterStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	iw := NewIntervalWriter(NewWriter(filepath.Join(t.TempDir(), "metrics.json"), nil), &testStorage{})
+
+	done := make(chan struct{})
+	go func() {
+		iw.Run(ctx, 1)
+		close(done)
+	}()
+
+	cancel()
+	<-done
+}
@@ -2,12 +2,18 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/mailru/easyjson"
 	"github.com/rs/zerolog/log"
 )
 
+var ErrChecksum = errors.New("model: checksum mismatch")
+
 // Metric - описание метрики для сохранения на файловую систему.
 //
 //go:generate easyjson -all model.go
@@ -20,15 +26,35 @@ type Metric struct {
 
 // Metrics - список метрик для сохранения на файловую систему.
 type Metrics struct {
-	List []Metric `json:"list"`
+	Checksum string   `json:"checksum,omitempty"` // sha256 от seq и списка метрик, сериализованных без checksum
+	List     []Metric `json:"list"`
+	Seq      uint64   `json:"seq,omitempty"` // номер последней записи журнала (WAL), учтенной в метриках
 }
 
-// Deserialize - преобразование байт в метрики типа counter и gauge.
+// Deserialize - преобразование байт в метрики типа counter и gauge. Если в данных есть контрольная сумма, то
+// она проверяется, данные без контрольной суммы (сохраненные ранее) принимаются как есть.
 func Deserialize(b []byte) (map[string]int64, map[string]float64, error) {
+	c, g, _, err := DeserializeSeq(b)
+	return c, g, err
+}
+
+// DeserializeSeq - преобразование байт в метрики типа counter и gauge и номер записи журнала (WAL).
+func DeserializeSeq(b []byte) (map[string]int64, map[string]float64, uint64, error) {
 	m := Metrics{}
 	err := easyjson.Unmarshal(b, &m)
 	if err != nil {
-		return nil, nil, fmt.Errorf("easyjson.Unmarshal error:%w", err)
+		return nil, nil, 0, fmt.Errorf("easyjson.Unmarshal error:%w", err)
+	}
+
+	if m.Checksum != "" {
+		sum, err := checksum(m.List, m.Seq)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("checksum error:%w", err)
+		}
+
+		if sum != m.Checksum {
+			return nil, nil, 0, ErrChecksum
+		}
 	}
 
 	c := make(map[string]int64)
@@ -53,11 +79,16 @@ func Deserialize(b []byte) (map[string]int64, map[string]float64, error) {
 		}
 	}
 
-	return c, g, nil
+	return c, g, m.Seq, nil
 }
 
 // Serialize - преобразование метрик типа counter и gauge в байты.
 func Serialize(c map[string]int64, g map[string]float64) ([]byte, error) {
+	return SerializeSeq(c, g, 0)
+}
+
+// SerializeSeq - преобразование метрик типа counter и gauge и номера записи журнала (WAL) в байты.
+func SerializeSeq(c map[string]int64, g map[string]float64, seq uint64) ([]byte, error) {
 	m := []Metric{}
 
 	for k, v := range c {
@@ -70,10 +101,33 @@ func Serialize(c map[string]int64, g map[string]float64) ([]byte, error) {
 		m = append(m, Metric{Name: k, MType: "gauge", Value: &v3})
 	}
 
-	b, err := easyjson.Marshal(&Metrics{List: m})
+	sort.Slice(m, func(i, j int) bool {
+		if m[i].MType != m[j].MType {
+			return m[i].MType < m[j].MType
+		}
+		return m[i].Name < m[j].Name
+	})
+
+	sum, err := checksum(m, seq)
+	if err != nil {
+		return nil, fmt.Errorf("checksum error:%w", err)
+	}
+
+	b, err := easyjson.Marshal(&Metrics{Checksum: sum, List: m, Seq: seq})
 	if err != nil {
 		return nil, fmt.Errorf("easyjson.Marshal error:%w", err)
 	}
 
 	return b, nil
 }
+
+func checksum(m []Metric, seq uint64) (string, error) {
+	b, err := easyjson.Marshal(&Metrics{List: m, Seq: seq})
+	if err != nil {
+		return "", fmt.Errorf("easyjson.Marshal error:%w", err)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return hex.EncodeToString(sum[:]), nil
+}
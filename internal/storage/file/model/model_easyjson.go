@@ -36,6 +36,8 @@ func easyjsonC80ae7adDecodeGithubComK0st1aMetricsInternalStorageFileModel(in *jl
 			continue
 		}
 		switch key {
+		case "checksum":
+			out.Checksum = string(in.String())
 		case "list":
 			if in.IsNull() {
 				in.Skip()
@@ -59,6 +61,8 @@ func easyjsonC80ae7adDecodeGithubComK0st1aMetricsInternalStorageFileModel(in *jl
 				}
 				in.Delim(']')
 			}
+		case "seq":
+			out.Seq = uint64(in.Uint64())
 		default:
 			in.SkipRecursive()
 		}
@@ -73,9 +77,20 @@ func easyjsonC80ae7adEncodeGithubComK0st1aMetricsInternalStorageFileModel(out *j
 	out.RawByte('{')
 	first := true
 	_ = first
+	if in.Checksum != "" {
+		const prefix string = ",\"checksum\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.String(string(in.Checksum))
+	}
 	{
 		const prefix string = ",\"list\":"
-		out.RawString(prefix[1:])
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
 		if in.List == nil && (out.Flags&jwriter.NilSliceAsEmpty) == 0 {
 			out.RawString("null")
 		} else {
@@ -89,6 +104,11 @@ func easyjsonC80ae7adEncodeGithubComK0st1aMetricsInternalStorageFileModel(out *j
 			out.RawByte(']')
 		}
 	}
+	if in.Seq != 0 {
+		const prefix string = ",\"seq\":"
+		out.RawString(prefix)
+		out.Uint64(uint64(in.Seq))
+	}
 	out.RawByte('}')
 }
 
@@ -134,10 +154,6 @@ func easyjsonC80ae7adDecodeGithubComK0st1aMetricsInternalStorageFileModel1(in *j
 			continue
 		}
 		switch key {
-		case "id":
-			out.Name = string(in.String())
-		case "type":
-			out.MType = string(in.String())
 		case "delta":
 			if in.IsNull() {
 				in.Skip()
@@ -158,6 +174,10 @@ func easyjsonC80ae7adDecodeGithubComK0st1aMetricsInternalStorageFileModel1(in *j
 				}
 				*out.Value = float64(in.Float64())
 			}
+		case "id":
+			out.Name = string(in.String())
+		case "type":
+			out.MType = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -172,9 +192,30 @@ func easyjsonC80ae7adEncodeGithubComK0st1aMetricsInternalStorageFileModel1(out *
 	out.RawByte('{')
 	first := true
 	_ = first
+	if in.Delta != nil {
+		const prefix string = ",\"delta\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.Int64(int64(*in.Delta))
+	}
+	if in.Value != nil {
+		const prefix string = ",\"value\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.Float64(float64(*in.Value))
+	}
 	{
 		const prefix string = ",\"id\":"
-		out.RawString(prefix[1:])
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
 		out.String(string(in.Name))
 	}
 	{
@@ -182,16 +223,6 @@ func easyjsonC80ae7adEncodeGithubComK0st1aMetricsInternalStorageFileModel1(out *
 		out.RawString(prefix)
 		out.String(string(in.MType))
 	}
-	if in.Delta != nil {
-		const prefix string = ",\"delta\":"
-		out.RawString(prefix)
-		out.Int64(int64(*in.Delta))
-	}
-	if in.Value != nil {
-		const prefix string = ",\"value\":"
-		out.RawString(prefix)
-		out.Float64(float64(*in.Value))
-	}
 	out.RawByte('}')
 }
 
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerializeDeserialize(t *testing.T) {
+	c := map[string]int64{"PollCount": 5}
+	g := map[string]float64{"Alloc": 123.5, "RandomValue": 0.1}
+
+	b, err := Serialize(c, g)
+	require.NoError(t, err)
+
+	c2, g2, err := Deserialize(b)
+	require.NoError(t, err)
+	assert.Equal(t, c, c2)
+	assert.Equal(t, g, g2)
+
+	_, _, err = Deserialize(bytes.Replace(b, []byte("123.5"), []byte("124.5"), 1))
+	assert.ErrorIs(t, err, ErrChecksum)
+
+	c3, g3, err := Deserialize([]byte(`{"list":[{"delta":1,"id":"PollCount","type":"counter"}]}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 1}, c3)
+	assert.Empty(t, g3)
+}
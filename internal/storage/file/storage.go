@@ -3,10 +3,17 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/k0st1a/metrics/internal/pkg/retry"
 	"github.com/k0st1a/metrics/internal/storage/file/io"
+	"github.com/k0st1a/metrics/internal/storage/file/wal"
 	"github.com/k0st1a/metrics/internal/storage/inmemory"
 	"github.com/rs/zerolog/log"
 )
@@ -18,14 +25,33 @@ type Storage interface {
 	GetCounter(ctx context.Context, name string) (*int64, error)
 	StoreCounter(ctx context.Context, name string, value int64) error
 
+	DeleteGauge(ctx context.Context, name string) error
+	DeleteCounter(ctx context.Context, name string) error
+
 	StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
 	GetAll(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
 }
 
+var ErrFlushStale = errors.New("file storage: last successful flush is too old")
+
+// WALSuffix - суффикс файла журнала (WAL) записей метрик.
+const WALSuffix = ".wal"
+
+// CompactSize - размер журнала в байтах, при превышении которого записывается снимок метрик и журнал очищается.
+const CompactSize = 4 << 20
+
 type FileStorage struct {
-	storage Storage
-	writer  io.Writer
-	mutex   sync.Mutex
+	created  time.Time
+	storage  Storage
+	writer   io.Writer
+	file     io.Writer
+	wal      *wal.Log
+	path     string
+	interval int
+	// ctx - контекст сервера, stop - остановка периодической записи метрик.
+	ctx   context.Context
+	stop  context.CancelFunc
+	mutex sync.Mutex
 }
 
 // NewStorage - создать storage для хранения метрик на файловой системе, где:
@@ -33,19 +59,28 @@ type FileStorage struct {
 //   - path - путь на файловой системе до файла, куда будут сохраняться метрики;
 //   - interval - интервал в секундах, через который по пути path будут сохраняться все метрики;
 //   - restore - при запуске загружать метрики из файла по пути path?
-func NewStorage(ctx context.Context, path string, interval int, restore bool) Storage {
+//   - walEnabled - писать каждое изменение метрик в журнал path+WALSuffix? Тогда по пути path периодически
+//     (раз в interval секунд, а при interval равном 0 - по достижении журналом размера CompactSize)
+//     сохраняется снимок всех метрик, после чего журнал очищается. При restore снимок дополняется записями
+//     журнала;
+//   - o - учет длительности и размера записей на файловую систему, может быть nil.
+func NewStorage(ctx context.Context, path string, interval int, restore, walEnabled bool, o io.Observer) Storage {
 	if path == "" {
 		return inmemory.NewStorage()
 	}
 
-	var s Storage
+	var (
+		s   Storage
+		seq uint64
+	)
 
 	if restore {
-		c, g, err := io.Read(path)
+		c, g, sq, err := io.ReadSeq(path)
 		if err != nil {
 			log.Error().Err(err).Msg("io.Read Error")
 		} else {
 			s = inmemory.NewStorageWith(c, g)
+			seq = sq
 		}
 	}
 
@@ -53,18 +88,117 @@ func NewStorage(ctx context.Context, path string, interval int, restore bool) St
 		s = inmemory.NewStorage()
 	}
 
-	w := io.NewWriter(path)
+	f := io.NewWriter(path, o)
+	fs := &FileStorage{
+		created:  time.Now(),
+		storage:  s,
+		writer:   f,
+		file:     f,
+		path:     path,
+		interval: interval,
+		ctx:      ctx,
+	}
+
+	if walEnabled {
+		fs.wal = openWAL(ctx, path+WALSuffix, s, seq, restore)
+	}
+
+	fs.startWriter()
+
+	return fs
+}
+
+// startWriter - выбор способа записи метрик на файловую систему по s.interval: синхронно при каждом
+// изменении или периодически. Вызывается под s.mutex или до начала работы с хранилищем.
+func (s *FileStorage) startWriter() {
+	s.writer = nil
+	s.stop = func() {}
+
+	switch {
+	case s.wal != nil && s.interval != 0:
+		ctx, cancel := context.WithCancel(s.ctx)
+		iw := io.NewIntervalWriter(&compactor{storage: s}, s.storage)
+		go iw.Run(ctx, s.interval)
+		s.stop = cancel
+	case s.wal != nil:
+		// Снимок записывается по достижении журналом размера CompactSize.
+	case s.interval != 0:
+		ctx, cancel := context.WithCancel(s.ctx)
+		iw := io.NewIntervalWriter(s.file, s.storage)
+		go iw.Run(ctx, s.interval)
+		s.stop = cancel
+	default:
+		s.writer = s.file
+	}
+}
+
+// SetInterval - смена интервала в секундах, через который сохраняются все метрики, без перезапуска сервера.
+// При переходе от периодической записи к синхронной метрики сохраняются сразу.
+func (s *FileStorage) SetInterval(ctx context.Context, interval int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if interval == s.interval {
+		return nil
+	}
+
+	s.stop()
+	s.interval = interval
+	s.startWriter()
 
 	if interval != 0 {
-		iw := io.NewIntervalWriter(w, s)
-		go iw.Run(ctx, interval)
-		w = nil
+		return nil
+	}
+
+	err := s.compact(ctx)
+	if err != nil {
+		return fmt.Errorf("compact error:%w", err)
+	}
+
+	return nil
+}
+
+// openWAL - открывает журнал по пути path, при restore применяет к s записи журнала новее снимка с номером seq,
+// иначе очищает журнал. При ошибке открытия журнала возвращает nil, и метрики пишутся без журнала.
+func openWAL(ctx context.Context, path string, s Storage, seq uint64, restore bool) *wal.Log {
+	var fn wal.ReplayFunc
+	if restore {
+		// Записи журнала содержат приращения counter, поэтому применяются поштучно.
+		fn = func(c map[string]int64, g map[string]float64) error {
+			for n, v := range c {
+				err := s.StoreCounter(ctx, n, v)
+				if err != nil {
+					return fmt.Errorf("store counter error:%w", err)
+				}
+			}
+
+			for n, v := range g {
+				err := s.StoreGauge(ctx, n, v)
+				if err != nil {
+					return fmt.Errorf("store gauge error:%w", err)
+				}
+			}
+
+			return nil
+		}
 	}
 
-	return &FileStorage{
-		storage: s,
-		writer:  w,
+	l, err := wal.Open(path, seq, fn)
+	if err != nil {
+		log.Error().Err(err).Msg("wal.Open error, file storage works without wal")
+		return nil
 	}
+
+	if !restore {
+		err = l.Truncate()
+		if err != nil {
+			log.Error().Err(err).Msg("wal truncate error, file storage works without wal")
+			_ = l.Close()
+			return nil
+		}
+	}
+
+	return l
 }
 
 // StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
@@ -77,7 +211,12 @@ func (s *FileStorage) StoreGauge(ctx context.Context, name string, value float64
 		Float64("value", value).
 		Msg("StoreGauge")
 
-	err := s.storage.StoreGauge(ctx, name, value)
+	err := s.appendWAL(nil, map[string]float64{name: value})
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.StoreGauge(ctx, name, value)
 	if err != nil {
 		return fmt.Errorf("store gauge error:%w", err)
 	}
@@ -111,7 +250,12 @@ func (s *FileStorage) StoreCounter(ctx context.Context, name string, value int64
 		Int64("value", value).
 		Msg("StoreCounter")
 
-	err := s.storage.StoreCounter(ctx, name, value)
+	err := s.appendWAL(map[string]int64{name: value}, nil)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.StoreCounter(ctx, name, value)
 	if err != nil {
 		return fmt.Errorf("store counter error:%w", err)
 	}
@@ -142,7 +286,12 @@ func (s *FileStorage) StoreAll(ctx context.Context, counter map[string]int64, ga
 	log.Debug().
 		Msg("StoreAll")
 
-	err := s.storage.StoreAll(ctx, counter, gauge)
+	err := s.appendWAL(counter, gauge)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.StoreAll(ctx, counter, gauge)
 	if err != nil {
 		return fmt.Errorf("store all error:%w", err)
 	}
@@ -165,9 +314,72 @@ func (s *FileStorage) GetAll(ctx context.Context) (map[string]int64, map[string]
 	return c, g, nil
 }
 
+// DeleteGauge - удаляет метрику типа gauge с именем name.
+func (s *FileStorage) DeleteGauge(ctx context.Context, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.storage.DeleteGauge(ctx, name)
+	if err != nil {
+		return fmt.Errorf("delete gauge error:%w", err)
+	}
+
+	return s.writeDeletion(ctx)
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name.
+func (s *FileStorage) DeleteCounter(ctx context.Context, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.storage.DeleteCounter(ctx, name)
+	if err != nil {
+		return fmt.Errorf("delete counter error:%w", err)
+	}
+
+	return s.writeDeletion(ctx)
+}
+
+// writeDeletion - сохраняет удаление метрики. Журнал хранит только изменения значений, поэтому с журналом
+// сразу записывается снимок метрик, иначе удаленная метрика восстановится из журнала после перезапуска.
+func (s *FileStorage) writeDeletion(ctx context.Context) error {
+	if s.wal == nil {
+		s.writeStorage(ctx)
+		return nil
+	}
+
+	err := s.compact(ctx)
+	if err != nil {
+		return fmt.Errorf("compact error:%w", err)
+	}
+
+	return nil
+}
+
+// appendWAL - дописывает изменения метрик в журнал, если он включен.
+func (s *FileStorage) appendWAL(counter map[string]int64, gauge map[string]float64) error {
+	if s.wal == nil {
+		return nil
+	}
+
+	err := s.wal.Append(counter, gauge)
+	if err != nil {
+		return fmt.Errorf("wal append error:%w", err)
+	}
+
+	return nil
+}
+
 // writeStorage - записывает все метрики на файловую систему.
 func (s *FileStorage) writeStorage(ctx context.Context) {
 	log.Debug().Msg("Write storage")
+	if s.wal != nil && s.wal.Size() >= CompactSize {
+		err := s.compact(ctx)
+		if err != nil {
+			log.Error().Err(err).Msg("wal compact error")
+		}
+	}
+
 	if s.writer == nil {
 		return
 	}
@@ -178,3 +390,119 @@ func (s *FileStorage) writeStorage(ctx context.Context) {
 	}
 	log.Debug().Msg("Storage writed")
 }
+
+// Flush - записывает все метрики на файловую систему, вызывается при завершении работы сервера.
+func (s *FileStorage) Flush(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	err := s.compact(ctx)
+	if err != nil {
+		return fmt.Errorf("flush error:%w", err)
+	}
+
+	return nil
+}
+
+// compact - записывает снимок всех метрик на файловую систему и очищает журнал, если он включен.
+// Вызывается под s.mutex.
+func (s *FileStorage) compact(ctx context.Context) error {
+	if s.wal == nil {
+		return s.file.Write(ctx, s.storage)
+	}
+
+	err := s.file.Write(ctx, &snapshot{storage: s.storage, seq: s.wal.Seq()})
+	if err != nil {
+		return fmt.Errorf("write snapshot error:%w", err)
+	}
+
+	err = s.wal.Truncate()
+	if err != nil {
+		return fmt.Errorf("wal truncate error:%w", err)
+	}
+
+	return nil
+}
+
+// snapshot - снимок метрик с номером последней учтенной в нем записи журнала.
+type snapshot struct {
+	storage io.StorageGeter
+	seq     uint64
+}
+
+func (s *snapshot) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
+	return s.storage.GetAll(ctx)
+}
+
+func (s *snapshot) Seq() uint64 {
+	return s.seq
+}
+
+// compactor - писатель для периодической записи снимка метрик с очисткой журнала.
+type compactor struct {
+	storage *FileStorage
+}
+
+func (c *compactor) Write(ctx context.Context, _ io.StorageGeter) error {
+	c.storage.mutex.Lock()
+	defer c.storage.mutex.Unlock()
+
+	return c.storage.compact(ctx)
+}
+
+func (c *compactor) LastWrite() (time.Time, error) {
+	return c.storage.file.LastWrite()
+}
+
+// CheckWritable - проверка, что в директорию файла с метриками можно писать.
+func (s *FileStorage) CheckWritable(_ context.Context) error {
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".metrics-writable-*")
+	if err != nil {
+		return fmt.Errorf("create temp file error:%w", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("close temp file error:%w", err)
+	}
+
+	err = os.Remove(f.Name())
+	if err != nil {
+		return fmt.Errorf("remove temp file error:%w", err)
+	}
+
+	return nil
+}
+
+// CheckFlush - проверка, что последняя запись метрик на файловую систему была успешной и, при периодической
+// записи, не старше двух интервалов записи.
+func (s *FileStorage) CheckFlush(_ context.Context) error {
+	last, err := s.file.LastWrite()
+	if err != nil {
+		return fmt.Errorf("last flush error:%w", err)
+	}
+
+	s.mutex.Lock()
+	interval := s.interval
+	s.mutex.Unlock()
+
+	if interval == 0 {
+		return nil
+	}
+
+	if last.IsZero() {
+		last = s.created
+	}
+
+	age := time.Since(last)
+	if age > 2*time.Duration(interval)*time.Second {
+		return fmt.Errorf("%w:%v", ErrFlushStale, age)
+	}
+
+	return nil
+}
+
+// IsRetryable - классификатор ошибок хранилища для повторных обращений: временные ошибки файловой системы.
+func IsRetryable(err error) bool {
+	return retry.IsTemporary(err) || errors.Is(err, syscall.EIO)
+}
@@ -0,0 +1,168 @@
+package file
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorageChecks(t *testing.T) {
+	ctx := context.Background()
+
+	s, ok := NewStorage(ctx, filepath.Join(t.TempDir(), "metrics.json"), 0, false, false, nil).(*FileStorage)
+	require.True(t, ok)
+
+	assert.NoError(t, s.CheckWritable(ctx))
+	assert.NoError(t, s.CheckFlush(ctx))
+
+	err := s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	assert.NoError(t, s.CheckFlush(ctx))
+
+	s, ok = NewStorage(ctx, filepath.Join(t.TempDir(), "no", "such", "dir.json"), 0, false, false, nil).(*FileStorage)
+	require.True(t, ok)
+
+	assert.Error(t, s.CheckWritable(ctx))
+
+	err = s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+	assert.Error(t, s.CheckFlush(ctx))
+
+	s, ok = NewStorage(ctx, filepath.Join(t.TempDir(), "metrics.json"), 1, false, false, nil).(*FileStorage)
+	require.True(t, ok)
+
+	s.created = time.Now().Add(-time.Minute)
+	assert.ErrorIs(t, s.CheckFlush(ctx), ErrFlushStale)
+}
+
+func TestFileStorageFlush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	s, ok := NewStorage(ctx, path, 300, false, false, nil).(*FileStorage)
+	require.True(t, ok)
+
+	err := s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	cancel()
+
+	err = s.Flush(context.Background())
+	require.NoError(t, err)
+
+	s2 := NewStorage(context.Background(), path, 0, true, false, nil)
+
+	v, err := s2.GetCounter(context.Background(), "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *v)
+}
+
+func TestFileStorageWAL(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	s := NewStorage(ctx, path, 300, false, true, nil)
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 1.5))
+
+	// Падение до записи снимка: метрики восстанавливаются из журнала.
+	s = NewStorage(ctx, path, 300, true, true, nil)
+
+	c, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *c)
+
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, *g)
+
+	fs, ok := s.(*FileStorage)
+	require.True(t, ok)
+
+	require.NoError(t, fs.Flush(ctx))
+	assert.Equal(t, int64(0), fs.wal.Size())
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 2))
+
+	// Снимок и журнал после него: записи, учтенные в снимке, повторно не применяются.
+	s = NewStorage(ctx, path, 0, true, true, nil)
+
+	c, err = s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *c)
+
+	// Без restore журнал очищается.
+	s = NewStorage(ctx, path, 0, false, true, nil)
+
+	_, err = s.GetCounter(ctx, "PollCount")
+	require.Error(t, err)
+
+	fs, ok = s.(*FileStorage)
+	require.True(t, ok)
+	assert.Equal(t, int64(0), fs.wal.Size())
+}
+
+func TestFileStorageDelete(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	s := NewStorage(ctx, path, 300, false, true, nil)
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, s.DeleteCounter(ctx, "PollCount"))
+	assert.ErrorIs(t, s.DeleteGauge(ctx, "Unknown"), utils.ErrMetricsNoGauge)
+
+	// Удаленная метрика не восстанавливается из журнала.
+	s = NewStorage(ctx, path, 300, true, true, nil)
+
+	_, err := s.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoCounter)
+
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, *g)
+}
+
+func TestFileStorageSetInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	s, ok := NewStorage(ctx, path, 300, false, false, nil).(*FileStorage)
+	require.True(t, ok)
+
+	err := s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	// Переход к синхронной записи сразу сохраняет накопленные метрики.
+	err = s.SetInterval(ctx, 0)
+	require.NoError(t, err)
+
+	v, err := NewStorage(ctx, path, 0, true, false, nil).GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *v)
+
+	err = s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	v, err = NewStorage(ctx, path, 0, true, false, nil).GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *v)
+
+	err = s.SetInterval(ctx, 300)
+	require.NoError(t, err)
+
+	err = s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	v, err = NewStorage(ctx, path, 0, true, false, nil).GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *v)
+}
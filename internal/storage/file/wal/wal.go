@@ -0,0 +1,259 @@
+// Package wal для журнала (write-ahead log) записей метрик на файловую систему.
+//
+// Каждая запись журнала - отдельная строка с метриками, номером записи и контрольной суммой в формате
+// model.Metrics. Записи только дописываются в конец файла, поврежденный при падении хвост журнала
+// отбрасывается при открытии.
+package wal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/k0st1a/metrics/internal/storage/file/model"
+	"github.com/rs/zerolog/log"
+)
+
+// FileMode - права доступа к файлу журнала.
+const FileMode = 0600
+
+// ReplayFunc - функция применения записи журнала, где:
+//   - counter - приращения метрик типа counter;
+//   - gauge - значения метрик типа gauge.
+type ReplayFunc func(counter map[string]int64, gauge map[string]float64) error
+
+// Log - журнал записей метрик.
+type Log struct {
+	file  *os.File
+	path  string
+	size  int64
+	seq   uint64
+	mutex sync.Mutex
+}
+
+// Open - открыть журнал, где:
+//   - path - полное имя файла журнала;
+//   - after - номер последней записи, уже учтенной в снимке метрик, записи с меньшим или равным номером
+//     не применяются;
+//   - fn - функция применения записей журнала, если nil, то записи не применяются.
+//
+// Поврежденный хвост журнала (недописанная при падении запись) обрезается.
+func Open(path string, after uint64, fn ReplayFunc) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, FileMode)
+	if err != nil {
+		return nil, fmt.Errorf("open wal file error:%w", err)
+	}
+
+	l := &Log{
+		file: f,
+		path: path,
+		seq:  after,
+	}
+
+	err = l.replay(after, fn)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Replay - применить функцией fn записи журнала path с номером больше after, не изменяя файл журнала.
+// Поврежденный хвост журнала пропускается.
+func Replay(path string, after uint64, fn ReplayFunc) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open wal file error:%w", err)
+	}
+	defer func() {
+		err := f.Close()
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("close wal file error")
+		}
+	}()
+
+	_, _, err = scan(f, path, after, fn)
+	return err
+}
+
+func (l *Log) replay(after uint64, fn ReplayFunc) error {
+	offset, seq, err := scan(l.file, l.path, after, fn)
+	if err != nil {
+		return err
+	}
+
+	err = l.file.Truncate(offset)
+	if err != nil {
+		return fmt.Errorf("truncate wal file error:%w", err)
+	}
+
+	_, err = l.file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek wal file error:%w", err)
+	}
+
+	l.size = offset
+	l.seq = seq
+
+	return nil
+}
+
+// scan - чтение записей журнала из f до поврежденного хвоста с применением записей с номером больше after
+// функцией fn. Возвращается размер целой части журнала и номер его последней записи, но не меньше after.
+func scan(f io.Reader, path string, after uint64, fn ReplayFunc) (int64, uint64, error) {
+	r := bufio.NewReader(f)
+
+	var (
+		offset  int64
+		applied int
+	)
+
+	last := after
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) != 0 {
+				log.Warn().Str("path", path).Int64("offset", offset).Msg("wal: torn tail dropped")
+			}
+			break
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("read wal file error:%w", err)
+		}
+
+		c, g, seq, err := model.DeserializeSeq(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			log.Warn().Err(err).Str("path", path).Int64("offset", offset).Msg("wal: corrupted tail dropped")
+			break
+		}
+
+		offset += int64(len(line))
+
+		if seq > last {
+			last = seq
+		}
+
+		if seq <= after || fn == nil {
+			continue
+		}
+
+		err = fn(c, g)
+		if err != nil {
+			return 0, 0, fmt.Errorf("apply wal record(%v) error:%w", seq, err)
+		}
+		applied++
+	}
+
+	log.Printf("Wal %v replayed, records applied:%v, last seq:%v", path, applied, last)
+	return offset, last, nil
+}
+
+// Append - дописать в журнал запись, где:
+//   - counter - приращения метрик типа counter;
+//   - gauge - значения метрик типа gauge.
+//
+// Запись считается сохраненной после fsync файла журнала.
+func (l *Log) Append(counter map[string]int64, gauge map[string]float64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, err := model.SerializeSeq(counter, gauge, l.seq+1)
+	if err != nil {
+		return fmt.Errorf("model.SerializeSeq error:%w", err)
+	}
+
+	b = append(b, '\n')
+
+	n, err := l.file.Write(b)
+	if err != nil {
+		// Недописанная запись будет отброшена при следующем открытии журнала, но дописывать
+		// после нее нельзя, поэтому откатываемся к последней целой записи.
+		if tErr := l.rollback(); tErr != nil {
+			err = errors.Join(err, tErr)
+		}
+		return fmt.Errorf("write wal file error:%w", err)
+	}
+
+	err = l.file.Sync()
+	if err != nil {
+		if tErr := l.rollback(); tErr != nil {
+			err = errors.Join(err, tErr)
+		}
+		return fmt.Errorf("sync wal file error:%w", err)
+	}
+
+	l.seq++
+	l.size += int64(n)
+
+	return nil
+}
+
+func (l *Log) rollback() error {
+	err := l.file.Truncate(l.size)
+	if err != nil {
+		return fmt.Errorf("truncate wal file error:%w", err)
+	}
+
+	_, err = l.file.Seek(l.size, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("seek wal file error:%w", err)
+	}
+
+	return nil
+}
+
+// Seq - номер последней записи журнала.
+func (l *Log) Seq() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.seq
+}
+
+// Size - размер журнала в байтах.
+func (l *Log) Size() int64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.size
+}
+
+// Truncate - очистить журнал, вызывается после записи снимка метрик, учитывающего все записи журнала.
+// Нумерация записей продолжается.
+func (l *Log) Truncate() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.size = 0
+
+	err := l.rollback()
+	if err != nil {
+		return err
+	}
+
+	err = l.file.Sync()
+	if err != nil {
+		return fmt.Errorf("sync wal file error:%w", err)
+	}
+
+	return nil
+}
+
+// Close - закрыть журнал.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	err := l.file.Close()
+	if err != nil {
+		return fmt.Errorf("close wal file error:%w", err)
+	}
+
+	return nil
+}
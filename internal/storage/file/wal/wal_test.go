@@ -0,0 +1,163 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type records struct {
+	counter []map[string]int64
+	gauge   []map[string]float64
+}
+
+func (r *records) apply(c map[string]int64, g map[string]float64) error {
+	r.counter = append(r.counter, c)
+	r.gauge = append(r.gauge, g)
+	return nil
+}
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json.wal")
+
+	l, err := Open(path, 0, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 1}, nil))
+	require.NoError(t, l.Append(nil, map[string]float64{"Alloc": 1.5}))
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 2}, map[string]float64{"Alloc": 2.5}))
+	assert.Equal(t, uint64(3), l.Seq())
+	require.NoError(t, l.Close())
+
+	tests := []struct {
+		name    string
+		counter []map[string]int64
+		gauge   []map[string]float64
+		after   uint64
+	}{
+		{
+			name: "replay all records",
+			counter: []map[string]int64{
+				{"PollCount": 1},
+				{},
+				{"PollCount": 2},
+			},
+			gauge: []map[string]float64{
+				{},
+				{"Alloc": 1.5},
+				{"Alloc": 2.5},
+			},
+		},
+		{
+			name:    "replay records after snapshot",
+			after:   2,
+			counter: []map[string]int64{{"PollCount": 2}},
+			gauge:   []map[string]float64{{"Alloc": 2.5}},
+		},
+		{
+			name:  "all records are in snapshot",
+			after: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &records{}
+
+			l, err := Open(path, test.after, r.apply)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, l.Close())
+			}()
+
+			assert.Equal(t, test.counter, r.counter)
+			assert.Equal(t, test.gauge, r.gauge)
+			assert.Equal(t, uint64(3), l.Seq())
+		})
+	}
+}
+
+func TestLogTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json.wal")
+
+	l, err := Open(path, 0, nil)
+	require.NoError(t, err)
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 1}, nil))
+	size := l.Size()
+	require.NoError(t, l.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, FileMode)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"checksum":"00","list":[{"id":"PollCount","type":"counter","del`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	r := &records{}
+	l, err = Open(path, 0, r.apply)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]int64{{"PollCount": 1}}, r.counter)
+	assert.Equal(t, size, l.Size())
+
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 2}, nil))
+	require.NoError(t, l.Close())
+
+	r = &records{}
+	l, err = Open(path, 0, r.apply)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]int64{{"PollCount": 1}, {"PollCount": 2}}, r.counter)
+	require.NoError(t, l.Close())
+}
+
+func TestReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json.wal")
+
+	l, err := Open(path, 0, nil)
+	require.NoError(t, err)
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 1}, nil))
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 2}, nil))
+	require.NoError(t, l.Close())
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, FileMode)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"checksum":"00","list":[{"id":"PollCount","type":"counter","del`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	before, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	r := &records{}
+	require.NoError(t, Replay(path, 1, r.apply))
+	assert.Equal(t, []map[string]int64{{"PollCount": 2}}, r.counter)
+
+	// Поврежденный хвост пропускается, но не обрезается.
+	after, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	err = Replay(filepath.Join(t.TempDir(), "unknown.wal"), 0, r.apply)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLogTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json.wal")
+
+	l, err := Open(path, 0, nil)
+	require.NoError(t, err)
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 1}, nil))
+	require.NoError(t, l.Truncate())
+	assert.Equal(t, int64(0), l.Size())
+
+	require.NoError(t, l.Append(map[string]int64{"PollCount": 2}, nil))
+	assert.Equal(t, uint64(2), l.Seq())
+	require.NoError(t, l.Close())
+
+	r := &records{}
+	l, err = Open(path, 1, r.apply)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]int64{{"PollCount": 2}}, r.counter)
+	require.NoError(t, l.Close())
+}
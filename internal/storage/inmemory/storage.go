@@ -4,45 +4,92 @@ package inmemory
 import (
 	"context"
 	"maps"
+	"sync"
 
 	"github.com/k0st1a/metrics/internal/utils"
 	"github.com/rs/zerolog/log"
 )
 
-// Storage - внутреннее хранилище метрик.
-type Storage struct {
+// DefaultShards - число шардов хранилища по умолчанию.
+const DefaultShards = 32
+
+// shard - часть метрик хранилища со своей блокировкой.
+type shard struct {
 	gauge   map[string]float64
 	counter map[string]int64
+	mutex   sync.RWMutex
+}
+
+// Storage - внутреннее хранилище метрик. Метрики распределены по шардам по хешу имени, каждый шард
+// защищен своей блокировкой, поэтому запросы к метрикам разных шардов не блокируют друг друга.
+type Storage struct {
+	shards []*shard
 }
 
 // NewStorage - создать storage для хранения метрик в RAM.
 func NewStorage() *Storage {
-	return &Storage{
-		gauge:   make(map[string]float64),
-		counter: make(map[string]int64),
+	return NewStorageWithShards(DefaultShards)
+}
+
+// NewStorageWithShards - создать storage для хранения метрик в RAM, где:
+//   - n - число шардов, значение меньше 1 равносильно 1.
+func NewStorageWithShards(n int) *Storage {
+	if n < 1 {
+		n = 1
+	}
+
+	s := &Storage{
+		shards: make([]*shard, n),
+	}
+
+	for i := range s.shards {
+		s.shards[i] = &shard{
+			gauge:   make(map[string]float64),
+			counter: make(map[string]int64),
+		}
 	}
+
+	return s
 }
 
 // NewStorageWith - создать storage для хранения метрик в RAM с заданными метриками типа counter и gauge, где:
 //   - counter - метрики типа counter;
 //   - gauge - метрики типа gauge.
+//
+// Метрики копируются, дальнейшие изменения counter и gauge не влияют на storage.
 func NewStorageWith(counter map[string]int64, gauge map[string]float64) *Storage {
-	return &Storage{
-		counter: counter,
-		gauge:   gauge,
+	s := NewStorage()
+
+	for k, v := range counter {
+		s.shard(k).counter[k] = v
+	}
+
+	for k, v := range gauge {
+		s.shard(k).gauge[k] = v
 	}
+
+	return s
 }
 
 // StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
 func (s *Storage) StoreGauge(ctx context.Context, name string, value float64) error {
 	log.Printf("StoreGauge, name(%v), value(%v)", name, value)
-	s.gauge[name] = value
+
+	sh := s.shard(name)
+	sh.mutex.Lock()
+	sh.gauge[name] = value
+	sh.mutex.Unlock()
+
 	return nil
 }
 
 // GetGauge - возвращает метрику типа gauge с именем name.
 func (s *Storage) GetGauge(ctx context.Context, name string) (*float64, error) {
-	v, ok := s.gauge[name]
+	sh := s.shard(name)
+	sh.mutex.RLock()
+	v, ok := sh.gauge[name]
+	sh.mutex.RUnlock()
+
 	log.Printf("GetGauge, name(%v), value(%v), ok(%v)", name, v, ok)
 	if ok {
 		return &v, nil
@@ -54,13 +101,22 @@ func (s *Storage) GetGauge(ctx context.Context, name string) (*float64, error) {
 // StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
 func (s *Storage) StoreCounter(ctx context.Context, name string, value int64) error {
 	log.Printf("StoreCounter, name(%v), value(%v)", name, value)
-	s.counter[name] += value
+
+	sh := s.shard(name)
+	sh.mutex.Lock()
+	sh.counter[name] += value
+	sh.mutex.Unlock()
+
 	return nil
 }
 
 // GetCounter - возвращает метрику типа gauge с именем name.
 func (s *Storage) GetCounter(ctx context.Context, name string) (*int64, error) {
-	v, ok := s.counter[name]
+	sh := s.shard(name)
+	sh.mutex.RLock()
+	v, ok := sh.counter[name]
+	sh.mutex.RUnlock()
+
 	log.Printf("GetCounter, name(%v), value(%v), ok(%v)", name, v, ok)
 	if ok {
 		return &v, nil
@@ -68,15 +124,125 @@ func (s *Storage) GetCounter(ctx context.Context, name string) (*int64, error) {
 	return nil, utils.ErrMetricsNoCounter
 }
 
-// StoreAll - сохраняет группу метрик типа counter и gauge.
+// DeleteGauge - удаляет метрику типа gauge с именем name.
+func (s *Storage) DeleteGauge(ctx context.Context, name string) error {
+	sh := s.shard(name)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if _, ok := sh.gauge[name]; !ok {
+		return utils.ErrMetricsNoGauge
+	}
+	delete(sh.gauge, name)
+
+	return nil
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name.
+func (s *Storage) DeleteCounter(ctx context.Context, name string) error {
+	sh := s.shard(name)
+	sh.mutex.Lock()
+	defer sh.mutex.Unlock()
+
+	if _, ok := sh.counter[name]; !ok {
+		return utils.ErrMetricsNoCounter
+	}
+	delete(sh.counter, name)
+
+	return nil
+}
+
+// StoreAll - сохраняет группу метрик типа counter и gauge: значения counter прибавляются к текущим,
+// значения gauge заменяют текущие. Группа сохраняется под блокировками всех затронутых ею шардов,
+// поэтому GetAll видит ее целиком или не видит вовсе, а группы из разных шардов не ждут друг друга.
 func (s *Storage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
-	maps.Copy(s.counter, counter)
-	maps.Copy(s.gauge, gauge)
+	locked := s.lock(counter, gauge)
+	defer func() {
+		for _, i := range locked {
+			s.shards[i].mutex.Unlock()
+		}
+	}()
+
+	for k, v := range counter {
+		s.shard(k).counter[k] += v
+	}
+
+	for k, v := range gauge {
+		s.shard(k).gauge[k] = v
+	}
 
 	return nil
 }
 
-// GetAll - возвращает все метрики типа counter и gauge.
+// GetAll - возвращает копии всех метрик типа counter и gauge. Копии делаются под блокировками всех шардов
+// сразу, чтобы группа метрик StoreAll не попала в ответ частично.
 func (s *Storage) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
-	return s.counter, s.gauge, nil
+	c := make(map[string]int64)
+	g := make(map[string]float64)
+
+	for _, sh := range s.shards {
+		sh.mutex.RLock()
+	}
+
+	for _, sh := range s.shards {
+		maps.Copy(c, sh.counter)
+		maps.Copy(g, sh.gauge)
+		sh.mutex.RUnlock()
+	}
+
+	return c, g, nil
+}
+
+// lock - блокирует шарды метрик counter и gauge в порядке возрастания индекса, как и GetAll, чтобы
+// одновременные блокировки нескольких шардов не приводили к взаимоблокировке. Возвращает индексы
+// заблокированных шардов.
+func (s *Storage) lock(counter map[string]int64, gauge map[string]float64) []int {
+	touched := make([]bool, len(s.shards))
+
+	for k := range counter {
+		touched[s.index(k)] = true
+	}
+
+	for k := range gauge {
+		touched[s.index(k)] = true
+	}
+
+	var locked []int
+
+	for i, ok := range touched {
+		if ok {
+			s.shards[i].mutex.Lock()
+			locked = append(locked, i)
+		}
+	}
+
+	return locked
+}
+
+func (s *Storage) shard(name string) *shard {
+	return s.shards[s.index(name)]
+}
+
+func (s *Storage) index(name string) int {
+	if len(s.shards) == 1 {
+		return 0
+	}
+
+	return int(fnv32a(name) % uint32(len(s.shards)))
+}
+
+// fnv32a - хеш FNV-1a имени метрики, без аллокаций в отличие от hash/fnv.
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	h := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(s[i])
+		h *= prime32
+	}
+
+	return h
 }
@@ -2,9 +2,13 @@ package inmemory
 
 import (
 	"context"
+	"strconv"
+	"sync"
 	"testing"
 
+	"github.com/k0st1a/metrics/internal/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInMemory(t *testing.T) {
@@ -38,3 +42,174 @@ func TestInMemory(t *testing.T) {
 		})
 	}
 }
+
+func TestInMemoryStoreAll(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, s.StoreAll(ctx, map[string]int64{"PollCount": 2}, map[string]float64{"Alloc": 2.5}))
+
+	c, g, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 3}, c)
+	assert.Equal(t, map[string]float64{"Alloc": 2.5}, g)
+
+	// GetAll возвращает копии, их изменение не влияет на storage.
+	c["PollCount"] = 100
+	g["Alloc"] = 100
+
+	v, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *v)
+
+	f, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, *f)
+}
+
+func TestInMemoryConcurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	const (
+		workers = 8
+		updates = 1000
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				assert.NoError(t, s.StoreAll(ctx,
+					map[string]int64{"PollCount": 1, "counter" + strconv.Itoa(i%10): 1},
+					map[string]float64{"gauge" + strconv.Itoa(w): float64(i)}))
+				assert.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+
+				_, _, err := s.GetAll(ctx)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	c, g, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*workers*updates), c["PollCount"])
+	assert.Equal(t, int64(workers*updates/10), c["counter0"])
+	assert.Len(t, g, workers)
+}
+
+// TestInMemoryStoreAllAtomic - GetAll во время StoreAll видит группу метрик целиком или не видит вовсе.
+// Каждая группа задает всем gauge одно значение и увеличивает все counter на единицу, а метрики группы
+// распределены по разным шардам.
+func TestInMemoryStoreAllAtomic(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorage()
+
+	const (
+		metrics = 4 * DefaultShards
+		batches = 500
+		readers = 4
+	)
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				c, g, err := s.GetAll(ctx)
+				assert.NoError(t, err)
+
+				for k, v := range g {
+					if !assert.Equal(t, g["gauge0"], v, "gauge %v is from another batch", k) {
+						return
+					}
+				}
+
+				for k, v := range c {
+					if !assert.Equal(t, c["counter0"], v, "counter %v is from another batch", k) {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < batches; i++ {
+		counter := make(map[string]int64, metrics)
+		gauge := make(map[string]float64, metrics)
+
+		for m := 0; m < metrics; m++ {
+			counter["counter"+strconv.Itoa(m)] = 1
+			gauge["gauge"+strconv.Itoa(m)] = float64(i)
+		}
+
+		require.NoError(t, s.StoreAll(ctx, counter, gauge))
+	}
+
+	close(done)
+	wg.Wait()
+}
+
+// BenchmarkStoreAll - параллельная запись групп метрик, как при обработке запросов /updates/ от агентов:
+// 30 gauge и один counter на запрос. Сравнивает хранилище с одним шардом (одна блокировка)
+// и с числом шардов по умолчанию.
+func BenchmarkStoreAll(b *testing.B) {
+	ctx := context.Background()
+
+	gauge := make(map[string]float64)
+	for i := 0; i < 30; i++ {
+		gauge["gauge"+strconv.Itoa(i)] = float64(i)
+	}
+	counter := map[string]int64{"PollCount": 1}
+
+	for _, shards := range []int{1, DefaultShards} {
+		b.Run("shards="+strconv.Itoa(shards), func(b *testing.B) {
+			s := NewStorageWithShards(shards)
+
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					i++
+					// Каждый десятый запрос читает все метрики, как обработчик списка метрик.
+					if i%10 == 0 {
+						_, _, _ = s.GetAll(ctx)
+						continue
+					}
+
+					_ = s.StoreAll(ctx, counter, gauge)
+				}
+			})
+		})
+	}
+}
+
+func TestInMemoryDelete(t *testing.T) {
+	ctx := context.Background()
+	s := NewStorageWith(map[string]int64{"PollCount": 1}, map[string]float64{"Alloc": 1.5})
+
+	require.NoError(t, s.DeleteCounter(ctx, "PollCount"))
+	require.NoError(t, s.DeleteGauge(ctx, "Alloc"))
+
+	assert.ErrorIs(t, s.DeleteCounter(ctx, "PollCount"), utils.ErrMetricsNoCounter)
+	assert.ErrorIs(t, s.DeleteGauge(ctx, "Alloc"), utils.ErrMetricsNoGauge)
+
+	c, g, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, c)
+	assert.Empty(t, g)
+}
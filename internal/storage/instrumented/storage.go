@@ -0,0 +1,214 @@
+// Package instrumented for storage decorator which measures latencies of storage operations and
+// serves metrics of the server itself as ordinary metrics.
+package instrumented
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/selfmetrics"
+	"github.com/k0st1a/metrics/internal/utils"
+)
+
+// Storage - интерфейс работы с хранилищем метрик.
+type Storage interface {
+	GetGauge(ctx context.Context, name string) (*float64, error)
+	StoreGauge(ctx context.Context, name string, value float64) error
+
+	GetCounter(ctx context.Context, name string) (*int64, error)
+	StoreCounter(ctx context.Context, name string, value int64) error
+
+	DeleteGauge(ctx context.Context, name string) error
+	DeleteCounter(ctx context.Context, name string) error
+
+	StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error
+	GetAll(ctx context.Context) (counter map[string]int64, gauge map[string]float64, err error)
+}
+
+// Registry - интерфейс реестра метрик сервера.
+type Registry interface {
+	ObserveDuration(name string, d time.Duration)
+	GetAll() (counter map[string]int64, gauge map[string]float64)
+}
+
+type storage struct {
+	storage  Storage
+	registry Registry
+}
+
+// NewStorage - создать хранилище, которое замеряет длительность операций хранилища s и отдает
+// метрики сервера из реестра r наравне с остальными метриками.
+func NewStorage(s Storage, r Registry) *storage {
+	return &storage{
+		storage:  s,
+		registry: r,
+	}
+}
+
+// StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
+func (s *storage) StoreGauge(ctx context.Context, name string, value float64) error {
+	if selfmetrics.IsReserved(name) {
+		return utils.ErrMetricsReservedName
+	}
+
+	defer s.observe("store_gauge", time.Now())
+
+	err := s.storage.StoreGauge(ctx, name, value)
+	if err != nil {
+		return fmt.Errorf("store gauge error:%w", err)
+	}
+
+	return nil
+}
+
+// GetGauge - возвращает метрику типа gauge с именем name.
+func (s *storage) GetGauge(ctx context.Context, name string) (*float64, error) {
+	if selfmetrics.IsReserved(name) {
+		_, g := s.registry.GetAll()
+		v, ok := g[name]
+		if !ok {
+			return nil, utils.ErrMetricsNoGauge
+		}
+		return &v, nil
+	}
+
+	defer s.observe("get_gauge", time.Now())
+
+	v, err := s.storage.GetGauge(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get gauge error:%w", err)
+	}
+
+	return v, nil
+}
+
+// StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
+func (s *storage) StoreCounter(ctx context.Context, name string, value int64) error {
+	if selfmetrics.IsReserved(name) {
+		return utils.ErrMetricsReservedName
+	}
+
+	defer s.observe("store_counter", time.Now())
+
+	err := s.storage.StoreCounter(ctx, name, value)
+	if err != nil {
+		return fmt.Errorf("store counter error:%w", err)
+	}
+
+	return nil
+}
+
+// GetCounter - возвращает метрику типа counter с именем name.
+func (s *storage) GetCounter(ctx context.Context, name string) (*int64, error) {
+	if selfmetrics.IsReserved(name) {
+		c, _ := s.registry.GetAll()
+		v, ok := c[name]
+		if !ok {
+			return nil, utils.ErrMetricsNoCounter
+		}
+		return &v, nil
+	}
+
+	defer s.observe("get_counter", time.Now())
+
+	v, err := s.storage.GetCounter(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("get counter error:%w", err)
+	}
+
+	return v, nil
+}
+
+// DeleteGauge - удаляет метрику типа gauge с именем name.
+func (s *storage) DeleteGauge(ctx context.Context, name string) error {
+	if selfmetrics.IsReserved(name) {
+		return utils.ErrMetricsReservedName
+	}
+
+	defer s.observe("delete_gauge", time.Now())
+
+	err := s.storage.DeleteGauge(ctx, name)
+	if err != nil {
+		return fmt.Errorf("delete gauge error:%w", err)
+	}
+
+	return nil
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name.
+func (s *storage) DeleteCounter(ctx context.Context, name string) error {
+	if selfmetrics.IsReserved(name) {
+		return utils.ErrMetricsReservedName
+	}
+
+	defer s.observe("delete_counter", time.Now())
+
+	err := s.storage.DeleteCounter(ctx, name)
+	if err != nil {
+		return fmt.Errorf("delete counter error:%w", err)
+	}
+
+	return nil
+}
+
+// StoreAll - сохраняет группу метрик типа counter и gauge.
+func (s *storage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	for k := range counter {
+		if selfmetrics.IsReserved(k) {
+			return utils.ErrMetricsReservedName
+		}
+	}
+
+	for k := range gauge {
+		if selfmetrics.IsReserved(k) {
+			return utils.ErrMetricsReservedName
+		}
+	}
+
+	defer s.observe("store_all", time.Now())
+
+	err := s.storage.StoreAll(ctx, counter, gauge)
+	if err != nil {
+		return fmt.Errorf("store all error:%w", err)
+	}
+
+	return nil
+}
+
+// GetAll - возвращает все метрики типа counter и gauge вместе с метриками сервера.
+func (s *storage) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
+	start := time.Now()
+
+	c, g, err := s.storage.GetAll(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get all error:%w", err)
+	}
+
+	s.observe("get_all", start)
+
+	sc, sg := s.registry.GetAll()
+
+	// Копируем, чтобы не изменить внутреннее состояние хранилища.
+	counter := make(map[string]int64, len(c)+len(sc))
+	for k, v := range c {
+		counter[k] = v
+	}
+	for k, v := range sc {
+		counter[k] = v
+	}
+
+	gauge := make(map[string]float64, len(g)+len(sg))
+	for k, v := range g {
+		gauge[k] = v
+	}
+	for k, v := range sg {
+		gauge[k] = v
+	}
+
+	return counter, gauge, nil
+}
+
+func (s *storage) observe(op string, start time.Time) {
+	s.registry.ObserveDuration("storage_"+op+"_duration_seconds", time.Since(start))
+}
@@ -0,0 +1,39 @@
+package instrumented
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k0st1a/metrics/internal/pkg/selfmetrics"
+	"github.com/k0st1a/metrics/internal/storage/inmemory"
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	r := selfmetrics.New()
+	s := NewStorage(inmemory.NewStorage(), r)
+
+	err := s.StoreCounter(ctx, "PollCount", 1)
+	require.NoError(t, err)
+
+	err = s.StoreGauge(ctx, "metrics_server_go_goroutines", 1)
+	assert.ErrorIs(t, err, utils.ErrMetricsReservedName)
+
+	err = s.StoreAll(ctx, map[string]int64{"metrics_server_x": 1}, nil)
+	assert.ErrorIs(t, err, utils.ErrMetricsReservedName)
+
+	v, err := s.GetCounter(ctx, "metrics_server_storage_store_counter_duration_seconds_count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *v)
+
+	_, err = s.GetGauge(ctx, "metrics_server_go_goroutines")
+	assert.NoError(t, err)
+
+	c, g, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), c["PollCount"])
+	assert.Contains(t, g, "metrics_server_go_heap_alloc_bytes")
+}
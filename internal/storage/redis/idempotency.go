@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyKind = "idempotency"
+
+type idempotencyStore struct {
+	c      redis.UniversalClient
+	prefix string
+	window time.Duration
+}
+
+// NewIdempotencyStore - создание хранилища ответов в Redis, общего для всех серверов пространства имен, где:
+//   - c - клиент Redis;
+//   - namespace - пространство имен ключей;
+//   - window - время, в течении которого ответ на запрос хранится.
+//
+// Ответы хранятся в ключах `{<namespace>}:idempotency:<key>` и удаляются Redis по окончании окна.
+func NewIdempotencyStore(c redis.UniversalClient, namespace string, window time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		c:      c,
+		prefix: "{" + namespace + "}:" + idempotencyKind + ":",
+		window: window,
+	}
+}
+
+// Reserve - резервирует ключ key за запросом с хешем hash командой SET NX, поэтому из одновременных запросов
+// с одним ключом, в том числе на разных серверах, ключ резервирует только один.
+func (s *idempotencyStore) Reserve(ctx context.Context, key, hash string) (*idempotency.Response, bool, error) {
+	b, err := json.Marshal(&idempotency.Response{Created: time.Now(), RequestHash: hash})
+	if err != nil {
+		return nil, false, fmt.Errorf("json marshal error:%w", err)
+	}
+
+	ok, err := s.c.SetNX(ctx, s.prefix+key, b, s.window).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("reserve idempotency key error:%w", err)
+	}
+
+	if ok {
+		return nil, true, nil
+	}
+
+	v, err := s.c.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Ключ удален между резервированием и чтением.
+		return nil, false, idempotency.ErrInProgress
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get idempotency key error:%w", err)
+	}
+
+	var r idempotency.Response
+
+	err = json.Unmarshal(v, &r)
+	if err != nil {
+		return nil, false, fmt.Errorf("json unmarshal error:%w", err)
+	}
+
+	if r.Pending() {
+		return nil, false, idempotency.ErrInProgress
+	}
+
+	return &r, false, nil
+}
+
+// Save - сохраняет ответ r по ключу key на время окна.
+func (s *idempotencyStore) Save(ctx context.Context, key string, r *idempotency.Response) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("json marshal error:%w", err)
+	}
+
+	err = s.c.Set(ctx, s.prefix+key, b, s.window).Err()
+	if err != nil {
+		return fmt.Errorf("save idempotency key error:%w", err)
+	}
+
+	return nil
+}
+
+// Release - снимает резервирование ключа key. Снимает его только зарезервировавший ключ запрос,
+// поэтому сохраненного ответа по ключу в этот момент нет.
+func (s *idempotencyStore) Release(ctx context.Context, key string) error {
+	err := s.c.Del(ctx, s.prefix+key).Err()
+	if err != nil {
+		return fmt.Errorf("release idempotency key error:%w", err)
+	}
+
+	return nil
+}
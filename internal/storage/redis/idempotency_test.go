@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	m := miniredis.RunT(t)
+
+	newStore := func() *idempotencyStore {
+		c := redis.NewClient(&redis.Options{Addr: m.Addr()})
+		t.Cleanup(func() {
+			assert.NoError(t, c.Close())
+		})
+
+		return NewIdempotencyStore(c, DefaultNamespace, time.Minute)
+	}
+
+	// Два сервера с общим Redis.
+	s1, s2 := newStore(), newStore()
+
+	_, reserved, err := s1.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+	assert.True(t, m.Exists("{metrics}:idempotency:key1"))
+
+	_, reserved, err = s2.Reserve(ctx, "key1", "hash1")
+	assert.ErrorIs(t, err, idempotency.ErrInProgress)
+	assert.False(t, reserved)
+
+	err = s1.Save(ctx, "key1", &idempotency.Response{Status: 200, RequestHash: "hash1", Body: []byte("ok")})
+	require.NoError(t, err)
+
+	r, reserved, err := s2.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, 200, r.Status)
+	assert.Equal(t, []byte("ok"), r.Body)
+
+	_, reserved, err = s1.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	require.NoError(t, s1.Release(ctx, "key2"))
+
+	_, reserved, err = s2.Reserve(ctx, "key2", "hash2")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Ключи удаляются по окончании окна.
+	m.FastForward(2 * time.Minute)
+
+	_, reserved, err = s2.Reserve(ctx, "key1", "hash1")
+	require.NoError(t, err)
+	assert.True(t, reserved)
+}
@@ -0,0 +1,244 @@
+// Package redis for save metrics to Redis.
+//
+// Метрики хранятся в отдельных ключах `{<namespace>}:counter:<name>` и `{<namespace>}:gauge:<name>`.
+// Пространство имен заключено в фигурные скобки (hash tag), поэтому в Redis Cluster все ключи одного
+// пространства попадают в один слот, что позволяет сохранять группу метрик одной транзакцией.
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/k0st1a/metrics/internal/pkg/retry"
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultNamespace - пространство имен ключей метрик по умолчанию.
+const DefaultNamespace = "metrics"
+
+const (
+	counterKind = "counter"
+	gaugeKind   = "gauge"
+
+	scanCount = 1000
+)
+
+type RedisStorage struct {
+	c      redis.UniversalClient
+	prefix string
+}
+
+// NewStorage - создать storage для хранения метрик в Redis, где:
+//   - c - клиент Redis;
+//   - namespace - пространство имен ключей метрик, позволяет нескольким группам серверов использовать
+//     один Redis.
+func NewStorage(c redis.UniversalClient, namespace string) *RedisStorage {
+	return &RedisStorage{
+		c:      c,
+		prefix: "{" + namespace + "}:",
+	}
+}
+
+// StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
+func (s *RedisStorage) StoreGauge(ctx context.Context, name string, value float64) error {
+	err := s.c.Set(ctx, s.key(gaugeKind, name), formatGauge(value), 0).Err()
+	if err != nil {
+		return fmt.Errorf("store gauge error:%w", err)
+	}
+
+	return nil
+}
+
+// GetGauge - возвращает метрику типа gauge с именем name.
+func (s *RedisStorage) GetGauge(ctx context.Context, name string) (*float64, error) {
+	v, err := s.c.Get(ctx, s.key(gaugeKind, name)).Float64()
+	if errors.Is(err, redis.Nil) {
+		return nil, utils.ErrMetricsNoGauge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get gauge error:%w", err)
+	}
+
+	return &v, nil
+}
+
+// StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
+func (s *RedisStorage) StoreCounter(ctx context.Context, name string, value int64) error {
+	err := s.c.IncrBy(ctx, s.key(counterKind, name), value).Err()
+	if err != nil {
+		return fmt.Errorf("store counter error:%w", err)
+	}
+
+	return nil
+}
+
+// GetCounter - возвращает метрику типа counter с именем name.
+func (s *RedisStorage) GetCounter(ctx context.Context, name string) (*int64, error) {
+	d, err := s.c.Get(ctx, s.key(counterKind, name)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return nil, utils.ErrMetricsNoCounter
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get counter error:%w", err)
+	}
+
+	return &d, nil
+}
+
+// DeleteGauge - удаляет метрику типа gauge с именем name.
+func (s *RedisStorage) DeleteGauge(ctx context.Context, name string) error {
+	return s.delete(ctx, gaugeKind, name, utils.ErrMetricsNoGauge)
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name.
+func (s *RedisStorage) DeleteCounter(ctx context.Context, name string) error {
+	return s.delete(ctx, counterKind, name, utils.ErrMetricsNoCounter)
+}
+
+// delete - удаляет ключ метрики, если метрики нет, то возвращается notFound.
+func (s *RedisStorage) delete(ctx context.Context, kind, name string, notFound error) error {
+	n, err := s.c.Del(ctx, s.key(kind, name)).Result()
+	if err != nil {
+		return fmt.Errorf("delete %s error:%w", kind, err)
+	}
+
+	if n == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+// StoreAll - сохраняет группу метрик типа counter и gauge одной транзакцией MULTI/EXEC, отправляемой
+// одним пакетом (pipeline).
+func (s *RedisStorage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	_, err := s.c.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for k, v := range counter {
+			p.IncrBy(ctx, s.key(counterKind, k), v)
+		}
+
+		for k, v := range gauge {
+			p.Set(ctx, s.key(gaugeKind, k), formatGauge(v), 0)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store all error:%w", err)
+	}
+
+	return nil
+}
+
+// GetAll - возвращает все метрики типа counter и gauge.
+func (s *RedisStorage) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
+	cv, err := s.getAll(ctx, counterKind)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get counters error:%w", err)
+	}
+
+	c := make(map[string]int64, len(cv))
+	for k, v := range cv {
+		d, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("counter(%v) parse error:%w", k, err)
+		}
+		c[k] = d
+	}
+
+	gv, err := s.getAll(ctx, gaugeKind)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get gauges error:%w", err)
+	}
+
+	g := make(map[string]float64, len(gv))
+	for k, v := range gv {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("gauge(%v) parse error:%w", k, err)
+		}
+		g[k] = f
+	}
+
+	return c, g, nil
+}
+
+// getAll - возвращает значения всех метрик типа kind. Ключи перебираются через SCAN, чтобы не блокировать
+// Redis, значения читаются через MGET.
+func (s *RedisStorage) getAll(ctx context.Context, kind string) (map[string]string, error) {
+	prefix := s.key(kind, "")
+	res := make(map[string]string)
+
+	var cursor uint64
+	for {
+		keys, next, err := s.c.Scan(ctx, cursor, escapeGlob(prefix)+"*", scanCount).Result()
+		if err != nil {
+			return nil, fmt.Errorf("scan error:%w", err)
+		}
+
+		if len(keys) != 0 {
+			vals, err := s.c.MGet(ctx, keys...).Result()
+			if err != nil {
+				return nil, fmt.Errorf("mget error:%w", err)
+			}
+
+			for i, v := range vals {
+				// Ключ мог быть удален между SCAN и MGET.
+				str, ok := v.(string)
+				if !ok {
+					continue
+				}
+				res[strings.TrimPrefix(keys[i], prefix)] = str
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return res, nil
+		}
+	}
+}
+
+// Ping - проверка доступности Redis.
+func (s *RedisStorage) Ping(ctx context.Context) error {
+	err := s.c.Ping(ctx).Err()
+	if err != nil {
+		return fmt.Errorf("redis ping error:%w", err)
+	}
+
+	return nil
+}
+
+func (s *RedisStorage) key(kind, name string) string {
+	return s.prefix + kind + ":" + name
+}
+
+func formatGauge(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeGlob - экранирует спецсимволы шаблона SCAN MATCH.
+func escapeGlob(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return r.Replace(s)
+}
+
+// IsRetryable - классификатор ошибок хранилища для повторных обращений: временные ошибки сети и состояния
+// Redis, в которых он временно не обслуживает запросы.
+func IsRetryable(err error) bool {
+	if retry.IsTemporary(err) {
+		return true
+	}
+
+	for _, p := range []string{"LOADING ", "TRYAGAIN ", "CLUSTERDOWN ", "MASTERDOWN ", "BUSY "} {
+		if redis.HasErrorPrefix(err, p) {
+			return true
+		}
+	}
+
+	return false
+}
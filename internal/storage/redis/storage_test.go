@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, namespace string) (*RedisStorage, *miniredis.Miniredis) {
+	t.Helper()
+
+	m := miniredis.RunT(t)
+
+	c := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	return NewStorage(c, namespace), m
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s, m := newTestStorage(t, DefaultNamespace)
+
+	_, err := s.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoCounter)
+
+	_, err = s.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoGauge)
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 2))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 2.5))
+
+	c, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *c)
+
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, *g)
+
+	err = s.StoreAll(ctx,
+		map[string]int64{"PollCount": 10, "Other": 5},
+		map[string]float64{"Alloc": 3.5, "RandomValue": 0.1})
+	require.NoError(t, err)
+
+	cs, gs, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 13, "Other": 5}, cs)
+	assert.Equal(t, map[string]float64{"Alloc": 3.5, "RandomValue": 0.1}, gs)
+
+	v, err := m.Get("{metrics}:counter:PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, "13", v)
+
+	v, err = m.Get("{metrics}:gauge:Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, "3.5", v)
+
+	assert.NoError(t, s.Ping(ctx))
+}
+
+func TestStorageNamespace(t *testing.T) {
+	ctx := context.Background()
+	s1, m := newTestStorage(t, "a")
+
+	c := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	defer func() {
+		assert.NoError(t, c.Close())
+	}()
+	s2 := NewStorage(c, "a*")
+
+	require.NoError(t, s1.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s2.StoreCounter(ctx, "PollCount", 2))
+
+	cs, _, err := s1.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 1}, cs)
+
+	cs, _, err = s2.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 2}, cs)
+}
+
+func TestStorageUnavailable(t *testing.T) {
+	ctx := context.Background()
+	s, m := newTestStorage(t, DefaultNamespace)
+
+	m.Close()
+
+	assert.Error(t, s.Ping(ctx))
+	assert.Error(t, s.StoreAll(ctx, map[string]int64{"PollCount": 1}, nil))
+}
+
+func TestStorageDelete(t *testing.T) {
+	ctx := context.Background()
+	s, m := newTestStorage(t, DefaultNamespace)
+
+	require.NoError(t, s.StoreAll(ctx, map[string]int64{"PollCount": 1}, map[string]float64{"Alloc": 1.5}))
+
+	require.NoError(t, s.DeleteCounter(ctx, "PollCount"))
+	require.NoError(t, s.DeleteGauge(ctx, "Alloc"))
+
+	assert.ErrorIs(t, s.DeleteCounter(ctx, "PollCount"), utils.ErrMetricsNoCounter)
+	assert.ErrorIs(t, s.DeleteGauge(ctx, "Alloc"), utils.ErrMetricsNoGauge)
+	assert.Empty(t, m.Keys())
+}
@@ -0,0 +1,116 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/k0st1a/metrics/internal/pkg/idempotency"
+)
+
+type idempotencyStore struct {
+	db     *sql.DB
+	now    func() time.Time
+	window time.Duration
+}
+
+// NewIdempotencyStore - создание хранилища ответов в той же БД SQLite, что и метрики, где:
+//   - s - storage метрик;
+//   - window - время, в течении которого ответ на запрос хранится.
+func NewIdempotencyStore(s *SQLiteStorage, window time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		db:     s.db,
+		now:    time.Now,
+		window: window,
+	}
+}
+
+// Reserve - резервирует ключ key за запросом с хешем hash. Резервирование вышедшего за окно ключа
+// перезаписывается.
+func (s *idempotencyStore) Reserve(ctx context.Context, key, hash string) (*idempotency.Response, bool, error) {
+	now := s.now()
+
+	res, err := s.db.ExecContext(ctx, "INSERT INTO idempotency_keys (key, request_hash, status, created_at) "+
+		"VALUES(?, ?, 0, ?) ON CONFLICT (key) DO UPDATE SET request_hash = excluded.request_hash, status = 0, "+
+		"header = NULL, body = NULL, created_at = excluded.created_at WHERE idempotency_keys.created_at <= ?",
+		key, hash, now.UnixNano(), now.Add(-s.window).UnixNano())
+	if err != nil {
+		return nil, false, fmt.Errorf("reserve idempotency key query error:%w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("rows affected error:%w", err)
+	}
+
+	if n == 1 {
+		return nil, true, nil
+	}
+
+	var (
+		r       idempotency.Response
+		header  sql.NullString
+		created int64
+	)
+
+	err = s.db.QueryRowContext(ctx, "SELECT request_hash, status, header, body, created_at FROM idempotency_keys "+
+		"WHERE key = ?", key).Scan(&r.RequestHash, &r.Status, &header, &r.Body, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Ключ удален между резервированием и чтением.
+		return nil, false, idempotency.ErrInProgress
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("get idempotency key query error:%w", err)
+	}
+
+	if r.Pending() {
+		return nil, false, idempotency.ErrInProgress
+	}
+
+	r.Created = time.Unix(0, created)
+
+	err = json.Unmarshal([]byte(header.String), &r.Header)
+	if err != nil {
+		return nil, false, fmt.Errorf("header json unmarshal error:%w", err)
+	}
+
+	return &r, false, nil
+}
+
+// Save - сохраняет ответ r по ключу key, попутно удаляя ответы вышедшие за окно.
+func (s *idempotencyStore) Save(ctx context.Context, key string, r *idempotency.Response) error {
+	header, err := json.Marshal(r.Header)
+	if err != nil {
+		return fmt.Errorf("header json marshal error:%w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < ?",
+		s.now().Add(-s.window).UnixNano())
+	if err != nil {
+		return fmt.Errorf("delete expired idempotency keys query error:%w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, "INSERT INTO idempotency_keys "+
+		"(key, request_hash, status, header, body, created_at) VALUES(?, ?, ?, ?, ?, ?) "+
+		"ON CONFLICT (key) DO UPDATE SET request_hash = excluded.request_hash, status = excluded.status, "+
+		"header = excluded.header, body = excluded.body, created_at = excluded.created_at",
+		key, r.RequestHash, r.Status, string(header), r.Body, r.Created.UnixNano())
+	if err != nil {
+		return fmt.Errorf("save idempotency key query error:%w", err)
+	}
+
+	return nil
+}
+
+// Release - снимает резервирование ключа key, если ответ по нему не сохранен.
+func (s *idempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE key = ? AND status = 0", key)
+	if err != nil {
+		return fmt.Errorf("release idempotency key query error:%w", err)
+	}
+
+	return nil
+}
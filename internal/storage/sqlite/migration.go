@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// migrations - миграции схемы БД, номер версии схемы равен числу примененных миграций и хранится в
+// PRAGMA user_version. Новые миграции добавляются только в конец списка.
+var migrations = []string{
+	`
+	CREATE TABLE IF NOT EXISTS counters(
+		name  TEXT    PRIMARY KEY,
+		delta INTEGER NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS gauges(
+		name  TEXT PRIMARY KEY,
+		value REAL NOT NULL
+	);
+	`,
+	`
+	CREATE TABLE IF NOT EXISTS idempotency_keys(
+		key          TEXT    PRIMARY KEY,
+		request_hash TEXT    NOT NULL,
+		status       INTEGER NOT NULL,
+		header       TEXT    NULL,
+		body         BLOB    NULL,
+		created_at   INTEGER NOT NULL
+	);
+	`,
+}
+
+// migrate - применяет к БД еще не примененные миграции, каждую в отдельной транзакции.
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+
+	err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version)
+	if err != nil {
+		return fmt.Errorf("get schema version error:%w", err)
+	}
+
+	if version > len(migrations) {
+		return fmt.Errorf("schema version(%v) is newer than known migrations(%v)", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		err = apply(ctx, db, i+1, migrations[i])
+		if err != nil {
+			return fmt.Errorf("migration(%v) error:%w", i+1, err)
+		}
+		log.Printf("sqlite migration %v applied", i+1)
+	}
+
+	return nil
+}
+
+func apply(ctx context.Context, db *sql.DB, version int, query string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("transaction begin error:%w", err)
+	}
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Error().Err(err).Msg("sqlite migration transaction rollback error")
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("exec error:%w", err)
+	}
+
+	// PRAGMA не поддерживает параметры запроса.
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version))
+	if err != nil {
+		return fmt.Errorf("set schema version error:%w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("transaction commit error:%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,306 @@
+// Package sqlite for save metrics to embedded SQLite DB.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/k0st1a/metrics/internal/pkg/retry"
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/rs/zerolog/log"
+	// Драйвер SQLite на чистом Go, без cgo.
+	sqlitedrv "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type SQLiteStorage struct {
+	db *sql.DB
+}
+
+// NewStorage - создать storage для хранения метрик во встроенной БД SQLite, где:
+//   - ctx - контекст;
+//   - path - путь на файловой системе до файла БД, если файла нет, то он будет создан.
+//
+// При создании к БД применяются миграции.
+func NewStorage(ctx context.Context, path string) (*SQLiteStorage, error) {
+	// WAL-журнал SQLite позволяет читать во время записи, busy_timeout - ждать снятия блокировки
+	// вместо немедленной ошибки SQLITE_BUSY.
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql open error:%w", err)
+	}
+
+	// SQLite допускает только одного писателя, поэтому все запросы идут через одно соединение.
+	db.SetMaxOpenConns(1)
+
+	err = migrate(ctx, db)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrate error:%w", err)
+	}
+
+	return &SQLiteStorage{
+		db: db,
+	}, nil
+}
+
+// StoreGauge - сохраняет метрику типа gauge с именем name и значенем value.
+func (s *SQLiteStorage) StoreGauge(ctx context.Context, name string, value float64) error {
+	_, err := s.db.ExecContext(ctx, storeGaugeQuery, name, value)
+	if err != nil {
+		return fmt.Errorf("store gauge query error:%w", err)
+	}
+
+	return nil
+}
+
+// GetGauge - возвращает метрику типа gauge с именем name.
+func (s *SQLiteStorage) GetGauge(ctx context.Context, name string) (*float64, error) {
+	var v float64
+
+	err := s.db.QueryRowContext(ctx, "SELECT value FROM gauges WHERE name = ?", name).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.ErrMetricsNoGauge
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get gauge query error:%w", err)
+	}
+
+	return &v, nil
+}
+
+// StoreCounter - сохраняет метрику типа counter с именем name и значенем value.
+func (s *SQLiteStorage) StoreCounter(ctx context.Context, name string, value int64) error {
+	_, err := s.db.ExecContext(ctx, storeCounterQuery, name, value)
+	if err != nil {
+		return fmt.Errorf("store counter query error:%w", err)
+	}
+
+	return nil
+}
+
+// GetCounter - возвращает метрику типа counter с именем name.
+func (s *SQLiteStorage) GetCounter(ctx context.Context, name string) (*int64, error) {
+	var d int64
+
+	err := s.db.QueryRowContext(ctx, "SELECT delta FROM counters WHERE name = ?", name).Scan(&d)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, utils.ErrMetricsNoCounter
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get counter query error:%w", err)
+	}
+
+	return &d, nil
+}
+
+// DeleteGauge - удаляет метрику типа gauge с именем name.
+func (s *SQLiteStorage) DeleteGauge(ctx context.Context, name string) error {
+	return s.delete(ctx, "DELETE FROM gauges WHERE name = ?", name, utils.ErrMetricsNoGauge)
+}
+
+// DeleteCounter - удаляет метрику типа counter с именем name.
+func (s *SQLiteStorage) DeleteCounter(ctx context.Context, name string) error {
+	return s.delete(ctx, "DELETE FROM counters WHERE name = ?", name, utils.ErrMetricsNoCounter)
+}
+
+// delete - удаляет метрику запросом query, если метрики нет, то возвращается notFound.
+func (s *SQLiteStorage) delete(ctx context.Context, query, name string, notFound error) error {
+	res, err := s.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return fmt.Errorf("delete query error:%w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("rows affected error:%w", err)
+	}
+
+	if n == 0 {
+		return notFound
+	}
+
+	return nil
+}
+
+const (
+	storeCounterQuery = "INSERT INTO counters (name,delta) VALUES(?, ?) " +
+		"ON CONFLICT (name) DO UPDATE SET delta = counters.delta + excluded.delta"
+	storeGaugeQuery = "INSERT INTO gauges (name,value) VALUES(?, ?) " +
+		"ON CONFLICT (name) DO UPDATE SET value = excluded.value"
+)
+
+// StoreAll - сохраняет группу метрик типа counter и gauge в одной транзакции: либо сохраняются все метрики,
+// либо ни одна.
+func (s *SQLiteStorage) StoreAll(ctx context.Context, counter map[string]int64, gauge map[string]float64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("store all transaction begin error:%w", err)
+	}
+	defer func() {
+		err := tx.Rollback()
+		if err != nil && !errors.Is(err, sql.ErrTxDone) {
+			log.Error().Err(err).Msg("store all transaction rollback error")
+		}
+	}()
+
+	cs, err := tx.PrepareContext(ctx, storeCounterQuery)
+	if err != nil {
+		return fmt.Errorf("prepare store counter error:%w", err)
+	}
+	defer closeStmt(cs)
+
+	for k, v := range counter {
+		_, err = cs.ExecContext(ctx, k, v)
+		if err != nil {
+			return fmt.Errorf("store counter(%v) error:%w", k, err)
+		}
+	}
+
+	gs, err := tx.PrepareContext(ctx, storeGaugeQuery)
+	if err != nil {
+		return fmt.Errorf("prepare store gauge error:%w", err)
+	}
+	defer closeStmt(gs)
+
+	for k, v := range gauge {
+		_, err = gs.ExecContext(ctx, k, v)
+		if err != nil {
+			return fmt.Errorf("store gauge(%v) error:%w", k, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("store all transaction commit error:%w", err)
+	}
+
+	return nil
+}
+
+// GetAll - возвращает все метрики типа counter и gauge.
+func (s *SQLiteStorage) GetAll(ctx context.Context) (map[string]int64, map[string]float64, error) {
+	c, err := s.getCounters(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	g, err := s.getGauges(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, g, nil
+}
+
+// getCounters - возвращает все метрики типа counter. Строки результата закрываются до возврата,
+// так как у БД одно соединение.
+func (s *SQLiteStorage) getCounters(ctx context.Context) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name,delta FROM counters")
+	if err != nil {
+		return nil, fmt.Errorf("get counters query error:%w", err)
+	}
+	defer closeRows(rows)
+
+	c := make(map[string]int64)
+
+	for rows.Next() {
+		var name string
+		var delta int64
+
+		err = rows.Scan(&name, &delta)
+		if err != nil {
+			return nil, fmt.Errorf("counter rows scan error:%w", err)
+		}
+
+		c[name] = delta
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("counter rows error:%w", err)
+	}
+
+	return c, nil
+}
+
+// getGauges - возвращает все метрики типа gauge.
+func (s *SQLiteStorage) getGauges(ctx context.Context) (map[string]float64, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name,value FROM gauges")
+	if err != nil {
+		return nil, fmt.Errorf("get gauges query error:%w", err)
+	}
+	defer closeRows(rows)
+
+	g := make(map[string]float64)
+
+	for rows.Next() {
+		var name string
+		var value float64
+
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			return nil, fmt.Errorf("gauge rows scan error:%w", err)
+		}
+
+		g[name] = value
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("gauge rows error:%w", err)
+	}
+
+	return g, nil
+}
+
+func closeStmt(stmt *sql.Stmt) {
+	err := stmt.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("stmt close error")
+	}
+}
+
+func closeRows(rows *sql.Rows) {
+	err := rows.Close()
+	if err != nil {
+		log.Error().Err(err).Msg("rows close error")
+	}
+}
+
+// Ping - проверка доступности БД.
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	err := s.db.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("sqlite ping error:%w", err)
+	}
+
+	return nil
+}
+
+// Close - закрывает БД.
+func (s *SQLiteStorage) Close() error {
+	err := s.db.Close()
+	if err != nil {
+		return fmt.Errorf("sqlite close error:%w", err)
+	}
+
+	return nil
+}
+
+// IsRetryable - классификатор ошибок хранилища для повторных обращений: БД занята или заблокирована
+// другим соединением, временные ошибки файловой системы.
+func IsRetryable(err error) bool {
+	var sErr *sqlitedrv.Error
+	if errors.As(err, &sErr) {
+		// Младший байт - основной код ошибки, старшие - расширенный.
+		code := sErr.Code() & 0xff
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+
+	return retry.IsTemporary(err)
+}
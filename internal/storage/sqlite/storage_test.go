@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/k0st1a/metrics/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, path string) *SQLiteStorage {
+	t.Helper()
+
+	s, err := NewStorage(context.Background(), path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+
+	return s
+}
+
+func TestStorage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "metrics.db"))
+
+	_, err := s.GetCounter(ctx, "PollCount")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoCounter)
+
+	_, err = s.GetGauge(ctx, "Alloc")
+	assert.ErrorIs(t, err, utils.ErrMetricsNoGauge)
+
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 2))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 1.5))
+	require.NoError(t, s.StoreGauge(ctx, "Alloc", 2.5))
+
+	c, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *c)
+
+	g, err := s.GetGauge(ctx, "Alloc")
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, *g)
+
+	err = s.StoreAll(ctx,
+		map[string]int64{"PollCount": 10, "Other": 5},
+		map[string]float64{"Alloc": 3.5, "RandomValue": 0.1})
+	require.NoError(t, err)
+
+	cs, gs, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"PollCount": 13, "Other": 5}, cs)
+	assert.Equal(t, map[string]float64{"Alloc": 3.5, "RandomValue": 0.1}, gs)
+
+	assert.NoError(t, s.Ping(ctx))
+}
+
+func TestStorageStoreAllRollback(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "metrics.db"))
+
+	// NaN сохраняется в SQLite как NULL и нарушает NOT NULL уже после записи counter.
+	err := s.StoreAll(ctx, map[string]int64{"PollCount": 1}, map[string]float64{"Alloc": math.NaN()})
+	require.Error(t, err)
+
+	cs, gs, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, cs)
+	assert.Empty(t, gs)
+}
+
+func TestStorageReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "metrics.db")
+
+	s, err := NewStorage(ctx, path)
+	require.NoError(t, err)
+	require.NoError(t, s.StoreCounter(ctx, "PollCount", 1))
+	require.NoError(t, s.Close())
+
+	s = newTestStorage(t, path)
+
+	var version int
+	require.NoError(t, s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version))
+	assert.Equal(t, len(migrations), version)
+
+	c, err := s.GetCounter(ctx, "PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), *c)
+}
+
+func TestStorageDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, filepath.Join(t.TempDir(), "metrics.db"))
+
+	require.NoError(t, s.StoreAll(ctx, map[string]int64{"PollCount": 1}, map[string]float64{"Alloc": 1.5}))
+
+	require.NoError(t, s.DeleteCounter(ctx, "PollCount"))
+	require.NoError(t, s.DeleteGauge(ctx, "Alloc"))
+
+	assert.ErrorIs(t, s.DeleteCounter(ctx, "PollCount"), utils.ErrMetricsNoCounter)
+	assert.ErrorIs(t, s.DeleteGauge(ctx, "Alloc"), utils.ErrMetricsNoGauge)
+
+	c, g, err := s.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, c)
+	assert.Empty(t, g)
+}
@@ -6,4 +6,8 @@ import "errors"
 var (
 	ErrMetricsNoCounter = errors.New("metrics: no counter")
 	ErrMetricsNoGauge   = errors.New("metrics: no gauge")
+	// ErrMetricsReservedName - имя метрики зарезервировано под метрики самого сервера.
+	ErrMetricsReservedName = errors.New("metrics: reserved name")
+	// ErrStorageUnavailable - хранилище временно недоступно, запрос нужно повторить позже.
+	ErrStorageUnavailable = errors.New("metrics: storage is unavailable")
 )